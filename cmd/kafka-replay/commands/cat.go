@@ -1,8 +1,10 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -10,27 +12,70 @@ import (
 	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/util"
 	"github.com/lolocompany/kafka-replay/v2/pkg"
 	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/output"
+	"github.com/lolocompany/kafka-replay/v2/pkg/export"
+	"github.com/lolocompany/kafka-replay/v2/pkg/schemaregistry"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
 	"github.com/urfave/cli/v3"
 )
 
 var globalFlags = util.GlobalFlags()
 
 type catMessage struct {
-	Timestamp string `json:"timestamp"`
-	Key       string `json:"key"`
-	Data      string `json:"data"`
+	Timestamp     string      `json:"timestamp"`
+	TimestampType string      `json:"timestampType,omitempty"`
+	Topic         string      `json:"topic,omitempty"`
+	Partition     *int        `json:"partition,omitempty"`
+	Offset        *int64      `json:"offset,omitempty"`
+	Key           any         `json:"key"`  // String, or JSON embedded or decoded with --schema-registry
+	Data          any         `json:"data"` // String, or JSON embedded or decoded with --schema-registry
+	KeyEncoding   string      `json:"keyEncoding,omitempty"`
+	ValueEncoding string      `json:"valueEncoding,omitempty"`
+	KeySchemaID   *int        `json:"keySchemaId,omitempty"`
+	ValueSchemaID *int        `json:"valueSchemaId,omitempty"`
+	Headers       []catHeader `json:"headers,omitempty"`
+}
+
+type catHeader struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// catSchemas decodes the values framed by Confluent serializers, and the keys if enabled
+type catSchemas struct {
+	decoder *schemaregistry.Decoder
+	keys    bool
+}
+
+// catEncodings are how keys and values are written in JSON output, and whether the encoding used for each of
+// them is reported
+type catEncodings struct {
+	Key   export.Encoding
+	Value export.Encoding // Also used for header values
+	Show  bool
+}
+
+// encode writes a key or value in an encoding and returns the encoding to report for it: always with Show, and
+// otherwise when it fell back to another encoding (e.g. base64 for utf8 bytes that are not valid UTF-8) or is
+// embedded JSON, so that import reads it back (an embedded "text" is the bytes with the quotes)
+func (e catEncodings) encode(encoding export.Encoding, data []byte) (any, string) {
+	value, used := encoding.EncodeJSON(data)
+	if e.Show || used != encoding || used == export.EncodingJSONEmbedded {
+		return value, string(used)
+	}
+	return value, ""
 }
 
 func CatCommand() *cli.Command {
 	return &cli.Command{
 		Name:        "cat",
 		Usage:       "Display recorded messages from a message file",
-		Description: "Read and display messages from a binary message file. Uses global --format flag (json, raw).",
-		Flags: append(globalFlags,
+		Description: "Read and display messages from a binary message file. Uses global --format flag (json, raw). The timestampType of JSON output is the message.timestamp.type configured for the topic when it was recorded, not the type of each message.",
+		Flags: append(append(append(globalFlags,
 			&cli.StringFlag{
 				Name:     "input",
 				Aliases:  []string{"i"},
-				Usage:    "Input file path containing recorded messages",
+				Usage:    "Input file path containing recorded messages ('-' for standard input)",
 				Required: true,
 			},
 			&cli.StringFlag{
@@ -43,23 +88,73 @@ func CatCommand() *cli.Command {
 				Usage:   "Only output the count of messages to stdout, do not display them",
 				Value:   false,
 			},
-		),
+			&cli.StringFlag{
+				Name:  "key-encoding",
+				Usage: "How keys are written with --format json: utf8 (text), base64, hex or json-embedded (compact JSON nested as is, otherwise text). Keys that are not valid UTF-8 are written in base64 with utf8 and json-embedded, and their keyEncoding is reported, as for embedded keys",
+				Value: string(export.EncodingUTF8),
+			},
+			&cli.StringFlag{
+				Name:  "value-encoding",
+				Usage: "How values and header values are written with --format json: utf8 (text), base64, hex or json-embedded (compact JSON nested as is, otherwise text). Values that are not valid UTF-8 are written in base64 with utf8 and json-embedded, and their valueEncoding (or header encoding) is reported, as for embedded values",
+				Value: string(export.EncodingUTF8),
+			},
+			&cli.BoolFlag{
+				Name:  "show-encoding",
+				Usage: "Add the encoding used for each key, value and header value (keyEncoding, valueEncoding and encoding fields) to the JSON output, not only for those that fell back to another encoding",
+			},
+			filterFlag(),
+		), startFlags()...), append(schemaRegistryFlags("schema-registry", "Schema Registry URL (e.g. http://localhost:18081): values in the Confluent wire format (magic byte and schema ID) are decoded from Avro, Protobuf or JSON Schema to JSON, with their schema IDs. Values with an unknown schema ID or that do not match their schema are written in --value-encoding. Requires --format json"),
+			&cli.BoolFlag{
+				Name:  "schema-registry-keys",
+				Usage: "Also decode keys with --schema-registry (not by default: keys such as big-endian integers may start with a zero byte and look framed)",
+			},
+		)...),
 		Action: func(ctx context.Context, cmd *cli.Command) error {
 			input := cmd.String("input")
 			findStr := cmd.String("find")
 			countOnly := cmd.Bool("count")
+			startEntry, startTime, err := resolveStart(cmd)
+			if err != nil {
+				return err
+			}
+			messageFilter, err := resolveFilter(cmd)
+			if err != nil {
+				return err
+			}
+			registry, err := resolveSchemaRegistry(cmd, "schema-registry")
+			if err != nil {
+				return err
+			}
+			var schemas *catSchemas
+			if registry != nil {
+				schemas = &catSchemas{decoder: schemaregistry.NewDecoder(registry), keys: cmd.Bool("schema-registry-keys")}
+			} else if cmd.Bool("schema-registry-keys") {
+				return fmt.Errorf("--schema-registry-keys requires --schema-registry")
+			}
+			encodings := catEncodings{Show: cmd.Bool("show-encoding")}
+			if encodings.Key, err = export.ParseJSONEncoding(cmd.String("key-encoding")); err != nil {
+				return fmt.Errorf("invalid --key-encoding: %w", err)
+			}
+			if encodings.Value, err = export.ParseJSONEncoding(cmd.String("value-encoding")); err != nil {
+				return fmt.Errorf("invalid --value-encoding: %w", err)
+			}
 
 			var findBytes []byte
 			if findStr != "" {
 				findBytes = []byte(findStr)
 			}
 
-			file, err := os.Open(input)
+			file, err := openInput(input)
 			if err != nil {
-				return fmt.Errorf("failed to open input file: %w", err)
+				return err
 			}
 			defer file.Close()
 
+			var index *transcoder.Index
+			if (startEntry > 0 || startTime != nil) && !isStream(input) {
+				index = loadRecordingIndex(input, util.Quiet(cmd))
+			}
+
 			// For cat, default to json when --format is not set
 			formatStr := util.GetFormat(cmd)
 			if formatStr == "" {
@@ -69,17 +164,29 @@ func CatCommand() *cli.Command {
 			if err != nil {
 				return err
 			}
-			formatter, err := catFormatter(format)
+			if format == output.FormatRaw {
+				for _, name := range []string{"key-encoding", "value-encoding", "show-encoding"} {
+					if cmd.IsSet(name) {
+						return fmt.Errorf("--%s requires --format json", name)
+					}
+				}
+			}
+			formatter, err := catFormatter(ctx, format, schemas, encodings)
 			if err != nil {
 				return err
 			}
 
 			count, err := pkg.Cat(ctx, pkg.CatConfig{
-				Reader:    file,
-				Formatter: formatter,
-				Output:    os.Stdout,
-				FindBytes: findBytes,
-				CountOnly: countOnly,
+				Reader:             file,
+				PreserveTimestamps: true, // Show the recorded timestamps
+				Formatter:          formatter,
+				Output:             os.Stdout,
+				FindBytes:          findBytes,
+				CountOnly:          countOnly,
+				StartEntry:         startEntry,
+				StartTime:          startTime,
+				Index:              index,
+				Filter:             messageFilter,
 			})
 			if err != nil {
 				return err
@@ -95,31 +202,85 @@ func CatCommand() *cli.Command {
 }
 
 // catFormatter returns a formatter for the given output format.
-func catFormatter(format output.Format) (func(time.Time, []byte, []byte) []byte, error) {
+// With schemas, the JSON format decodes the values (and keys if enabled) framed by Confluent serializers.
+// The JSON format writes the other keys and values in their encodings.
+func catFormatter(ctx context.Context, format output.Format, schemas *catSchemas, encodings catEncodings) (func(*transcoder.Entry) ([]byte, error), error) {
 	switch format {
 	case output.FormatJSON:
-		return jsonFormatter, nil
+		return func(entry *transcoder.Entry) ([]byte, error) {
+			return jsonFormatter(ctx, entry, schemas, encodings)
+		}, nil
 	case output.FormatRaw:
+		if schemas != nil {
+			return nil, fmt.Errorf("--schema-registry requires --format json")
+		}
 		return rawFormatter, nil
 	default:
 		return nil, fmt.Errorf("cat command only supports formats: json, raw (got %q)", format)
 	}
 }
 
-func rawFormatter(timestamp time.Time, key []byte, data []byte) []byte {
-	return data
+func rawFormatter(entry *transcoder.Entry) ([]byte, error) {
+	return entry.Data, nil
 }
 
-func jsonFormatter(timestamp time.Time, key []byte, data []byte) []byte {
+func jsonFormatter(ctx context.Context, entry *transcoder.Entry, schemas *catSchemas, encodings catEncodings) ([]byte, error) {
 	msg := catMessage{
-		Timestamp: timestamp.Format(time.RFC3339Nano),
-		Key:       string(key),
-		Data:      string(data),
+		Timestamp:     entry.Timestamp.Format(time.RFC3339Nano),
+		TimestampType: entry.TimestampType.String(),
+		Topic:         entry.Topic,
+	}
+	msg.Key, msg.KeyEncoding = encodings.encode(encodings.Key, entry.Key)
+	msg.Data, msg.ValueEncoding = encodings.encode(encodings.Value, entry.Data)
+	if entry.HasSource() {
+		msg.Partition = &entry.Partition
+		msg.Offset = &entry.Offset
+	}
+	for _, h := range entry.Headers {
+		header := catHeader{Key: h.Key}
+		header.Value, header.Encoding = encodings.encode(encodings.Value, h.Value)
+		msg.Headers = append(msg.Headers, header)
+	}
+	// Keys and values decoded with their schema are always reported, so that import refuses them
+	schemaEncoding := string(export.EncodingSchemaDecoded)
+	if schemas != nil {
+		if schemas.keys {
+			key, keySchemaID, err := decodeWithSchema(ctx, schemas.decoder, entry.Key)
+			if err != nil {
+				return nil, fmt.Errorf("key: %w", err)
+			}
+			if key != nil {
+				msg.Key, msg.KeySchemaID, msg.KeyEncoding = key, keySchemaID, schemaEncoding
+			}
+		}
+		value, valueSchemaID, err := decodeWithSchema(ctx, schemas.decoder, entry.Data)
+		if err != nil {
+			return nil, fmt.Errorf("value: %w", err)
+		}
+		if value != nil {
+			msg.Data, msg.ValueSchemaID, msg.ValueEncoding = value, valueSchemaID, schemaEncoding
+		}
+	}
+	// Without escaping <, > and &, so that strings in embedded JSON keep their recorded text
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(msg); err != nil {
+		return []byte(fmt.Sprintf("{\"error\":\"%s\"}\n", err.Error())), nil
+	}
+	return b.Bytes(), nil
+}
+
+// decodeWithSchema decodes a key or value framed by Confluent serializers to JSON and returns its schema ID,
+// or nil if it is not framed, or only looks framed: its schema ID is unknown or it does not match its schema
+func decodeWithSchema(ctx context.Context, schemas *schemaregistry.Decoder, data []byte) (json.RawMessage, *int, error) {
+	decoded, schemaID, err := schemas.Decode(ctx, data)
+	if errors.Is(err, schemaregistry.ErrNotFramed) || errors.Is(err, schemaregistry.ErrSchemaNotFound) || errors.Is(err, schemaregistry.ErrInvalidPayload) {
+		return nil, nil, nil
 	}
-	b, err := json.Marshal(msg)
 	if err != nil {
-		return []byte(fmt.Sprintf("{\"error\":\"%s\"}\n", err.Error()))
+		return nil, nil, err
 	}
-	return append(b, '\n')
+	return decoded, &schemaID, nil
 }
 
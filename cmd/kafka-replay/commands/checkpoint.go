@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg"
+)
+
+// CheckpointFileSuffix is appended to the path of the replayed file to get the default checkpoint path
+const CheckpointFileSuffix = ".checkpoint"
+
+// readCheckpointFile reads the checkpoint at path, or returns nil if there is none
+func readCheckpointFile(path string) (*pkg.Checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open checkpoint file: %w", err)
+	}
+	defer file.Close()
+
+	checkpoint, err := pkg.ReadCheckpoint(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file %s: %w", path, err)
+	}
+	return checkpoint, nil
+}
+
+// writeCheckpointFile replaces the checkpoint at path
+// The checkpoint is written to a temporary file first, so that a crash never leaves a partial checkpoint
+func writeCheckpointFile(path string, checkpoint pkg.Checkpoint) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := pkg.WriteCheckpoint(file, checkpoint); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
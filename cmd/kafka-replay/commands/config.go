@@ -16,7 +16,7 @@ func ConfigCommand() *cli.Command {
 		Name:        "config",
 		Aliases:     []string{"cfg", "conf"},
 		Usage:       "Show resolved configuration and where each value comes from",
-		Description: "Resolves and displays the config currently in use: config file path, profile, and brokers. Shows the source of each value and whether it is overridden by a higher-priority source.",
+		Description: "Resolves and displays the config currently in use: config file path, profile, brokers, TLS and SASL. Shows the source of each value and whether it is overridden by a higher-priority source.",
 		Flags:       util.GlobalFlags(),
 		Action:      runConfig,
 	}
@@ -119,5 +119,39 @@ func runConfig(ctx context.Context, cmd *cli.Command) error {
 		fmt.Fprintf(os.Stdout, "]\n")
 	}
 
+	// Resolve TLS and SASL: --tls* / --sasl-* flags > profile from config
+	var profile config.Profile
+	if cfg.Profiles != nil && profileName != "(none)" {
+		profile = cfg.Profiles[profileName]
+	}
+	security, err := util.ResolveSecurity(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "security:      (invalid: %v)\n", err)
+		return nil
+	}
+	tlsFlagSet := cmd.IsSet("tls") || cmd.IsSet("tls-ca-file") || cmd.IsSet("tls-cert-file") || cmd.IsSet("tls-key-file") || cmd.IsSet("tls-insecure-skip-verify")
+	fmt.Fprintf(os.Stdout, "tls:           %s\n", securitySetting(security != nil && security.TLSEnabled(), "enabled", tlsFlagSet, profile.TLS != nil, profileName))
+	mechanism := ""
+	if security != nil {
+		mechanism = security.SASLMechanism()
+	}
+	saslFlagSet := cmd.IsSet("sasl-mechanism") || cmd.IsSet("sasl-username") || cmd.IsSet("sasl-password")
+	fmt.Fprintf(os.Stdout, "sasl:          %s\n", securitySetting(mechanism != "", mechanism, saslFlagSet, profile.SASL != nil, profileName))
+
 	return nil
 }
+
+// securitySetting describes a resolved TLS or SASL setting and where it comes from
+func securitySetting(enabled bool, value string, fromFlags bool, fromProfile bool, profileName string) string {
+	if !enabled {
+		return "(disabled)"
+	}
+	switch {
+	case fromFlags && fromProfile:
+		return value + "  [from flags; overrides config profile \"" + profileName + "\"]"
+	case fromFlags:
+		return value + "  [from flags]"
+	default:
+		return value + "  [from config profile \"" + profileName + "\"]"
+	}
+}
@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/output"
+	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/util"
+	"github.com/lolocompany/kafka-replay/v2/pkg"
+	"github.com/lolocompany/kafka-replay/v2/pkg/export"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+	"github.com/urfave/cli/v3"
+)
+
+func ExportCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "export",
+		Usage:       "Export recorded messages to JSON lines, CSV or Parquet",
+		Description: "Convert a recording to a file other tools load directly (DuckDB, pandas, spreadsheets). Uses the global --format flag (jsonl, csv, parquet; default: from the --output extension, or jsonl).",
+		Flags: append(append(globalFlags,
+			&cli.StringFlag{
+				Name:     "input",
+				Aliases:  []string{"i"},
+				Usage:    "Input file path containing recorded messages ('-' for standard input)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Output file path ('-' for standard output)",
+				Value:   streamPath,
+			},
+			&cli.StringSliceFlag{
+				Name:  "columns",
+				Usage: "Columns to export, in order (comma-separated or repeated): timestamp, topic, partition, offset, key, value, headers (default: all)",
+			},
+			&cli.StringFlag{
+				Name:  "key-encoding",
+				Usage: "How keys are written: base64 (default), hex or utf8 (text, replacing invalid UTF-8); Parquet stores keys as binary unless set",
+			},
+			&cli.StringFlag{
+				Name:  "value-encoding",
+				Usage: "How values and header values are written: base64 (default), hex or utf8 (text, replacing invalid UTF-8); Parquet stores values as binary unless set",
+			},
+			&cli.StringFlag{
+				Name:  "find",
+				Usage: "Only export messages containing the specified literal byte sequence, case-sensitive (string converted to bytes)",
+			},
+			filterFlag(),
+		), startFlags()...),
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			input := cmd.String("input")
+			outputPath := cmd.String("output")
+			format, err := resolveFileFormat(cmd, outputPath)
+			if err != nil {
+				return err
+			}
+			opts := export.Options{}
+			if cmd.IsSet("columns") {
+				if opts.Columns, err = export.ParseColumns(cmd.StringSlice("columns")); err != nil {
+					return err
+				}
+			}
+			// Unset encodings are left empty: base64, except for Parquet keys and values stored as binary
+			if cmd.IsSet("key-encoding") {
+				if opts.KeyEncoding, err = export.ParseEncoding(cmd.String("key-encoding")); err != nil {
+					return fmt.Errorf("invalid --key-encoding: %w", err)
+				}
+			}
+			if cmd.IsSet("value-encoding") {
+				if opts.ValueEncoding, err = export.ParseEncoding(cmd.String("value-encoding")); err != nil {
+					return fmt.Errorf("invalid --value-encoding: %w", err)
+				}
+			}
+			startEntry, startTime, err := resolveStart(cmd)
+			if err != nil {
+				return err
+			}
+			messageFilter, err := resolveFilter(cmd)
+			if err != nil {
+				return err
+			}
+			if format == export.FormatParquet && isStream(outputPath) && output.IsTTY(os.Stdout) {
+				return fmt.Errorf("refusing to write Parquet to a terminal: set --output or redirect standard output")
+			}
+
+			var findBytes []byte
+			if findStr := cmd.String("find"); findStr != "" {
+				findBytes = []byte(findStr)
+			}
+
+			file, err := openInput(input)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			quiet := util.Quiet(cmd)
+			var index *transcoder.Index
+			if (startEntry > 0 || startTime != nil) && !isStream(input) {
+				index = loadRecordingIndex(input, quiet)
+			}
+
+			var out io.Writer = os.Stdout
+			if !isStream(outputPath) {
+				outputFile, err := os.Create(outputPath)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer outputFile.Close()
+				out = outputFile
+			}
+			buffered := bufio.NewWriter(out)
+			writer, err := export.NewWriter(buffered, format, opts)
+			if err != nil {
+				return err
+			}
+
+			count, err := pkg.Export(ctx, pkg.ExportConfig{
+				Reader:     file,
+				Writer:     writer,
+				FindBytes:  findBytes,
+				StartEntry: startEntry,
+				StartTime:  startTime,
+				Index:      index,
+				Filter:     messageFilter,
+			})
+			if err != nil {
+				return err
+			}
+			if err := buffered.Flush(); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "Exported %d messages to %s (%s)\n", count, describePath(outputPath, "standard output"), format)
+			}
+			return nil
+		},
+	}
+}
+
+// resolveFileFormat returns the format of an export or import file set with the global --format, or the one of
+// the file extension (.jsonl, .csv or .parquet), JSON lines by default
+func resolveFileFormat(cmd *cli.Command, path string) (export.Format, error) {
+	name := util.GetFormat(cmd)
+	if name == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			return export.FormatCSV, nil
+		case ".parquet":
+			return export.FormatParquet, nil
+		default:
+			return export.FormatJSONL, nil
+		}
+	}
+	if name == string(output.FormatJSON) {
+		return export.FormatJSONL, nil // One object per line, like the json format of the other commands
+	}
+	return export.ParseFormat(name)
+}
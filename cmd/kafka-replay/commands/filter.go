@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"github.com/lolocompany/kafka-replay/v2/pkg/filter"
+	"github.com/urfave/cli/v3"
+)
+
+// filterFlag is the --filter flag shared by record, replay and cat
+func filterFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "filter",
+		Usage: `Only keep messages matching this expression, e.g. '.type == "OrderCreated" && .amount > 100'. Compares key, value, topic, partition, offset, timestamp, header["name"] and JSON paths into the value (.a.b[0]) with ==, !=, <, <=, >, >=, =~ and !~ (regular expressions), combined with &&, || and !`,
+	}
+}
+
+// resolveFilter returns the filter set with --filter, or nil
+func resolveFilter(cmd *cli.Command) (*filter.Filter, error) {
+	if !cmd.IsSet("filter") {
+		return nil, nil
+	}
+	return filter.Parse(cmd.String("filter"))
+}
@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/util"
+	"github.com/lolocompany/kafka-replay/v2/pkg"
+	"github.com/lolocompany/kafka-replay/v2/pkg/export"
+	"github.com/lolocompany/kafka-replay/v2/pkg/importer"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+	"github.com/urfave/cli/v3"
+)
+
+func ImportCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "import",
+		Usage:       "Write messages from JSON lines or CSV to a recording",
+		Description: "Convert messages written by hand, by cat or by export to a recording that replay reads. Uses the global --format flag (jsonl, csv; default: from the --input extension, or jsonl).",
+		Flags: append(globalFlags,
+			&cli.StringFlag{
+				Name:     "input",
+				Aliases:  []string{"i"},
+				Usage:    "Input file path of the messages, in JSON lines or CSV ('-' for standard input)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "output",
+				Aliases:  []string{"o"},
+				Usage:    "Output file path for the recording ('-' for standard output). Cannot be used together with --index when '-'",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "key-encoding",
+				Usage: "How keys are written in the input: utf8 (text), base64 or hex",
+				Value: string(export.EncodingUTF8),
+			},
+			&cli.StringFlag{
+				Name:  "value-encoding",
+				Usage: "How values and header values are written in the input: utf8 (text), base64 or hex. In JSON lines, values that are not strings (objects, arrays, numbers) are imported as their JSON text",
+				Value: string(export.EncodingUTF8),
+			},
+			&cli.StringFlag{
+				Name:  "compression",
+				Usage: "Compress the recorded messages in blocks: none, gzip, zstd or snappy (detected automatically by cat and replay)",
+				Value: "none",
+			},
+			&cli.BoolFlag{
+				Name:  "index",
+				Usage: "Also write an index of the recording to <output>.idx",
+			},
+		),
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			input := cmd.String("input")
+			output := cmd.String("output")
+			format, err := resolveFileFormat(cmd, input)
+			if err != nil {
+				return err
+			}
+			opts := importer.Options{}
+			if opts.KeyEncoding, err = export.ParseEncoding(cmd.String("key-encoding")); err != nil {
+				return fmt.Errorf("invalid --key-encoding: %w", err)
+			}
+			if opts.ValueEncoding, err = export.ParseEncoding(cmd.String("value-encoding")); err != nil {
+				return fmt.Errorf("invalid --value-encoding: %w", err)
+			}
+			compression, err := transcoder.ParseCompression(cmd.String("compression"))
+			if err != nil {
+				return err
+			}
+			if isStream(output) {
+				if cmd.Bool("index") {
+					return fmt.Errorf("--index cannot be used together with --output -: it needs a file")
+				}
+				if err := checkStreamOutput(); err != nil {
+					return err
+				}
+			}
+
+			file, err := openInput(input)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			reader, err := importer.NewReader(file, format, opts)
+			if err != nil {
+				return fmt.Errorf("%s: %w", describePath(input, "standard input"), err)
+			}
+
+			fileWriter := io.Writer(os.Stdout)
+			if !isStream(output) {
+				outputFile, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer outputFile.Close()
+				fileWriter = outputFile
+			}
+			var indexWriter io.Writer
+			if cmd.Bool("index") {
+				indexFile, err := os.Create(transcoder.IndexPath(output))
+				if err != nil {
+					return fmt.Errorf("failed to create index file: %w", err)
+				}
+				defer indexFile.Close()
+				indexWriter = indexFile
+			}
+
+			count, err := pkg.Import(ctx, pkg.ImportConfig{
+				Reader:      reader,
+				Output:      util.CountingWriter(fileWriter, nil),
+				Compression: compression,
+				Index:       indexWriter,
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", describePath(input, "standard input"), err)
+			}
+
+			if !util.Quiet(cmd) {
+				fmt.Fprintf(os.Stderr, "Imported %d messages to %s\n", count, describePath(output, "standard output"))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/util"
+	"github.com/lolocompany/kafka-replay/v2/pkg"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+	"github.com/urfave/cli/v3"
+)
+
+func IndexCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "index",
+		Usage:       "Build the index of a message file",
+		Description: "Read a message file and write its index next to it (<input>.idx). cat and replay use the index to start at an entry number or a time without reading the file from the start.",
+		Flags: append(util.GlobalFlags(),
+			&cli.StringFlag{
+				Name:     "input",
+				Aliases:  []string{"i"},
+				Usage:    "Input file path containing recorded messages",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Output file path for the index (default: <input>.idx)",
+			},
+		),
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			input := cmd.String("input")
+			output := cmd.String("output")
+			if isStream(input) {
+				return fmt.Errorf("cannot index standard input: an index describes a recording file, for seeking in it")
+			}
+			if output == "" {
+				output = transcoder.IndexPath(input)
+			}
+
+			file, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("failed to open input file: %w", err)
+			}
+			defer file.Close()
+
+			indexFile, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create index file: %w", err)
+			}
+			defer indexFile.Close()
+
+			index, err := pkg.BuildIndex(file, indexFile)
+			if err != nil {
+				return fmt.Errorf("failed to build index: %w", err)
+			}
+			if err := indexFile.Close(); err != nil {
+				return fmt.Errorf("failed to write index file: %w", err)
+			}
+
+			if !util.Quiet(cmd) {
+				fmt.Fprintf(os.Stderr, "Indexed %d messages (%d index points) into %s\n", index.Entries, len(index.Points), output)
+			}
+			return nil
+		},
+	}
+}
+
+// loadRecordingIndex reads the index next to the recording at path, if there is one
+// A missing index returns nil; a stale or invalid index is ignored with a warning, since seeking
+// without an index only takes longer
+func loadRecordingIndex(path string, quiet bool) *transcoder.Index {
+	indexPath := transcoder.IndexPath(path)
+	file, err := os.Open(indexPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) && !quiet {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring index %s: %v\n", indexPath, err)
+		}
+		return nil
+	}
+	defer file.Close()
+
+	index, err := transcoder.ReadIndex(file)
+	if err != nil {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring index %s: %v\n", indexPath, err)
+		}
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != index.RecordingSize {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring stale index %s (rebuild it with 'kafka-replay index')\n", indexPath)
+		}
+		return nil
+	}
+	return index
+}
+
+// startFlags are the flags of cat and replay that choose the first entry to read
+func startFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "start-entry",
+			Usage: "Start at this entry number (0-based, in recording order). Uses the index file (<input>.idx) if there is one",
+		},
+		&cli.TimestampFlag{
+			Name:   "start-time",
+			Usage:  "Start at the first message with a timestamp at or after this time (RFC3339, or 'YYYY-MM-DD HH:MM[:SS]' in local time). Uses the index file (<input>.idx) if there is one. Cannot be used together with --start-entry",
+			Config: recordTimeConfig,
+		},
+	}
+}
+
+// resolveStart returns the start entry and start time set with startFlags
+func resolveStart(cmd *cli.Command) (int64, *time.Time, error) {
+	startEntry := cmd.Int64("start-entry")
+	if startEntry < 0 {
+		return 0, nil, fmt.Errorf("--start-entry must not be negative")
+	}
+	if !cmd.IsSet("start-time") {
+		return startEntry, nil, nil
+	}
+	if cmd.IsSet("start-entry") {
+		return 0, nil, fmt.Errorf("--start-entry and --start-time cannot be used together")
+	}
+	startTime := cmd.Timestamp("start-time")
+	return 0, &startTime, nil
+}
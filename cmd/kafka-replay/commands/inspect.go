@@ -44,7 +44,11 @@ func inspectTopicCommand() *cli.Command {
 			if err != nil {
 				return err
 			}
-			partitions, err := pkg.ListPartitions(ctx, brokers, true, true)
+			security, err := util.ResolveSecurity(cmd)
+			if err != nil {
+				return err
+			}
+			partitions, err := pkg.ListPartitions(ctx, brokers, true, true, security)
 			if err != nil {
 				return err
 			}
@@ -96,7 +100,11 @@ func inspectConsumerGroupCommand() *cli.Command {
 			if err != nil {
 				return err
 			}
-			groups, err := pkg.ListConsumerGroups(ctx, brokers, true, true)
+			security, err := util.ResolveSecurity(cmd)
+			if err != nil {
+				return err
+			}
+			groups, err := pkg.ListConsumerGroups(ctx, brokers, true, true, security)
 			if err != nil {
 				return err
 			}
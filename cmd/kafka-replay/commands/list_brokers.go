@@ -23,8 +23,12 @@ func listBrokersCommand() *cli.Command {
 			if err != nil {
 				return err
 			}
+			security, err := util.ResolveSecurity(cmd)
+			if err != nil {
+				return err
+			}
 
-			brokerList, err := pkg.ListBrokers(ctx, brokers)
+			brokerList, err := pkg.ListBrokers(ctx, brokers, security)
 			if err != nil {
 				return err
 			}
@@ -35,11 +35,15 @@ func listConsumerGroupsCommand() *cli.Command {
 			if err != nil {
 				return err
 			}
+			security, err := util.ResolveSecurity(cmd)
+			if err != nil {
+				return err
+			}
 
 			includeOffsets := cmd.Bool("offsets")
 			includeMembers := cmd.Bool("members")
 
-			groups, err := pkg.ListConsumerGroups(ctx, brokers, includeOffsets, includeMembers)
+			groups, err := pkg.ListConsumerGroups(ctx, brokers, includeOffsets, includeMembers, security)
 			if err != nil {
 				return err
 			}
@@ -34,11 +34,15 @@ func listPartitionsCommand() *cli.Command {
 			if err != nil {
 				return err
 			}
+			security, err := util.ResolveSecurity(cmd)
+			if err != nil {
+				return err
+			}
 
 			includeOffsets := cmd.Bool("offsets")
 			includeReplicas := cmd.Bool("replicas")
 
-			partitions, err := pkg.ListPartitions(ctx, brokers, includeOffsets, includeReplicas)
+			partitions, err := pkg.ListPartitions(ctx, brokers, includeOffsets, includeReplicas, security)
 			if err != nil {
 				return err
 			}
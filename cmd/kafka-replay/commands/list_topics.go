@@ -23,8 +23,12 @@ func listTopicsCommand() *cli.Command {
 			if err != nil {
 				return err
 			}
+			security, err := util.ResolveSecurity(cmd)
+			if err != nil {
+				return err
+			}
 
-			topics, err := pkg.ListTopics(ctx, brokers)
+			topics, err := pkg.ListTopics(ctx, brokers, security)
 			if err != nil {
 				return err
 			}
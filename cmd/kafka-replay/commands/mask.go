@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/mask"
+	"github.com/urfave/cli/v3"
+)
+
+// maskFlags are the record flags that mask messages before they are written
+func maskFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "mask",
+			Usage: "YAML file of masking rules hashing, redacting or tokenizing JSON fields, key patterns and headers before messages are written. The recording is marked as sanitized with the fingerprint of the rules",
+		},
+		&cli.StringFlag{
+			Name:    "mask-secret",
+			Usage:   "Secret key for the hashes and tokens of --mask (without it, they can be recomputed for guessed values). Use the same secret for recordings that must be joinable",
+			Sources: cli.EnvVars("KAFKA_REPLAY_MASK_SECRET"),
+		},
+	}
+}
+
+// loadMasking returns the masker configured in the YAML file set with --mask, or nil
+func loadMasking(cmd *cli.Command) (*mask.Masker, error) {
+	path := cmd.String("mask")
+	if path == "" {
+		if cmd.IsSet("mask-secret") {
+			return nil, fmt.Errorf("--mask-secret requires --mask")
+		}
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read masking rules file: %w", err)
+	}
+	masker, err := mask.Parse(data, []byte(cmd.String("mask-secret")))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return masker, nil
+}
@@ -2,26 +2,42 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/util"
 	"github.com/lolocompany/kafka-replay/v2/pkg"
 	"github.com/lolocompany/kafka-replay/v2/pkg/kafka"
+	"github.com/lolocompany/kafka-replay/v2/pkg/mask"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
 	"github.com/urfave/cli/v3"
 )
 
+// recordTimeConfig accepts RFC3339 timestamps and local date/times for --from-time and --to-time
+var recordTimeConfig = cli.TimestampConfig{
+	Timezone: time.Local,
+	Layouts:  []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04"},
+}
+
 func RecordCommand() *cli.Command {
 	return &cli.Command{
 		Name:        "record",
-		Usage:       "Record messages from a Kafka topic",
-		Description: "Record messages from a Kafka topic and save them to a file or output location.",
-		Flags: append(util.GlobalFlags(),
+		Usage:       "Record messages from Kafka topics",
+		Description: "Record messages from one or more Kafka topics and save them to a file or output location.",
+		Flags: append(append(util.GlobalFlags(),
+			&cli.StringSliceFlag{
+				Name:    "topic",
+				Aliases: []string{"t"},
+				Usage:   "Kafka topic(s) to record messages from (comma-separated or repeated). At least one of --topic or --topic-regex is required",
+			},
 			&cli.StringFlag{
-				Name:     "topic",
-				Aliases:  []string{"t"},
-				Usage:    "Kafka topic to record messages from",
-				Required: true,
+				Name:  "topic-regex",
+				Usage: "Also record every topic whose name matches this regular expression (e.g. '^(orders|payments)\\.')",
 			},
 			&cli.StringFlag{
 				Name:    "group",
@@ -35,10 +51,14 @@ func RecordCommand() *cli.Command {
 				Usage:   "Kafka partition to record messages from",
 				Value:   0,
 			},
+			&cli.BoolFlag{
+				Name:  "all-partitions",
+				Usage: "Record all partitions of the topic concurrently into one file. Cannot be used together with --group or --partition.",
+			},
 			&cli.StringFlag{
 				Name:    "output",
 				Aliases: []string{"o"},
-				Usage:   "Output file path for recorded messages",
+				Usage:   "Output file path for recorded messages ('-' for standard output, e.g. to pipe into 'replay --input -'). Cannot be used together with --append or --index when '-'",
 				Value:   "messages.log",
 			},
 			&cli.Int64Flag{
@@ -47,6 +67,20 @@ func RecordCommand() *cli.Command {
 				Usage:   "Start reading from a specific offset (-1 to use current position, 0 to start from beginning). Cannot be used together with --group.",
 				Value:   -1,
 			},
+			&cli.TimestampFlag{
+				Name:   "from-time",
+				Usage:  "Start each partition at its first message with a timestamp at or after this time (RFC3339, or 'YYYY-MM-DD HH:MM[:SS]' in local time). Cannot be used together with --group or --offset.",
+				Config: recordTimeConfig,
+			},
+			&cli.TimestampFlag{
+				Name:   "to-time",
+				Usage:  "Stop each partition once its messages are past this time (same formats as --from-time). Cannot be used together with --group.",
+				Config: recordTimeConfig,
+			},
+			&cli.BoolFlag{
+				Name:  "until-latest",
+				Usage: "Stop each partition at the latest offset it had when recording started (point-in-time snapshot). Cannot be used together with --group.",
+			},
 			&cli.IntFlag{
 				Name:    "limit",
 				Aliases: []string{"l"},
@@ -64,26 +98,125 @@ func RecordCommand() *cli.Command {
 				Aliases: []string{"f"},
 				Usage:   "Only record messages containing the specified byte sequence (string is converted to bytes). When combined with --limit, keeps consuming until the limit of matching messages is found",
 			},
-		),
+			filterFlag(),
+			&cli.StringFlag{
+				Name:  "compression",
+				Usage: "Compress the recorded messages in blocks: none, gzip, zstd or snappy (detected automatically by cat and replay)",
+				Value: "none",
+			},
+			&cli.BoolFlag{
+				Name:  "append",
+				Usage: "Append to the output file if it exists instead of overwriting it, continuing each partition after its last recorded offset. A partially written last message is discarded. Cannot be used together with --group.",
+			},
+			&cli.BoolFlag{
+				Name:  "index",
+				Usage: "Also write an index of the recording to <output>.idx, so that cat and replay can start at an entry number or a time without reading the file from the start",
+			},
+		), maskFlags()...),
 		Action: func(ctx context.Context, cmd *cli.Command) error {
+			if len(cmd.StringSlice("topic")) == 0 && cmd.String("topic-regex") == "" {
+				return fmt.Errorf("at least one of --topic or --topic-regex is required")
+			}
 			brokers, err := util.ResolveBrokers(cmd)
 			if err != nil {
 				return err
 			}
-			topic := cmd.String("topic")
+			security, err := util.ResolveSecurity(cmd)
+			if err != nil {
+				return err
+			}
 			groupID := cmd.String("group")
 			partition := cmd.Int("partition")
+			allPartitions := cmd.Bool("all-partitions")
 			output := cmd.String("output")
 			offsetFlag := cmd.Int64("offset")
 			limit := cmd.Int("limit")
 			timeout := cmd.Duration("timeout")
 			findStr := cmd.String("find")
+			compression, err := transcoder.ParseCompression(cmd.String("compression"))
+			if err != nil {
+				return err
+			}
+			messageFilter, err := resolveFilter(cmd)
+			if err != nil {
+				return err
+			}
+			masking, err := loadMasking(cmd)
+			if err != nil {
+				return err
+			}
+
+			if cmd.Bool("append") && groupID != "" {
+				return fmt.Errorf("--append and --group cannot be used together: continuing after the last recorded offsets requires direct partition access")
+			}
+			if isStream(output) {
+				if cmd.Bool("append") || cmd.Bool("index") {
+					return fmt.Errorf("--append and --index cannot be used together with --output -: they need a file")
+				}
+				if err := checkStreamOutput(); err != nil {
+					return err
+				}
+			}
+
+			// Find where to continue the output file, before connecting to the cluster
+			var appendFile *os.File
+			var appendPoint *transcoder.AppendPoint
+			if cmd.Bool("append") {
+				var discarded int64
+				appendFile, appendPoint, discarded, err = openAppendOutput(output)
+				if err != nil {
+					return err
+				}
+				if appendFile != nil {
+					defer appendFile.Close()
+					if cmd.IsSet("compression") && compression != appendPoint.Compression {
+						return fmt.Errorf("cannot append with compression %s: %s uses compression %s", compression, output, appendPoint.Compression)
+					}
+					compression = appendPoint.Compression
+					if err := pkg.CheckAppendMasking(appendPoint, masking); err != nil {
+						return fmt.Errorf("%s: %w", output, err)
+					}
+					if discarded > 0 && !util.Quiet(cmd) {
+						fmt.Fprintf(os.Stderr, "Discarded %d bytes of a partially written message at the end of %s\n", discarded, output)
+					}
+				}
+			}
 
 			// Validate that --group and --offset are not used together
 			// offsetFlag >= 0 means an explicit offset was provided (not the default -1)
 			if groupID != "" && offsetFlag >= 0 {
 				return fmt.Errorf("--group and --offset cannot be used together: consumer groups manage offsets automatically, while --offset requires direct partition access")
 			}
+			if allPartitions && groupID != "" {
+				return fmt.Errorf("--all-partitions and --group cannot be used together: consumer groups assign partitions automatically")
+			}
+			if allPartitions && cmd.IsSet("partition") {
+				return fmt.Errorf("--all-partitions and --partition cannot be used together")
+			}
+
+			// Time range (resolved to offsets per partition)
+			var fromTime, toTime *time.Time
+			if cmd.IsSet("from-time") {
+				t := cmd.Timestamp("from-time")
+				fromTime = &t
+			}
+			if cmd.IsSet("to-time") {
+				t := cmd.Timestamp("to-time")
+				toTime = &t
+			}
+			if (fromTime != nil || toTime != nil) && groupID != "" {
+				return fmt.Errorf("--from-time/--to-time and --group cannot be used together: time ranges require direct partition access")
+			}
+			untilLatest := cmd.Bool("until-latest")
+			if untilLatest && groupID != "" {
+				return fmt.Errorf("--until-latest and --group cannot be used together: reading up to the latest offsets requires direct partition access")
+			}
+			if fromTime != nil && offsetFlag >= 0 {
+				return fmt.Errorf("--from-time and --offset cannot be used together")
+			}
+			if fromTime != nil && toTime != nil && toTime.Before(*fromTime) {
+				return fmt.Errorf("--to-time (%s) is before --from-time (%s)", toTime.Format(time.RFC3339), fromTime.Format(time.RFC3339))
+			}
 
 			// Convert find string to byte slice if provided
 			var findBytes []byte
@@ -106,20 +239,55 @@ func RecordCommand() *cli.Command {
 				offset = &offsetFlag
 			}
 
+			topics, err := resolveRecordTopics(ctx, cmd, brokers, security)
+			if err != nil {
+				return err
+			}
+
 			quiet := util.Quiet(cmd)
 			if !quiet {
-				fmt.Fprintf(os.Stderr, "Recording messages from topic '%s' on brokers %v\n", topic, brokers)
+				if len(topics) == 1 {
+					fmt.Fprintf(os.Stderr, "Recording messages from topic '%s' on brokers %v\n", topics[0], brokers)
+				} else {
+					fmt.Fprintf(os.Stderr, "Recording messages from topics %v on brokers %v\n", topics, brokers)
+				}
 				if groupID != "" {
 					fmt.Fprintf(os.Stderr, "Consumer group: %s\n", groupID)
+				} else if allPartitions {
+					fmt.Fprintln(os.Stderr, "Using direct partition access on all partitions (no consumer group)")
 				} else {
 					fmt.Fprintln(os.Stderr, "Using direct partition access (no consumer group)")
 				}
-				fmt.Fprintf(os.Stderr, "Output file: %s\n", output)
+				if appendPoint != nil {
+					fmt.Fprintf(os.Stderr, "Output file: %s (appending after %d messages)\n", output, appendPoint.Entries())
+				} else {
+					fmt.Fprintf(os.Stderr, "Output file: %s\n", describePath(output, "standard output"))
+				}
+				if compression != transcoder.CompressionNone {
+					fmt.Fprintf(os.Stderr, "Compression: %s\n", compression)
+				}
+				if masking != nil {
+					fmt.Fprintf(os.Stderr, "Masking: rules from %s (rule set %s)\n", cmd.String("mask"), masking.RuleSet())
+					if cmd.String("mask-secret") == "" {
+						fmt.Fprintln(os.Stderr, "Warning: without --mask-secret, hashes and tokens can be recomputed for guessed values")
+					}
+					if masking.NonJSON() == mask.NonJSONKeep {
+						fmt.Fprintln(os.Stderr, "Warning: with non_json: keep, values that are not valid JSON are recorded unmasked")
+					}
+				}
 				if offset != nil {
 					fmt.Fprintf(os.Stderr, "Starting from offset: %d\n", *offset)
+				} else if fromTime != nil {
+					fmt.Fprintf(os.Stderr, "Starting from time: %s\n", fromTime.Format(time.RFC3339))
 				} else {
 					fmt.Fprintln(os.Stderr, "Starting from current position")
 				}
+				if toTime != nil {
+					fmt.Fprintf(os.Stderr, "Stopping at time: %s\n", toTime.Format(time.RFC3339))
+				}
+				if untilLatest {
+					fmt.Fprintln(os.Stderr, "Stopping at the latest offsets (snapshot)")
+				}
 				if limit > 0 {
 					fmt.Fprintf(os.Stderr, "Message limit: %d\n", limit)
 				}
@@ -130,16 +298,65 @@ func RecordCommand() *cli.Command {
 					fmt.Fprintf(os.Stderr, "Find filter: %s\n", findStr)
 				}
 			}
-			consumer, err := kafka.NewConsumer(ctx, brokers, topic, partition, groupID)
-			if err != nil {
-				return err
+			timestampTypes := topicTimestampTypes(ctx, brokers, security, topics, quiet)
+
+			var consumers []*kafka.Consumer
+			defer func() {
+				for _, consumer := range consumers {
+					consumer.Close()
+				}
+			}()
+			if groupID != "" && len(topics) > 1 {
+				// One group member subscribed to all topics
+				consumer, err := kafka.NewGroupConsumer(brokers, topics, groupID, security)
+				if err != nil {
+					return err
+				}
+				consumers = append(consumers, consumer)
+			} else {
+				for _, topic := range topics {
+					partitions := []int{partition}
+					if allPartitions {
+						if partitions, err = pkg.TopicPartitions(ctx, brokers, topic, security); err != nil {
+							return err
+						}
+						if !quiet {
+							fmt.Fprintf(os.Stderr, "Partitions of '%s': %v\n", topic, partitions)
+						}
+					}
+					for _, p := range partitions {
+						consumer, err := kafka.NewConsumer(ctx, brokers, topic, p, groupID, security)
+						if err != nil {
+							return err
+						}
+						consumers = append(consumers, consumer)
+					}
+				}
 			}
-			defer consumer.Close()
-			fileWriter, err := os.Create(output)
-			if err != nil {
-				return err
+			var fileWriter io.Writer
+			switch {
+			case appendFile != nil:
+				fileWriter = appendFile
+			case isStream(output):
+				fileWriter = os.Stdout
+			default:
+				file, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				fileWriter = file
+			}
+
+			var indexWriter io.Writer
+			if cmd.Bool("index") {
+				indexFile, err := os.Create(transcoder.IndexPath(output))
+				if err != nil {
+					return fmt.Errorf("failed to create index file: %w", err)
+				}
+				defer indexFile.Close()
+				indexWriter = indexFile
 			}
-			defer fileWriter.Close()
 
 			var spinner *util.ProgressSpinner
 			if !quiet {
@@ -148,11 +365,20 @@ func RecordCommand() *cli.Command {
 			writer := util.CountingWriter(fileWriter, spinner)
 
 			read, messageCount, err := pkg.Record(ctx, pkg.RecordConfig{
-				Consumer:  consumer,
-				Offset:    offset,
-				Output:    writer,
-				Limit:     limit,
-				FindBytes: findBytes,
+				Consumers:      consumers,
+				Offset:         offset,
+				Output:         writer,
+				Limit:          limit,
+				FindBytes:      findBytes,
+				Filter:         messageFilter,
+				Masking:        masking,
+				FromTime:       fromTime,
+				ToTime:         toTime,
+				UntilLatest:    untilLatest,
+				TimestampTypes: timestampTypes,
+				Compression:    compression,
+				Index:          indexWriter,
+				Append:         appendPoint,
 			})
 
 			if err != nil {
@@ -164,8 +390,117 @@ func RecordCommand() *cli.Command {
 			}
 			if !quiet {
 				fmt.Fprintf(os.Stderr, "Recorded %d messages (%d bytes)\n", messageCount, read)
+				if masking != nil && masking.NonJSONValues() > 0 {
+					if masking.NonJSON() == mask.NonJSONKeep {
+						fmt.Fprintf(os.Stderr, "Warning: %d values were not valid JSON and were recorded unmasked (non_json: keep)\n", masking.NonJSONValues())
+					} else {
+						fmt.Fprintf(os.Stderr, "Masking: %d values were not valid JSON and were redacted entirely\n", masking.NonJSONValues())
+					}
+				}
 			}
 			return nil
 		},
 	}
 }
+
+// openAppendOutput opens the recording at path to append to it, positioned after its last complete message
+// A partially written last message (e.g. after a crash) is truncated; the number of discarded bytes is returned
+// Returns a nil file if there is no recording at path yet
+func openAppendOutput(path string) (*os.File, *transcoder.AppendPoint, int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, 0, nil
+		}
+		return nil, nil, 0, fmt.Errorf("failed to open output file: %w", err)
+	}
+
+	point, err := transcoder.FindAppendPoint(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, 0, fmt.Errorf("cannot append to %s: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, 0, err
+	}
+	discarded := info.Size() - point.Size
+	if discarded > 0 {
+		if err := file.Truncate(point.Size); err != nil {
+			file.Close()
+			return nil, nil, 0, fmt.Errorf("failed to truncate the partially written message: %w", err)
+		}
+	}
+	if _, err := file.Seek(point.Size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, 0, err
+	}
+	return file, point, discarded, nil
+}
+
+// resolveRecordTopics returns the topics given with --topic and the topics matching --topic-regex,
+// without duplicates and in the order given (matched topics sorted by name)
+func resolveRecordTopics(ctx context.Context, cmd *cli.Command, brokers []string, security *kafka.Security) ([]string, error) {
+	var topics []string
+	seen := make(map[string]bool)
+	add := func(topic string) {
+		if topic != "" && !seen[topic] {
+			seen[topic] = true
+			topics = append(topics, topic)
+		}
+	}
+	for _, topic := range cmd.StringSlice("topic") {
+		add(strings.TrimSpace(topic))
+	}
+
+	if expr := cmd.String("topic-regex"); expr != "" {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --topic-regex: %w", err)
+		}
+		matched, err := pkg.MatchTopics(ctx, brokers, pattern, security)
+		if err != nil {
+			return nil, err
+		}
+		if len(matched) == 0 {
+			return nil, fmt.Errorf("no topics match --topic-regex %q", expr)
+		}
+		for _, topic := range matched {
+			add(topic)
+		}
+	}
+
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("at least one of --topic or --topic-regex is required")
+	}
+	return topics, nil
+}
+
+// topicTimestampTypes looks up the timestamp type of every topic
+// Kafka does not report the timestamp type per message, it is a property of the topic
+// Topics whose type cannot be determined are left out (and recorded with an unknown timestamp type)
+func topicTimestampTypes(ctx context.Context, brokers []string, security *kafka.Security, topics []string, quiet bool) map[string]transcoder.TimestampType {
+	timestampTypes := make(map[string]transcoder.TimestampType, len(topics))
+	for _, topic := range topics {
+		name, err := kafka.TopicTimestampType(ctx, brokers, topic, security)
+		if err != nil {
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "Warning: could not determine timestamp type of topic '%s': %v\n", topic, err)
+			}
+			continue
+		}
+		timestampType, err := transcoder.ParseTimestampType(name)
+		if err != nil {
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "Warning: topic '%s': %v\n", topic, err)
+			}
+			continue
+		}
+		timestampTypes[topic] = timestampType
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Timestamp type of '%s': %s\n", topic, timestampType)
+		}
+	}
+	return timestampTypes
+}
@@ -2,13 +2,18 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/util"
 	"github.com/lolocompany/kafka-replay/v2/pkg"
 	"github.com/lolocompany/kafka-replay/v2/pkg/kafka"
+	"github.com/lolocompany/kafka-replay/v2/pkg/schemaregistry"
 	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
 	"github.com/urfave/cli/v3"
 )
@@ -16,19 +21,26 @@ import (
 func ReplayCommand() *cli.Command {
 	return &cli.Command{
 		Name:        "replay",
-		Usage:       "Replay recorded messages to a Kafka topic",
-		Description: "Replay previously recorded messages from a file back to a Kafka topic.",
-		Flags: append(util.GlobalFlags(),
+		Usage:       "Replay recorded messages to Kafka",
+		Description: "Replay previously recorded messages from a file back to a Kafka topic, or with --original-topics to the topics they were recorded from.",
+		Flags: append(append(append(util.GlobalFlags(),
 			&cli.StringFlag{
-				Name:     "topic",
-				Aliases:  []string{"t"},
-				Usage:    "Kafka topic to replay messages to",
-				Required: true,
+				Name:    "topic",
+				Aliases: []string{"t"},
+				Usage:   "Kafka topic to replay all messages to (required unless --original-topics is set)",
+			},
+			&cli.BoolFlag{
+				Name:  "original-topics",
+				Usage: "Replay each message to the topic it was recorded from instead of one topic. Cannot be used together with --topic",
+			},
+			&cli.StringSliceFlag{
+				Name:  "topic-map",
+				Usage: "Rename recorded topics when replaying with --original-topics, as source=target pairs (comma-separated or repeated)",
 			},
 			&cli.StringFlag{
 				Name:     "input",
 				Aliases:  []string{"i"},
-				Usage:    "Input file path containing recorded messages",
+				Usage:    "Input file path containing recorded messages ('-' for standard input, which is read as a stream: cannot be used together with --loop, --checkpoint or --resume)",
 				Required: true,
 			},
 			&cli.IntFlag{
@@ -36,6 +48,19 @@ func ReplayCommand() *cli.Command {
 				Usage: "Messages per second to replay (0 for maximum speed)",
 				Value: 0,
 			},
+			&cli.BoolFlag{
+				Name:  "original-timing",
+				Usage: "Replay messages with the gaps between their recorded timestamps (bursts and pauses as recorded). Cannot be used together with --rate",
+			},
+			&cli.Float64Flag{
+				Name:  "speed",
+				Usage: "Speed factor for --original-timing (2 replays twice as fast, 0.5 half as fast)",
+				Value: 1,
+			},
+			&cli.DurationFlag{
+				Name:  "max-wait",
+				Usage: "Maximum wait between two messages with --original-timing, after applying --speed (e.g. 5s; 0 for no limit)",
+			},
 			&cli.BoolFlag{
 				Name:  "preserve-timestamps",
 				Usage: "Preserve original message timestamps",
@@ -57,6 +82,36 @@ func ReplayCommand() *cli.Command {
 				Usage:   "Target partition to write messages to (default: auto-assign)",
 				Value:   -1,
 			},
+			&cli.StringFlag{
+				Name:  "balancer",
+				Usage: "How the producer picks the partition of each message: round-robin, murmur2 (keys hashed like the Java client), crc32 (keys hashed like librdkafka), least-bytes or sticky (keys hashed with murmur2, keyless messages batched per partition like the Java client). Cannot be used together with --partition or --preserve-partitions",
+				Value: "round-robin",
+			},
+			&cli.BoolFlag{
+				Name:  "preserve-partitions",
+				Usage: "Send each message to the partition it was recorded from, keeping the per-partition order. Cannot be used together with --partition",
+			},
+			&cli.StringFlag{
+				Name:  "partition-strategy",
+				Usage: "With --preserve-partitions, what to do with messages from partitions the target topic does not have: fail or modulo (partition modulo the partition count)",
+				Value: "fail",
+			},
+			&cli.StringSliceFlag{
+				Name:  "partition-map",
+				Usage: "With --preserve-partitions, remap recorded partitions as source=target pairs (comma-separated or repeated), e.g. 3=0,4=1",
+			},
+			&cli.BoolFlag{
+				Name:  "checkpoint",
+				Usage: "Save the position of the last batch acknowledged by the brokers to the checkpoint file, so that a failed replay can be resumed with --resume. Cannot be used together with --loop",
+			},
+			&cli.BoolFlag{
+				Name:  "resume",
+				Usage: "Continue a replay from the checkpoint file (starts from the first message if there is none), and keep saving checkpoints. Cannot be used together with --loop, --start-entry or --start-time",
+			},
+			&cli.StringFlag{
+				Name:  "checkpoint-file",
+				Usage: "Path of the checkpoint file (default: <input>.checkpoint)",
+			},
 			&cli.BoolFlag{
 				Name:  "dry-run",
 				Usage: "Validate configuration, messages and connectivity without actually sending to Kafka",
@@ -67,18 +122,42 @@ func ReplayCommand() *cli.Command {
 				Aliases: []string{"f"},
 				Usage:   "Only replay messages containing the specified byte sequence (string is converted to bytes)",
 			},
+			filterFlag(),
+			&cli.StringFlag{
+				Name:  "transforms",
+				Usage: "YAML file of transformations applied to each message before it is sent (JSON field set/delete/rename, key rewrite, header injection, regex replace), after --find and --filter",
+			},
 			&cli.BoolFlag{
 				Name:  "no-ack",
 				Usage: "Don't wait for broker acknowledgment (faster but less reliable - messages may be lost if broker fails immediately)",
 				Value: false,
 			},
-		),
+		), startFlags()...), schemaRemapFlags()...),
 		Action: func(ctx context.Context, cmd *cli.Command) error {
+			topic := cmd.String("topic")
+			topicMap, err := parseTopicMap(cmd.StringSlice("topic-map"))
+			if err != nil {
+				return err
+			}
+			originalTopics := cmd.Bool("original-topics")
+			switch {
+			case topic != "" && originalTopics:
+				return fmt.Errorf("--topic and --original-topics cannot be used together: --topic sends all messages to one topic")
+			case topic != "" && len(topicMap) > 0:
+				return fmt.Errorf("--topic and --topic-map cannot be used together: --topic sends all messages to one topic")
+			case len(topicMap) > 0 && !originalTopics:
+				return fmt.Errorf("--topic-map requires --original-topics")
+			case topic == "" && !originalTopics:
+				return fmt.Errorf("--topic is required (or --original-topics to replay each message to the topic it was recorded from)")
+			}
 			brokers, err := util.ResolveBrokers(cmd)
 			if err != nil {
 				return err
 			}
-			topic := cmd.String("topic")
+			security, err := util.ResolveSecurity(cmd)
+			if err != nil {
+				return err
+			}
 			input := cmd.String("input")
 			rate := cmd.Int("rate")
 			preserveTimestamps := cmd.Bool("preserve-timestamps")
@@ -88,6 +167,58 @@ func ReplayCommand() *cli.Command {
 			dryRun := cmd.Bool("dry-run")
 			findStr := cmd.String("find")
 			noAck := cmd.Bool("no-ack")
+			startEntry, startTime, err := resolveStart(cmd)
+			if err != nil {
+				return err
+			}
+			timing, err := resolveTiming(cmd)
+			if err != nil {
+				return err
+			}
+			messageFilter, err := resolveFilter(cmd)
+			if err != nil {
+				return err
+			}
+			transforms, err := loadTransforms(cmd)
+			if err != nil {
+				return err
+			}
+			routing, err := resolvePartitionRouting(cmd, brokers, security)
+			if err != nil {
+				return err
+			}
+			schemaRemap, err := resolveSchemaRemap(cmd)
+			if err != nil {
+				return err
+			}
+			resume := cmd.Bool("resume")
+			checkpointing := resume || cmd.Bool("checkpoint")
+			if checkpointing && loop {
+				return fmt.Errorf("--checkpoint and --resume cannot be used together with --loop")
+			}
+			if resume && (cmd.IsSet("start-entry") || cmd.IsSet("start-time")) {
+				return fmt.Errorf("--resume cannot be used together with --start-entry or --start-time")
+			}
+			if isStream(input) {
+				if loop {
+					return fmt.Errorf("--loop cannot be used together with --input -: looping reads the recording again from the start, which a stream cannot do")
+				}
+				if checkpointing {
+					return fmt.Errorf("--checkpoint and --resume cannot be used together with --input -: checkpoints are positions in a file")
+				}
+			}
+			checkpointPath := cmd.String("checkpoint-file")
+			if checkpointPath == "" {
+				checkpointPath = input + CheckpointFileSuffix
+			}
+
+			balancer, err := kafka.ParseBalancer(cmd.String("balancer"))
+			if err != nil {
+				return err
+			}
+			if cmd.IsSet("balancer") && (partitionFlag >= 0 || routing != nil) {
+				return fmt.Errorf("--balancer cannot be used together with --partition or --preserve-partitions")
+			}
 
 			var partition *int
 			if partitionFlag >= 0 {
@@ -105,9 +236,25 @@ func ReplayCommand() *cli.Command {
 				if dryRun {
 					fmt.Fprintln(os.Stderr, "DRY RUN MODE: No messages will be sent to Kafka")
 				}
-				fmt.Fprintf(os.Stderr, "Replaying messages to topic '%s' on brokers %v\n", topic, brokers)
-				fmt.Fprintf(os.Stderr, "Input file: %s\n", input)
-				if rate > 0 {
+				if !originalTopics {
+					fmt.Fprintf(os.Stderr, "Replaying messages to topic '%s' on brokers %v\n", topic, brokers)
+				} else {
+					fmt.Fprintf(os.Stderr, "Replaying messages to their recorded topics on brokers %v\n", brokers)
+					for source, target := range topicMap {
+						fmt.Fprintf(os.Stderr, "Topic rename: %s -> %s\n", source, target)
+					}
+				}
+				fmt.Fprintf(os.Stderr, "Input file: %s\n", describePath(input, "standard input"))
+				if transforms != nil {
+					fmt.Fprintf(os.Stderr, "Transforms: %d steps from %s\n", transforms.Len(), cmd.String("transforms"))
+				}
+				if timing != nil {
+					fmt.Fprintf(os.Stderr, "Timing: original gaps at %gx speed", timing.Speed)
+					if timing.MaxWait > 0 {
+						fmt.Fprintf(os.Stderr, " (waiting at most %s)", timing.MaxWait)
+					}
+					fmt.Fprintln(os.Stderr)
+				} else if rate > 0 {
 					fmt.Fprintf(os.Stderr, "Rate limit: %d messages/second\n", rate)
 				} else {
 					fmt.Fprintln(os.Stderr, "Rate limit: maximum speed")
@@ -121,35 +268,108 @@ func ReplayCommand() *cli.Command {
 				if partition != nil {
 					fmt.Fprintf(os.Stderr, "Target partition: %d\n", *partition)
 				}
+				if routing != nil {
+					fmt.Fprintf(os.Stderr, "Target partition: as recorded (strategy: %s)\n", routing.Strategy)
+					for source, target := range routing.Map {
+						fmt.Fprintf(os.Stderr, "Partition remap: %d -> %d\n", source, target)
+					}
+				}
+				if partition == nil && routing == nil {
+					fmt.Fprintf(os.Stderr, "Balancer: %s\n", balancer)
+				}
+				if schemaRemap != nil {
+					scope := "values"
+					if cmd.Bool("schema-remap-keys") {
+						scope = "keys and values"
+					}
+					fmt.Fprintf(os.Stderr, "Schema remap: %s -> %s (%s, subject strategy: %s)\n", schemaRemap.Source().URL(), schemaRemap.Target().URL(), scope, cmd.String("subject-strategy"))
+					if dryRun {
+						fmt.Fprintln(os.Stderr, "Schema remap: skipped in dry-run mode (no schemas are registered)")
+					}
+				}
 				if findStr != "" {
 					fmt.Fprintf(os.Stderr, "Find filter: %s\n", findStr)
 				}
 				if noAck {
 					fmt.Fprintln(os.Stderr, "No acknowledgment: enabled (faster but less reliable)")
 				}
+				if startTime != nil {
+					fmt.Fprintf(os.Stderr, "Start time: %s\n", startTime.Format(time.RFC3339))
+				} else if startEntry > 0 {
+					fmt.Fprintf(os.Stderr, "Start entry: %d\n", startEntry)
+				}
 			}
 
 			// Open input file
-			file, err := os.Open(input)
+			file, err := openInput(input)
 			if err != nil {
-				return fmt.Errorf("failed to open input file: %w", err)
+				return err
 			}
 			defer file.Close()
 
+			// Load the checkpoint to resume from
+			var inputSize int64
+			var resumeAt *transcoder.Position
+			var resumedMessages int64
+			if checkpointing {
+				info, err := os.Stat(input)
+				if err != nil {
+					return fmt.Errorf("failed to stat input file: %w", err)
+				}
+				inputSize = info.Size()
+			}
+			if resume {
+				checkpoint, err := readCheckpointFile(checkpointPath)
+				if err != nil {
+					return err
+				}
+				switch {
+				case checkpoint == nil:
+					if !quiet {
+						fmt.Fprintf(os.Stderr, "No checkpoint at %s, starting from the first message\n", checkpointPath)
+					}
+				case checkpoint.InputSize != inputSize:
+					return fmt.Errorf("checkpoint %s was written for a file of %d bytes, but %s has %d bytes", checkpointPath, checkpoint.InputSize, input, inputSize)
+				case checkpoint.Completed:
+					return fmt.Errorf("checkpoint %s shows the replay of %s has completed (%d messages); delete it to replay again", checkpointPath, input, checkpoint.Messages)
+				default:
+					position := checkpoint.Position()
+					resumeAt = &position
+					resumedMessages = checkpoint.Messages
+					if !quiet {
+						fmt.Fprintf(os.Stderr, "Resuming at entry %d (%d messages already replayed)\n", position.Entry, resumedMessages)
+					}
+				}
+			}
+			var saveCheckpoint func(transcoder.Position, int64) error
+			if checkpointing {
+				saveCheckpoint = func(position transcoder.Position, sent int64) error {
+					return writeCheckpointFile(checkpointPath, pkg.NewCheckpoint(inputSize, position, resumedMessages+sent))
+				}
+			}
+
+			var index *transcoder.Index
+			if (startEntry > 0 || startTime != nil) && !isStream(input) {
+				index = loadRecordingIndex(input, quiet)
+			}
+
 			var spinner *util.ProgressSpinner
 			if !quiet {
 				spinner = util.NewProgressSpinner("Replaying messages")
 			}
-			countingReader := util.CountingReadSeeker(file, spinner)
+			countingReader := util.CountingReader(file, spinner)
 
-			// Create message decoder
-			decoder, err := transcoder.NewDecodeReader(countingReader, preserveTimestamps)
+			// Create message decoder (the original timing, filters and transforms need the recorded timestamps)
+			decoder, err := transcoder.NewDecodeReader(countingReader, preserveTimestamps || timing != nil || messageFilter != nil || transforms != nil)
 			if err != nil {
 				return fmt.Errorf("failed to create message decoder: %w", err)
 			}
+			if sanitization := decoder.Sanitization(); sanitization != nil && !quiet {
+				fmt.Fprintf(os.Stderr, "Recording sanitized with masking rule set %s\n", sanitization.RuleSet)
+			}
 
-			// Create Kafka producer
-			producer := kafka.NewProducer(brokers, topic, createTopic, noAck)
+			// Create Kafka producer (without a topic, each message carries its own)
+			producer := kafka.NewProducer(brokers, topic, createTopic, noAck, balancer, security)
 			defer producer.Close()
 
 			logWriter := io.Writer(os.Stderr)
@@ -157,19 +377,47 @@ func ReplayCommand() *cli.Command {
 				logWriter = io.Discard
 			}
 			messageCount, err := pkg.Replay(ctx, pkg.ReplayConfig{
-				Producer:  producer,
-				Decoder:   decoder,
-				Rate:      rate,
-				Loop:      loop,
-				Partition: partition,
-				LogWriter: logWriter,
-				DryRun:    dryRun,
-				FindBytes: findBytes,
+				Producer:       producer,
+				Decoder:        decoder,
+				Rate:           rate,
+				Loop:           loop,
+				Partition:      partition,
+				LogWriter:      logWriter,
+				DryRun:         dryRun,
+				FindBytes:      findBytes,
+				Filter:         messageFilter,
+				Transform:      transforms,
+				OriginalTopics: originalTopics,
+				TopicMap:       topicMap,
+				StartEntry:     startEntry,
+				StartTime:      startTime,
+				Index:          index,
+				Timing:         timing,
+				// Only used with Timing, Filter and Transform, otherwise the decoder applies it
+				PreserveTimestamps: preserveTimestamps,
+				PartitionRouting:   routing,
+				Resume:             resumeAt,
+				Checkpoint:         saveCheckpoint,
+				SchemaRemap:        schemaRemap,
+				SchemaRemapKeys:    cmd.Bool("schema-remap-keys"),
 			})
 
+			if errors.Is(err, schemaregistry.ErrSchemaNotFound) {
+				err = fmt.Errorf("%w (--skip-unknown-schemas sends such values unchanged)", err)
+			}
 			if err != nil {
+				if checkpointing && !dryRun && !quiet {
+					fmt.Fprintf(os.Stderr, "Replay stopped; continue it with --resume (checkpoint: %s)\n", checkpointPath)
+				}
 				return err
 			}
+			if checkpointing && !dryRun {
+				checkpoint := pkg.NewCheckpoint(inputSize, decoder.Position(), resumedMessages+messageCount)
+				checkpoint.Completed = true
+				if err := writeCheckpointFile(checkpointPath, checkpoint); err != nil {
+					return fmt.Errorf("failed to write checkpoint: %w", err)
+				}
+			}
 
 			if spinner != nil {
 				spinner.Close()
@@ -177,11 +425,101 @@ func ReplayCommand() *cli.Command {
 			if !quiet {
 				if dryRun {
 					fmt.Fprintf(os.Stderr, "Dry run completed: validated %d messages (no messages were sent)\n", messageCount)
-				} else {
+				} else if !originalTopics {
 					fmt.Fprintf(os.Stderr, "Successfully replayed %d messages to topic '%s'\n", messageCount, topic)
+				} else {
+					fmt.Fprintf(os.Stderr, "Successfully replayed %d messages to their recorded topics\n", messageCount)
+				}
+				if schemaRemap != nil && schemaRemap.Skipped() > 0 {
+					fmt.Fprintf(os.Stderr, "Sent %d values with a schema ID unknown to the source registry unchanged\n", schemaRemap.Skipped())
 				}
 			}
 			return nil
 		},
 	}
 }
+
+// resolveTiming returns the replay timing set with --original-timing, --speed and --max-wait
+// (nil to replay at --rate)
+func resolveTiming(cmd *cli.Command) (*pkg.ReplayTiming, error) {
+	if !cmd.Bool("original-timing") {
+		if cmd.IsSet("speed") || cmd.IsSet("max-wait") {
+			return nil, fmt.Errorf("--speed and --max-wait require --original-timing")
+		}
+		return nil, nil
+	}
+	if cmd.Int("rate") > 0 {
+		return nil, fmt.Errorf("--original-timing and --rate cannot be used together")
+	}
+	speed := cmd.Float64("speed")
+	if speed <= 0 {
+		return nil, fmt.Errorf("--speed must be greater than 0 (got %g)", speed)
+	}
+	maxWait := cmd.Duration("max-wait")
+	if maxWait < 0 {
+		return nil, fmt.Errorf("--max-wait must not be negative")
+	}
+	return &pkg.ReplayTiming{Speed: speed, MaxWait: maxWait}, nil
+}
+
+// resolvePartitionRouting returns the partition routing set with --preserve-partitions, --partition-strategy
+// and --partition-map (nil to let the producer pick the partitions)
+func resolvePartitionRouting(cmd *cli.Command, brokers []string, security *kafka.Security) (*pkg.PartitionRouting, error) {
+	if !cmd.Bool("preserve-partitions") {
+		if cmd.IsSet("partition-strategy") || cmd.IsSet("partition-map") {
+			return nil, fmt.Errorf("--partition-strategy and --partition-map require --preserve-partitions")
+		}
+		return nil, nil
+	}
+	if cmd.Int("partition") >= 0 {
+		return nil, fmt.Errorf("--preserve-partitions and --partition cannot be used together")
+	}
+	strategy, err := pkg.ParsePartitionStrategy(cmd.String("partition-strategy"))
+	if err != nil {
+		return nil, err
+	}
+	partitionMap, err := parsePartitionMap(cmd.StringSlice("partition-map"))
+	if err != nil {
+		return nil, err
+	}
+	return &pkg.PartitionRouting{
+		Strategy: strategy,
+		Map:      partitionMap,
+		PartitionCount: func(ctx context.Context, topic string) (int, error) {
+			partitions, err := pkg.TopicPartitions(ctx, brokers, topic, security)
+			if err != nil {
+				return 0, err
+			}
+			return len(partitions), nil
+		},
+	}, nil
+}
+
+// parsePartitionMap parses source=target partition remap pairs
+func parsePartitionMap(pairs []string) (map[int]int, error) {
+	partitionMap := make(map[int]int, len(pairs))
+	for _, pair := range pairs {
+		source, target, ok := strings.Cut(pair, "=")
+		sourceID, sourceErr := strconv.Atoi(strings.TrimSpace(source))
+		targetID, targetErr := strconv.Atoi(strings.TrimSpace(target))
+		if !ok || sourceErr != nil || targetErr != nil || sourceID < 0 || targetID < 0 {
+			return nil, fmt.Errorf("invalid --partition-map entry %q: expected source=target partition numbers", pair)
+		}
+		partitionMap[sourceID] = targetID
+	}
+	return partitionMap, nil
+}
+
+// parseTopicMap parses source=target topic rename pairs
+func parseTopicMap(pairs []string) (map[string]string, error) {
+	topicMap := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		source, target, ok := strings.Cut(pair, "=")
+		source, target = strings.TrimSpace(source), strings.TrimSpace(target)
+		if !ok || source == "" || target == "" {
+			return nil, fmt.Errorf("invalid --topic-map entry %q: expected source=target", pair)
+		}
+		topicMap[source] = target
+	}
+	return topicMap, nil
+}
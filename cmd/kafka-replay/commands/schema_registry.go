@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/schemaregistry"
+	"github.com/urfave/cli/v3"
+)
+
+// schemaRegistryFlags are the flags of a Schema Registry: --<name> for its URL, and --<name>-username and
+// --<name>-password for basic authentication, also read from KAFKA_REPLAY_<NAME>[_USERNAME|_PASSWORD]
+func schemaRegistryFlags(name, usage string) []cli.Flag {
+	env := "KAFKA_REPLAY_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    name,
+			Usage:   usage,
+			Sources: cli.EnvVars(env),
+		},
+		&cli.StringFlag{
+			Name:    name + "-username",
+			Usage:   "Username for basic authentication to --" + name + " (or set it in the URL)",
+			Sources: cli.EnvVars(env + "_USERNAME"),
+		},
+		&cli.StringFlag{
+			Name:    name + "-password",
+			Usage:   "Password for basic authentication to --" + name + " (prefer the " + env + "_PASSWORD environment variable)",
+			Sources: cli.EnvVars(env + "_PASSWORD"),
+		},
+	}
+}
+
+// resolveSchemaRegistry returns a client for the registry set with --<name>, or nil
+func resolveSchemaRegistry(cmd *cli.Command, name string) (*schemaregistry.Client, error) {
+	url := cmd.String(name)
+	if url == "" {
+		return nil, nil
+	}
+	return schemaregistry.NewClient(url, cmd.String(name+"-username"), cmd.String(name+"-password"))
+}
+
+// schemaRemapFlags are the replay flags remapping schema IDs from a source registry to a target registry
+func schemaRemapFlags() []cli.Flag {
+	flags := append(
+		schemaRegistryFlags("source-schema-registry", "URL of the Schema Registry the recorded keys and values were serialized with, to remap their schema IDs to --target-schema-registry"),
+		schemaRegistryFlags("target-schema-registry", "URL of the Schema Registry of the target environment: the schemas of the recorded values (and keys with --schema-remap-keys) are registered there and their schema IDs rewritten (requires --source-schema-registry)")...,
+	)
+	return append(flags,
+		&cli.StringFlag{
+			Name:  "subject-strategy",
+			Usage: "Subjects schemas are registered under in --target-schema-registry: topic (<topic>-key and <topic>-value), record (full name of the Avro record or Protobuf message) or topic-record (<topic>-<record>)",
+			Value: string(schemaregistry.TopicNameStrategy),
+		},
+		&cli.BoolFlag{
+			Name:  "schema-remap-keys",
+			Usage: "Also remap the schema IDs of keys (not by default: keys such as big-endian integers may start with a zero byte and look framed)",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-unknown-schemas",
+			Usage: "Send values whose schema ID --source-schema-registry does not know unchanged and report how many there were, instead of stopping the replay (keys with an unknown schema ID are always sent unchanged)",
+		},
+	)
+}
+
+// resolveSchemaRemap returns the schema ID remapper set with --source-schema-registry, --target-schema-registry
+// and --subject-strategy, or nil
+func resolveSchemaRemap(cmd *cli.Command) (*schemaregistry.Remapper, error) {
+	source, err := resolveSchemaRegistry(cmd, "source-schema-registry")
+	if err != nil {
+		return nil, err
+	}
+	target, err := resolveSchemaRegistry(cmd, "target-schema-registry")
+	if err != nil {
+		return nil, err
+	}
+	if source == nil && target == nil {
+		for _, name := range []string{"subject-strategy", "schema-remap-keys", "skip-unknown-schemas"} {
+			if cmd.IsSet(name) {
+				return nil, fmt.Errorf("--%s requires --source-schema-registry and --target-schema-registry", name)
+			}
+		}
+		return nil, nil
+	}
+	if source == nil || target == nil {
+		return nil, fmt.Errorf("--source-schema-registry and --target-schema-registry must be used together")
+	}
+	strategy, err := schemaregistry.ParseSubjectStrategy(cmd.String("subject-strategy"))
+	if err != nil {
+		return nil, err
+	}
+	remapper := schemaregistry.NewRemapper(source, target, strategy)
+	if cmd.Bool("skip-unknown-schemas") {
+		remapper.SkipUnknownSchemas()
+	}
+	return remapper, nil
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/output"
+)
+
+// streamPath is the --input or --output path that stands for standard input or output
+const streamPath = "-"
+
+// streamBufferSize is the size of the buffer standard input is read through (entries are read field by field)
+const streamBufferSize = 1 << 20
+
+// isStream reports whether an --input or --output path stands for standard input or output
+func isStream(path string) bool {
+	return path == streamPath
+}
+
+// describePath names an --input or --output path in messages
+func describePath(path string, stdio string) string {
+	if isStream(path) {
+		return stdio
+	}
+	return path
+}
+
+// openInput opens the recording at path, or standard input for "-"
+// Standard input is read as a stream, even when it is redirected from a file: it can only be read forward
+func openInput(path string) (io.ReadCloser, error) {
+	if isStream(path) {
+		return io.NopCloser(bufio.NewReaderSize(os.Stdin, streamBufferSize)), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	return file, nil
+}
+
+// checkStreamOutput returns an error if standard output is a terminal, which binary recordings are not written to
+func checkStreamOutput() error {
+	if output.IsTTY(os.Stdout) {
+		return fmt.Errorf("refusing to write a recording to a terminal: redirect or pipe standard output, e.g. --output - | kafka-replay replay --input -")
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transform"
+	"github.com/urfave/cli/v3"
+)
+
+// loadTransforms returns the transform pipeline configured in the YAML file set with --transforms, or nil
+func loadTransforms(cmd *cli.Command) (*transform.Pipeline, error) {
+	path := cmd.String("transforms")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transforms file: %w", err)
+	}
+	pipeline, err := transform.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pipeline, nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/kafka"
 )
 
 // ResolveProfile returns the profile identified by profileName from cfg.
@@ -52,3 +54,30 @@ func ResolveBrokers(globalBrokers []string, profileName string, cfg Config) ([]s
 
 	return nil, fmt.Errorf("no brokers configured; set --brokers, a profile, or KAFKA_BROKERS")
 }
+
+// ResolveSecurity returns the TLS and SASL settings of the profile identified by profileName.
+// It returns empty settings (plain TCP) when there is no such profile; flags override these
+// settings, see util.ResolveSecurity.
+func ResolveSecurity(profileName string, cfg Config) kafka.SecurityConfig {
+	var security kafka.SecurityConfig
+	if cfg.Profiles == nil {
+		return security
+	}
+	profile, err := ResolveProfile(cfg, profileName)
+	if err != nil {
+		return security
+	}
+	if profile.TLS != nil {
+		security.TLS = profile.TLS.Enabled
+		security.CAFile = profile.TLS.CAFile
+		security.CertFile = profile.TLS.CertFile
+		security.KeyFile = profile.TLS.KeyFile
+		security.InsecureSkipVerify = profile.TLS.InsecureSkipVerify
+	}
+	if profile.SASL != nil {
+		security.SASLMechanism = profile.SASL.Mechanism
+		security.SASLUsername = profile.SASL.Username
+		security.SASLPassword = profile.SASL.Password
+	}
+	return security
+}
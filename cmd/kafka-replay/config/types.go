@@ -1,10 +1,30 @@
 package config
 
 // Profile represents a named connection/profile configuration.
-// For now this only contains brokers, but it can be extended with
-// SASL/TLS and other options without changing the CLI surface.
+// It contains the brokers and the optional TLS and SASL settings used
+// to connect to them.
 type Profile struct {
-	Brokers []string `json:"brokers" yaml:"brokers"`
+	Brokers []string    `json:"brokers" yaml:"brokers"`
+	TLS     *TLSConfig  `json:"tls,omitempty" yaml:"tls,omitempty"`
+	SASL    *SASLConfig `json:"sasl,omitempty" yaml:"sasl,omitempty"`
+}
+
+// TLSConfig holds the TLS settings of a profile.
+// TLS is used when Enabled is true or any other field is set.
+type TLSConfig struct {
+	Enabled            bool   `json:"enabled" yaml:"enabled"`
+	CAFile             string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// SASLConfig holds the SASL settings of a profile.
+// Mechanism is one of PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512.
+type SASLConfig struct {
+	Mechanism string `json:"mechanism" yaml:"mechanism"`
+	Username  string `json:"username" yaml:"username"`
+	Password  string `json:"password" yaml:"password"`
 }
 
 // Config is the top-level configuration structure loaded from disk.
@@ -6,6 +6,8 @@ package main_test
 import (
 	"bytes"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,6 +15,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/lolocompany/kafka-replay/v2/pkg"
+	"github.com/lolocompany/kafka-replay/v2/pkg/mask"
 	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
 )
 
@@ -127,6 +131,99 @@ func TestCLI_Cat_OutputJSON(t *testing.T) {
 	}
 }
 
+func TestCLI_Cat_OutputJSON_Headers(t *testing.T) {
+	path := createEntryFile(t, &transcoder.Entry{
+		Timestamp: time.Unix(0, 0),
+		Key:       []byte("key1"),
+		Data:      []byte("hello"),
+		Headers:   []transcoder.Header{{Key: "trace-id", Value: []byte("abc")}},
+	})
+	defer os.Remove(path)
+	stdout, stderr, code := runCLI("cat", "--input", path)
+	if code != 0 {
+		t.Fatalf("cat json: exit %d, stderr %q", code, string(stderr))
+	}
+	var obj struct {
+		Headers []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"headers"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(stdout), &obj); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(obj.Headers) != 1 || obj.Headers[0].Key != "trace-id" || obj.Headers[0].Value != "abc" {
+		t.Errorf("expected header trace-id=abc, got %+v", obj.Headers)
+	}
+}
+
+func TestCLI_Cat_OutputJSON_Source(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Data: []byte("a"), Topic: "orders", Partition: 2, Offset: 42},
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Data: []byte("b"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+	stdout, stderr, code := runCLI("cat", "--input", path)
+	if code != 0 {
+		t.Fatalf("cat json: exit %d, stderr %q", code, string(stderr))
+	}
+	lines := strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %d", len(lines))
+	}
+	var withSource map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &withSource); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if withSource["topic"] != "orders" || withSource["partition"] != float64(2) || withSource["offset"] != float64(42) {
+		t.Errorf("expected orders/2@42, got %v", withSource)
+	}
+	var withoutSource map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &withoutSource); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, field := range []string{"topic", "partition", "offset"} {
+		if _, ok := withoutSource[field]; ok {
+			t.Errorf("expected %q to be omitted for an unknown source, got %v", field, withoutSource)
+		}
+	}
+}
+
+func TestCLI_Cat_OutputJSON_MillisecondTimestamps(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.UnixMilli(1706868930123), TimestampType: transcoder.TimestampTypeLogAppendTime, Data: []byte("a"), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.UnixMilli(1706868930456), TimestampType: transcoder.TimestampTypeUnknown, Data: []byte("b"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+	stdout, stderr, code := runCLI("cat", "--input", path)
+	if code != 0 {
+		t.Fatalf("cat json: exit %d, stderr %q", code, string(stderr))
+	}
+	lines := strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %d", len(lines))
+	}
+	expected := []struct {
+		timestamp     string
+		timestampType any
+	}{
+		{"2024-02-02T10:15:30.123Z", "LogAppendTime"},
+		{"2024-02-02T10:15:30.456Z", nil},
+	}
+	for i, line := range lines {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("line %d: invalid JSON: %v", i, err)
+		}
+		if obj["timestamp"] != expected[i].timestamp {
+			t.Errorf("line %d: expected timestamp %q, got %v", i, expected[i].timestamp, obj["timestamp"])
+		}
+		if obj["timestampType"] != expected[i].timestampType {
+			t.Errorf("line %d: expected timestampType %v, got %v", i, expected[i].timestampType, obj["timestampType"])
+		}
+	}
+}
+
 func TestCLI_Cat_OutputRaw(t *testing.T) {
 	payload := []byte("raw-payload")
 	path := createMessageFile(t, []byte(""), payload)
@@ -153,6 +250,547 @@ func TestCLI_Cat_Count(t *testing.T) {
 	}
 }
 
+func TestCLI_Cat_Compressed(t *testing.T) {
+	// Compression is detected from the file header
+	for _, compression := range []transcoder.Compression{transcoder.CompressionGzip, transcoder.CompressionZstd, transcoder.CompressionSnappy} {
+		path := createCompressedEntryFile(t, compression,
+			&transcoder.Entry{Timestamp: time.Unix(0, 0), Key: []byte("k1"), Data: []byte(`{"n":1}`), Partition: -1, Offset: -1},
+			&transcoder.Entry{Timestamp: time.Unix(1, 0), Key: []byte("k2"), Data: []byte(`{"n":2}`), Partition: -1, Offset: -1},
+		)
+		defer os.Remove(path)
+
+		stdout, stderr, code := runCLI("cat", "--input", path)
+		if code != 0 {
+			t.Fatalf("cat %s file: exit %d, stderr %q", compression, code, string(stderr))
+		}
+		lines := strings.Split(strings.TrimSpace(string(stdout)), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("cat %s file: expected 2 lines, got %d: %q", compression, len(lines), string(stdout))
+		}
+		var msg struct {
+			Key  string `json:"key"`
+			Data string `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(lines[1]), &msg); err != nil {
+			t.Fatalf("cat %s file: invalid JSON %q: %v", compression, lines[1], err)
+		}
+		if msg.Key != "k2" || msg.Data != `{"n":2}` {
+			t.Errorf("cat %s file: unexpected message %+v", compression, msg)
+		}
+	}
+}
+
+func TestCLI_Index_CatStart(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Key: []byte("k0"), Data: []byte("a"), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(10, 0), Key: []byte("k1"), Data: []byte("b"), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(20, 0), Key: []byte("k2"), Data: []byte("c"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+	defer os.Remove(transcoder.IndexPath(path))
+
+	_, stderr, code := runCLI("index", "--input", path)
+	if code != 0 {
+		t.Fatalf("index: exit %d, stderr %q", code, string(stderr))
+	}
+	if !strings.Contains(string(stderr), "Indexed 3 messages") {
+		t.Errorf("stderr should report the indexed messages; got %q", string(stderr))
+	}
+	if _, err := os.Stat(transcoder.IndexPath(path)); err != nil {
+		t.Fatalf("index file not written: %v", err)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		keys []string
+	}{
+		{[]string{"--start-entry", "1"}, []string{"k1", "k2"}},
+		{[]string{"--start-entry", "3"}, nil},
+		{[]string{"--start-time", "1970-01-01T00:00:05Z"}, []string{"k1", "k2"}},
+		{[]string{"--start-time", "1970-01-01T00:00:20Z"}, []string{"k2"}},
+	} {
+		stdout, stderr, code := runCLI(append([]string{"cat", "--input", path}, tc.args...)...)
+		if code != 0 {
+			t.Fatalf("cat %v: exit %d, stderr %q", tc.args, code, string(stderr))
+		}
+		var keys []string
+		for _, line := range strings.Split(strings.TrimSpace(string(stdout)), "\n") {
+			if line == "" {
+				continue
+			}
+			var msg struct {
+				Key string `json:"key"`
+			}
+			if err := json.Unmarshal([]byte(line), &msg); err != nil {
+				t.Fatalf("cat %v: invalid JSON %q: %v", tc.args, line, err)
+			}
+			keys = append(keys, msg.Key)
+		}
+		if strings.Join(keys, ",") != strings.Join(tc.keys, ",") {
+			t.Errorf("cat %v: expected keys %v, got %v", tc.args, tc.keys, keys)
+		}
+	}
+
+	_, stderr, code = runCLI("cat", "--input", path, "--start-entry", "1", "--start-time", "1970-01-01T00:00:05Z")
+	if code != 1 {
+		t.Errorf("--start-entry with --start-time: expected exit 1, got %d", code)
+	}
+	if !strings.Contains(string(stderr), "cannot be used together") {
+		t.Errorf("stderr should mention the conflicting flags; got %q", string(stderr))
+	}
+}
+
+func TestCLI_Cat_Filter(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Key: []byte("k0"), Data: []byte(`{"type":"OrderCreated","amount":50}`), Topic: "orders", Partition: 0, Offset: 10},
+		&transcoder.Entry{Timestamp: time.Unix(10, 0), Key: []byte("k1"), Data: []byte(`{"type":"OrderCreated","amount":150}`), Topic: "orders", Partition: 1, Offset: 11,
+			Headers: []transcoder.Header{{Key: "source", Value: []byte("web")}}},
+		&transcoder.Entry{Timestamp: time.Unix(20, 0), Key: []byte("k2"), Data: []byte("not json"), Topic: "orders", Partition: 1, Offset: 12},
+	)
+	defer os.Remove(path)
+
+	for _, tc := range []struct {
+		filter string
+		count  string
+	}{
+		{`.type == "OrderCreated" && .amount > 100`, "1"},
+		{`.type == "OrderCreated"`, "2"},
+		{`header["source"] == "web"`, "1"},
+		{`key =~ "^k[02]$"`, "2"},
+		{`partition == 1 && offset >= 12`, "1"},
+		{`timestamp >= "1970-01-01T00:00:10Z"`, "2"},
+		{`!(.amount > 100)`, "2"},
+	} {
+		stdout, stderr, code := runCLI("cat", "--input", path, "--count", "--filter", tc.filter)
+		if code != 0 {
+			t.Fatalf("cat --filter %s: exit %d, stderr %q", tc.filter, code, string(stderr))
+		}
+		if got := strings.TrimSpace(string(stdout)); got != tc.count {
+			t.Errorf("cat --filter %s: expected count %s, got %q", tc.filter, tc.count, got)
+		}
+	}
+
+	_, stderr, code := runCLI("cat", "--input", path, "--filter", `.amount >`)
+	if code != 1 {
+		t.Errorf("invalid filter: expected exit 1, got %d", code)
+	}
+	if !strings.Contains(string(stderr), "invalid filter") {
+		t.Errorf("stderr should report the invalid filter; got %q", string(stderr))
+	}
+}
+
+func TestCLI_Cat_Stdin(t *testing.T) {
+	path := createCompressedEntryFile(t, transcoder.CompressionZstd,
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Key: []byte("k0"), Data: []byte("first"), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(10, 0), Key: []byte("k1"), Data: []byte("second"), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(20, 0), Key: []byte("k2"), Data: []byte("third"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+
+	recording, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binaryPath, "cat", "--input", "-", "--start-entry", "1", "--format", "raw")
+	cmd.Stdin = bytes.NewReader(recording) // Passed through a pipe
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("cat --input -: %v, stderr %q", err, stderr.String())
+	}
+	if got := stdout.String(); got != "secondthird" {
+		t.Errorf("cat --input - --start-entry 1: expected secondthird, got %q", got)
+	}
+
+	_, stderrOut, code := runCLI("replay", "--brokers", "localhost:19999", "--topic", "t", "--input", "-", "--loop")
+	if code != 1 {
+		t.Errorf("replay --input - --loop: expected exit 1, got %d", code)
+	}
+	if !strings.Contains(string(stderrOut), "--loop cannot be used together with --input -") {
+		t.Errorf("stderr should explain that a stream cannot be looped; got %q", string(stderrOut))
+	}
+
+	_, stderrOut, code = runCLI("record", "--brokers", "localhost:19999", "--topic", "t", "--output", "-", "--append")
+	if code != 1 {
+		t.Errorf("record --output - --append: expected exit 1, got %d", code)
+	}
+	if !strings.Contains(string(stderrOut), "cannot be used together with --output -") {
+		t.Errorf("stderr should explain that --append needs a file; got %q", string(stderrOut))
+	}
+
+	_, stderrOut, code = runCLI("record", "--brokers", "localhost:19999", "--topic", "t", "--output", filepath.Join(t.TempDir(), "out.bin"), "--append", "--group", "g")
+	if code != 1 {
+		t.Errorf("record --append --group: expected exit 1, got %d", code)
+	}
+	if !strings.Contains(string(stderrOut), "--append and --group cannot be used together") {
+		t.Errorf("stderr should explain that --append needs direct partition access; got %q", string(stderrOut))
+	}
+}
+
+func TestCLI_Cat_SchemaRegistry(t *testing.T) {
+	// Fake registry with an Avro schema (ID 7)
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schemas/ids/7" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+			return
+		}
+		w.Write([]byte(`{"schema":"{\"type\":\"record\",\"name\":\"Order\",\"fields\":[{\"name\":\"id\",\"type\":\"string\"},{\"name\":\"amount\",\"type\":\"long\"}]}"}`))
+	}))
+	defer registry.Close()
+
+	avroOrder := []byte{0, 0, 0, 0, 7, 6, 'o', '-', '1', 0x90, 0x03} // Schema 7: {"id": "o-1", "amount": 200}
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Key: []byte("k0"), Data: avroOrder, Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(1, 0), Key: []byte("k1"), Data: []byte(`plain`), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+
+	stdout, stderr, code := runCLI("cat", "--input", path, "--schema-registry", registry.URL)
+	if code != 0 {
+		t.Fatalf("cat --schema-registry: exit %d, stderr %q", code, string(stderr))
+	}
+	lines := strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", string(stdout))
+	}
+	var decoded struct {
+		Key           string          `json:"key"`
+		Data          json.RawMessage `json:"data"`
+		ValueEncoding string          `json:"valueEncoding"`
+		ValueSchemaID *int            `json:"valueSchemaId"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil {
+		t.Fatalf("invalid JSON line %q: %v", lines[0], err)
+	}
+	if string(decoded.Data) != `{"id":"o-1","amount":200}` || decoded.ValueSchemaID == nil || *decoded.ValueSchemaID != 7 || decoded.Key != "k0" || decoded.ValueEncoding != "schema-decoded" {
+		t.Errorf("unexpected decoded message: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"data":"plain"`) || strings.Contains(lines[1], "SchemaId") || strings.Contains(lines[1], "Encoding") {
+		t.Errorf("messages that are not framed should be kept as strings: %s", lines[1])
+	}
+
+	// Decoded values are not the recorded bytes, so import refuses them
+	jsonl := filepath.Join(t.TempDir(), "decoded.jsonl")
+	if err := os.WriteFile(jsonl, stdout, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code = runCLI("import", "--input", jsonl, "--output", filepath.Join(t.TempDir(), "imported.bin"))
+	if code != 1 || !strings.Contains(string(stderr), "message 1: invalid valueEncoding: schema-decoded") {
+		t.Errorf("import of decoded values: expected exit 1 with a schema-decoded error, got %d and %q", code, string(stderr))
+	}
+
+	// Keys of Java's LongSerializer start with zero bytes: they are only decoded with --schema-registry-keys, and
+	// data with an unknown schema ID or that does not match its schema is written in its encoding
+	longKey := []byte{0, 0, 0, 0, 0, 0, 0, 0x2a}
+	lookalikes := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Key: longKey, Data: avroOrder, Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(1, 0), Key: longKey, Data: []byte{0, 0, 0, 0, 9, 0}, Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(2, 0), Key: longKey, Data: []byte{0, 0, 0, 0, 7, 0xff}, Partition: -1, Offset: -1},
+	)
+	defer os.Remove(lookalikes)
+	want := `{"timestamp":"1970-01-01T00:00:00Z","timestampType":"CreateTime","key":"000000000000002a","data":{"id":"o-1","amount":200},"keyEncoding":"hex","valueEncoding":"schema-decoded","valueSchemaId":7}
+{"timestamp":"1970-01-01T00:00:01Z","timestampType":"CreateTime","key":"000000000000002a","data":"AAAAAAkA","keyEncoding":"hex","valueEncoding":"base64"}
+{"timestamp":"1970-01-01T00:00:02Z","timestampType":"CreateTime","key":"000000000000002a","data":"AAAAAAf/","keyEncoding":"hex","valueEncoding":"base64"}
+`
+	for _, args := range [][]string{nil, {"--schema-registry-keys"}} {
+		args = append([]string{"cat", "--input", lookalikes, "--schema-registry", registry.URL, "--key-encoding", "hex", "--value-encoding", "base64", "--show-encoding"}, args...)
+		stdout, stderr, code = runCLI(args...)
+		if code != 0 {
+			t.Fatalf("cat %v: exit %d, stderr %q", args, code, string(stderr))
+		}
+		if string(stdout) != want {
+			t.Errorf("cat %v:\n%s\nwant:\n%s", args, stdout, want)
+		}
+	}
+
+	// Other registry errors still stop cat
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error_code":50001,"message":"Error in the backend datastore"}`))
+	}))
+	defer failing.Close()
+	_, stderr, code = runCLI("cat", "--input", path, "--schema-registry", failing.URL)
+	if code != 1 || !strings.Contains(string(stderr), "backend datastore") {
+		t.Errorf("failing registry: expected exit 1 with the registry error, got %d and %q", code, string(stderr))
+	}
+	_, stderr, code = runCLI("cat", "--input", path, "--schema-registry-keys")
+	if code != 1 || !strings.Contains(string(stderr), "--schema-registry-keys requires --schema-registry") {
+		t.Errorf("--schema-registry-keys alone: expected exit 1, got %d and %q", code, string(stderr))
+	}
+	_, stderr, code = runCLI("cat", "--input", path, "--schema-registry", registry.URL, "--format", "raw")
+	if code != 1 || !strings.Contains(string(stderr), "--schema-registry requires --format json") {
+		t.Errorf("raw format: expected exit 1, got %d and %q", code, string(stderr))
+	}
+}
+
+func TestCLI_Export(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.UnixMilli(1500), Topic: "orders", Key: []byte("k0"), Data: []byte{0xff, 0}, Partition: 1, Offset: 9},
+		&transcoder.Entry{Timestamp: time.UnixMilli(2500), Data: []byte("second"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+
+	stdout, stderr, code := runCLI("export", "--input", path, "--columns", "timestamp,partition,key,value", "--key-encoding", "utf8", "--value-encoding", "hex")
+	if code != 0 {
+		t.Fatalf("export: exit %d, stderr %q", code, string(stderr))
+	}
+	want := `{"timestamp":"1970-01-01T00:00:01.500Z","partition":1,"key":"k0","value":"ff00"}
+{"timestamp":"1970-01-01T00:00:02.500Z","partition":null,"key":null,"value":"7365636f6e64"}
+`
+	if string(stdout) != want {
+		t.Errorf("export to JSON lines:\n%s\nwant:\n%s", stdout, want)
+	}
+	if !strings.Contains(string(stderr), "Exported 2 messages to standard output (jsonl)") {
+		t.Errorf("stderr should report the export; got %q", string(stderr))
+	}
+
+	// The format follows the output extension
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		file   string
+		prefix string
+	}{
+		{"messages.csv", "timestamp,topic,partition,offset,key,value,headers\n1970-01-01T00:00:01.500Z,orders,1,9,azA=,/wA=,\n"},
+		{"messages.parquet", "PAR1"},
+	} {
+		output := filepath.Join(dir, tc.file)
+		if _, stderr, code := runCLI("export", "--input", path, "--output", output, "--quiet"); code != 0 {
+			t.Fatalf("export --output %s: exit %d, stderr %q", tc.file, code, string(stderr))
+		}
+		exported, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(exported), tc.prefix) {
+			t.Errorf("export --output %s: expected %q at the start, got %q", tc.file, tc.prefix, exported)
+		}
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--format", "avro"}, "unsupported export format"},
+		{[]string{"--columns", "payload"}, "unknown column"},
+		{[]string{"--value-encoding", "base32"}, "invalid --value-encoding"},
+	} {
+		args := append([]string{"export", "--input", path}, tc.args...)
+		_, stderr, code := runCLI(args...)
+		if code != 1 || !strings.Contains(string(stderr), tc.want) {
+			t.Errorf("export %v: expected exit 1 with %q, got %d and %q", tc.args, tc.want, code, string(stderr))
+		}
+	}
+}
+
+func TestCLI_Cat_Encodings(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{
+			Timestamp: time.UnixMilli(1500), Key: []byte("k<1>"), Data: []byte(`{"id":1}`), Partition: -1, Offset: -1,
+			Headers: []transcoder.Header{{Key: "bin", Value: []byte{0xff}}},
+		},
+		&transcoder.Entry{Timestamp: time.UnixMilli(2500), Key: []byte{0, 0xfe}, Data: []byte{0x0a, 0xff, 0}, Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+
+	stdout, stderr, code := runCLI("cat", "--input", path, "--key-encoding", "hex", "--value-encoding", "json-embedded", "--show-encoding")
+	if code != 0 {
+		t.Fatalf("cat: exit %d, stderr %q", code, string(stderr))
+	}
+	want := `{"timestamp":"1970-01-01T00:00:01.5Z","timestampType":"CreateTime","key":"6b3c313e","data":{"id":1},"keyEncoding":"hex","valueEncoding":"json-embedded","headers":[{"key":"bin","value":"/w==","encoding":"base64"}]}
+{"timestamp":"1970-01-01T00:00:02.5Z","timestampType":"CreateTime","key":"00fe","data":"Cv8A","keyEncoding":"hex","valueEncoding":"base64"}
+`
+	if string(stdout) != want {
+		t.Errorf("cat with encodings:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	// The reported encodings import back to the same bytes
+	dir := t.TempDir()
+	jsonl := filepath.Join(dir, "messages.jsonl")
+	if err := os.WriteFile(jsonl, stdout, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	recording := filepath.Join(dir, "imported.bin")
+	if _, stderr, code := runCLI("import", "--input", jsonl, "--output", recording, "--quiet"); code != 0 {
+		t.Fatalf("import: exit %d, stderr %q", code, string(stderr))
+	}
+	for _, args := range [][]string{{"--value-encoding", "base64"}, {"--key-encoding", "base64", "--value-encoding", "hex"}} {
+		original, _, _ := runCLI(append([]string{"cat", "--input", path}, args...)...)
+		imported, _, _ := runCLI(append([]string{"cat", "--input", recording}, args...)...)
+		if string(imported) != string(original) {
+			t.Errorf("cat %v after import:\n%s\nwant:\n%s", args, imported, original)
+		}
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--value-encoding", "json"}, "invalid --value-encoding"},
+		{[]string{"--format", "raw", "--show-encoding"}, "--show-encoding requires --format json"},
+	} {
+		_, stderr, code := runCLI(append([]string{"cat", "--input", path}, tc.args...)...)
+		if code != 1 || !strings.Contains(string(stderr), tc.want) {
+			t.Errorf("cat %v: expected exit 1 with %q, got %d and %q", tc.args, tc.want, code, string(stderr))
+		}
+	}
+}
+
+// TestCLI_Cat_Import_Binary tests that binary keys and values printed by cat with the default flags import back
+// to the same bytes
+func TestCLI_Cat_Import_Binary(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{
+			Timestamp: time.UnixMilli(1500), Key: []byte{0, 0xfe}, Data: []byte{0x0a, 0xff, 0}, Partition: -1, Offset: -1,
+			Headers: []transcoder.Header{{Key: "bin", Value: []byte{0xff}}, {Key: "trace", Value: []byte("t-1")}},
+		},
+		&transcoder.Entry{Timestamp: time.UnixMilli(2500), Key: []byte("k1"), Data: []byte("text"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+
+	stdout, stderr, code := runCLI("cat", "--input", path)
+	if code != 0 {
+		t.Fatalf("cat: exit %d, stderr %q", code, string(stderr))
+	}
+	want := `{"timestamp":"1970-01-01T00:00:01.5Z","timestampType":"CreateTime","key":"AP4=","data":"Cv8A","keyEncoding":"base64","valueEncoding":"base64","headers":[{"key":"bin","value":"/w==","encoding":"base64"},{"key":"trace","value":"t-1"}]}
+{"timestamp":"1970-01-01T00:00:02.5Z","timestampType":"CreateTime","key":"k1","data":"text"}
+`
+	if string(stdout) != want {
+		t.Errorf("cat:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	dir := t.TempDir()
+	jsonl := filepath.Join(dir, "messages.jsonl")
+	if err := os.WriteFile(jsonl, stdout, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	recording := filepath.Join(dir, "imported.bin")
+	if _, stderr, code := runCLI("import", "--input", jsonl, "--output", recording, "--quiet"); code != 0 {
+		t.Fatalf("import: exit %d, stderr %q", code, string(stderr))
+	}
+	args := []string{"--key-encoding", "hex", "--value-encoding", "hex"}
+	original, _, _ := runCLI(append([]string{"cat", "--input", path}, args...)...)
+	imported, _, _ := runCLI(append([]string{"cat", "--input", recording}, args...)...)
+	if string(imported) != string(original) {
+		t.Errorf("cat %v after import:\n%s\nwant:\n%s", args, imported, original)
+	}
+}
+
+// TestCLI_Cat_Import_JSONEmbedded tests that keys and values printed by cat with json-embedded import back to the
+// same bytes: compact JSON and JSON strings with their quotes, and other JSON as text
+func TestCLI_Cat_Import_JSONEmbedded(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{
+			Timestamp: time.UnixMilli(1500), Key: []byte(`"k1"`), Data: []byte(`{"id": 1,` + "\n" + ` "note": "<b>"}`), Partition: -1, Offset: -1,
+			Headers: []transcoder.Header{{Key: "h", Value: []byte(`"t-1"`)}},
+		},
+		&transcoder.Entry{Timestamp: time.UnixMilli(2500), Key: []byte(" 7"), Data: []byte(`{"id":2,"note":"<b>","n":1.50}`), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.UnixMilli(3500), Data: []byte(`"text"`), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+
+	stdout, stderr, code := runCLI("cat", "--input", path, "--key-encoding", "json-embedded", "--value-encoding", "json-embedded")
+	if code != 0 {
+		t.Fatalf("cat: exit %d, stderr %q", code, string(stderr))
+	}
+	want := `{"timestamp":"1970-01-01T00:00:01.5Z","timestampType":"CreateTime","key":"k1","data":"{\"id\": 1,\n \"note\": \"<b>\"}","keyEncoding":"json-embedded","valueEncoding":"utf8","headers":[{"key":"h","value":"t-1","encoding":"json-embedded"}]}
+{"timestamp":"1970-01-01T00:00:02.5Z","timestampType":"CreateTime","key":" 7","data":{"id":2,"note":"<b>","n":1.50},"keyEncoding":"utf8","valueEncoding":"json-embedded"}
+{"timestamp":"1970-01-01T00:00:03.5Z","timestampType":"CreateTime","key":"","data":"text","keyEncoding":"utf8","valueEncoding":"json-embedded"}
+`
+	if string(stdout) != want {
+		t.Errorf("cat:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	dir := t.TempDir()
+	jsonl := filepath.Join(dir, "messages.jsonl")
+	if err := os.WriteFile(jsonl, stdout, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	recording := filepath.Join(dir, "imported.bin")
+	if _, stderr, code := runCLI("import", "--input", jsonl, "--output", recording, "--quiet"); code != 0 {
+		t.Fatalf("import: exit %d, stderr %q", code, string(stderr))
+	}
+	args := []string{"--key-encoding", "hex", "--value-encoding", "hex"}
+	original, _, _ := runCLI(append([]string{"cat", "--input", path}, args...)...)
+	imported, _, _ := runCLI(append([]string{"cat", "--input", recording}, args...)...)
+	if string(imported) != string(original) {
+		t.Errorf("cat %v after import:\n%s\nwant:\n%s", args, imported, original)
+	}
+}
+
+func TestCLI_Import(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{
+			Timestamp: time.UnixMilli(1500), Topic: "orders", Key: []byte("k0"), Data: []byte(`{"id":1}`), Partition: 1, Offset: 9,
+			Headers: []transcoder.Header{{Key: "trace", Value: []byte("t-1")}},
+		},
+		&transcoder.Entry{Timestamp: time.UnixMilli(2500), Data: []byte("second"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+	catted, stderr, code := runCLI("cat", "--input", path)
+	if code != 0 {
+		t.Fatalf("cat: exit %d, stderr %q", code, string(stderr))
+	}
+
+	// What cat prints imports back to the same messages
+	dir := t.TempDir()
+	jsonl := filepath.Join(dir, "messages.jsonl")
+	if err := os.WriteFile(jsonl, catted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	recording := filepath.Join(dir, "imported.bin")
+	_, stderr, code = runCLI("import", "--input", jsonl, "--output", recording, "--compression", "gzip", "--index")
+	if code != 0 {
+		t.Fatalf("import: exit %d, stderr %q", code, string(stderr))
+	}
+	if !strings.Contains(string(stderr), "Imported 2 messages to "+recording) {
+		t.Errorf("stderr should report the import; got %q", string(stderr))
+	}
+	if _, err := os.Stat(recording + ".idx"); err != nil {
+		t.Errorf("expected an index next to the recording: %v", err)
+	}
+	stdout, stderr, code := runCLI("cat", "--input", recording)
+	if code != 0 {
+		t.Fatalf("cat imported: exit %d, stderr %q", code, string(stderr))
+	}
+	if string(stdout) != string(catted) {
+		t.Errorf("round trip through import gave:\n%s\nwant:\n%s", stdout, catted)
+	}
+
+	// CSV with base64 keys and values, as export writes it
+	csvPath := filepath.Join(dir, "messages.csv")
+	if err := os.WriteFile(csvPath, []byte("timestamp,topic,key,value\n1970-01-01T00:00:01.500Z,orders,AP8=,aGk=\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code = runCLI("import", "--input", csvPath, "--output", recording, "--key-encoding", "base64", "--value-encoding", "base64", "--quiet")
+	if code != 0 {
+		t.Fatalf("import CSV: exit %d, stderr %q", code, string(stderr))
+	}
+	stdout, stderr, code = runCLI("export", "--input", recording, "--columns", "topic,key,value", "--key-encoding", "hex", "--value-encoding", "utf8")
+	if code != 0 {
+		t.Fatalf("export imported CSV: exit %d, stderr %q", code, string(stderr))
+	}
+	if want := `{"topic":"orders","key":"00ff","value":"hi"}` + "\n"; string(stdout) != want {
+		t.Errorf("imported CSV exported as %q, want %q", stdout, want)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--input", jsonl, "--output", "-", "--index"}, "--index cannot be used together with --output -"},
+		{[]string{"--input", jsonl, "--output", recording, "--format", "parquet"}, "unsupported import format"},
+		{[]string{"--input", jsonl, "--output", recording, "--key-encoding", "base32"}, "invalid --key-encoding"},
+		{[]string{"--input", jsonl, "--output", recording, "--format", "csv"}, "invalid CSV header row"},
+	} {
+		_, stderr, code := runCLI(append([]string{"import"}, tc.args...)...)
+		if code != 1 || !strings.Contains(string(stderr), tc.want) {
+			t.Errorf("import %v: expected exit 1 with %q, got %d and %q", tc.args, tc.want, code, string(stderr))
+		}
+	}
+}
+
 func TestCLI_ExitCode_Usage(t *testing.T) {
 	_, _, code := runCLI("list", "brokers") // no brokers
 	if code != 1 {
@@ -193,6 +831,124 @@ func TestCLI_Record(t *testing.T) {
 	}
 }
 
+func TestCLI_Record_Append(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Data: []byte("a"), Topic: "orders", Partition: 0, Offset: 41},
+		&transcoder.Entry{Timestamp: time.Unix(1, 0), Data: []byte("b"), Topic: "orders", Partition: 0, Offset: 42},
+	)
+	defer os.Remove(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A partially written message at the end is discarded before connecting to the cluster
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 2, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, stderr, code := runCLI("record", "--brokers", "localhost:19999", "--topic", "orders", "--output", path, "--append")
+	if code == 0 {
+		t.Fatalf("record without a reachable cluster should fail; stderr %q", string(stderr))
+	}
+	if !strings.Contains(string(stderr), "Discarded 9 bytes") {
+		t.Errorf("stderr should report the discarded bytes; got %q", string(stderr))
+	}
+	if truncated, err := os.Stat(path); err != nil || truncated.Size() != info.Size() {
+		t.Errorf("file should be truncated to its complete messages (%d bytes): %v, %v", info.Size(), truncated, err)
+	}
+
+	// Appending with another compression is refused
+	_, stderr, code = runCLI("record", "--brokers", "localhost:19999", "--topic", "orders", "--output", path, "--append", "--compression", "zstd")
+	if code != 1 || !strings.Contains(string(stderr), "cannot append with compression zstd") {
+		t.Errorf("record --append --compression zstd: expected exit 1 with compression error, got %d %q", code, string(stderr))
+	}
+
+	// Only files in the current format version can be appended to
+	old := filepath.Join(t.TempDir(), "old.log")
+	if err := os.WriteFile(old, append([]byte{0, 0, 0, 2}, make([]byte, transcoder.HeaderSize-4)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code = runCLI("record", "--brokers", "localhost:19999", "--topic", "orders", "--output", old, "--append")
+	if code != 1 || !strings.Contains(string(stderr), "format version 2") {
+		t.Errorf("record --append to a version 2 file: expected exit 1 with version error, got %d %q", code, string(stderr))
+	}
+}
+
+func TestCLI_Record_MaskAppend(t *testing.T) {
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	rules := "rules:\n  - field: .email\n    action: hash\n"
+	if err := os.WriteFile(rulesPath, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	masker, err := mask.Parse([]byte(rules), []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := createEntryFile(t, &transcoder.Entry{Timestamp: time.Unix(0, 0), Data: []byte("a"), Topic: "orders", Partition: 0, Offset: 1})
+	defer os.Remove(plain)
+	sanitized := filepath.Join(dir, "sanitized.log")
+	f, err := os.Create(sanitized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := transcoder.NewSanitizedEncodeWriter(f, transcoder.CompressionNone, &transcoder.Sanitization{RuleSet: masker.RuleSet()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.WriteEntry(&transcoder.Entry{Timestamp: time.Unix(0, 0), Data: []byte(`{"email":"masked"}`), Topic: "orders", Partition: 0, Offset: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(output string, args ...string) ([]byte, int) {
+		_, stderr, code := runCLI(append([]string{"record", "--brokers", "localhost:19999", "--topic", "orders", "--output", output, "--append"}, args...)...)
+		return stderr, code
+	}
+	for _, tc := range []struct {
+		output string
+		args   []string
+		want   string
+	}{
+		{plain, []string{"--mask", rulesPath}, "cannot append masked messages to a recording that is not sanitized"},
+		{sanitized, nil, "append with the same masking rules"},
+		{sanitized, []string{"--mask", rulesPath, "--mask-secret", "other"}, "is sanitized with rule set " + masker.RuleSet().String()},
+		{plain, []string{"--mask-secret", "s3cret"}, "--mask-secret requires --mask"},
+	} {
+		stderr, code := record(tc.output, tc.args...)
+		if code != 1 || !strings.Contains(string(stderr), tc.want) {
+			t.Errorf("record --append %v: expected exit 1 with %q, got %d %q", tc.args, tc.want, code, string(stderr))
+		}
+	}
+	// The same rules and secret pass the check (and fail without a reachable cluster)
+	stderr, code := record(sanitized, "--mask", rulesPath, "--mask-secret", "s3cret")
+	if code == 0 || strings.Contains(string(stderr), "masking rules") {
+		t.Errorf("record --append with the same masking rules: unexpected exit %d, stderr %q", code, string(stderr))
+	}
+
+	if err := os.WriteFile(rulesPath, []byte("rules:\n  - field: .email\n    action: encrypt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stderr, code = record(plain, "--mask", rulesPath)
+	if code != 1 || !strings.Contains(string(stderr), "invalid masking rule 1") {
+		t.Errorf("record with invalid masking rules: expected exit 1, got %d %q", code, string(stderr))
+	}
+
+	_, stderr, code = runCLI("replay", "--brokers", "localhost:19999", "--input", sanitized, "--topic", "t", "--dry-run")
+	if code != 0 || !strings.Contains(string(stderr), "Recording sanitized with masking rule set "+masker.RuleSet().String()) {
+		t.Errorf("replay of a sanitized recording: unexpected exit %d, stderr %q", code, string(stderr))
+	}
+}
+
 func TestCLI_Replay(t *testing.T) {
 	// Missing required --topic: exit 1
 	_, stderr, code := runCLI("replay", "--input", "/dev/null")
@@ -202,6 +958,278 @@ func TestCLI_Replay(t *testing.T) {
 	if !strings.Contains(string(stderr), "topic") && !strings.Contains(string(stderr), "required") {
 		t.Errorf("stderr should mention topic/required; got %q", string(stderr))
 	}
+
+	// Missing required --input: exit 1
+	_, stderr, code = runCLI("replay", "--topic", "t")
+	if code != 1 {
+		t.Errorf("expected exit 1 without --input, got %d", code)
+	}
+	if !strings.Contains(string(stderr), "input") && !strings.Contains(string(stderr), "required") {
+		t.Errorf("stderr should mention input/required; got %q", string(stderr))
+	}
+}
+
+func TestCLI_Replay_InvalidTopicMap(t *testing.T) {
+	// Malformed rename: exit 1
+	_, stderr, code := runCLI("replay", "--input", "/dev/null", "--topic-map", "orders")
+	if code != 1 {
+		t.Errorf("expected exit 1 for malformed --topic-map, got %d", code)
+	}
+	if !strings.Contains(string(stderr), "topic-map") {
+		t.Errorf("stderr should mention topic-map; got %q", string(stderr))
+	}
+
+	// A fixed target topic cannot be combined with renames
+	_, stderr, code = runCLI("replay", "--input", "/dev/null", "--topic", "t", "--topic-map", "orders=orders-copy")
+	if code != 1 {
+		t.Errorf("expected exit 1 for --topic with --topic-map, got %d", code)
+	}
+	if !strings.Contains(string(stderr), "cannot be used together") {
+		t.Errorf("stderr should explain the conflict; got %q", string(stderr))
+	}
+
+	// Replaying to the recorded topics is an explicit choice
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--topic", "t", "--original-topics"}, "--topic and --original-topics cannot be used together"},
+		{[]string{"--topic-map", "orders=orders-copy"}, "--topic-map requires --original-topics"},
+	} {
+		_, stderr, code := runCLI(append([]string{"replay", "--input", "/dev/null"}, tc.args...)...)
+		if code != 1 || !strings.Contains(string(stderr), tc.want) {
+			t.Errorf("replay %v: expected exit 1 with %q, got %d and %q", tc.args, tc.want, code, string(stderr))
+		}
+	}
+}
+
+func TestCLI_Replay_InvalidTiming(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--speed", "2"}, "require --original-timing"},
+		{[]string{"--original-timing", "--rate", "10"}, "cannot be used together"},
+		{[]string{"--original-timing", "--speed", "0"}, "--speed must be greater than 0"},
+	} {
+		args := append([]string{"replay", "--brokers", "localhost:19999", "--input", "/dev/null", "--topic", "t"}, tc.args...)
+		_, stderr, code := runCLI(args...)
+		if code != 1 {
+			t.Errorf("replay %v: expected exit 1, got %d", tc.args, code)
+		}
+		if !strings.Contains(string(stderr), tc.want) {
+			t.Errorf("replay %v: stderr should contain %q; got %q", tc.args, tc.want, string(stderr))
+		}
+	}
+}
+
+func TestCLI_Replay_InvalidPartitionRouting(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--partition-strategy", "modulo"}, "require --preserve-partitions"},
+		{[]string{"--preserve-partitions", "--partition", "1"}, "cannot be used together"},
+		{[]string{"--preserve-partitions", "--partition-strategy", "random"}, "unsupported partition strategy"},
+		{[]string{"--preserve-partitions", "--partition-map", "3=x"}, "invalid --partition-map entry"},
+		{[]string{"--balancer", "fnv"}, "unsupported balancer"},
+		{[]string{"--balancer", "murmur2", "--preserve-partitions"}, "--balancer cannot be used together"},
+		{[]string{"--balancer", "sticky", "--partition", "0"}, "--balancer cannot be used together"},
+	} {
+		args := append([]string{"replay", "--brokers", "localhost:19999", "--input", "/dev/null", "--topic", "t"}, tc.args...)
+		_, stderr, code := runCLI(args...)
+		if code != 1 {
+			t.Errorf("replay %v: expected exit 1, got %d", tc.args, code)
+		}
+		if !strings.Contains(string(stderr), tc.want) {
+			t.Errorf("replay %v: stderr should contain %q; got %q", tc.args, tc.want, string(stderr))
+		}
+	}
+
+	// The partition count of the target topic is checked through the cluster metadata before sending
+	path := createEntryFile(t, &transcoder.Entry{Timestamp: time.Unix(0, 0), Data: []byte("a"), Topic: "orders", Partition: 2, Offset: 7})
+	defer os.Remove(path)
+	_, stderr, code := runCLI("replay", "--brokers", "localhost:19999", "--input", path, "--topic", "t", "--dry-run", "--preserve-partitions")
+	if code != 1 {
+		t.Errorf("replay without a reachable cluster: expected exit 1, got %d", code)
+	}
+	if !strings.Contains(string(stderr), "partition count of topic 't'") {
+		t.Errorf("stderr should mention the partition count lookup; got %q", string(stderr))
+	}
+}
+
+func TestCLI_Replay_InvalidSchemaRemap(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--source-schema-registry", "http://localhost:18081"}, "must be used together"},
+		{[]string{"--subject-strategy", "record"}, "--subject-strategy requires --source-schema-registry"},
+		{[]string{"--schema-remap-keys"}, "--schema-remap-keys requires --source-schema-registry"},
+		{[]string{"--skip-unknown-schemas"}, "--skip-unknown-schemas requires --source-schema-registry"},
+		{[]string{"--source-schema-registry", "http://localhost:18081", "--target-schema-registry", "localhost:18082"}, "invalid schema registry URL"},
+		{[]string{"--source-schema-registry", "http://localhost:18081", "--target-schema-registry", "http://localhost:18082", "--subject-strategy", "name"}, "invalid subject strategy"},
+	} {
+		args := append([]string{"replay", "--brokers", "localhost:19999", "--input", "/dev/null", "--topic", "t"}, tc.args...)
+		_, stderr, code := runCLI(args...)
+		if code != 1 {
+			t.Errorf("replay %v: expected exit 1, got %d", tc.args, code)
+		}
+		if !strings.Contains(string(stderr), tc.want) {
+			t.Errorf("replay %v: stderr should contain %q; got %q", tc.args, tc.want, string(stderr))
+		}
+	}
+}
+
+func TestCLI_Replay_Resume(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Data: []byte("a"), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(1, 0), Data: []byte("b"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpointPath := filepath.Join(t.TempDir(), "replay.checkpoint")
+	replay := func(args ...string) ([]byte, int) {
+		args = append([]string{"replay", "--brokers", "localhost:19999", "--input", path, "--topic", "t", "--checkpoint-file", checkpointPath}, args...)
+		_, stderr, code := runCLI(args...)
+		return stderr, code
+	}
+
+	// Without a checkpoint, the replay starts from the first message (dry runs do not write checkpoints)
+	stderr, code := replay("--resume", "--dry-run")
+	if code != 0 {
+		t.Fatalf("replay --resume without checkpoint: exit %d, stderr %q", code, string(stderr))
+	}
+	if !strings.Contains(string(stderr), "No checkpoint") || !strings.Contains(string(stderr), "validated 2 messages") {
+		t.Errorf("replay --resume without checkpoint: unexpected stderr %q", string(stderr))
+	}
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("dry run should not write a checkpoint: %v", err)
+	}
+
+	// A checkpoint at the second entry resumes there
+	writeCheckpoint := func(checkpoint pkg.Checkpoint) {
+		t.Helper()
+		f, err := os.Create(checkpointPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := pkg.WriteCheckpoint(f, checkpoint); err != nil {
+			t.Fatal(err)
+		}
+	}
+	firstEntrySize := int64(transcoder.HeaderSize) + (info.Size()-int64(transcoder.HeaderSize))/2
+	writeCheckpoint(pkg.Checkpoint{InputSize: info.Size(), Entry: 1, Offset: firstEntrySize, OffsetEntry: 1, Messages: 1})
+	stderr, code = replay("--resume", "--dry-run")
+	if code != 0 {
+		t.Fatalf("replay --resume: exit %d, stderr %q", code, string(stderr))
+	}
+	if !strings.Contains(string(stderr), "Resuming at entry 1") || !strings.Contains(string(stderr), "validated 1 messages") {
+		t.Errorf("replay --resume: unexpected stderr %q", string(stderr))
+	}
+
+	// Checkpoints of another file or of a completed replay are refused
+	for _, tc := range []struct {
+		checkpoint pkg.Checkpoint
+		want       string
+	}{
+		{pkg.Checkpoint{InputSize: info.Size() + 1, Entry: 1, Offset: firstEntrySize, OffsetEntry: 1}, "was written for a file of"},
+		{pkg.Checkpoint{InputSize: info.Size(), Entry: 2, Offset: info.Size(), OffsetEntry: 2, Completed: true}, "has completed"},
+	} {
+		writeCheckpoint(tc.checkpoint)
+		stderr, code = replay("--resume", "--dry-run")
+		if code != 1 {
+			t.Errorf("replay --resume with checkpoint %+v: expected exit 1, got %d", tc.checkpoint, code)
+		}
+		if !strings.Contains(string(stderr), tc.want) {
+			t.Errorf("replay --resume with checkpoint %+v: stderr should contain %q; got %q", tc.checkpoint, tc.want, string(stderr))
+		}
+	}
+
+	stderr, code = replay("--resume", "--loop")
+	if code != 1 || !strings.Contains(string(stderr), "cannot be used together with --loop") {
+		t.Errorf("replay --resume --loop: expected exit 1 with conflict, got %d %q", code, string(stderr))
+	}
+}
+
+func TestCLI_Replay_OriginalTiming_DryRun(t *testing.T) {
+	// Gaps of 2s and 2s (the third message goes back in time, as from another partition, and is due immediately)
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.Unix(100, 0), Data: []byte("a"), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(102, 0), Data: []byte("b"), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(101, 0), Data: []byte("c"), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(104, 0), Data: []byte("d"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+
+	for _, tc := range []struct {
+		args     []string
+		min, max time.Duration
+	}{
+		{[]string{"--speed", "4"}, 900 * time.Millisecond, 5 * time.Second},
+		{[]string{"--speed", "4", "--max-wait", "100ms"}, 150 * time.Millisecond, 900 * time.Millisecond},
+	} {
+		args := append([]string{"replay", "--brokers", "localhost:19999", "--input", path, "--topic", "t", "--dry-run", "--original-timing"}, tc.args...)
+		start := time.Now()
+		_, stderr, code := runCLI(args...)
+		elapsed := time.Since(start)
+		if code != 0 {
+			t.Fatalf("replay %v: exit %d, stderr %q", tc.args, code, string(stderr))
+		}
+		if !strings.Contains(string(stderr), "validated 4 messages") {
+			t.Errorf("replay %v: stderr should report 4 messages; got %q", tc.args, string(stderr))
+		}
+		if elapsed < tc.min || elapsed > tc.max {
+			t.Errorf("replay %v: took %s, expected between %s and %s", tc.args, elapsed, tc.min, tc.max)
+		}
+	}
+}
+
+func TestCLI_Replay_Transforms_DryRun(t *testing.T) {
+	path := createEntryFile(t,
+		&transcoder.Entry{Timestamp: time.Unix(0, 0), Key: []byte("k0"), Data: []byte(`{"type":"OrderCreated","tenant":"prod"}`), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(1, 0), Key: []byte("k1"), Data: []byte(`{"type":"OrderPaid","tenant":"prod"}`), Partition: -1, Offset: -1},
+		&transcoder.Entry{Timestamp: time.Unix(2, 0), Key: []byte("k2"), Data: []byte("not json"), Partition: -1, Offset: -1},
+	)
+	defer os.Remove(path)
+
+	transforms := filepath.Join(t.TempDir(), "transforms.yaml")
+	writeTransforms := func(yaml string) {
+		t.Helper()
+		if err := os.WriteFile(transforms, []byte(yaml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replay := func(args ...string) ([]byte, int) {
+		_, stderr, code := runCLI(append([]string{"replay", "--brokers", "localhost:19999", "--input", path, "--topic", "t", "--dry-run", "--transforms", transforms}, args...)...)
+		return stderr, code
+	}
+
+	writeTransforms(`
+transforms:
+  - set: .tenant
+    value: staging
+  - set: header["x-replayed-by"]
+    value: kafka-replay
+    where: 'timestamp >= "1970-01-01T00:00:01Z"'
+`)
+	stderr, code := replay("--filter", `.tenant == "prod"`)
+	if code != 0 {
+		t.Fatalf("replay --transforms: exit %d, stderr %q", code, string(stderr))
+	}
+	if !strings.Contains(string(stderr), "Transforms: 2 steps") || !strings.Contains(string(stderr), "validated 2 messages") {
+		t.Errorf("replay --transforms: unexpected stderr %q", string(stderr))
+	}
+
+	writeTransforms("transforms:\n  - rename: .tenant\n")
+	stderr, code = replay()
+	if code != 1 || !strings.Contains(string(stderr), "invalid transform 1: rename requires to") {
+		t.Errorf("replay with an invalid transform: expected exit 1 with the error, got %d %q", code, string(stderr))
+	}
 }
 
 func TestCLI_Config(t *testing.T) {
@@ -253,6 +1281,132 @@ profiles:
 	}
 }
 
+func TestCLI_Config_LoadConfigFile_Security(t *testing.T) {
+	// TLS and SASL settings of the profile are resolved; --sasl-mechanism overrides the profile
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	const yamlContent = `
+default_profile: cloud
+profiles:
+  cloud:
+    brokers:
+      - kafka.example.com:9093
+    tls:
+      enabled: true
+    sasl:
+      mechanism: SCRAM-SHA-512
+      username: replay
+      password: secret
+`
+	if err := os.WriteFile(configPath, []byte(yamlContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runCLI("debug", "config", "--config", configPath)
+	if code != 0 {
+		t.Fatalf("debug config with file: exit %d, stderr %q", code, string(stderr))
+	}
+	out := string(stdout)
+	if !strings.Contains(out, "tls:           enabled  [from config profile \"cloud\"]") {
+		t.Errorf("output should show TLS enabled by the profile; got:\n%s", out)
+	}
+	if !strings.Contains(out, "sasl:          SCRAM-SHA-512  [from config profile \"cloud\"]") {
+		t.Errorf("output should show the SASL mechanism of the profile; got:\n%s", out)
+	}
+
+	stdout, stderr, code = runCLI("debug", "config", "--config", configPath, "--sasl-mechanism", "PLAIN")
+	if code != 0 {
+		t.Fatalf("debug config with --sasl-mechanism: exit %d, stderr %q", code, string(stderr))
+	}
+	if !strings.Contains(string(stdout), "sasl:          PLAIN  [from flags; overrides config profile \"cloud\"]") {
+		t.Errorf("output should show the SASL mechanism from flags; got:\n%s", string(stdout))
+	}
+}
+
+func TestCLI_Config_SecurityPrecedence(t *testing.T) {
+	// --tls* and --sasl-* flags override the settings of the profile one by one, the others come from the profile
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	yamlContent := `
+default_profile: cloud
+profiles:
+  cloud:
+    brokers:
+      - kafka.example.com:9093
+    tls:
+      enabled: true
+      ca_file: ` + filepath.Join(dir, "missing.pem") + `
+    sasl:
+      mechanism: GSSAPI
+      username: replay
+      password: secret
+`
+	if err := os.WriteFile(configPath, []byte(yamlContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		flags []string
+		want  []string
+	}{
+		{
+			name: "profile only",
+			want: []string{"security:      (invalid: failed to read TLS CA file"},
+		},
+		{
+			name:  "flags fix the profile",
+			flags: []string{"--tls-ca-file", "", "--sasl-mechanism", "scram-sha-256"},
+			want: []string{
+				"tls:           enabled  [from flags; overrides config profile \"cloud\"]",
+				"sasl:          SCRAM-SHA-256  [from flags; overrides config profile \"cloud\"]",
+			},
+		},
+		{
+			name:  "flags disable TLS",
+			flags: []string{"--tls=false", "--tls-ca-file", "", "--sasl-mechanism", "PLAIN"},
+			want:  []string{"tls:           (disabled)", "sasl:          PLAIN  [from flags; overrides config profile \"cloud\"]"},
+		},
+		{
+			name:  "flags clear the profile username",
+			flags: []string{"--tls-ca-file", "", "--sasl-mechanism", "PLAIN", "--sasl-username", ""},
+			want:  []string{"security:      (invalid: SASL mechanism PLAIN requires a username)"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout, stderr, code := runCLI(append([]string{"debug", "config", "--config", configPath}, tc.flags...)...)
+			if code != 0 {
+				t.Fatalf("debug config: exit %d, stderr %q", code, string(stderr))
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(string(stdout), want) {
+					t.Errorf("output should contain %q; got:\n%s", want, string(stdout))
+				}
+			}
+		})
+	}
+}
+
+func TestCLI_ListTopics_InvalidSecurity_Exit1(t *testing.T) {
+	// Invalid TLS/SASL settings are rejected before connecting
+	_, stderr, code := runCLI("list", "topics", "--brokers", "localhost:19999", "--sasl-mechanism", "GSSAPI", "--sasl-username", "u")
+	if code != 1 {
+		t.Errorf("expected exit 1 for unsupported SASL mechanism, got %d", code)
+	}
+	if !strings.Contains(string(stderr), "unsupported SASL mechanism") {
+		t.Errorf("stderr should mention the unsupported mechanism; got %q", string(stderr))
+	}
+
+	_, stderr, code = runCLI("list", "topics", "--brokers", "localhost:19999", "--tls-ca-file", filepath.Join(t.TempDir(), "missing.pem"))
+	if code != 1 {
+		t.Errorf("expected exit 1 for missing CA file, got %d", code)
+	}
+	if !strings.Contains(string(stderr), "TLS CA file") {
+		t.Errorf("stderr should mention the CA file; got %q", string(stderr))
+	}
+}
+
 func TestCLI_InspectTopic(t *testing.T) {
 	// Missing topic name: exit 1 or error
 	_, stderr, code := runCLI("--brokers", "localhost:19999", "inspect", "topic")
@@ -294,25 +1448,38 @@ func TestCLI_Version(t *testing.T) {
 	}
 }
 
-// createMessageFile writes a single message in v2 format and returns the path.
+// createMessageFile writes a single message in the current format and returns the path.
 func createMessageFile(t *testing.T, key, data []byte) string {
+	t.Helper()
+	return createEntryFile(t, &transcoder.Entry{Timestamp: time.Unix(0, 0), Key: key, Data: data})
+}
+
+// createEntryFile writes the given entries in the current format and returns the path.
+func createEntryFile(t *testing.T, entries ...*transcoder.Entry) string {
+	t.Helper()
+	return createCompressedEntryFile(t, transcoder.CompressionNone, entries...)
+}
+
+// createCompressedEntryFile writes the given entries in the current format with the given compression and returns the path.
+func createCompressedEntryFile(t *testing.T, compression transcoder.Compression, entries ...*transcoder.Entry) string {
 	t.Helper()
 	f, err := os.CreateTemp("", "kafka-replay-cat-*")
 	if err != nil {
 		t.Fatal(err)
 	}
 	path := f.Name()
-	enc, err := transcoder.NewEncodeWriter(f)
+	enc, err := transcoder.NewCompressedEncodeWriter(f, compression)
 	if err != nil {
 		f.Close()
 		os.Remove(path)
 		t.Fatal(err)
 	}
-	_, err = enc.Write(time.Unix(0, 0), data, key)
-	if err != nil {
-		f.Close()
-		os.Remove(path)
-		t.Fatal(err)
+	for _, entry := range entries {
+		if _, err := enc.WriteEntry(entry); err != nil {
+			f.Close()
+			os.Remove(path)
+			t.Fatal(err)
+		}
 	}
 	// enc.Close() closes the underlying file
 	if err := enc.Close(); err != nil {
@@ -19,6 +19,9 @@ func main() {
 			commands.RecordCommand(),
 			commands.ReplayCommand(),
 			commands.CatCommand(),
+			commands.ExportCommand(),
+			commands.ImportCommand(),
+			commands.IndexCommand(),
 			commands.InspectCommand(),
 			commands.DebugCommand(),
 			commands.VersionCommand(),
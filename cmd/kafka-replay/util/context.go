@@ -2,6 +2,7 @@ package util
 
 import (
 	"github.com/lolocompany/kafka-replay/v2/cmd/kafka-replay/config"
+	"github.com/lolocompany/kafka-replay/v2/pkg/kafka"
 	"github.com/urfave/cli/v3"
 )
 
@@ -21,6 +22,42 @@ func ResolveBrokers(cmd *cli.Command) ([]string, error) {
 	return config.ResolveBrokers(cmd.StringSlice("brokers"), cmd.String("profile"), c)
 }
 
+// ResolveSecurity returns the TLS and SASL settings for the current invocation.
+// Settings of the profile selected by --config and --profile are overridden by
+// the --tls* and --sasl-* flags that are set. Returns nil for plain TCP.
+func ResolveSecurity(cmd *cli.Command) (*kafka.Security, error) {
+	c, err := config.LoadConfig(cmd.String("config"))
+	if err != nil {
+		return nil, err
+	}
+	security := config.ResolveSecurity(cmd.String("profile"), c)
+	if cmd.IsSet("tls") {
+		security.TLS = cmd.Bool("tls")
+	}
+	if cmd.IsSet("tls-ca-file") {
+		security.CAFile = cmd.String("tls-ca-file")
+	}
+	if cmd.IsSet("tls-cert-file") {
+		security.CertFile = cmd.String("tls-cert-file")
+	}
+	if cmd.IsSet("tls-key-file") {
+		security.KeyFile = cmd.String("tls-key-file")
+	}
+	if cmd.IsSet("tls-insecure-skip-verify") {
+		security.InsecureSkipVerify = cmd.Bool("tls-insecure-skip-verify")
+	}
+	if cmd.IsSet("sasl-mechanism") {
+		security.SASLMechanism = cmd.String("sasl-mechanism")
+	}
+	if cmd.IsSet("sasl-username") {
+		security.SASLUsername = cmd.String("sasl-username")
+	}
+	if cmd.IsSet("sasl-password") {
+		security.SASLPassword = cmd.String("sasl-password")
+	}
+	return kafka.NewSecurity(security)
+}
+
 // GetFormat returns the global --format flag value from the command.
 // It may be empty if not set; callers should use output.ParseFormat with a
 // default (e.g. from TTY detection).
@@ -26,10 +26,58 @@ func GlobalFlags() []cli.Flag {
 			Sources: cli.EnvVars("KAFKA_REPLAY_BROKERS"),
 			Local:   false,
 		},
+		&cli.BoolFlag{
+			Name:    "tls",
+			Usage:   "Connect to the brokers over TLS (implied by the other --tls-* flags)",
+			Sources: cli.EnvVars("KAFKA_REPLAY_TLS"),
+			Local:   false,
+		},
+		&cli.StringFlag{
+			Name:    "tls-ca-file",
+			Usage:   "PEM file with the CA certificates used to verify the brokers (defaults to the system roots)",
+			Sources: cli.EnvVars("KAFKA_REPLAY_TLS_CA_FILE"),
+			Local:   false,
+		},
+		&cli.StringFlag{
+			Name:    "tls-cert-file",
+			Usage:   "PEM file with the TLS client certificate (requires --tls-key-file)",
+			Sources: cli.EnvVars("KAFKA_REPLAY_TLS_CERT_FILE"),
+			Local:   false,
+		},
+		&cli.StringFlag{
+			Name:    "tls-key-file",
+			Usage:   "PEM file with the private key of the TLS client certificate",
+			Sources: cli.EnvVars("KAFKA_REPLAY_TLS_KEY_FILE"),
+			Local:   false,
+		},
+		&cli.BoolFlag{
+			Name:    "tls-insecure-skip-verify",
+			Usage:   "Do not verify the broker certificates (insecure, for testing only)",
+			Sources: cli.EnvVars("KAFKA_REPLAY_TLS_INSECURE_SKIP_VERIFY"),
+			Local:   false,
+		},
+		&cli.StringFlag{
+			Name:    "sasl-mechanism",
+			Usage:   "SASL mechanism: PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512",
+			Sources: cli.EnvVars("KAFKA_REPLAY_SASL_MECHANISM"),
+			Local:   false,
+		},
+		&cli.StringFlag{
+			Name:    "sasl-username",
+			Usage:   "SASL username",
+			Sources: cli.EnvVars("KAFKA_REPLAY_SASL_USERNAME"),
+			Local:   false,
+		},
+		&cli.StringFlag{
+			Name:    "sasl-password",
+			Usage:   "SASL password (prefer the KAFKA_REPLAY_SASL_PASSWORD environment variable)",
+			Sources: cli.EnvVars("KAFKA_REPLAY_SASL_PASSWORD"),
+			Local:   false,
+		},
 		&cli.StringFlag{
 			Name:    "format",
 			Aliases: []string{"f"},
-			Usage:   "Global output format: table (default), json (one object per line), or raw (cat only)",
+			Usage:   "Global output format: table (default), json (one object per line), or raw (cat only); export uses jsonl, csv or parquet and import jsonl or csv",
 			Local:   false,
 		},
 		&cli.BoolFlag{
@@ -68,6 +68,18 @@ func (wc *writeCloser) Close() error {
 	return nil
 }
 
+// CountingReader wraps a Reader to count bytes for the spinner, keeping it seekable if it is a ReadSeeker.
+// If spinner is nil, the reader is returned unchanged (no counting).
+func CountingReader(reader io.Reader, spinner *ProgressSpinner) io.Reader {
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		return CountingReadSeeker(seeker, spinner)
+	}
+	if spinner == nil {
+		return reader
+	}
+	return io.TeeReader(reader, &byteCounter{spinner: spinner})
+}
+
 // CountingReadSeeker wraps a ReadSeeker to count bytes for the spinner.
 // If spinner is nil, the seeker is returned unchanged (no counting).
 // We need a wrapper struct because io.TeeReader only returns io.Reader, not io.ReadSeeker.
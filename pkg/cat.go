@@ -4,19 +4,28 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"time"
 
+	"github.com/lolocompany/kafka-replay/v2/pkg/filter"
 	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
 )
 
 type CatConfig struct {
-	Reader             io.ReadSeeker
+	Reader             io.Reader // Recording to read, possibly a stream (see transcoder.NewDecodeReader)
 	PreserveTimestamps bool
-	Formatter          func(timestamp time.Time, key []byte, data []byte) []byte
+	Formatter          func(entry *transcoder.Entry) ([]byte, error)
 	Output             io.Writer
 	FindBytes          []byte // Optional byte sequence to search for in messages
 	CountOnly          bool   // If true, only count messages without outputting them
+	StartEntry         int64  // Number (0-based) of the first entry to read
+	// StartTime starts reading at the first entry with a timestamp at or after this time (overrides StartEntry)
+	StartTime *time.Time
+	// Index is the optional index of the recording, used to seek to StartEntry or StartTime without reading from the start
+	Index *transcoder.Index
+	// Filter is an optional filter expression messages must match (with FindBytes if both are set)
+	Filter *filter.Filter
 }
 
 func Cat(ctx context.Context, cfg CatConfig) (int, error) {
@@ -28,6 +37,9 @@ func Cat(ctx context.Context, cfg CatConfig) (int, error) {
 		return 0, err
 	}
 	defer decoder.Close()
+	if err := seekStart(decoder, cfg.StartEntry, cfg.StartTime, cfg.Index); err != nil {
+		return 0, err
+	}
 
 	count := 0
 
@@ -53,6 +65,9 @@ func Cat(ctx context.Context, cfg CatConfig) (int, error) {
 		if cfg.FindBytes != nil && !bytes.Contains(entry.Data, cfg.FindBytes) {
 			continue
 		}
+		if !cfg.Filter.Match(entry) {
+			continue
+		}
 
 		// Increment count
 		count++
@@ -63,8 +78,10 @@ func Cat(ctx context.Context, cfg CatConfig) (int, error) {
 		}
 
 		// Display message
-		// Note: key is read but not currently used in cat output
-		formattedMessage := cfg.Formatter(entry.Timestamp, entry.Key, entry.Data)
+		formattedMessage, err := cfg.Formatter(entry)
+		if err != nil {
+			return count, fmt.Errorf("failed to format entry %d: %w", decoder.EntryNumber()-1, err)
+		}
 		if _, err := cfg.Output.Write(formattedMessage); err != nil {
 			return count, err
 		}
@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// Checkpoint records how far a replay got, so that it can be resumed without sending messages twice
+type Checkpoint struct {
+	// InputSize is the size in bytes of the replayed file, to detect a checkpoint of another file
+	InputSize int64 `json:"inputSize"`
+	// Entry is the number (0-based) of the next entry to replay
+	Entry int64 `json:"entry"`
+	// Offset is the byte offset reading starts at to reach Entry (the entry, or the start of its compressed frame)
+	Offset int64 `json:"offset"`
+	// OffsetEntry is the number of the entry at Offset
+	OffsetEntry int64 `json:"offsetEntry"`
+	// Messages is the number of messages acknowledged by the brokers, over all resumed runs
+	Messages int64 `json:"messages"`
+	// Completed is set once the whole file has been replayed
+	Completed bool      `json:"completed"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NewCheckpoint returns the checkpoint of a replay at position
+func NewCheckpoint(inputSize int64, position transcoder.Position, messages int64) Checkpoint {
+	return Checkpoint{
+		InputSize:   inputSize,
+		Entry:       position.Entry,
+		Offset:      position.Offset,
+		OffsetEntry: position.OffsetEntry,
+		Messages:    messages,
+		UpdatedAt:   time.Now().UTC(),
+	}
+}
+
+// Position returns the position to resume the replay at
+func (c Checkpoint) Position() transcoder.Position {
+	return transcoder.Position{Entry: c.Entry, Offset: c.Offset, OffsetEntry: c.OffsetEntry}
+}
+
+// WriteCheckpoint writes a checkpoint as JSON
+func WriteCheckpoint(w io.Writer, c Checkpoint) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// ReadCheckpoint reads a checkpoint written by WriteCheckpoint
+func ReadCheckpoint(r io.Reader) (*Checkpoint, error) {
+	var c Checkpoint
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+func TestCheckpoint_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkpoint Checkpoint
+	}{
+		{name: "start", checkpoint: NewCheckpoint(0, transcoder.Position{}, 0)},
+		{name: "uncompressed", checkpoint: NewCheckpoint(4096, transcoder.Position{Entry: 12, Offset: 1024, OffsetEntry: 12}, 12)},
+		{name: "inside a compressed frame", checkpoint: NewCheckpoint(1<<40, transcoder.Position{Entry: 1500, Offset: 65536, OffsetEntry: 1024}, 3000)},
+		{name: "completed", checkpoint: Checkpoint{InputSize: 10, Entry: 3, Offset: 10, OffsetEntry: 3, Messages: 3, Completed: true, UpdatedAt: time.Date(2026, 2, 2, 10, 15, 30, 123456789, time.UTC)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteCheckpoint(&buf, tc.checkpoint); err != nil {
+				t.Fatalf("WriteCheckpoint failed: %v", err)
+			}
+			got, err := ReadCheckpoint(&buf)
+			if err != nil {
+				t.Fatalf("ReadCheckpoint failed: %v", err)
+			}
+			if !got.UpdatedAt.Equal(tc.checkpoint.UpdatedAt) {
+				t.Errorf("UpdatedAt = %v, want %v", got.UpdatedAt, tc.checkpoint.UpdatedAt)
+			}
+			got.UpdatedAt = tc.checkpoint.UpdatedAt
+			if *got != tc.checkpoint {
+				t.Errorf("checkpoint = %+v, want %+v", *got, tc.checkpoint)
+			}
+			if got.Position() != tc.checkpoint.Position() {
+				t.Errorf("position = %+v, want %+v", got.Position(), tc.checkpoint.Position())
+			}
+		})
+	}
+
+	for _, input := range []string{"", "{", `{"entry":"3"}`} {
+		if _, err := ReadCheckpoint(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "invalid checkpoint") {
+			t.Errorf("%q: expected an invalid checkpoint error, got %v", input, err)
+		}
+	}
+}
+
+// TestCheckpoint_Resume tests that a replay resumed at a saved checkpoint continues with the next entry
+func TestCheckpoint_Resume(t *testing.T) {
+	for _, compression := range []transcoder.Compression{transcoder.CompressionNone, transcoder.CompressionZstd} {
+		var recording bytes.Buffer
+		encoder, err := transcoder.NewCompressedEncodeWriter(&recording, compression)
+		if err != nil {
+			t.Fatalf("NewCompressedEncodeWriter failed: %v", err)
+		}
+		for i := 0; i < 50; i++ {
+			if _, err := encoder.Write(time.UnixMilli(int64(i)), []byte(fmt.Sprintf("message %d", i)), nil); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+		if err := encoder.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		decoder, err := transcoder.NewDecodeReader(bytes.NewReader(recording.Bytes()), true)
+		if err != nil {
+			t.Fatalf("NewDecodeReader failed: %v", err)
+		}
+		for i := 0; i < 20; i++ {
+			if _, err := decoder.Read(); err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+		}
+		var saved bytes.Buffer
+		if err := WriteCheckpoint(&saved, NewCheckpoint(int64(recording.Len()), decoder.Position(), 20)); err != nil {
+			t.Fatalf("WriteCheckpoint failed: %v", err)
+		}
+
+		checkpoint, err := ReadCheckpoint(&saved)
+		if err != nil {
+			t.Fatalf("ReadCheckpoint failed: %v", err)
+		}
+		resumed, err := transcoder.NewDecodeReader(bytes.NewReader(recording.Bytes()), true)
+		if err != nil {
+			t.Fatalf("NewDecodeReader failed: %v", err)
+		}
+		if err := resumed.SeekPosition(checkpoint.Position()); err != nil {
+			t.Fatalf("%s: SeekPosition failed: %v", compression, err)
+		}
+		entry, err := resumed.Read()
+		if err != nil || string(entry.Data) != "message 20" {
+			t.Errorf("%s: resumed at %q, %v, want message 20", compression, entry.Data, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/export"
+	"github.com/lolocompany/kafka-replay/v2/pkg/filter"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+type ExportConfig struct {
+	Reader io.Reader // Recording to read, possibly a stream (see transcoder.NewDecodeReader)
+	// Writer writes the messages in the export format. It is closed after the last message
+	Writer     export.Writer
+	FindBytes  []byte // Optional byte sequence to search for in messages
+	StartEntry int64  // Number (0-based) of the first entry to read
+	// StartTime starts reading at the first entry with a timestamp at or after this time (overrides StartEntry)
+	StartTime *time.Time
+	// Index is the optional index of the recording, used to seek to StartEntry or StartTime without reading from the start
+	Index *transcoder.Index
+	// Filter is an optional filter expression messages must match (with FindBytes if both are set)
+	Filter *filter.Filter
+}
+
+// Export writes the messages of a recording to an export file (JSON lines, CSV or Parquet) and returns their number
+func Export(ctx context.Context, cfg ExportConfig) (int, error) {
+	if cfg.Writer == nil {
+		return 0, errors.New("writer is required")
+	}
+	decoder, err := transcoder.NewDecodeReader(cfg.Reader, true)
+	if err != nil {
+		return 0, err
+	}
+	defer decoder.Close()
+	if err := seekStart(decoder, cfg.StartEntry, cfg.StartTime, cfg.Index); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return count, ctx.Err()
+		default:
+		}
+
+		entry, err := decoder.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		if cfg.FindBytes != nil && !bytes.Contains(entry.Data, cfg.FindBytes) {
+			continue
+		}
+		if !cfg.Filter.Match(entry) {
+			continue
+		}
+		if err := cfg.Writer.Write(entry); err != nil {
+			return count, fmt.Errorf("failed to export entry %d: %w", decoder.EntryNumber()-1, err)
+		}
+		count++
+	}
+	if err := cfg.Writer.Close(); err != nil {
+		return count, fmt.Errorf("failed to finish export: %w", err)
+	}
+	return count, nil
+}
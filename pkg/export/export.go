@@ -0,0 +1,259 @@
+// Package export writes recorded messages in formats other tools load directly: JSON lines, CSV and Parquet
+//
+// Each message is a row with the selected columns. Keys and values (and header values) are written as strings
+// in a binary-safe encoding, base64 or hex, or as UTF-8 text for messages known to be text
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatJSONL   Format = "jsonl"
+	FormatCSV     Format = "csv"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat parses an export format name: jsonl, csv or parquet
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case FormatJSONL, FormatCSV, FormatParquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q: expected jsonl, csv or parquet", name)
+}
+
+// Column is an exported field of the messages
+type Column string
+
+const (
+	ColumnTimestamp Column = "timestamp"
+	ColumnTopic     Column = "topic"
+	ColumnPartition Column = "partition"
+	ColumnOffset    Column = "offset"
+	ColumnKey       Column = "key"
+	ColumnValue     Column = "value"
+	ColumnHeaders   Column = "headers"
+)
+
+// DefaultColumns are all the columns, in their default order
+var DefaultColumns = []Column{ColumnTimestamp, ColumnTopic, ColumnPartition, ColumnOffset, ColumnKey, ColumnValue, ColumnHeaders}
+
+// ParseColumns parses column names, in the order they are given
+func ParseColumns(names []string) ([]Column, error) {
+	var columns []Column
+	seen := make(map[Column]bool)
+	for _, name := range names {
+		c := Column(strings.ToLower(strings.TrimSpace(name)))
+		valid := false
+		for _, known := range DefaultColumns {
+			valid = valid || c == known
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown column %q: expected timestamp, topic, partition, offset, key, value or headers", name)
+		}
+		if seen[c] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[c] = true
+		columns = append(columns, c)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns to export")
+	}
+	return columns, nil
+}
+
+// Encoding is how bytes are written as strings
+type Encoding string
+
+const (
+	// EncodingBase64 is standard base64 with padding
+	EncodingBase64 Encoding = "base64"
+	// EncodingHex is lowercase hex
+	EncodingHex Encoding = "hex"
+	// EncodingUTF8 writes bytes as text, replacing invalid UTF-8 sequences with U+FFFD (not binary-safe)
+	EncodingUTF8 Encoding = "utf8"
+	// EncodingJSONEmbedded writes bytes that are a compact JSON value as that value, nested in JSON output (cat
+	// only). Other bytes are written as UTF-8 text, or in base64 if they are not valid UTF-8
+	EncodingJSONEmbedded Encoding = "json-embedded"
+	// EncodingSchemaDecoded is reported by cat for keys and values decoded to JSON with their schema from a
+	// Schema Registry. It is not an encoding of the recorded bytes, which cannot be read back from the JSON
+	EncodingSchemaDecoded Encoding = "schema-decoded"
+)
+
+// ParseEncoding parses an encoding name: base64, hex or utf8
+func ParseEncoding(name string) (Encoding, error) {
+	switch e := Encoding(strings.ToLower(name)); e {
+	case EncodingBase64, EncodingHex, EncodingUTF8:
+		return e, nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q: expected base64, hex or utf8", name)
+}
+
+// ParseJSONEncoding parses the name of an encoding of JSON output: base64, hex, utf8 or json-embedded
+func ParseJSONEncoding(name string) (Encoding, error) {
+	if e := Encoding(strings.ToLower(name)); e == EncodingJSONEmbedded {
+		return e, nil
+	}
+	e, err := ParseEncoding(name)
+	if err != nil {
+		return "", fmt.Errorf("unsupported encoding %q: expected base64, hex, utf8 or json-embedded", name)
+	}
+	return e, nil
+}
+
+// EncodeJSON returns bytes as a JSON value and the encoding it is written in, without losing bytes. With
+// EncodingJSONEmbedded, a compact JSON value (valid UTF-8 without whitespace outside strings) is returned as a
+// json.RawMessage, which encoders without HTML escaping write byte for byte, and other bytes, non-compact JSON
+// included, fall back to utf8 or base64; with EncodingUTF8, bytes that are not valid UTF-8 fall back to base64;
+// the other encodings return a string, like Encode
+func (e Encoding) EncodeJSON(data []byte) (any, Encoding) {
+	switch {
+	case e == EncodingJSONEmbedded && utf8.Valid(data) && isCompactJSON(data):
+		return json.RawMessage(data), EncodingJSONEmbedded
+	case e == EncodingJSONEmbedded || e == EncodingUTF8:
+		if utf8.Valid(data) {
+			return string(data), EncodingUTF8
+		}
+		return EncodingBase64.Encode(data), EncodingBase64
+	default:
+		return e.Encode(data), e
+	}
+}
+
+// isCompactJSON reports whether bytes are a JSON value that compacting leaves unchanged
+func isCompactJSON(data []byte) bool {
+	var compact bytes.Buffer
+	return json.Compact(&compact, data) == nil && bytes.Equal(compact.Bytes(), data)
+}
+
+// Encode writes bytes as a string
+func (e Encoding) Encode(data []byte) string {
+	switch e {
+	case EncodingHex:
+		return hex.EncodeToString(data)
+	case EncodingUTF8:
+		return strings.ToValidUTF8(string(data), "\uFFFD")
+	default:
+		return base64.StdEncoding.EncodeToString(data)
+	}
+}
+
+// Decode reads bytes written with Encode
+func (e Encoding) Decode(s string) ([]byte, error) {
+	switch e {
+	case EncodingHex:
+		return hex.DecodeString(s)
+	case EncodingUTF8:
+		return []byte(s), nil
+	default:
+		return base64.StdEncoding.DecodeString(s)
+	}
+}
+
+// Options are the columns and encodings of an export
+type Options struct {
+	Columns       []Column // DefaultColumns if empty
+	KeyEncoding   Encoding // Base64 if empty (binary in Parquet)
+	ValueEncoding Encoding // Base64 if empty (binary in Parquet), also used for header values
+}
+
+// Writer writes messages to an export file
+type Writer interface {
+	Write(entry *transcoder.Entry) error
+	// Close writes what is buffered and the end of the file (it does not close the underlying writer)
+	Close() error
+}
+
+// NewWriter creates a writer of an export format
+func NewWriter(w io.Writer, format Format, opts Options) (Writer, error) {
+	if len(opts.Columns) == 0 {
+		opts.Columns = DefaultColumns
+	}
+	if format == FormatParquet {
+		return newParquetWriter(w, opts) // Keys and values without an encoding are stored as they are
+	}
+	if opts.KeyEncoding == "" {
+		opts.KeyEncoding = EncodingBase64
+	}
+	if opts.ValueEncoding == "" {
+		opts.ValueEncoding = EncodingBase64
+	}
+	switch format {
+	case FormatJSONL:
+		return newJSONLWriter(w, opts), nil
+	case FormatCSV:
+		return newCSVWriter(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// Header is an exported message header, with its value encoded
+type Header struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// value returns the value of a column for an entry: a time.Time, string, int64 or []Header, or nil for null
+// Keys are null when empty (recordings do not tell empty keys from missing ones), and so are the topic,
+// partition and offset of messages recorded without them
+func (o *Options) value(c Column, entry *transcoder.Entry) any {
+	switch c {
+	case ColumnTimestamp:
+		return entry.Timestamp.UTC()
+	case ColumnTopic:
+		if entry.Topic == "" {
+			return nil
+		}
+		return entry.Topic
+	case ColumnPartition:
+		if !entry.HasSource() {
+			return nil
+		}
+		return int64(entry.Partition)
+	case ColumnOffset:
+		if !entry.HasSource() {
+			return nil
+		}
+		return entry.Offset
+	case ColumnKey:
+		if len(entry.Key) == 0 {
+			return nil
+		}
+		return o.KeyEncoding.Encode(entry.Key)
+	case ColumnValue:
+		return o.ValueEncoding.Encode(entry.Data)
+	case ColumnHeaders:
+		if len(entry.Headers) == 0 {
+			return nil
+		}
+		headers := make([]Header, len(entry.Headers))
+		for i, h := range entry.Headers {
+			headers[i] = Header{Key: h.Key, Value: o.ValueEncoding.Encode(h.Value)}
+		}
+		return headers
+	}
+	return nil
+}
+
+// TimestampLayout is the layout of timestamps in JSON lines and CSV (RFC 3339 in UTC, with milliseconds)
+const TimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+func formatTimestamp(t time.Time) string {
+	return t.Format(TimestampLayout)
+}
@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+func testEntries() []*transcoder.Entry {
+	return []*transcoder.Entry{
+		{
+			Timestamp: time.UnixMilli(1700000000123), Topic: "orders", Partition: 2, Offset: 41,
+			Key: []byte("k1"), Data: []byte{0, 0xff, 'a'},
+			Headers: []transcoder.Header{{Key: "trace", Value: []byte("t-1")}},
+		},
+		{Timestamp: time.UnixMilli(1700000001000), Partition: -1, Offset: -1, Data: []byte(`{"a":1}`)},
+	}
+}
+
+func export(t *testing.T, format Format, opts Options) []byte {
+	t.Helper()
+	return exportEntries(t, format, opts, testEntries())
+}
+
+func exportEntries(t *testing.T, format Format, opts Options, entries []*transcoder.Entry) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewWriter(&out, format, opts)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	for _, entry := range entries {
+		if err := w.Write(entry); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestWriter_JSONL(t *testing.T) {
+	got := string(export(t, FormatJSONL, Options{}))
+	want := `{"timestamp":"2023-11-14T22:13:20.123Z","topic":"orders","partition":2,"offset":41,"key":"azE=","value":"AP9h","headers":[{"key":"trace","value":"dC0x"}]}
+{"timestamp":"2023-11-14T22:13:21.000Z","topic":null,"partition":null,"offset":null,"key":null,"value":"eyJhIjoxfQ==","headers":null}
+`
+	if got != want {
+		t.Errorf("JSONL export:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = string(export(t, FormatJSONL, Options{Columns: []Column{ColumnValue, ColumnKey}, KeyEncoding: EncodingUTF8, ValueEncoding: EncodingHex}))
+	want = `{"value":"00ff61","key":"k1"}
+{"value":"7b2261223a317d","key":null}
+`
+	if got != want {
+		t.Errorf("JSONL export with columns and encodings:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriter_CSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(export(t, FormatCSV, Options{ValueEncoding: EncodingUTF8}))).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	want := [][]string{
+		{"timestamp", "topic", "partition", "offset", "key", "value", "headers"},
+		{"2023-11-14T22:13:20.123Z", "orders", "2", "41", "azE=", "\x00\uFFFDa", `[{"key":"trace","value":"t-1"}]`},
+		{"2023-11-14T22:13:21.000Z", "", "", "", "", `{"a":1}`, ""},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("CSV export has %d records, want %d: %q", len(records), len(want), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("CSV record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+
+	var empty bytes.Buffer
+	w, _ := NewWriter(&empty, FormatCSV, Options{Columns: []Column{ColumnKey}})
+	if err := w.Close(); err != nil || empty.String() != "key\n" {
+		t.Errorf("empty CSV export = %q, %v; want the header row", empty.String(), err)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	data := []byte{0, 1, 0xfe, 'x'}
+	for _, e := range []Encoding{EncodingBase64, EncodingHex} {
+		decoded, err := e.Decode(e.Encode(data))
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("%s: round trip gave %v, %v", e, decoded, err)
+		}
+	}
+	if _, err := ParseEncoding("base32"); err == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+	if _, err := ParseEncoding("json-embedded"); err == nil {
+		t.Error("expected json-embedded to be for JSON output only")
+	}
+}
+
+func TestEncoding_EncodeJSON(t *testing.T) {
+	for _, tc := range []struct {
+		encoding Encoding
+		data     []byte
+		want     string
+		used     Encoding
+	}{
+		{EncodingJSONEmbedded, []byte(`{"id":1,"tags":["a"],"n":1.50}`), `{"id":1,"tags":["a"],"n":1.50}`, EncodingJSONEmbedded},
+		{EncodingJSONEmbedded, []byte(`"quoted"`), `"quoted"`, EncodingJSONEmbedded},
+		{EncodingJSONEmbedded, []byte(`{"id": 1}`), `"{\"id\": 1}"`, EncodingUTF8},
+		{EncodingJSONEmbedded, []byte("[1]\n"), `"[1]\n"`, EncodingUTF8},
+		{EncodingJSONEmbedded, []byte("\"\xff\""), `"Iv8i"`, EncodingBase64},
+		{EncodingJSONEmbedded, []byte("plain text"), `"plain text"`, EncodingUTF8},
+		{EncodingJSONEmbedded, nil, `""`, EncodingUTF8},
+		{EncodingJSONEmbedded, []byte{0xff, 0}, `"/wA="`, EncodingBase64},
+		{EncodingHex, []byte(`{}`), `"7b7d"`, EncodingHex},
+		{EncodingUTF8, []byte(`{}`), `"{}"`, EncodingUTF8},
+		{EncodingUTF8, []byte{0, 'k', 0xfe}, `"AGv+"`, EncodingBase64},
+		{EncodingBase64, []byte("text"), `"dGV4dA=="`, EncodingBase64},
+	} {
+		value, used := tc.encoding.EncodeJSON(tc.data)
+		got, err := json.Marshal(value)
+		if err != nil || string(got) != tc.want || used != tc.used {
+			t.Errorf("%s %q: got %s in %s (%v), want %s in %s", tc.encoding, tc.data, got, used, err, tc.want, tc.used)
+		}
+	}
+	for _, name := range []string{"JSON-Embedded", "hex"} {
+		if _, err := ParseJSONEncoding(name); err != nil {
+			t.Errorf("ParseJSONEncoding(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := ParseJSONEncoding("json"); err == nil || !strings.Contains(err.Error(), "json-embedded") {
+		t.Errorf("expected an error listing json-embedded, got %v", err)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns([]string{"Value", " key"})
+	if err != nil || len(columns) != 2 || columns[0] != ColumnValue || columns[1] != ColumnKey {
+		t.Errorf("ParseColumns = %v, %v", columns, err)
+	}
+	for _, names := range [][]string{{"key", "key"}, {"payload"}, {}} {
+		if _, err := ParseColumns(names); err == nil {
+			t.Errorf("ParseColumns(%q): expected an error", names)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("Parquet"); err != nil || f != FormatParquet {
+		t.Errorf("ParseFormat = %q, %v", f, err)
+	}
+	if _, err := ParseFormat("avro"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,323 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// Parquet files are written with a row group per parquetRowGroupRows messages (or parquetRowGroupBytes of
+// values), each column chunk in a single snappy-compressed data page with PLAIN encoding
+const (
+	parquetRowGroupRows  = 100_000
+	parquetRowGroupBytes = 64 * 1024 * 1024
+	parquetMagic         = "PAR1"
+	parquetCreatedBy     = "kafka-replay"
+)
+
+// Parquet physical types, repetitions, converted types, encodings and codecs (parquet.thrift)
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMillis = 9
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecSnappy = 1
+	parquetDataPage    = 0
+)
+
+// parquetColumn buffers the values of a column for the current row group
+type parquetColumn struct {
+	column   Column
+	physical int32
+	required bool
+	binary   bool   // Keys or values without an encoding, stored as they are and not annotated as a string
+	present  []bool // Definition levels of optional columns
+	values   []byte // PLAIN-encoded non-null values
+}
+
+// parquetChunk is a column chunk written to the file
+type parquetChunk struct {
+	offset           int64
+	values           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	rows   int64
+	size   int64
+}
+
+// parquetWriter writes a Parquet file with a flat schema: the timestamp as a required INT64 (timestamp in
+// milliseconds, UTC), the partition as INT32, the offset as INT64, and the other columns as byte arrays
+// (headers as JSON arrays of {"key", "value"} objects), all optional. The schema only depends on the options:
+// keys and values are stored as they are in BINARY columns, unless their encoding is set, and the other byte
+// array columns, keys and values in an encoding included, are annotated as strings
+type parquetWriter struct {
+	out       *bufio.Writer
+	offset    int64
+	opts      Options
+	columns   []*parquetColumn
+	rows      int64
+	bytes     int
+	rowGroups []parquetRowGroup
+	page      []byte
+}
+
+func newParquetWriter(w io.Writer, opts Options) (*parquetWriter, error) {
+	pw := &parquetWriter{out: bufio.NewWriter(w), opts: opts}
+	for _, c := range opts.Columns {
+		column := &parquetColumn{column: c, physical: parquetByteArray}
+		switch c {
+		case ColumnTimestamp:
+			column.physical, column.required = parquetInt64, true
+		case ColumnPartition:
+			column.physical = parquetInt32
+		case ColumnOffset:
+			column.physical = parquetInt64
+		case ColumnKey:
+			column.binary = opts.KeyEncoding == ""
+		case ColumnValue:
+			column.binary = opts.ValueEncoding == ""
+		}
+		pw.columns = append(pw.columns, column)
+	}
+	if pw.opts.ValueEncoding == "" {
+		pw.opts.ValueEncoding = EncodingBase64 // Header values
+	}
+	if err := pw.write([]byte(parquetMagic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (w *parquetWriter) write(data []byte) error {
+	n, err := w.out.Write(data)
+	w.offset += int64(n)
+	return err
+}
+
+func (w *parquetWriter) Write(entry *transcoder.Entry) error {
+	for _, column := range w.columns {
+		v := w.opts.value(column.column, entry)
+		if column.binary && v != nil {
+			v = string(rawValue(column.column, entry))
+		}
+		if !column.required {
+			column.present = append(column.present, v != nil)
+		}
+		size := len(column.values)
+		switch v := v.(type) {
+		case int64:
+			if column.physical == parquetInt32 {
+				column.values = binary.LittleEndian.AppendUint32(column.values, uint32(v))
+			} else {
+				column.values = binary.LittleEndian.AppendUint64(column.values, uint64(v))
+			}
+		case string:
+			column.values = binary.LittleEndian.AppendUint32(column.values, uint32(len(v)))
+			column.values = append(column.values, v...)
+		case []Header:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			column.values = binary.LittleEndian.AppendUint32(column.values, uint32(len(encoded)))
+			column.values = append(column.values, encoded...)
+		case time.Time:
+			column.values = binary.LittleEndian.AppendUint64(column.values, uint64(v.UnixMilli()))
+		}
+		w.bytes += len(column.values) - size
+	}
+	w.rows++
+	if w.rows >= parquetRowGroupRows || w.bytes >= parquetRowGroupBytes {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+// rawValue returns the bytes of a key or value column
+func rawValue(c Column, entry *transcoder.Entry) []byte {
+	if c == ColumnKey {
+		return entry.Key
+	}
+	return entry.Data
+}
+
+// flushRowGroup writes the buffered rows as a row group
+func (w *parquetWriter) flushRowGroup() error {
+	if w.rows == 0 {
+		return nil
+	}
+	rowGroup := parquetRowGroup{rows: w.rows}
+	for _, column := range w.columns {
+		w.page = w.page[:0]
+		if !column.required {
+			w.page = appendLevels(w.page, column.present)
+		}
+		w.page = append(w.page, column.values...)
+		compressed := snappy.Encode(nil, w.page)
+
+		header := &thriftWriter{}
+		header.begin(0)
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(w.page)))
+		header.i32(3, int32(len(compressed)))
+		header.begin(5) // DataPageHeader
+		header.i32(1, int32(w.rows))
+		header.i32(2, parquetEncodingPlain)
+		header.i32(3, parquetEncodingRLE)
+		header.i32(4, parquetEncodingRLE)
+		header.end()
+		header.end()
+
+		chunk := parquetChunk{
+			offset:           w.offset,
+			values:           w.rows,
+			uncompressedSize: int64(len(header.buf) + len(w.page)),
+			compressedSize:   int64(len(header.buf) + len(compressed)),
+		}
+		if err := w.write(header.buf); err != nil {
+			return err
+		}
+		if err := w.write(compressed); err != nil {
+			return err
+		}
+		rowGroup.chunks = append(rowGroup.chunks, chunk)
+		rowGroup.size += chunk.uncompressedSize
+
+		column.present = column.present[:0]
+		column.values = column.values[:0]
+	}
+	w.rowGroups = append(w.rowGroups, rowGroup)
+	w.rows, w.bytes = 0, 0
+	return nil
+}
+
+// appendLevels appends the definition levels of an optional column (1 for values, 0 for nulls), in the
+// RLE/bit-packing hybrid encoding with RLE runs only, after their 4-byte length
+func appendLevels(buf []byte, present []bool) []byte {
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0)
+	for i := 0; i < len(present); {
+		j := i + 1
+		for j < len(present) && present[j] == present[i] {
+			j++
+		}
+		buf = binary.AppendUvarint(buf, uint64(j-i)<<1)
+		if present[i] {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		i = j
+	}
+	binary.LittleEndian.PutUint32(buf[start:], uint32(len(buf)-start-4))
+	return buf
+}
+
+func (w *parquetWriter) Close() error {
+	if err := w.flushRowGroup(); err != nil {
+		return err
+	}
+
+	var rows int64
+	for _, rowGroup := range w.rowGroups {
+		rows += rowGroup.rows
+	}
+	footer := &thriftWriter{}
+	footer.begin(0) // FileMetaData
+	footer.i32(1, 1)
+	footer.list(2, thriftStruct, len(w.columns)+1)
+	footer.begin(0) // Root of the schema
+	footer.binary(4, "schema")
+	footer.i32(5, int32(len(w.columns)))
+	footer.end()
+	for _, column := range w.columns {
+		w.writeSchemaElement(footer, column)
+	}
+	footer.i64(3, rows)
+	footer.list(4, thriftStruct, len(w.rowGroups))
+	for _, rowGroup := range w.rowGroups {
+		footer.begin(0) // RowGroup
+		footer.list(1, thriftStruct, len(rowGroup.chunks))
+		for i, chunk := range rowGroup.chunks {
+			footer.begin(0) // ColumnChunk
+			footer.i64(2, chunk.offset)
+			footer.begin(3) // ColumnMetaData
+			footer.i32(1, w.columns[i].physical)
+			footer.listI32(2, parquetEncodingPlain, parquetEncodingRLE)
+			footer.listBinary(3, string(w.columns[i].column))
+			footer.i32(4, parquetCodecSnappy)
+			footer.i64(5, chunk.values)
+			footer.i64(6, chunk.uncompressedSize)
+			footer.i64(7, chunk.compressedSize)
+			footer.i64(9, chunk.offset)
+			footer.end()
+			footer.end()
+		}
+		footer.i64(2, rowGroup.size)
+		footer.i64(3, rowGroup.rows)
+		footer.end()
+	}
+	footer.binary(6, parquetCreatedBy)
+	footer.end()
+
+	if err := w.write(footer.buf); err != nil {
+		return err
+	}
+	if err := w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer.buf)))); err != nil {
+		return err
+	}
+	if err := w.write([]byte(parquetMagic)); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// writeSchemaElement writes the SchemaElement of a column, with its logical type
+func (w *parquetWriter) writeSchemaElement(t *thriftWriter, column *parquetColumn) {
+	t.begin(0)
+	t.i32(1, column.physical)
+	if column.required {
+		t.i32(3, parquetRequired)
+	} else {
+		t.i32(3, parquetOptional)
+	}
+	t.binary(4, string(column.column))
+	switch {
+	case column.column == ColumnTimestamp:
+		t.i32(6, parquetConvertedTimestampMillis)
+		t.begin(10) // LogicalType
+		t.begin(8)  // TimestampType
+		t.boolean(1, true)
+		t.begin(2) // TimeUnit
+		t.begin(1) // MilliSeconds
+		t.end()
+		t.end()
+		t.end()
+		t.end()
+	case column.physical == parquetByteArray && !column.binary:
+		t.i32(6, parquetConvertedUTF8)
+		t.begin(10) // LogicalType
+		t.begin(1)  // StringType
+		t.end()
+		t.end()
+	}
+	t.end()
+}
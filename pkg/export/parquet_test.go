@@ -0,0 +1,311 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// decodedStruct is a decoded Thrift compact struct: its field values by ID (int64, []byte, bool, []any or
+// decodedStruct)
+type decodedStruct map[int]any
+
+// readThrift decodes a Thrift compact struct, returning the number of bytes read
+func readThrift(t *testing.T, data []byte) (decodedStruct, int) {
+	t.Helper()
+	r := &thriftReader{t: t, data: data}
+	s := r.readStruct()
+	return s, r.pos
+}
+
+type thriftReader struct {
+	t    *testing.T
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("invalid varint at %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) readStruct() decodedStruct {
+	s := make(decodedStruct)
+	last := 0
+	for {
+		b := r.data[r.pos]
+		r.pos++
+		if b == 0 {
+			return s
+		}
+		typ := b & 0x0f
+		if delta := int(b >> 4); delta > 0 {
+			last += delta
+		} else {
+			id := r.uvarint()
+			last = int(int64(id>>1) ^ -int64(id&1))
+		}
+		s[last] = r.readValue(typ)
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) any {
+	switch typ {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64:
+		v := r.uvarint()
+		return int64(v>>1) ^ -int64(v&1)
+	case thriftBinary:
+		n := int(r.uvarint())
+		r.pos += n
+		return r.data[r.pos-n : r.pos]
+	case thriftList:
+		b := r.data[r.pos]
+		r.pos++
+		n := int(b >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		values := make([]any, n)
+		for i := range values {
+			values[i] = r.readValue(b & 0x0f)
+		}
+		return values
+	case thriftStruct:
+		return r.readStruct()
+	}
+	r.t.Fatalf("unexpected Thrift type %d", typ)
+	return nil
+}
+
+// readParquet reads the columns of a Parquet file written by parquetWriter, as strings ("<nil>" for nulls)
+func readParquet(t *testing.T, file []byte) (decodedStruct, map[string][]string) {
+	t.Helper()
+	if !bytes.HasPrefix(file, []byte(parquetMagic)) || !bytes.HasSuffix(file, []byte(parquetMagic)) {
+		t.Fatal("missing Parquet magic")
+	}
+	size := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	metadata, n := readThrift(t, file[len(file)-8-size:len(file)-8])
+	if n != size {
+		t.Fatalf("footer is %d bytes, read %d", size, n)
+	}
+
+	schema := metadata[2].([]any)
+	columns := make(map[string][]string)
+	for _, rowGroup := range metadata[4].([]any) {
+		for i, chunk := range rowGroup.(decodedStruct)[1].([]any) {
+			element := schema[i+1].(decodedStruct)
+			name, physical, optional := string(element[4].([]byte)), element[1].(int64), element[3].(int64) == parquetOptional
+			meta := chunk.(decodedStruct)[3].(decodedStruct)
+			offset := int(meta[9].(int64))
+			header, n := readThrift(t, file[offset:])
+			compressed := file[offset+n : offset+n+int(header[3].(int64))]
+			page, err := snappy.Decode(nil, compressed)
+			if err != nil || len(page) != int(header[2].(int64)) {
+				t.Fatalf("column %s: invalid page (%v)", name, err)
+			}
+			count := int(header[5].(decodedStruct)[1].(int64))
+
+			present := make([]bool, count)
+			if optional {
+				levels := page[4 : 4+binary.LittleEndian.Uint32(page)]
+				page = page[4+len(levels):]
+				for i := 0; len(levels) > 0; {
+					run, n := binary.Uvarint(levels)
+					for j := 0; j < int(run>>1); j++ {
+						present[i] = levels[n] == 1
+						i++
+					}
+					levels = levels[n+1:]
+				}
+			}
+			for i := 0; i < count; i++ {
+				if optional && !present[i] {
+					columns[name] = append(columns[name], "<nil>")
+					continue
+				}
+				var v string
+				switch physical {
+				case parquetInt32:
+					v, page = fmt.Sprint(int32(binary.LittleEndian.Uint32(page))), page[4:]
+				case parquetInt64:
+					v, page = fmt.Sprint(int64(binary.LittleEndian.Uint64(page))), page[8:]
+				default:
+					n := binary.LittleEndian.Uint32(page)
+					v, page = string(page[4:4+n]), page[4+n:]
+				}
+				columns[name] = append(columns[name], v)
+			}
+			if len(page) != 0 {
+				t.Errorf("column %s: %d bytes left in the page", name, len(page))
+			}
+		}
+	}
+	return metadata, columns
+}
+
+func TestWriter_Parquet(t *testing.T) {
+	metadata, columns := readParquet(t, export(t, FormatParquet, Options{}))
+	if rows := metadata[3].(int64); rows != 2 {
+		t.Errorf("num_rows = %d, want 2", rows)
+	}
+	want := map[string][]string{
+		"timestamp": {"1700000000123", "1700000001000"},
+		"topic":     {"orders", "<nil>"},
+		"partition": {"2", "<nil>"},
+		"offset":    {"41", "<nil>"},
+		"key":       {"k1", "<nil>"},
+		"value":     {"\x00\xffa", `{"a":1}`},
+		"headers":   {`[{"key":"trace","value":"dC0x"}]`, "<nil>"},
+	}
+	for name, values := range want {
+		if fmt.Sprint(columns[name]) != fmt.Sprint(values) {
+			t.Errorf("column %s = %q, want %q", name, columns[name], values)
+		}
+	}
+
+	// Keys and values are binary unless their encoding is set, whatever the bytes
+	tests := []struct {
+		name       string
+		opts       Options
+		entries    []*transcoder.Entry
+		key, value string
+		annotated  map[string]bool
+	}{
+		{
+			name: "no encodings", entries: testEntries()[1:], key: "<nil>", value: `{"a":1}`,
+			annotated: map[string]bool{"topic": true, "headers": true},
+		},
+		{
+			name: "explicit encodings", opts: Options{KeyEncoding: EncodingBase64, ValueEncoding: EncodingUTF8},
+			entries: testEntries()[:1], key: "azE=", value: "\x00\ufffda",
+			annotated: map[string]bool{"topic": true, "key": true, "value": true, "headers": true},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			metadata, columns := readParquet(t, exportEntries(t, FormatParquet, tc.opts, tc.entries))
+			if columns["key"][0] != tc.key || columns["value"][0] != tc.value {
+				t.Errorf("key, value = %q, %q; want %q, %q", columns["key"][0], columns["value"][0], tc.key, tc.value)
+			}
+			for _, element := range metadata[2].([]any)[1:] {
+				element := element.(decodedStruct)
+				name := string(element[4].([]byte))
+				if element[1].(int64) != parquetByteArray {
+					continue
+				}
+				if _, annotated := element[6]; annotated != tc.annotated[name] {
+					t.Errorf("column %s: string annotation is %v", name, annotated)
+				}
+			}
+		})
+	}
+
+	timestamp := metadata[2].([]any)[1].(decodedStruct)
+	logical := timestamp[10].(decodedStruct)[8].(decodedStruct)
+	if timestamp[6].(int64) != parquetConvertedTimestampMillis || logical[1] != true {
+		t.Errorf("timestamp should be a UTC timestamp in milliseconds: %v", timestamp)
+	}
+}
+
+func TestWriter_Parquet_RowGroups(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, FormatParquet, Options{Columns: []Column{ColumnOffset, ColumnKey}})
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	rows := parquetRowGroupRows + 3
+	for i := 0; i < rows; i++ {
+		entry := testEntries()[i%2]
+		if err := w.Write(entry); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	metadata, columns := readParquet(t, out.Bytes())
+	if n := len(metadata[4].([]any)); n != 2 {
+		t.Errorf("expected 2 row groups, got %d", n)
+	}
+	if len(columns["offset"]) != rows || columns["offset"][rows-1] != "41" || columns["key"][rows-2] != "<nil>" {
+		t.Errorf("unexpected columns across row groups: %d offsets", len(columns["offset"]))
+	}
+
+	var empty bytes.Buffer
+	w, _ = NewWriter(&empty, FormatParquet, Options{})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if metadata, _ := readParquet(t, empty.Bytes()); metadata[3].(int64) != 0 || len(metadata[4].([]any)) != 0 {
+		t.Errorf("empty export: expected no rows, got %v", metadata)
+	}
+}
+
+// pyarrowSchema prints the columns of a Parquet file as read by pyarrow: their Arrow types, then their values
+// as JSON (bytes in hex)
+const pyarrowSchema = `
+import json, sys
+import pyarrow.parquet as pq
+table = pq.read_table(sys.argv[1])
+for field in table.schema:
+    print(field.name, field.type)
+for name, values in zip(table.column_names, table.to_pydict().values()):
+    print(name, json.dumps(values, default=lambda v: v.hex() if isinstance(v, bytes) else v.isoformat()))
+`
+
+// TestWriter_Parquet_Interop reads an export with Apache Arrow (pyarrow). It is skipped when pyarrow is not
+// installed, except in CI (the CI environment variable is set), which installs it
+func TestWriter_Parquet_Interop(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err == nil {
+		err = exec.Command(python, "-c", "import pyarrow.parquet").Run()
+	}
+	if err != nil {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("pyarrow is not installed: %v", err)
+		}
+		t.Skip("pyarrow is not installed")
+	}
+
+	path := filepath.Join(t.TempDir(), "messages.parquet")
+	if err := os.WriteFile(path, export(t, FormatParquet, Options{}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(python, "-c", pyarrowSchema, path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("pyarrow failed to read the file: %v\n%s", err, out)
+	}
+	want := `timestamp timestamp[ms, tz=UTC]
+topic string
+partition int32
+offset int64
+key binary
+value binary
+headers string
+timestamp ["2023-11-14T22:13:20.123000+00:00", "2023-11-14T22:13:21+00:00"]
+topic ["orders", null]
+partition [2, null]
+offset [41, null]
+key ["6b31", null]
+value ["00ff61", "7b2261223a317d"]
+headers ["[{\"key\":\"trace\",\"value\":\"dC0x\"}]", null]
+`
+	if string(out) != want {
+		t.Errorf("pyarrow read:\n%s\nwant:\n%s", out, want)
+	}
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// jsonlWriter writes a JSON object per line, with the columns in order and null for missing values
+type jsonlWriter struct {
+	out  *bufio.Writer
+	opts Options
+	line bytes.Buffer
+}
+
+func newJSONLWriter(w io.Writer, opts Options) *jsonlWriter {
+	return &jsonlWriter{out: bufio.NewWriter(w), opts: opts}
+}
+
+func (w *jsonlWriter) Write(entry *transcoder.Entry) error {
+	w.line.Reset()
+	w.line.WriteByte('{')
+	for i, c := range w.opts.Columns {
+		if i > 0 {
+			w.line.WriteByte(',')
+		}
+		w.line.WriteString(strconv.Quote(string(c)))
+		w.line.WriteByte(':')
+		v := w.opts.value(c, entry)
+		if t, ok := v.(time.Time); ok {
+			v = formatTimestamp(t)
+		}
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.line.Write(encoded)
+	}
+	w.line.WriteString("}\n")
+	_, err := w.out.Write(w.line.Bytes())
+	return err
+}
+
+func (w *jsonlWriter) Close() error {
+	return w.out.Flush()
+}
+
+// csvWriter writes a header row with the column names, then a row per message with empty cells for missing
+// values. Headers are written as JSON arrays of {"key", "value"} objects
+type csvWriter struct {
+	out    *csv.Writer
+	opts   Options
+	header bool
+	row    []string
+}
+
+func newCSVWriter(w io.Writer, opts Options) *csvWriter {
+	return &csvWriter{out: csv.NewWriter(w), opts: opts, row: make([]string, len(opts.Columns))}
+}
+
+func (w *csvWriter) Write(entry *transcoder.Entry) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	for i, c := range w.opts.Columns {
+		switch v := w.opts.value(c, entry).(type) {
+		case nil:
+			w.row[i] = ""
+		case time.Time:
+			w.row[i] = formatTimestamp(v)
+		case string:
+			w.row[i] = v
+		case int64:
+			w.row[i] = strconv.FormatInt(v, 10)
+		case []Header:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			w.row[i] = string(encoded)
+		}
+	}
+	return w.out.Write(w.row)
+}
+
+// writeHeader writes the header row before the first message (or at Close for an empty export)
+func (w *csvWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	for i, c := range w.opts.Columns {
+		w.row[i] = string(c)
+	}
+	return w.out.Write(w.row)
+}
+
+func (w *csvWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.out.Flush()
+	return w.out.Error()
+}
@@ -0,0 +1,323 @@
+// Package filter selects recorded messages with expressions such as
+//
+//	key == "user-1" && header["source"] == "web"
+//	.type == "OrderCreated" && .amount > 100
+//	timestamp >= "2026-02-01T14:00:00Z" && partition <= 3 && value =~ "error|fail"
+//
+// Expressions compare message fields (key, value, topic, partition, offset, timestamp, header["name"]) and
+// JSON paths into the message value (.order.items[0].sku) with literals (strings, numbers, true, false, null),
+// using ==, !=, <, <=, >, >=, =~ and !~ (regular expression match), combined with &&, || and ! and grouped
+// with parentheses. A field or path on its own tests that it exists and is not null or false
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/jsonpath"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// Filter is a compiled filter expression, safe for concurrent use
+type Filter struct {
+	expression string
+	root       node
+}
+
+// Parse compiles a filter expression
+func Parse(expression string) (*Filter, error) {
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expression, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = p.errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expression, err)
+	}
+	return &Filter{expression: expression, root: root}, nil
+}
+
+// String returns the expression the filter was compiled from
+func (f *Filter) String() string {
+	return f.expression
+}
+
+// Match reports whether an entry matches the filter (a nil filter matches every entry)
+// The message value is decoded as JSON only if the expression has paths; values that are not valid JSON
+// have no paths, so comparisons of paths are false for them
+func (f *Filter) Match(entry *transcoder.Entry) bool {
+	if f == nil {
+		return true
+	}
+	return f.root.match(&context{entry: entry})
+}
+
+// context is the entry being matched, with its value decoded as JSON on first use
+type context struct {
+	entry    *transcoder.Entry
+	document any
+	decoded  bool
+	valid    bool
+}
+
+func (c *context) json() (any, bool) {
+	if !c.decoded {
+		c.decoded = true
+		document, err := jsonpath.Decode(c.entry.Data)
+		c.document, c.valid = document, err == nil
+	}
+	return c.document, c.valid
+}
+
+// node is a boolean expression
+type node interface {
+	match(c *context) bool
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(c *context) bool { return n.left.match(c) || n.right.match(c) }
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(c *context) bool { return n.left.match(c) && n.right.match(c) }
+
+type notNode struct{ operand node }
+
+func (n notNode) match(c *context) bool { return !n.operand.match(c) }
+
+// existsNode tests that an operand exists and is not null or false
+type existsNode struct{ operand operand }
+
+func (n existsNode) match(c *context) bool {
+	v := n.operand.value(c)
+	switch v.kind {
+	case kindMissing, kindNull:
+		return false
+	case kindBool:
+		return v.b
+	default:
+		return true
+	}
+}
+
+type compareNode struct {
+	op          string
+	left, right operand
+}
+
+func (n compareNode) match(c *context) bool {
+	return compare(n.op, n.left.value(c), n.right.value(c))
+}
+
+type regexNode struct {
+	operand operand
+	re      *regexp.Regexp
+	negate  bool
+}
+
+func (n regexNode) match(c *context) bool {
+	s, ok := n.operand.value(c).text()
+	if !ok {
+		return false
+	}
+	return n.re.MatchString(s) != n.negate
+}
+
+type valueKind int
+
+const (
+	kindMissing valueKind = iota
+	kindNull
+	kindBool
+	kindNumber
+	kindString
+	kindTime
+	kindComposite // JSON object or array
+)
+
+// value is the value of an operand for an entry
+type value struct {
+	kind valueKind
+	s    string
+	n    float64
+	b    bool
+	t    time.Time
+}
+
+// text returns the value as text for regular expression matching
+func (v value) text() (string, bool) {
+	switch v.kind {
+	case kindString:
+		return v.s, true
+	case kindNumber:
+		return strconv.FormatFloat(v.n, 'f', -1, 64), true
+	case kindBool:
+		return strconv.FormatBool(v.b), true
+	case kindTime:
+		return v.t.Format(time.RFC3339Nano), true
+	default:
+		return "", false
+	}
+}
+
+// compare compares two values. Comparisons with missing values are false; values of different kinds
+// are only different (timestamps compare with numbers as Unix milliseconds)
+func compare(op string, a, b value) bool {
+	if a.kind == kindMissing || b.kind == kindMissing {
+		return false
+	}
+	if a.kind == kindTime && b.kind == kindNumber {
+		a = value{kind: kindNumber, n: float64(a.t.UnixMilli())}
+	}
+	if b.kind == kindTime && a.kind == kindNumber {
+		b = value{kind: kindNumber, n: float64(b.t.UnixMilli())}
+	}
+	if a.kind != b.kind || a.kind == kindComposite {
+		return op == "!="
+	}
+	var cmp int
+	switch a.kind {
+	case kindNull:
+		cmp = 0
+	case kindBool:
+		if op != "==" && op != "!=" {
+			return false
+		}
+		if a.b != b.b {
+			cmp = 1
+		}
+	case kindNumber:
+		cmp = compareOrdered(a.n, b.n)
+	case kindString:
+		cmp = compareOrdered(a.s, b.s)
+	case kindTime:
+		cmp = a.t.Compare(b.t)
+	}
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // ">="
+		return cmp >= 0
+	}
+}
+
+func compareOrdered[T float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// operand is a field, header, path or literal
+type operand interface {
+	value(c *context) value
+}
+
+type literal struct{ v value }
+
+func (l literal) value(*context) value { return l.v }
+
+// field is a message field: key, value, topic, partition, offset or timestamp
+type field string
+
+var fields = map[string]bool{"key": true, "value": true, "topic": true, "partition": true, "offset": true, "timestamp": true}
+
+func (f field) value(c *context) value {
+	entry := c.entry
+	switch f {
+	case "key":
+		if len(entry.Key) == 0 { // Recordings do not tell a null key from an empty one
+			return value{kind: kindNull}
+		}
+		return value{kind: kindString, s: string(entry.Key)}
+	case "value":
+		if entry.Data == nil {
+			return value{kind: kindNull}
+		}
+		return value{kind: kindString, s: string(entry.Data)}
+	case "topic":
+		if entry.Topic == "" {
+			return value{}
+		}
+		return value{kind: kindString, s: entry.Topic}
+	case "partition":
+		if entry.Partition < 0 {
+			return value{}
+		}
+		return value{kind: kindNumber, n: float64(entry.Partition)}
+	case "offset":
+		if entry.Offset < 0 {
+			return value{}
+		}
+		return value{kind: kindNumber, n: float64(entry.Offset)}
+	default: // "timestamp"
+		return value{kind: kindTime, t: entry.Timestamp}
+	}
+}
+
+// header is the value of the first header with a key
+type header string
+
+func (h header) value(c *context) value {
+	for _, hdr := range c.entry.Headers {
+		if hdr.Key == string(h) {
+			return value{kind: kindString, s: string(hdr.Value)}
+		}
+	}
+	return value{}
+}
+
+// path is a JSON path into the message value
+type path struct{ path jsonpath.Path }
+
+func (p path) value(c *context) value {
+	document, ok := c.json()
+	if !ok {
+		return value{}
+	}
+	v, ok := p.path.Get(document)
+	if !ok {
+		return value{}
+	}
+	return jsonValue(v)
+}
+
+// jsonValue converts a value decoded by jsonpath.Decode
+func jsonValue(v any) value {
+	switch v := v.(type) {
+	case nil:
+		return value{kind: kindNull}
+	case bool:
+		return value{kind: kindBool, b: v}
+	case string:
+		return value{kind: kindString, s: v}
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			return value{}
+		}
+		return value{kind: kindNumber, n: n}
+	default:
+		return value{kind: kindComposite}
+	}
+}
@@ -0,0 +1,144 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+func testEntry() *transcoder.Entry {
+	return &transcoder.Entry{
+		Timestamp: time.Date(2026, 2, 1, 14, 30, 0, 0, time.UTC),
+		Key:       []byte("user-1"),
+		Data:      []byte(`{"type":"OrderCreated","amount":150.5,"paid":true,"coupon":null,"items":[{"sku":"A-1"},{"sku":"B-2"}],"unit price":3}`),
+		Headers:   []transcoder.Header{{Key: "source", Value: []byte("web")}},
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    1042,
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{`key == "user-1"`, true},
+		{`key != "user-1"`, false},
+		{`key =~ "^user-"`, true},
+		{`key !~ "^user-"`, false},
+		{`header["source"] == "web"`, true},
+		{`header["source"]`, true},
+		{`header["missing"]`, false},
+		{`header["missing"] != "web"`, false},
+		{`value =~ "Order(Created|Updated)"`, true},
+		{`topic == "orders" && partition == 2 && offset >= 1000 && offset < 2000`, true},
+		{`partition >= 3`, false},
+		{`timestamp >= "2026-02-01T14:00:00Z" && timestamp < "2026-02-01T15:00:00Z"`, true},
+		{`timestamp > "2026-02-01T14:30:00Z"`, false},
+		{`timestamp == 1769956200000`, true},
+		{`.type == "OrderCreated" && .amount > 100`, true},
+		{`.type == "OrderCreated" && .amount > 200`, false},
+		{`.type == "OrderCreated" || .amount > 200`, true},
+		{`!(.amount > 200)`, true},
+		{`.paid`, true},
+		{`.paid == true`, true},
+		{`.coupon`, false},
+		{`.coupon == null`, true},
+		{`.missing`, false},
+		{`.missing == null`, false},
+		{`.missing != 1`, false},
+		{`.items[1].sku == "B-2"`, true},
+		{`.items[2].sku == "B-2"`, false},
+		{`.["unit price"] == 3`, true},
+		{`.amount == "150.5"`, false},
+		{`.amount != "150.5"`, true},
+		{`.amount =~ "^150\\."`, true},
+		{`.items == 1`, false},
+		{`.type < "P" && .type > "N"`, true},
+		{`(key == "x" || key == "user-1") && !(partition == 1)`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			f, err := Parse(tt.expression)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if got := f.Match(testEntry()); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_MatchNonJSONValue(t *testing.T) {
+	entry := testEntry()
+	entry.Data = []byte("not json")
+	entry.Key = nil
+	entry.Partition, entry.Offset = -1, -1
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{`.type == "OrderCreated"`, false},
+		{`!(.type == "OrderCreated")`, true},
+		{`value == "not json"`, true},
+		{`key == null`, true},
+		{`key`, false},
+		{`partition >= 0`, false},
+		{`offset`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			f, err := Parse(tt.expression)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if got := f.Match(entry); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_NilMatchesEverything(t *testing.T) {
+	var f *Filter
+	if !f.Match(testEntry()) {
+		t.Error("nil filter should match every entry")
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{``, "unexpected end of expression"},
+		{`key ==`, "unexpected end of expression"},
+		{`name == "x"`, `unknown field "name"`},
+		{`key == "x" &&`, "unexpected end of expression"},
+		{`(key == "x"`, "expected ')'"},
+		{`key == "x")`, `unexpected ")"`},
+		{`key =~ 1`, "must be followed by a regular expression string"},
+		{`key =~ "("`, "invalid regular expression"},
+		{`key == "x`, "unterminated string"},
+		{`timestamp > "yesterday"`, `invalid timestamp "yesterday"`},
+		{`header.source == "x"`, "expected '[' after header"},
+		{`.items[x] == 1`, "invalid array index"},
+		{`key # "x"`, "unexpected character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := Parse(tt.expression)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenPath
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+// token is a lexical token of a filter expression
+type token struct {
+	kind  tokenKind
+	text  string
+	start int // Byte offset in the expression, for error messages
+}
+
+// operators are the operator tokens, longest first so that "==" is not read as "="
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")", "[", "]"}
+
+// lex splits an expression into tokens
+func lex(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			n, err := stringLength(expression[i:])
+			if err != nil {
+				return nil, fmt.Errorf("at position %d: %w", i, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: expression[i : i+n], start: i})
+			i += n
+		case c == '.' && (i+1 == len(expression) || !isDigit(expression[i+1])):
+			n, err := pathLength(expression[i:])
+			if err != nil {
+				return nil, fmt.Errorf("at position %d: %w", i, err)
+			}
+			tokens = append(tokens, token{kind: tokenPath, text: expression[i : i+n], start: i})
+			i += n
+		case isDigit(c) || c == '.' || (c == '-' && i+1 < len(expression) && (isDigit(expression[i+1]) || expression[i+1] == '.')):
+			start := i
+			i++
+			for i < len(expression) && (isDigit(expression[i]) || strings.IndexByte(".eE+-", expression[i]) >= 0) {
+				// A sign is only part of the number after an exponent
+				if (expression[i] == '+' || expression[i] == '-') && expression[i-1] != 'e' && expression[i-1] != 'E' {
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expression[start:i], start: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(expression) && (isIdentStart(expression[i]) || isDigit(expression[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: expression[start:i], start: start})
+		default:
+			operator := ""
+			for _, op := range operators {
+				if strings.HasPrefix(expression[i:], op) {
+					operator = op
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, start: i})
+			i += len(operator)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, start: len(expression)}), nil
+}
+
+// stringLength returns the length of the double-quoted string at the start of s, quotes included
+func stringLength(s string) (int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string")
+}
+
+// pathLength returns the length of the JSON path at the start of s: keys, indexes and quoted keys in brackets
+func pathLength(s string) (int, error) {
+	i := 1
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '.' || c == '_' || c == '-' || c == '$' || c == '@' || isIdentStart(c) || isDigit(c) || c >= 0x80:
+			i++
+		case c == '[':
+			if i+1 < len(s) && s[i+1] == '"' {
+				n, err := stringLength(s[i+1:])
+				if err != nil {
+					return 0, err
+				}
+				i += 1 + n
+			}
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return 0, fmt.Errorf("missing ']' in path")
+			}
+			i += end + 1
+		default:
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,229 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/jsonpath"
+)
+
+// timeLayouts are the layouts of timestamps compared with the timestamp field, in order of preference
+// Layouts without a zone are in local time
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parser is a recursive descent parser:
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" or ")" | comparison
+//	comparison = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) operand | ( "=~" | "!~" ) string ]
+//	operand    = field | "header" "[" string "]" | path | string | number | "true" | "false" | "null"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator
+func (p *parser) accept(operator string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.text == operator {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at position %d: %s", p.peek().start, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	if p.accept("(") {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return existsNode{operand: left}, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if left, right, err = timestampOperands(left, right); err != nil {
+			return nil, err
+		}
+		return compareNode{op: t.text, left: left, right: right}, nil
+	case "=~", "!~":
+		p.next()
+		pattern := p.next()
+		if pattern.kind != tokenString {
+			return nil, fmt.Errorf("at position %d: %s must be followed by a regular expression string", pattern.start, t.text)
+		}
+		s, err := unquote(pattern)
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("at position %d: invalid regular expression: %w", pattern.start, err)
+		}
+		return regexNode{operand: left, re: re, negate: t.text == "!~"}, nil
+	default:
+		return existsNode{operand: left}, nil
+	}
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		s, err := unquote(t)
+		if err != nil {
+			return nil, err
+		}
+		return literal{v: value{kind: kindString, s: s}}, nil
+	case tokenNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("at position %d: invalid number %q", t.start, t.text)
+		}
+		return literal{v: value{kind: kindNumber, n: n}}, nil
+	case tokenPath:
+		jp, err := jsonpath.Parse(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("at position %d: %w", t.start, err)
+		}
+		return path{path: jp}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true", "false":
+			return literal{v: value{kind: kindBool, b: t.text == "true"}}, nil
+		case "null":
+			return literal{v: value{kind: kindNull}}, nil
+		case "header":
+			if !p.accept("[") {
+				return nil, p.errorf("expected '[' after header")
+			}
+			name := p.next()
+			if name.kind != tokenString {
+				return nil, fmt.Errorf("at position %d: expected a header name string", name.start)
+			}
+			key, err := unquote(name)
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept("]") {
+				return nil, p.errorf("expected ']'")
+			}
+			return header(key), nil
+		}
+		if fields[t.text] {
+			return field(t.text), nil
+		}
+		return nil, fmt.Errorf("at position %d: unknown field %q (fields are key, value, topic, partition, offset, timestamp and header[\"name\"]; JSON paths start with '.')", t.start, t.text)
+	case tokenEOF:
+		return nil, fmt.Errorf("at position %d: unexpected end of expression", t.start)
+	default:
+		return nil, fmt.Errorf("at position %d: unexpected %q", t.start, t.text)
+	}
+}
+
+// timestampOperands parses a string compared with the timestamp field as a time
+func timestampOperands(left, right operand) (operand, operand, error) {
+	var err error
+	if left == field("timestamp") {
+		right, err = timeLiteral(right)
+	} else if right == field("timestamp") {
+		left, err = timeLiteral(left)
+	}
+	return left, right, err
+}
+
+func timeLiteral(o operand) (operand, error) {
+	l, ok := o.(literal)
+	if !ok || l.v.kind != kindString {
+		return o, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, l.v.s, time.Local); err == nil {
+			return literal{v: value{kind: kindTime, t: t}}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid timestamp %q (use RFC3339, e.g. 2026-02-01T14:00:00Z, or local time YYYY-MM-DD[ HH:MM[:SS]])", l.v.s)
+}
+
+func unquote(t token) (string, error) {
+	s, err := strconv.Unquote(t.text)
+	if err != nil {
+		return "", fmt.Errorf("at position %d: invalid string %s", t.start, t.text)
+	}
+	return s, nil
+}
@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/importer"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+type ImportConfig struct {
+	Reader      importer.Reader // Messages to import (JSON lines or CSV)
+	Output      io.Writer       // Recording to write
+	Compression transcoder.Compression
+	// Index is an optional writer for the index of the recording, written once all messages are imported
+	Index io.Writer
+}
+
+// Import writes the messages of an import file to a recording and returns their number
+func Import(ctx context.Context, cfg ImportConfig) (int, error) {
+	if cfg.Reader == nil {
+		return 0, errors.New("reader is required")
+	}
+	encoder, err := transcoder.NewCompressedEncodeWriter(cfg.Output, cfg.Compression)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create encoder: %w", err)
+	}
+	defer encoder.Close()
+
+	count := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return count, ctx.Err()
+		default:
+		}
+
+		entry, err := cfg.Reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		if _, err := encoder.WriteEntry(entry); err != nil {
+			return count, fmt.Errorf("failed to write message %d: %w", count+1, err)
+		}
+		count++
+	}
+	if err := encoder.Flush(); err != nil {
+		return count, fmt.Errorf("failed to write recording: %w", err)
+	}
+	if cfg.Index != nil {
+		if err := writeRecordingIndex(encoder, cfg.Index); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
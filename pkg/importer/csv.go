@@ -0,0 +1,117 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/export"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// csvReader reads a message per row after the header row. Empty cells are missing values, except for values,
+// which are empty
+type csvReader struct {
+	reader  *csv.Reader
+	opts    Options
+	columns map[string]int // Index of the cells of each column
+}
+
+func newCSVReader(r io.Reader, opts Options) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	names, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("missing CSV header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header row: %w", err)
+	}
+
+	columns := make(map[string]int, len(names))
+	for i, name := range names {
+		switch name {
+		case string(export.ColumnTimestamp), fieldTimestampType, string(export.ColumnTopic), string(export.ColumnPartition),
+			string(export.ColumnOffset), string(export.ColumnKey), string(export.ColumnValue), fieldData, string(export.ColumnHeaders):
+		default:
+			return nil, fmt.Errorf("unknown CSV column %q: expected timestamp, timestampType, topic, partition, offset, key, value (or data) or headers", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate CSV column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns[string(export.ColumnTimestamp)]; !ok {
+		return nil, errors.New("missing CSV column timestamp")
+	}
+	_, hasValue := columns[string(export.ColumnValue)]
+	if _, hasData := columns[fieldData]; hasValue && hasData {
+		return nil, fmt.Errorf("CSV columns %s and value cannot be used together", fieldData)
+	}
+	return &csvReader{reader: reader, opts: opts, columns: columns}, nil
+}
+
+// cell returns the cell of a column in a row, empty if there is no such column
+func (r *csvReader) cell(row []string, name string) string {
+	if i, ok := r.columns[name]; ok {
+		return row[i]
+	}
+	return ""
+}
+
+func (r *csvReader) Read() (*transcoder.Entry, error) {
+	row, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	line, _ := r.reader.FieldPos(0)
+	entry, err := r.entry(row)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", line, err)
+	}
+	return entry, nil
+}
+
+func (r *csvReader) entry(row []string) (*transcoder.Entry, error) {
+	entry := &transcoder.Entry{Topic: r.cell(row, string(export.ColumnTopic)), Partition: -1, Offset: -1}
+	var err error
+	if entry.Timestamp, err = parseTimestamp(r.cell(row, string(export.ColumnTimestamp))); err != nil {
+		return nil, err
+	}
+	if entry.TimestampType, err = parseTimestampType(r.cell(row, fieldTimestampType)); err != nil {
+		return nil, err
+	}
+	if partition := r.cell(row, string(export.ColumnPartition)); partition != "" {
+		if entry.Partition, err = strconv.Atoi(partition); err != nil {
+			return nil, fmt.Errorf("invalid partition %q", partition)
+		}
+	}
+	if offset := r.cell(row, string(export.ColumnOffset)); offset != "" {
+		if entry.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid offset %q", offset)
+		}
+	}
+	if key := r.cell(row, string(export.ColumnKey)); key != "" {
+		if entry.Key, err = decodeString(key, r.opts.KeyEncoding); err != nil {
+			return nil, fmt.Errorf("key: %w", err)
+		}
+	}
+	value := r.cell(row, string(export.ColumnValue))
+	if _, ok := r.columns[fieldData]; ok {
+		value = r.cell(row, fieldData)
+	}
+	if entry.Data, err = decodeString(value, r.opts.ValueEncoding); err != nil {
+		return nil, fmt.Errorf("value: %w", err)
+	}
+	if headers := r.cell(row, string(export.ColumnHeaders)); headers != "" {
+		if entry.Headers, err = parseHeaders([]byte(headers), r.opts.ValueEncoding); err != nil {
+			return nil, err
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,149 @@
+// Package importer reads messages from JSON lines and CSV files, such as those written by cat and export, to
+// write them to recordings
+//
+// JSON lines hold an object per message with the fields of cat (timestamp, timestampType, topic, partition,
+// offset, key, data and headers), the value being named data or value. CSV files start with a header row
+// naming the same columns. Keys, values and header values are strings in an encoding (UTF-8 text, base64 or
+// hex); in JSON lines, values that are not strings (objects, arrays, numbers) are imported as their JSON text.
+// The keyEncoding, valueEncoding and header encoding fields written by cat --show-encoding override the
+// encodings of their message, json-embedded included (the JSON text of an embedded value is its bytes)
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/export"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// Options are the encodings of the imported keys and values
+type Options struct {
+	KeyEncoding   export.Encoding // UTF-8 text if empty
+	ValueEncoding export.Encoding // UTF-8 text if empty, also used for header values
+}
+
+// Reader reads messages from an import file
+type Reader interface {
+	// Read returns the next message, or io.EOF after the last one
+	Read() (*transcoder.Entry, error)
+}
+
+// NewReader creates a reader of an import format: JSON lines or CSV
+func NewReader(r io.Reader, format export.Format, opts Options) (Reader, error) {
+	if opts.KeyEncoding == "" {
+		opts.KeyEncoding = export.EncodingUTF8
+	}
+	if opts.ValueEncoding == "" {
+		opts.ValueEncoding = export.EncodingUTF8
+	}
+	switch format {
+	case export.FormatJSONL:
+		return newJSONLReader(r, opts), nil
+	case export.FormatCSV:
+		return newCSVReader(r, opts)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q: expected jsonl or csv", format)
+	}
+}
+
+// Fields of the messages besides the export columns
+const (
+	fieldTimestampType = "timestampType"
+	fieldData          = "data" // The value, as named by cat
+)
+
+// parseTimestamp parses an RFC 3339 timestamp (with or without fractional seconds)
+func parseTimestamp(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, errors.New("missing timestamp")
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: expected RFC 3339, e.g. 2026-02-02T10:15:30.123Z", s)
+	}
+	return t.UTC(), nil
+}
+
+// parseTimestampType parses a timestamp type, unknown if empty
+func parseTimestampType(s string) (transcoder.TimestampType, error) {
+	if s == "" {
+		return transcoder.TimestampTypeUnknown, nil
+	}
+	return transcoder.ParseTimestampType(s)
+}
+
+// header is a message header as written by cat and export, with an encoded value
+type header struct {
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	Encoding string          `json:"encoding"` // Set by cat --show-encoding, overrides the value encoding
+}
+
+// entryEncoding returns the encoding named in a message, as written by cat, or the default one. Keys and values
+// cat decoded with their schema are refused: their JSON is not the recorded bytes
+func entryEncoding(name string, defaultEncoding export.Encoding) (export.Encoding, error) {
+	if name == "" {
+		return defaultEncoding, nil
+	}
+	if export.Encoding(strings.ToLower(name)) == export.EncodingSchemaDecoded {
+		return "", fmt.Errorf("%s: decoded by cat --schema-registry, the recorded bytes are lost (cat the recording without --schema-registry to import it)", name)
+	}
+	return export.ParseJSONEncoding(name)
+}
+
+// parseHeaders parses a JSON array of headers
+func parseHeaders(data []byte, encoding export.Encoding) ([]transcoder.Header, error) {
+	var parsed []header
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid headers: expected a list of {\"key\": ..., \"value\": ...} objects: %w", err)
+	}
+	headers := make([]transcoder.Header, len(parsed))
+	for i, h := range parsed {
+		headerEncoding, err := entryEncoding(h.Encoding, encoding)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: invalid encoding: %w", h.Key, err)
+		}
+		value, err := decodeJSONBytes(h.Value, headerEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", h.Key, err)
+		}
+		headers[i] = transcoder.Header{Key: h.Key, Value: value}
+	}
+	return headers, nil
+}
+
+// decodeJSONBytes returns the bytes of a JSON value: nil for null or a missing value, the decoded bytes of a
+// string, and the JSON text of other values. Embedded JSON values are their JSON text, strings and null included
+func decodeJSONBytes(raw json.RawMessage, encoding export.Encoding) ([]byte, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	switch {
+	case trimmed == "":
+		return nil, nil
+	case encoding == export.EncodingJSONEmbedded:
+		return []byte(trimmed), nil
+	case trimmed == "null":
+		return nil, nil
+	case trimmed[0] == '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return decodeString(s, encoding)
+	default:
+		return []byte(trimmed), nil
+	}
+}
+
+// decodeString decodes a key or value in an encoding
+func decodeString(s string, encoding export.Encoding) ([]byte, error) {
+	data, err := encoding.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", encoding, err)
+	}
+	return data, nil
+}
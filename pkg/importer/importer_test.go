@@ -0,0 +1,185 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/export"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+func readAll(t *testing.T, input string, format export.Format, opts Options) ([]*transcoder.Entry, error) {
+	t.Helper()
+	reader, err := NewReader(strings.NewReader(input), format, opts)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*transcoder.Entry
+	for {
+		entry, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func describe(entries []*transcoder.Entry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s %s %s/%d/%d key=%q data=%q headers=%v\n",
+			e.Timestamp.Format(time.RFC3339Nano), e.TimestampType, e.Topic, e.Partition, e.Offset, e.Key, e.Data, e.Headers)
+	}
+	return b.String()
+}
+
+func TestReader_JSONL(t *testing.T) {
+	input := `{"timestamp":"2026-02-02T10:15:30.123Z","timestampType":"CreateTime","topic":"orders","partition":1,"offset":7,"key":"k1","data":"{\"a\":1}","headers":[{"key":"trace","value":"t-1"}],"valueSchemaId":3}
+
+{"timestamp":1700000000000,"value":{"embedded":[1,2]},"key":null}
+{
+  "timestamp": "2026-02-02T11:00:00+01:00",
+  "data": ""
+}
+`
+	entries, err := readAll(t, input, export.FormatJSONL, Options{})
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	want := `2026-02-02T10:15:30.123Z CreateTime orders/1/7 key="k1" data="{\"a\":1}" headers=[{trace [116 45 49]}]
+2023-11-14T22:13:20Z  /-1/-1 key="" data="{\"embedded\":[1,2]}" headers=[]
+2026-02-02T10:00:00Z  /-1/-1 key="" data="" headers=[]
+`
+	if got := describe(entries); got != want {
+		t.Errorf("entries:\n%s\nwant:\n%s", got, want)
+	}
+
+	entries, err = readAll(t, `{"timestamp":"2026-02-02T10:15:30Z","key":"AP8=","value":"6869","headers":[{"key":"h","value":"00"}]}`,
+		export.FormatJSONL, Options{KeyEncoding: export.EncodingBase64, ValueEncoding: export.EncodingHex})
+	if err != nil || len(entries) != 1 || !bytes.Equal(entries[0].Key, []byte{0, 0xff}) || string(entries[0].Data) != "hi" || !bytes.Equal(entries[0].Headers[0].Value, []byte{0}) {
+		t.Errorf("encoded entries = %s, %v", describe(entries), err)
+	}
+
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{`{"data":"x"}`, "message 1: missing timestamp"},
+		{`{"timestamp":"yesterday"}`, "invalid timestamp"},
+		{`{"timestamp":1,"data":"a","value":"b"}`, "both data and value are set"},
+		{`{"timestamp":1,"timestampType":"BrokerTime"}`, "unknown timestamp type"},
+		{`{"timestamp":1,"headers":{"k":"v"}}`, "invalid headers"},
+		{`{"timestamp":1}` + "\n" + `{"timestamp":`, "message 2: invalid JSON"},
+	} {
+		if _, err := readAll(t, tc.input, export.FormatJSONL, Options{}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.input, tc.want, err)
+		}
+	}
+	if _, err := readAll(t, `{"timestamp":1,"key":"not base64!"}`, export.FormatJSONL, Options{KeyEncoding: export.EncodingBase64}); err == nil || !strings.Contains(err.Error(), "key: invalid base64") {
+		t.Errorf("expected an invalid base64 error, got %v", err)
+	}
+}
+
+// TestReader_JSONL_EntryEncodings reads the encodings reported by cat --show-encoding
+func TestReader_JSONL_EntryEncodings(t *testing.T) {
+	input := `{"timestamp":1,"key":"6b31","data":{"a":1},"keyEncoding":"hex","valueEncoding":"json-embedded","headers":[{"key":"h","value":"AP8=","encoding":"base64"},{"key":"j","value":"null","encoding":"json-embedded"}]}
+{"timestamp":1,"key":"k2","data":"\"quoted\"","keyEncoding":"utf8","valueEncoding":"json-embedded"}
+{"timestamp":1,"data":"text","valueEncoding":"utf8"}
+`
+	entries, err := readAll(t, input, export.FormatJSONL, Options{KeyEncoding: export.EncodingBase64, ValueEncoding: export.EncodingBase64})
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	want := `1970-01-01T00:00:00.001Z  /-1/-1 key="k1" data="{\"a\":1}" headers=[{h [0 255]} {j [34 110 117 108 108 34]}]
+1970-01-01T00:00:00.001Z  /-1/-1 key="k2" data="\"\\\"quoted\\\"\"" headers=[]
+1970-01-01T00:00:00.001Z  /-1/-1 key="" data="text" headers=[]
+`
+	if got := describe(entries); got != want {
+		t.Errorf("entries:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := readAll(t, `{"timestamp":1,"valueEncoding":"base32"}`, export.FormatJSONL, Options{}); err == nil || !strings.Contains(err.Error(), "invalid valueEncoding") {
+		t.Errorf("expected an invalid valueEncoding error, got %v", err)
+	}
+	if _, err := readAll(t, `{"timestamp":1,"key":{"id":1},"keyEncoding":"schema-decoded"}`, export.FormatJSONL, Options{}); err == nil || !strings.Contains(err.Error(), "decoded by cat --schema-registry") {
+		t.Errorf("expected keys decoded with their schema to be refused, got %v", err)
+	}
+}
+
+func TestReader_CSV(t *testing.T) {
+	input := "timestamp,topic,partition,offset,key,data,headers\n" +
+		"2026-02-02T10:15:30.123Z,orders,1,7,k1,\"{\"\"a\"\":1}\",\"[{\"\"key\"\":\"\"trace\"\",\"\"value\"\":\"\"t-1\"\"}]\"\n" +
+		"2026-02-02T10:15:31Z,,,,,,\n"
+	entries, err := readAll(t, input, export.FormatCSV, Options{})
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	want := `2026-02-02T10:15:30.123Z  orders/1/7 key="k1" data="{\"a\":1}" headers=[{trace [116 45 49]}]
+2026-02-02T10:15:31Z  /-1/-1 key="" data="" headers=[]
+`
+	if got := describe(entries); got != want {
+		t.Errorf("entries:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{"", "missing CSV header row"},
+		{"timestamp,payload\n", `unknown CSV column "payload"`},
+		{"key\n", "missing CSV column timestamp"},
+		{"timestamp,data,value\n", "cannot be used together"},
+		{"timestamp,partition\n2026-02-02T10:15:31Z,one\n", "line 2: invalid partition"},
+	} {
+		if _, err := readAll(t, tc.input, export.FormatCSV, Options{}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: expected an error containing %q, got %v", tc.input, tc.want, err)
+		}
+	}
+	if _, err := NewReader(strings.NewReader(""), export.FormatParquet, Options{}); err == nil {
+		t.Error("expected an error for Parquet")
+	}
+}
+
+// TestReader_ExportRoundTrip imports exported messages back, for every format and binary-safe encoding
+func TestReader_ExportRoundTrip(t *testing.T) {
+	entries := []*transcoder.Entry{
+		{
+			Timestamp: time.UnixMilli(1700000000123).UTC(), TimestampType: transcoder.TimestampTypeUnknown,
+			Topic: "orders", Partition: 2, Offset: 41, Key: []byte{0, 'k'}, Data: []byte{0xff, 0, '"', ','},
+			Headers: []transcoder.Header{{Key: "trace", Value: []byte{0xfe}}},
+		},
+		{Timestamp: time.UnixMilli(1700000001000).UTC(), TimestampType: transcoder.TimestampTypeUnknown, Partition: -1, Offset: -1, Data: []byte("plain")},
+	}
+	for _, format := range []export.Format{export.FormatJSONL, export.FormatCSV} {
+		for _, encoding := range []export.Encoding{export.EncodingBase64, export.EncodingHex} {
+			var out bytes.Buffer
+			writer, err := export.NewWriter(&out, format, export.Options{KeyEncoding: encoding, ValueEncoding: encoding})
+			if err != nil {
+				t.Fatalf("NewWriter failed: %v", err)
+			}
+			for _, entry := range entries {
+				if err := writer.Write(entry); err != nil {
+					t.Fatalf("Write failed: %v", err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			imported, err := readAll(t, out.String(), format, Options{KeyEncoding: encoding, ValueEncoding: encoding})
+			if err != nil {
+				t.Fatalf("%s/%s: import failed: %v", format, encoding, err)
+			}
+			if got, want := describe(imported), describe(entries); got != want {
+				t.Errorf("%s/%s: round trip gave:\n%s\nwant:\n%s", format, encoding, got, want)
+			}
+		}
+	}
+}
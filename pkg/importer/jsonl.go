@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// jsonMessage is a message in JSON lines. Fields other than these (e.g. the schema IDs of cat) are ignored
+type jsonMessage struct {
+	Timestamp     json.RawMessage `json:"timestamp"` // RFC 3339 string or Unix milliseconds
+	TimestampType string          `json:"timestampType"`
+	Topic         *string         `json:"topic"`
+	Partition     *int            `json:"partition"`
+	Offset        *int64          `json:"offset"`
+	Key           json.RawMessage `json:"key"`
+	Data          json.RawMessage `json:"data"`
+	Value         json.RawMessage `json:"value"`
+	Headers       json.RawMessage `json:"headers"`
+	KeyEncoding   string          `json:"keyEncoding"`   // Set by cat --show-encoding, overrides the options
+	ValueEncoding string          `json:"valueEncoding"` // Set by cat --show-encoding, overrides the options
+}
+
+// jsonlReader reads a JSON object per message (objects may also span lines)
+type jsonlReader struct {
+	decoder *json.Decoder
+	opts    Options
+	n       int
+}
+
+func newJSONLReader(r io.Reader, opts Options) *jsonlReader {
+	return &jsonlReader{decoder: json.NewDecoder(r), opts: opts}
+}
+
+func (r *jsonlReader) Read() (*transcoder.Entry, error) {
+	var msg jsonMessage
+	if err := r.decoder.Decode(&msg); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("message %d: invalid JSON: %w", r.n+1, err)
+	}
+	r.n++
+	entry, err := r.entry(&msg)
+	if err != nil {
+		return nil, fmt.Errorf("message %d: %w", r.n, err)
+	}
+	return entry, nil
+}
+
+func (r *jsonlReader) entry(msg *jsonMessage) (*transcoder.Entry, error) {
+	entry := &transcoder.Entry{Partition: -1, Offset: -1}
+	var err error
+	if entry.Timestamp, err = parseJSONTimestamp(msg.Timestamp); err != nil {
+		return nil, err
+	}
+	if entry.TimestampType, err = parseTimestampType(msg.TimestampType); err != nil {
+		return nil, err
+	}
+	if msg.Topic != nil {
+		entry.Topic = *msg.Topic
+	}
+	if msg.Partition != nil {
+		entry.Partition = *msg.Partition
+	}
+	if msg.Offset != nil {
+		entry.Offset = *msg.Offset
+	}
+	keyEncoding, err := entryEncoding(msg.KeyEncoding, r.opts.KeyEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyEncoding: %w", err)
+	}
+	valueEncoding, err := entryEncoding(msg.ValueEncoding, r.opts.ValueEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid valueEncoding: %w", err)
+	}
+	if entry.Key, err = decodeJSONBytes(msg.Key, keyEncoding); err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+
+	value := msg.Value
+	if msg.Data != nil {
+		if msg.Value != nil {
+			return nil, fmt.Errorf("both %s and value are set", fieldData)
+		}
+		value = msg.Data
+	}
+	if entry.Data, err = decodeJSONBytes(value, valueEncoding); err != nil {
+		return nil, fmt.Errorf("value: %w", err)
+	}
+	if headers := strings.TrimSpace(string(msg.Headers)); headers != "" && headers != "null" {
+		if entry.Headers, err = parseHeaders(msg.Headers, r.opts.ValueEncoding); err != nil {
+			return nil, err
+		}
+	}
+	return entry, nil
+}
+
+// parseJSONTimestamp parses a timestamp as an RFC 3339 string or a number of Unix milliseconds
+func parseJSONTimestamp(raw json.RawMessage) (time.Time, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return parseTimestamp(s)
+	}
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return time.Time{}, errors.New("missing timestamp")
+	}
+	millis, err := strconv.ParseInt(trimmed, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %s: expected an RFC 3339 string or Unix milliseconds", trimmed)
+	}
+	return time.UnixMilli(millis).UTC(), nil
+}
@@ -0,0 +1,19 @@
+package pkg
+
+import (
+	"io"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// BuildIndex reads a recording and writes its index to output
+func BuildIndex(reader io.ReadSeeker, output io.Writer) (*transcoder.Index, error) {
+	index, err := transcoder.BuildIndex(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := transcoder.WriteIndex(output, index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
@@ -0,0 +1,249 @@
+// Package jsonpath addresses values in JSON documents with paths such as .order.items[0].sku
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a path to a value in a JSON document: a sequence of object keys and array indexes
+// The empty path (".") addresses the whole document
+type Path []Segment
+
+// Segment is an object key or an array index of a Path
+type Segment struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// Parse parses a path: "." followed by keys separated by dots, array indexes in brackets ([0]) and
+// keys with special characters in quoted brackets (["first name"]), e.g. .order.items[0]["unit price"]
+func Parse(s string) (Path, error) {
+	if !strings.HasPrefix(s, ".") {
+		return nil, fmt.Errorf("invalid path %q: paths start with '.'", s)
+	}
+	var path Path
+	for i := 1; i < len(s); {
+		switch {
+		case s[i] == '[':
+			segment, n, err := parseBracket(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %w", s, err)
+			}
+			path = append(path, segment)
+			i += n
+		case s[i] == '.' && len(path) > 0:
+			i++
+			fallthrough
+		default:
+			start := i
+			for i < len(s) && isKeyChar(s[i]) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("invalid path %q: expected a key at position %d", s, start)
+			}
+			path = append(path, Segment{Key: s[start:i]})
+		}
+	}
+	return path, nil
+}
+
+// parseBracket parses an index ([0]) or a quoted key (["key"]) and returns the number of bytes read
+func parseBracket(s string) (Segment, int, error) {
+	if len(s) > 1 && s[1] == '"' {
+		// Quoted key: find the closing quote, then the bracket
+		var key string
+		n, err := quotedLength(s[1:])
+		if err != nil {
+			return Segment{}, 0, err
+		}
+		if key, err = strconv.Unquote(s[1 : 1+n]); err != nil {
+			return Segment{}, 0, fmt.Errorf("invalid quoted key %s", s[1:1+n])
+		}
+		if len(s) <= 1+n || s[1+n] != ']' {
+			return Segment{}, 0, fmt.Errorf("expected ']' after quoted key")
+		}
+		return Segment{Key: key}, n + 2, nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return Segment{}, 0, fmt.Errorf("missing ']'")
+	}
+	index, err := strconv.Atoi(s[1:end])
+	if err != nil || index < 0 {
+		return Segment{}, 0, fmt.Errorf("invalid array index %q", s[1:end])
+	}
+	return Segment{Index: index, IsIndex: true}, end + 1, nil
+}
+
+// quotedLength returns the length of the double-quoted string at the start of s, quotes included
+func quotedLength(s string) (int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted key")
+}
+
+func isKeyChar(c byte) bool {
+	return c == '_' || c == '-' || c == '$' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+// String returns the path in the syntax accepted by Parse
+func (p Path) String() string {
+	if len(p) == 0 {
+		return "."
+	}
+	var b strings.Builder
+	for i, segment := range p {
+		key := segment.Key != "" && strings.IndexFunc(segment.Key, func(r rune) bool { return r < 0x80 && !isKeyChar(byte(r)) }) < 0
+		if i == 0 && !key {
+			b.WriteString(".") // Paths start with '.', also before a bracket
+		}
+		switch {
+		case segment.IsIndex:
+			fmt.Fprintf(&b, "[%d]", segment.Index)
+		case key:
+			b.WriteString(".")
+			b.WriteString(segment.Key)
+		default:
+			fmt.Fprintf(&b, "[%s]", strconv.Quote(segment.Key))
+		}
+	}
+	return b.String()
+}
+
+// Decode decodes a JSON document, keeping numbers as json.Number so that they are encoded again unchanged
+func Decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON document")
+	}
+	return doc, nil
+}
+
+// Get returns the value at the path in a document decoded by Decode, and whether it exists
+func (p Path) Get(doc any) (any, bool) {
+	value := doc
+	for _, segment := range p {
+		var ok bool
+		if value, ok = child(value, segment); !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// child returns the value of a segment in an object or array
+func child(value any, segment Segment) (any, bool) {
+	if segment.IsIndex {
+		array, ok := value.([]any)
+		if !ok || segment.Index >= len(array) {
+			return nil, false
+		}
+		return array[segment.Index], true
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	v, ok := object[segment.Key]
+	return v, ok
+}
+
+// Set sets the value at the path in a document decoded by Decode and returns the updated document
+// Missing objects along the path are created; array indexes must exist, or be the length of the array to append
+func (p Path) Set(doc any, value any) (any, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	segment := p[0]
+	if segment.IsIndex {
+		array, ok := doc.([]any)
+		if !ok || segment.Index > len(array) {
+			return nil, fmt.Errorf("cannot set %s: no array element %d", p, segment.Index)
+		}
+		if segment.Index == len(array) {
+			array = append(array, nil)
+		}
+		v, err := p[1:].Set(array[segment.Index], value)
+		if err != nil {
+			return nil, err
+		}
+		array[segment.Index] = v
+		return array, nil
+	}
+	object, ok := doc.(map[string]any)
+	if !ok {
+		if doc != nil {
+			return nil, fmt.Errorf("cannot set %s: the parent of %q is not an object", p, segment.Key)
+		}
+		object = map[string]any{}
+	}
+	v, err := p[1:].Set(object[segment.Key], value)
+	if err != nil {
+		return nil, err
+	}
+	object[segment.Key] = v
+	return object, nil
+}
+
+// Delete removes the value at the path from a document decoded by Decode and reports whether it existed
+// Deleting an array element shifts the following elements; the whole document (".") cannot be deleted
+func (p Path) Delete(doc any) (any, bool) {
+	if len(p) == 0 {
+		return doc, false
+	}
+	parent, ok := p[:len(p)-1].Get(doc)
+	if !ok {
+		return doc, false
+	}
+	last := p[len(p)-1]
+	if last.IsIndex {
+		array, ok := parent.([]any)
+		if !ok || last.Index >= len(array) {
+			return doc, false
+		}
+		array = append(array[:last.Index], array[last.Index+1:]...)
+		if len(p) == 1 {
+			return array, true
+		}
+		// The slice header changed: store it back in its parent
+		doc, _ = p[:len(p)-1].Set(doc, array)
+		return doc, true
+	}
+	object, ok := parent.(map[string]any)
+	if !ok {
+		return doc, false
+	}
+	if _, ok := object[last.Key]; !ok {
+		return doc, false
+	}
+	delete(object, last.Key)
+	return doc, true
+}
+
+// Encode encodes a document decoded by Decode, without escaping HTML characters
+func Encode(doc any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
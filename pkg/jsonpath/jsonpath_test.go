@@ -0,0 +1,119 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		path string
+		want Path
+	}{
+		{".", nil},
+		{".type", Path{{Key: "type"}}},
+		{".order.items[0].sku", Path{{Key: "order"}, {Key: "items"}, {Index: 0, IsIndex: true}, {Key: "sku"}}},
+		{`.["unit price"]`, Path{{Key: "unit price"}}},
+		{`.a["b.c"][2]`, Path{{Key: "a"}, {Key: "b.c"}, {Index: 2, IsIndex: true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := Parse(tt.path)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Parse = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("segment %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+			if s := got.String(); s != tt.path {
+				t.Errorf("String = %q, want %q", s, tt.path)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, path := range []string{"", "type", "..a", ".a.", ".a[", ".a[-1]", `.a["b]`, `.a["b"`} {
+		if _, err := Parse(path); err == nil {
+			t.Errorf("Parse(%q): expected an error", path)
+		}
+	}
+}
+
+func TestPath_Get(t *testing.T) {
+	doc, err := Decode([]byte(`{"order":{"id":12345678901234567890,"items":[{"sku":"A-1"}]}}`))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	v, ok := Path{{Key: "order"}, {Key: "id"}}.Get(doc)
+	if !ok || v != json.Number("12345678901234567890") {
+		t.Errorf("Get(.order.id) = %v, %v; want the number unchanged", v, ok)
+	}
+	v, ok = Path{{Key: "order"}, {Key: "items"}, {Index: 0, IsIndex: true}, {Key: "sku"}}.Get(doc)
+	if !ok || v != "A-1" {
+		t.Errorf("Get(.order.items[0].sku) = %v, %v", v, ok)
+	}
+	if _, ok := (Path{{Key: "order"}, {Key: "items"}, {Index: 1, IsIndex: true}}).Get(doc); ok {
+		t.Error("Get(.order.items[1]) should not exist")
+	}
+	if _, ok := (Path{{Key: "order"}, {Key: "id"}, {Key: "x"}}).Get(doc); ok {
+		t.Error("Get(.order.id.x) should not exist")
+	}
+}
+
+func TestPath_SetDelete(t *testing.T) {
+	doc, err := Decode([]byte(`{"tenant":"prod","items":[1,2,3],"user":{"email":"a@prod.example.com"}}`))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	steps := []struct {
+		path  string
+		value any
+	}{
+		{".tenant", "staging"},
+		{".meta.version", 2},
+		{".items[3]", 4},
+	}
+	for _, step := range steps {
+		path, err := Parse(step.path)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", step.path, err)
+		}
+		if doc, err = path.Set(doc, step.value); err != nil {
+			t.Fatalf("Set(%s) failed: %v", step.path, err)
+		}
+	}
+	for _, p := range []string{".items[0]", ".user.email"} {
+		path, _ := Parse(p)
+		var ok bool
+		if doc, ok = path.Delete(doc); !ok {
+			t.Errorf("Delete(%s): expected the value to exist", p)
+		}
+	}
+	if _, ok := (Path{{Key: "missing"}}).Delete(doc); ok {
+		t.Error("Delete(.missing) should report that nothing was deleted")
+	}
+
+	data, err := Encode(doc)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	want := `{"items":[2,3,4],"meta":{"version":2},"tenant":"staging","user":{}}`
+	if string(data) != want {
+		t.Errorf("Encode = %s, want %s", data, want)
+	}
+
+	if _, err := (Path{{Key: "tenant"}, {Key: "x"}}).Set(doc, 1); err == nil {
+		t.Error("Set(.tenant.x) should fail as .tenant is a string")
+	}
+	if _, err := (Path{{Key: "items"}, {Index: 5, IsIndex: true}}).Set(doc, 1); err == nil {
+		t.Error("Set(.items[5]) should fail past the end of the array")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"sort"
 
+	kafkapkg "github.com/lolocompany/kafka-replay/v2/pkg/kafka"
 	"github.com/segmentio/kafka-go"
 )
 
@@ -175,7 +176,7 @@ func getConsumerGroupOffsets(ctx context.Context, client *kafka.Client, brokerAd
 }
 
 // ListConsumerGroups lists all consumer groups in the cluster
-func ListConsumerGroups(ctx context.Context, brokers []string) ([]string, error) {
+func ListConsumerGroups(ctx context.Context, brokers []string, security *kafkapkg.Security) ([]string, error) {
 	// Create a client - we'll use the first broker address
 	if len(brokers) == 0 {
 		return nil, fmt.Errorf("at least one broker address is required")
@@ -183,14 +184,15 @@ func ListConsumerGroups(ctx context.Context, brokers []string) ([]string, error)
 
 	brokerAddr := kafka.TCP(brokers[0])
 	client := &kafka.Client{
-		Addr: brokerAddr,
+		Addr:      brokerAddr,
+		Transport: security.Transport(),
 	}
 
 	return listConsumerGroups(ctx, client, brokerAddr)
 }
 
 // DescribeConsumerGroup describes a specific consumer group
-func DescribeConsumerGroup(ctx context.Context, brokers []string, groupID string, includeOffsets bool, includeMembers bool) (*ConsumerGroupInfo, error) {
+func DescribeConsumerGroup(ctx context.Context, brokers []string, groupID string, includeOffsets bool, includeMembers bool, security *kafkapkg.Security) (*ConsumerGroupInfo, error) {
 	if len(brokers) == 0 {
 		return nil, fmt.Errorf("at least one broker address is required")
 	}
@@ -198,7 +200,8 @@ func DescribeConsumerGroup(ctx context.Context, brokers []string, groupID string
 	// Create a client using the first broker
 	brokerAddr := kafka.TCP(brokers[0])
 	client := &kafka.Client{
-		Addr: brokerAddr,
+		Addr:      brokerAddr,
+		Transport: security.Transport(),
 	}
 
 	// Find the group coordinator
@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Balancer is the strategy a producer uses to pick the partition of each message
+type Balancer int
+
+const (
+	// BalancerRoundRobin spreads messages evenly across partitions, ignoring keys (kafka-go's default)
+	BalancerRoundRobin Balancer = iota
+	// BalancerMurmur2 hashes keys with murmur2 like the Java client's default partitioner
+	// Messages without a key are spread round-robin
+	BalancerMurmur2
+	// BalancerCRC32 hashes keys with CRC32 like librdkafka's default partitioner (consistent_random)
+	BalancerCRC32
+	// BalancerLeastBytes sends each message to the partition that has received the fewest bytes
+	BalancerLeastBytes
+	// BalancerSticky hashes keys with murmur2 and sends messages without a key to one partition until a
+	// batch of StickyBatchBytes is full, like the Java client's sticky partitioner
+	BalancerSticky
+)
+
+// StickyBatchBytes is the number of bytes of keyless messages BalancerSticky sends to a partition before
+// switching to another one (the Java client's default batch.size)
+const StickyBatchBytes = 16 * 1024
+
+// String returns the name of the balancer
+func (b Balancer) String() string {
+	switch b {
+	case BalancerRoundRobin:
+		return "round-robin"
+	case BalancerMurmur2:
+		return "murmur2"
+	case BalancerCRC32:
+		return "crc32"
+	case BalancerLeastBytes:
+		return "least-bytes"
+	case BalancerSticky:
+		return "sticky"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(b))
+	}
+}
+
+// ParseBalancer parses a balancer name (round-robin, murmur2, crc32, least-bytes or sticky)
+func ParseBalancer(name string) (Balancer, error) {
+	switch strings.ToLower(name) {
+	case "", "round-robin", "roundrobin":
+		return BalancerRoundRobin, nil
+	case "murmur2":
+		return BalancerMurmur2, nil
+	case "crc32":
+		return BalancerCRC32, nil
+	case "least-bytes", "leastbytes":
+		return BalancerLeastBytes, nil
+	case "sticky":
+		return BalancerSticky, nil
+	default:
+		return BalancerRoundRobin, fmt.Errorf("unsupported balancer %q (supported: round-robin, murmur2, crc32, least-bytes, sticky)", name)
+	}
+}
+
+// kafkaBalancer returns the kafka-go balancer implementing b
+func (b Balancer) kafkaBalancer() kafkago.Balancer {
+	switch b {
+	case BalancerMurmur2:
+		return &kafkago.Murmur2Balancer{}
+	case BalancerCRC32:
+		return &kafkago.CRC32Balancer{}
+	case BalancerLeastBytes:
+		return &kafkago.LeastBytes{}
+	case BalancerSticky:
+		return &stickyBalancer{partitions: make(map[string]*stickyPartition)}
+	default:
+		return &kafkago.RoundRobin{}
+	}
+}
+
+// stickyBalancer implements BalancerSticky
+type stickyBalancer struct {
+	murmur2    kafkago.Murmur2Balancer
+	mutex      sync.Mutex
+	partitions map[string]*stickyPartition // Current partition for keyless messages, by topic
+}
+
+type stickyPartition struct {
+	partition int
+	bytes     int
+}
+
+func (b *stickyBalancer) Balance(msg kafkago.Message, partitions ...int) int {
+	if len(msg.Key) > 0 {
+		return b.murmur2.Balance(msg, partitions...)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	current, ok := b.partitions[msg.Topic]
+	if !ok || current.bytes >= StickyBatchBytes || !containsPartition(partitions, current.partition) {
+		next := partitions[rand.Intn(len(partitions))]
+		// Switch to another partition when there is one
+		if ok && len(partitions) > 1 {
+			for next == current.partition {
+				next = partitions[rand.Intn(len(partitions))]
+			}
+		}
+		current = &stickyPartition{partition: next}
+		b.partitions[msg.Topic] = current
+	}
+	current.bytes += len(msg.Value)
+	return current.partition
+}
+
+func containsPartition(partitions []int, partition int) bool {
+	for _, p := range partitions {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
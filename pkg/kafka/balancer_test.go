@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"bytes"
+	"testing"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+func TestParseBalancer(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want Balancer
+	}{
+		{"", BalancerRoundRobin},
+		{"round-robin", BalancerRoundRobin},
+		{"RoundRobin", BalancerRoundRobin},
+		{"murmur2", BalancerMurmur2},
+		{"CRC32", BalancerCRC32},
+		{"least-bytes", BalancerLeastBytes},
+		{"sticky", BalancerSticky},
+	} {
+		got, err := ParseBalancer(tc.name)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseBalancer(%q) = %s, %v, want %s", tc.name, got, err, tc.want)
+		}
+	}
+	if _, err := ParseBalancer("random"); err == nil {
+		t.Error("expected an error for an unknown balancer")
+	}
+}
+
+func TestStickyBalancer(t *testing.T) {
+	partitions := []int{0, 1, 2, 3}
+	value := bytes.Repeat([]byte("v"), 1000)
+	keyless := func(topic string) kafkago.Message {
+		return kafkago.Message{Topic: topic, Value: value}
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, b *stickyBalancer)
+	}{
+		{
+			name: "keyless messages stick to a partition until a batch is full",
+			run: func(t *testing.T, b *stickyBalancer) {
+				first := b.Balance(keyless("orders"), partitions...)
+				perBatch := (StickyBatchBytes + len(value) - 1) / len(value)
+				for i := 1; i < perBatch; i++ {
+					if p := b.Balance(keyless("orders"), partitions...); p != first {
+						t.Fatalf("message %d went to partition %d, want %d", i, p, first)
+					}
+				}
+				if p := b.Balance(keyless("orders"), partitions...); p == first {
+					t.Errorf("message after a full batch stayed on partition %d", p)
+				}
+			},
+		},
+		{
+			name: "partitions are sticky per topic",
+			run: func(t *testing.T, b *stickyBalancer) {
+				orders := b.Balance(keyless("orders"), partitions...)
+				b.Balance(keyless("payments"), partitions...)
+				for i := 0; i < 5; i++ {
+					b.Balance(keyless("payments"), partitions...)
+					if p := b.Balance(keyless("orders"), partitions...); p != orders {
+						t.Fatalf("orders message %d went to partition %d, want %d", i, p, orders)
+					}
+				}
+			},
+		},
+		{
+			name: "keyed messages are hashed with murmur2",
+			run: func(t *testing.T, b *stickyBalancer) {
+				var murmur2 kafkago.Murmur2Balancer
+				for _, key := range []string{"a", "b", "customer-42", "customer-43"} {
+					msg := kafkago.Message{Topic: "orders", Key: []byte(key), Value: value}
+					want := murmur2.Balance(msg, partitions...)
+					for i := 0; i < 3; i++ {
+						if p := b.Balance(msg, partitions...); p != want {
+							t.Errorf("key %q went to partition %d, want %d", key, p, want)
+						}
+					}
+				}
+				if b.partitions["orders"] != nil {
+					t.Error("keyed messages should not start a sticky batch")
+				}
+			},
+		},
+		{
+			name: "a partition that disappeared is replaced",
+			run: func(t *testing.T, b *stickyBalancer) {
+				first := b.Balance(keyless("orders"), partitions...)
+				var remaining []int
+				for _, p := range partitions {
+					if p != first {
+						remaining = append(remaining, p)
+					}
+				}
+				if p := b.Balance(keyless("orders"), remaining...); p == first {
+					t.Errorf("message went to partition %d, which is not available", p)
+				}
+			},
+		},
+		{
+			name: "a single partition is kept",
+			run: func(t *testing.T, b *stickyBalancer) {
+				for i := 0; i < 40; i++ {
+					if p := b.Balance(keyless("orders"), 2); p != 2 {
+						t.Fatalf("message %d went to partition %d, want 2", i, p)
+					}
+				}
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, BalancerSticky.kafkaBalancer().(*stickyBalancer))
+		})
+	}
+}
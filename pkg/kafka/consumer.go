@@ -19,9 +19,24 @@ type Consumer struct {
 	// conn and batch are used when no groupID is provided (direct partition mode)
 	conn  *kafkago.Conn
 	batch *kafkago.Batch
-	mu    sync.Mutex
+	// batchMessages counts the messages read from the current batch, and emptyFetch is set once a batch had none
+	batchMessages int
+	emptyFetch    bool
+	mu            sync.Mutex
 	// usingGroup indicates whether we're using consumer group mode
 	usingGroup bool
+	// topic and partition are read in direct partition mode
+	topic     string
+	partition int
+}
+
+// Partition returns the topic and partition read in direct partition mode
+// Returns false in consumer group mode, where partitions are assigned by the group
+func (c *Consumer) Partition() (string, int, bool) {
+	if c.usingGroup {
+		return "", 0, false
+	}
+	return c.topic, c.partition, true
 }
 
 // SetOffset sets the offset to a specific value.
@@ -39,6 +54,103 @@ func (c *Consumer) SetOffset(offset int64) error {
 	return err
 }
 
+// SetAbsoluteOffset moves to an absolute offset, unlike SetOffset, whose offset counts from the first offset
+// still in the partition. It fails if the offset is no longer in the partition (deleted by retention) or past its end.
+// Note: This only works in direct partition mode (no consumer group).
+func (c *Consumer) SetAbsoluteOffset(offset int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.usingGroup {
+		return fmt.Errorf("SetAbsoluteOffset is not supported when using consumer groups; offsets are managed automatically")
+	}
+
+	first, last, err := c.conn.ReadOffsets()
+	if err != nil {
+		return err
+	}
+	if offset < first {
+		return fmt.Errorf("offset %d is no longer in the partition, which now starts at offset %d (older messages were deleted)", offset, first)
+	}
+	if offset > last {
+		return fmt.Errorf("offset %d is past the end of the partition (offset %d)", offset, last)
+	}
+	_, err = c.conn.Seek(offset, kafkago.SeekAbsolute)
+	return err
+}
+
+// SetOffsetForTime moves to the first message with a timestamp at or after t, or to the end of
+// the partition if there is none, and returns the new offset.
+// Note: This only works in direct partition mode (no consumer group).
+func (c *Consumer) SetOffsetForTime(t time.Time) (int64, error) {
+	offset, err := c.OffsetForTime(t)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if offset < 0 {
+		if offset, err = c.conn.ReadLastOffset(); err != nil {
+			return 0, err
+		}
+	}
+	return c.conn.Seek(offset, kafkago.SeekAbsolute)
+}
+
+// OffsetForTime returns the offset of the first message with a timestamp at or after t,
+// or -1 if the partition has no such message (yet).
+// Note: This only works in direct partition mode (no consumer group).
+func (c *Consumer) OffsetForTime(t time.Time) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.usingGroup {
+		return 0, fmt.Errorf("time-based offset lookup is not supported when using consumer groups")
+	}
+
+	offset, err := c.conn.ReadOffset(t)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up offset for time %s: %w", t.Format(time.RFC3339), err)
+	}
+	return offset, nil
+}
+
+// LastOffset returns the offset the next message written to the partition will get (the high-watermark).
+// Note: This only works in direct partition mode (no consumer group).
+func (c *Consumer) LastOffset() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.usingGroup {
+		return 0, fmt.Errorf("LastOffset is not supported when using consumer groups")
+	}
+	return c.conn.ReadLastOffset()
+}
+
+// Position returns the absolute offset of the next message that will be read.
+// Note: This only works in direct partition mode (no consumer group).
+func (c *Consumer) Position() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.usingGroup {
+		return 0, fmt.Errorf("Position is not supported when using consumer groups")
+	}
+
+	offset, whence := c.conn.Offset()
+	switch whence {
+	case kafkago.SeekStart:
+		first, err := c.conn.ReadFirstOffset()
+		return first + offset, err
+	case kafkago.SeekEnd:
+		last, err := c.conn.ReadLastOffset()
+		return last - offset, err
+	default:
+		return offset, nil
+	}
+}
+
 func (c *Consumer) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -61,24 +173,15 @@ func (c *Consumer) Close() error {
 }
 
 // ReadNextMessage reads the next complete message from Kafka
-// Returns the message timestamp, key, value, and error
-func (c *Consumer) ReadNextMessage(ctx context.Context) (time.Time, []byte, []byte, error) {
+// The returned message owns its key, value and headers (they are copied out of the read buffers)
+func (c *Consumer) ReadNextMessage(ctx context.Context) (kafkago.Message, error) {
 	if c.usingGroup {
 		// Use Reader for consumer group mode
 		msg, err := c.reader.ReadMessage(ctx)
 		if err != nil {
-			return time.Time{}, nil, nil, err
-		}
-
-		// Return the message timestamp, key, and value
-		var key []byte
-		if len(msg.Key) > 0 {
-			key = make([]byte, len(msg.Key))
-			copy(key, msg.Key)
+			return kafkago.Message{}, err
 		}
-		value := make([]byte, len(msg.Value))
-		copy(value, msg.Value)
-		return msg.Time, key, value, nil
+		return copyMessage(msg), nil
 	}
 
 	// Use direct partition mode (Conn + Batch)
@@ -103,11 +206,12 @@ func (c *Consumer) ReadNextMessage(ctx context.Context) (time.Time, []byte, []by
 
 		select {
 		case <-ctx.Done():
-			return time.Time{}, nil, nil, ctx.Err()
+			return kafkago.Message{}, ctx.Err()
 		case err := <-errChan:
-			return time.Time{}, nil, nil, err
+			return kafkago.Message{}, err
 		case b := <-batchChan:
 			c.batch = b
+			c.batchMessages = 0
 		}
 	}
 
@@ -117,13 +221,28 @@ func (c *Consumer) ReadNextMessage(ctx context.Context) (time.Time, []byte, []by
 		// If batch is exhausted, close it and return EOF
 		// The next call will read a new batch
 		if err == io.EOF {
+			c.emptyFetch = c.batchMessages == 0
 			c.batch.Close()
 			c.batch = nil
 		}
-		return time.Time{}, nil, nil, err
+		return kafkago.Message{}, err
 	}
+	c.batchMessages++
+
+	return copyMessage(msg), nil
+}
+
+// EmptyFetch reports whether the last batch read ended without messages: the consumer has read everything
+// below the high-watermark of its partition, apart from records that are not messages (transaction markers)
+// Note: This only works in direct partition mode (no consumer group).
+func (c *Consumer) EmptyFetch() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.emptyFetch
+}
 
-	// Return the message timestamp, key, and value
+// copyMessage returns a copy of msg with its own key, value and headers
+func copyMessage(msg kafkago.Message) kafkago.Message {
 	var key []byte
 	if len(msg.Key) > 0 {
 		key = make([]byte, len(msg.Key))
@@ -131,19 +250,36 @@ func (c *Consumer) ReadNextMessage(ctx context.Context) (time.Time, []byte, []by
 	}
 	value := make([]byte, len(msg.Value))
 	copy(value, msg.Value)
-	return msg.Time, key, value, nil
+
+	var headers []kafkago.Header
+	if len(msg.Headers) > 0 {
+		headers = make([]kafkago.Header, len(msg.Headers))
+		for i, h := range msg.Headers {
+			headers[i] = kafkago.Header{Key: h.Key}
+			if len(h.Value) > 0 {
+				headers[i].Value = make([]byte, len(h.Value))
+				copy(headers[i].Value, h.Value)
+			}
+		}
+	}
+
+	msg.Key = key
+	msg.Value = value
+	msg.Headers = headers
+	return msg
 }
 
 // NewConsumer creates a new Consumer. If groupID is provided and non-empty, it uses
 // kafka.Reader with consumer group support. Otherwise, it uses kafka.DialLeader for
-// direct partition access.
-func NewConsumer(ctx context.Context, brokers []string, topic string, partition int, groupID string) (*Consumer, error) {
+// direct partition access. Connections use the TLS and SASL settings of security (plain TCP if nil).
+func NewConsumer(ctx context.Context, brokers []string, topic string, partition int, groupID string, security *Security) (*Consumer, error) {
 	if groupID != "" {
 		// Use kafka.Reader for consumer group mode
 		readerConfig := kafkago.ReaderConfig{
 			Brokers:  brokers,
 			Topic:    topic,
 			GroupID:  groupID,
+			Dialer:   security.Dialer(),
 			MinBytes: 1,
 			MaxBytes: 10 * 1024 * 1024, // 10MB
 		}
@@ -164,10 +300,11 @@ func NewConsumer(ctx context.Context, brokers []string, topic string, partition
 	// Use direct partition mode (kafka.DialLeader)
 	// DialLeader expects a single broker address - it will discover the leader from metadata
 	// Try each broker until one works
+	dialer := security.Dialer()
 	var conn *kafkago.Conn
 	var err error
 	for _, broker := range brokers {
-		conn, err = kafkago.DialLeader(ctx, "tcp", broker, topic, partition)
+		conn, err = dialer.DialLeader(ctx, "tcp", broker, topic, partition)
 		if err == nil {
 			break
 		}
@@ -183,5 +320,28 @@ func NewConsumer(ctx context.Context, brokers []string, topic string, partition
 	return &Consumer{
 		conn:       conn,
 		usingGroup: false,
+		topic:      topic,
+		partition:  partition,
+	}, nil
+}
+
+// NewGroupConsumer creates a Consumer that reads several topics as a member of a consumer group.
+// Partitions of all topics are assigned by the group, and offsets are committed automatically.
+func NewGroupConsumer(brokers []string, topics []string, groupID string, security *Security) (*Consumer, error) {
+	if groupID == "" {
+		return nil, fmt.Errorf("a consumer group is required to read several topics with one consumer")
+	}
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:     brokers,
+		GroupID:     groupID,
+		GroupTopics: topics,
+		Dialer:      security.Dialer(),
+		MinBytes:    1,
+		MaxBytes:    10 * 1024 * 1024, // 10MB
+	})
+
+	return &Consumer{
+		reader:     reader,
+		usingGroup: true,
 	}, nil
 }
@@ -31,15 +31,17 @@ type Partition struct {
 }
 
 // ConnectToAnyBroker connects to the first available broker from the given list
-func ConnectToAnyBroker(ctx context.Context, brokers []string) (*Conn, error) {
+// Connections use the TLS and SASL settings of security (plain TCP if nil)
+func ConnectToAnyBroker(ctx context.Context, brokers []string, security *Security) (*Conn, error) {
 	if len(brokers) == 0 {
 		return nil, fmt.Errorf("at least one broker address is required")
 	}
 
+	dialer := security.Dialer()
 	var conn *kafkago.Conn
 	var err error
 	for _, broker := range brokers {
-		conn, err = kafkago.DialContext(ctx, "tcp", broker)
+		conn, err = dialer.DialContext(ctx, "tcp", broker)
 		if err == nil {
 			return &Conn{conn: conn}, nil
 		}
@@ -136,8 +138,8 @@ func ReadAllPartitions(conn *Conn) ([]Partition, error) {
 }
 
 // DialLeader connects to the leader broker for a specific topic-partition
-func DialLeader(ctx context.Context, network, address, topic string, partitionID int) (*Conn, error) {
-	conn, err := kafkago.DialLeader(ctx, network, address, topic, partitionID)
+func DialLeader(ctx context.Context, network, address, topic string, partitionID int, security *Security) (*Conn, error) {
+	conn, err := security.Dialer().DialLeader(ctx, network, address, topic, partitionID)
 	if err != nil {
 		return nil, err
 	}
@@ -150,15 +152,50 @@ func (c *Conn) ReadOffsets() (int64, int64, error) {
 }
 
 // IsBrokerReachable checks if a broker is reachable by attempting to connect to it
-func IsBrokerReachable(ctx context.Context, address string) bool {
+func IsBrokerReachable(ctx context.Context, address string, security *Security) bool {
 	// Create a context with a short timeout for reachability check
 	checkCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	conn, err := kafkago.DialContext(checkCtx, "tcp", address)
+	conn, err := security.Dialer().DialContext(checkCtx, "tcp", address)
 	if err != nil {
 		return false
 	}
 	conn.Close()
 	return true
 }
+
+// TopicTimestampType reads the message.timestamp.type configuration of a topic
+// Returns "CreateTime" (the Kafka default) or "LogAppendTime"
+func TopicTimestampType(ctx context.Context, brokers []string, topic string, security *Security) (string, error) {
+	if len(brokers) == 0 {
+		return "", fmt.Errorf("at least one broker address is required")
+	}
+
+	client := &kafkago.Client{
+		Addr:      kafkago.TCP(brokers...),
+		Transport: security.Transport(),
+	}
+	resp, err := client.DescribeConfigs(ctx, &kafkago.DescribeConfigsRequest{
+		Resources: []kafkago.DescribeConfigRequestResource{{
+			ResourceType: kafkago.ResourceTypeTopic,
+			ResourceName: topic,
+			ConfigNames:  []string{"message.timestamp.type"},
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe topic config: %w", err)
+	}
+
+	for _, resource := range resp.Resources {
+		if resource.Error != nil {
+			return "", fmt.Errorf("error describing topic config: %w", resource.Error)
+		}
+		for _, entry := range resource.ConfigEntries {
+			if entry.ConfigName == "message.timestamp.type" && entry.ConfigValue != "" {
+				return entry.ConfigValue, nil
+			}
+		}
+	}
+	return "CreateTime", nil
+}
@@ -8,11 +8,17 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
+// Producer writes messages with at-least-once delivery: a failed batch is retried, and a batch whose
+// acknowledgment was lost is written twice. kafka-go writes record batches without a producer ID or sequence
+// numbers, so the brokers cannot drop such duplicates, and idempotent or transactional writes are not available
 type Producer struct {
 	writer *kafka.Writer
 }
 
-func NewProducer(brokers []string, topic string, allowAutoTopicCreation bool, noAck bool) *Producer {
+// NewProducer creates a producer for topic (or for the topic of each message if topic is empty)
+// balancer picks the partition of each message
+// Connections use the TLS and SASL settings of security (plain TCP if nil)
+func NewProducer(brokers []string, topic string, allowAutoTopicCreation bool, noAck bool, balancer Balancer, security *Security) *Producer {
 	requiredAcks := kafka.RequireOne // Default: wait for leader acknowledgment (reliable)
 	if noAck {
 		requiredAcks = kafka.RequireNone // No acknowledgment wait = maximum speed (less reliable)
@@ -22,6 +28,8 @@ func NewProducer(brokers []string, topic string, allowAutoTopicCreation bool, no
 			Addr:                   kafka.TCP(brokers...),
 			Topic:                  topic,
 			AllowAutoTopicCreation: allowAutoTopicCreation,
+			Transport:              security.Transport(),
+			Balancer:               balancer.kafkaBalancer(),
 			// Optimized for maximum throughput
 			// Based on Apache Kafka best practices and kafka-go documentation:
 			// - Large batches reduce per-message overhead
@@ -38,6 +46,25 @@ func NewProducer(brokers []string, topic string, allowAutoTopicCreation bool, no
 	}
 }
 
+// Topic returns the topic of the producer (empty if each message carries its own)
+func (p *Producer) Topic() string {
+	return p.writer.Topic
+}
+
+// UseMessagePartitions sends each message to the partition set in its Partition field instead of
+// balancing messages across partitions (kafka-go ignores the field otherwise)
+// It must be called before the first write
+func (p *Producer) UseMessagePartitions() {
+	p.writer.Balancer = messagePartitionBalancer{}
+}
+
+// messagePartitionBalancer picks the partition set in the message
+type messagePartitionBalancer struct{}
+
+func (messagePartitionBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	return msg.Partition
+}
+
 // WriteMessages writes multiple messages to Kafka
 func (p *Producer) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
 	return p.writer.WriteMessages(ctx, messages...)
@@ -53,3 +80,9 @@ func (p *Producer) Close() error {
 	}
 	return nil
 }
+
+// SetBatchTimeout sets how long the producer waits for more messages before sending a batch
+// It must be called before the first write
+func (p *Producer) SetBatchTimeout(timeout time.Duration) {
+	p.writer.BatchTimeout = timeout
+}
@@ -0,0 +1,161 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// SASL mechanisms supported by SecurityConfig
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismScramSHA256 = "SCRAM-SHA-256"
+	SASLMechanismScramSHA512 = "SCRAM-SHA-512"
+)
+
+// SecurityConfig holds the TLS and SASL settings used to connect to a cluster
+type SecurityConfig struct {
+	// TLS enables TLS; it is implied by any of the TLS file settings or InsecureSkipVerify
+	TLS                bool
+	CAFile             string // PEM file with the CA certificates to trust (system roots if empty)
+	CertFile           string // PEM file with the client certificate (mutual TLS)
+	KeyFile            string // PEM file with the client certificate's private key
+	InsecureSkipVerify bool   // Do not verify the broker certificates
+
+	// SASLMechanism is one of PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512 (case-insensitive), empty for no SASL
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+}
+
+// Security is a resolved SecurityConfig, applied to every connection made to the cluster
+// A nil *Security connects over plain TCP without authentication
+type Security struct {
+	tls  *tls.Config
+	sasl sasl.Mechanism
+}
+
+// dialTimeout matches the timeout of kafka-go's default dialer
+const dialTimeout = 10 * time.Second
+
+// NewSecurity loads the certificates and sets up the SASL mechanism of cfg
+// Returns nil (plain TCP) when cfg enables neither TLS nor SASL
+func NewSecurity(cfg SecurityConfig) (*Security, error) {
+	useTLS := cfg.TLS || cfg.CAFile != "" || cfg.CertFile != "" || cfg.KeyFile != "" || cfg.InsecureSkipVerify
+	if !useTLS && cfg.SASLMechanism == "" {
+		return nil, nil
+	}
+
+	s := &Security{}
+	if useTLS {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.tls = tlsConfig
+	}
+	if cfg.SASLMechanism != "" {
+		mechanism, err := newSASLMechanism(cfg.SASLMechanism, cfg.SASLUsername, cfg.SASLPassword)
+		if err != nil {
+			return nil, err
+		}
+		s.sasl = mechanism
+	}
+	return s, nil
+}
+
+// newTLSConfig builds the TLS configuration from the CA and client certificate files
+func newTLSConfig(cfg SecurityConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in TLS CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("a TLS client certificate requires both a certificate file and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// newSASLMechanism returns the SASL mechanism for name with the given credentials
+func newSASLMechanism(name, username, password string) (sasl.Mechanism, error) {
+	if username == "" {
+		return nil, fmt.Errorf("SASL mechanism %s requires a username", name)
+	}
+	switch strings.ToUpper(name) {
+	case SASLMechanismPlain:
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case SASLMechanismScramSHA256:
+		return scram.Mechanism(scram.SHA256, username, password)
+	case SASLMechanismScramSHA512:
+		return scram.Mechanism(scram.SHA512, username, password)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism %q (supported: %s, %s, %s)", name, SASLMechanismPlain, SASLMechanismScramSHA256, SASLMechanismScramSHA512)
+	}
+}
+
+// Dialer returns a dialer for direct connections to brokers
+func (s *Security) Dialer() *kafkago.Dialer {
+	dialer := &kafkago.Dialer{
+		Timeout:   dialTimeout,
+		DualStack: true,
+	}
+	if s != nil {
+		dialer.TLS = s.tls
+		dialer.SASLMechanism = s.sasl
+	}
+	return dialer
+}
+
+// Transport returns the transport for writers and admin clients
+// Returns nil (kafka-go's default transport) for plain TCP connections
+func (s *Security) Transport() kafkago.RoundTripper {
+	if s == nil {
+		return nil
+	}
+	return &kafkago.Transport{
+		DialTimeout: dialTimeout,
+		TLS:         s.tls,
+		SASL:        s.sasl,
+	}
+}
+
+// TLSEnabled reports whether connections use TLS
+func (s *Security) TLSEnabled() bool {
+	return s != nil && s.tls != nil
+}
+
+// SASLMechanism returns the name of the SASL mechanism, or an empty string if SASL is not used
+func (s *Security) SASLMechanism() string {
+	if s == nil || s.sasl == nil {
+		return ""
+	}
+	return s.sasl.Name()
+}
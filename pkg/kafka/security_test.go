@@ -0,0 +1,176 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificates writes a CA certificate, a client certificate signed by it with its key, and another key to
+// dir, and returns their paths
+func testCertificates(t *testing.T, dir string) (caFile, certFile, keyFile, otherKeyFile string) {
+	t.Helper()
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate a key: %v", err)
+		}
+		return key
+	}
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	writeKey := func(name string, key *ecdsa.PrivateKey) string {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return writePEM(name, "PRIVATE KEY", der)
+	}
+
+	caKey := newKey()
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka-replay test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create the CA certificate: %v", err)
+	}
+
+	clientKey := newKey()
+	client := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "kafka-replay"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, client, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create the client certificate: %v", err)
+	}
+
+	return writePEM("ca.pem", "CERTIFICATE", caDER), writePEM("client.pem", "CERTIFICATE", clientDER),
+		writeKey("client-key.pem", clientKey), writeKey("other-key.pem", newKey())
+}
+
+func TestNewSecurity_TLS(t *testing.T) {
+	dir := t.TempDir()
+	caFile, certFile, keyFile, otherKeyFile := testCertificates(t, dir)
+	emptyCAFile := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyCAFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		cfg          SecurityConfig
+		wantErr      string
+		wantRootCAs  bool
+		wantCerts    int
+		wantInsecure bool
+	}{
+		{name: "TLS with the system roots", cfg: SecurityConfig{TLS: true}},
+		{name: "CA file implies TLS", cfg: SecurityConfig{CAFile: caFile}, wantRootCAs: true},
+		{name: "client certificate", cfg: SecurityConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, wantRootCAs: true, wantCerts: 1},
+		{name: "insecure skip verify implies TLS", cfg: SecurityConfig{InsecureSkipVerify: true}, wantInsecure: true},
+		{name: "missing CA file", cfg: SecurityConfig{CAFile: filepath.Join(dir, "missing.pem")}, wantErr: "failed to read TLS CA file"},
+		{name: "empty CA file", cfg: SecurityConfig{CAFile: emptyCAFile}, wantErr: "no PEM certificates found in TLS CA file"},
+		{name: "key file is not a certificate", cfg: SecurityConfig{CAFile: keyFile}, wantErr: "no PEM certificates found in TLS CA file"},
+		{name: "certificate without a key", cfg: SecurityConfig{CertFile: certFile}, wantErr: "requires both a certificate file and a key file"},
+		{name: "key without a certificate", cfg: SecurityConfig{KeyFile: keyFile}, wantErr: "requires both a certificate file and a key file"},
+		{name: "key of another certificate", cfg: SecurityConfig{CertFile: certFile, KeyFile: otherKeyFile}, wantErr: "failed to load TLS client certificate"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			security, err := NewSecurity(tc.cfg)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewSecurity failed: %v", err)
+			}
+			if !security.TLSEnabled() || security.SASLMechanism() != "" {
+				t.Fatalf("expected TLS without SASL, got TLS %v and SASL %q", security.TLSEnabled(), security.SASLMechanism())
+			}
+			config := security.Dialer().TLS
+			if (config.RootCAs != nil) != tc.wantRootCAs || len(config.Certificates) != tc.wantCerts || config.InsecureSkipVerify != tc.wantInsecure {
+				t.Errorf("TLS config has root CAs %v, %d certificates and InsecureSkipVerify %v",
+					config.RootCAs != nil, len(config.Certificates), config.InsecureSkipVerify)
+			}
+		})
+	}
+}
+
+func TestNewSecurity_SASL(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SecurityConfig
+		want    string
+		wantErr string
+	}{
+		{name: "PLAIN", cfg: SecurityConfig{SASLMechanism: "PLAIN", SASLUsername: "u", SASLPassword: "p"}, want: SASLMechanismPlain},
+		{name: "lowercase plain", cfg: SecurityConfig{SASLMechanism: "plain", SASLUsername: "u"}, want: SASLMechanismPlain},
+		{name: "lowercase scram-sha-256", cfg: SecurityConfig{SASLMechanism: "scram-sha-256", SASLUsername: "u", SASLPassword: "p"}, want: SASLMechanismScramSHA256},
+		{name: "mixed case SCRAM-sha-512", cfg: SecurityConfig{SASLMechanism: "Scram-Sha-512", SASLUsername: "u", SASLPassword: "p"}, want: SASLMechanismScramSHA512},
+		{name: "unknown mechanism", cfg: SecurityConfig{SASLMechanism: "GSSAPI", SASLUsername: "u"}, wantErr: `unsupported SASL mechanism "GSSAPI"`},
+		{name: "missing username", cfg: SecurityConfig{SASLMechanism: "PLAIN", SASLPassword: "p"}, wantErr: "SASL mechanism PLAIN requires a username"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			security, err := NewSecurity(tc.cfg)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewSecurity failed: %v", err)
+			}
+			if got := security.SASLMechanism(); got != tc.want || security.TLSEnabled() {
+				t.Errorf("SASL mechanism %q with TLS %v, want %q without TLS", got, security.TLSEnabled(), tc.want)
+			}
+			if security.Dialer().SASLMechanism == nil || security.Transport() == nil {
+				t.Error("the dialer and transport should authenticate with SASL")
+			}
+		})
+	}
+}
+
+// TestNewSecurity_PlainTCP tests that settings enabling neither TLS nor SASL connect over plain TCP
+func TestNewSecurity_PlainTCP(t *testing.T) {
+	security, err := NewSecurity(SecurityConfig{SASLUsername: "u", SASLPassword: "p"})
+	if err != nil || security != nil {
+		t.Fatalf("NewSecurity = %v, %v, want nil", security, err)
+	}
+	if security.TLSEnabled() || security.SASLMechanism() != "" || security.Transport() != nil {
+		t.Error("a nil *Security should use plain TCP without authentication")
+	}
+	if dialer := security.Dialer(); dialer.TLS != nil || dialer.SASLMechanism != nil || dialer.Timeout != dialTimeout {
+		t.Errorf("unexpected dialer for plain TCP: %+v", dialer)
+	}
+}
@@ -16,8 +16,8 @@ type BrokerOutput struct {
 }
 
 // ListBrokers lists all brokers with their reachability status
-func ListBrokers(ctx context.Context, brokers []string) ([]BrokerOutput, error) {
-	conn, err := kafka.ConnectToAnyBroker(ctx, brokers)
+func ListBrokers(ctx context.Context, brokers []string, security *kafka.Security) ([]BrokerOutput, error) {
+	conn, err := kafka.ConnectToAnyBroker(ctx, brokers, security)
 	if err != nil {
 		return nil, err
 	}
@@ -47,7 +47,7 @@ func ListBrokers(ctx context.Context, brokers []string) ([]BrokerOutput, error)
 		}
 
 		brokerAddress := broker.Address
-		reachable := kafka.IsBrokerReachable(ctx, brokerAddress)
+		reachable := kafka.IsBrokerReachable(ctx, brokerAddress, security)
 
 		output := BrokerOutput{
 			ID:        broker.ID,
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/lolocompany/kafka-replay/v2/pkg/kafka"
 	"github.com/lolocompany/kafka-replay/v2/pkg/kafka/admin"
 )
 
@@ -33,13 +34,13 @@ type ConsumerGroupOffset struct {
 }
 
 // ListConsumerGroups lists all consumer groups
-func ListConsumerGroups(ctx context.Context, brokers []string, includeOffsets bool, includeMembers bool) ([]ConsumerGroupOutput, error) {
+func ListConsumerGroups(ctx context.Context, brokers []string, includeOffsets bool, includeMembers bool, security *kafka.Security) ([]ConsumerGroupOutput, error) {
 	if len(brokers) == 0 {
 		return nil, fmt.Errorf("at least one broker address is required")
 	}
 
 	// List all consumer groups
-	groups, err := admin.ListConsumerGroups(ctx, brokers)
+	groups, err := admin.ListConsumerGroups(ctx, brokers, security)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list consumer groups: %w", err)
 	}
@@ -49,7 +50,7 @@ func ListConsumerGroups(ctx context.Context, brokers []string, includeOffsets bo
 	result := make([]ConsumerGroupOutput, 0, len(groups))
 	for _, g := range groups {
 		// Always describe each group to get base information (State, ProtocolType)
-		info, err := admin.DescribeConsumerGroup(ctx, brokers, g, includeOffsets, includeMembers)
+		info, err := admin.DescribeConsumerGroup(ctx, brokers, g, includeOffsets, includeMembers, security)
 		if err != nil {
 			// If we can't describe a group, still include it but without details
 			result = append(result, ConsumerGroupOutput{
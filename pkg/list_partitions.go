@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"context"
+	"fmt"
 	"sort"
 
 	"github.com/lolocompany/kafka-replay/v2/pkg/kafka"
@@ -20,8 +21,8 @@ type PartitionOutput struct {
 }
 
 // ListPartitions lists all partitions with optional offsets and replicas
-func ListPartitions(ctx context.Context, brokers []string, includeOffsets bool, includeReplicas bool) ([]PartitionOutput, error) {
-	conn, err := kafka.ConnectToAnyBroker(ctx, brokers)
+func ListPartitions(ctx context.Context, brokers []string, includeOffsets bool, includeReplicas bool, security *kafka.Security) ([]PartitionOutput, error) {
+	conn, err := kafka.ConnectToAnyBroker(ctx, brokers, security)
 	if err != nil {
 		return nil, err
 	}
@@ -68,7 +69,7 @@ func ListPartitions(ctx context.Context, brokers []string, includeOffsets bool,
 
 		if includeOffsets {
 			// Get offsets for this partition
-			leaderConn, err := kafka.DialLeader(ctx, "tcp", partition.Leader.Address, partition.Topic, partition.ID)
+			leaderConn, err := kafka.DialLeader(ctx, "tcp", partition.Leader.Address, partition.Topic, partition.ID, security)
 			if err == nil {
 				firstOffset, lastOffset, err := leaderConn.ReadOffsets()
 				leaderConn.Close()
@@ -105,3 +106,28 @@ func ListPartitions(ctx context.Context, brokers []string, includeOffsets bool,
 
 	return result, nil
 }
+
+// TopicPartitions returns the IDs of all partitions of a topic, in ascending order
+func TopicPartitions(ctx context.Context, brokers []string, topic string, security *kafka.Security) ([]int, error) {
+	conn, err := kafka.ConnectToAnyBroker(ctx, brokers, security)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	all, err := kafka.ReadAllPartitions(conn)
+	if err != nil {
+		return nil, err
+	}
+	var partitions []int
+	for _, p := range all {
+		if p.Topic == topic {
+			partitions = append(partitions, p.ID)
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("topic '%s' has no partitions (does it exist?)", topic)
+	}
+	sort.Ints(partitions)
+	return partitions, nil
+}
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"context"
+	"regexp"
 	"sort"
 
 	"github.com/lolocompany/kafka-replay/v2/pkg/kafka"
@@ -15,8 +16,8 @@ type TopicOutput struct {
 }
 
 // ListTopics lists all topics with partition count and replication factor
-func ListTopics(ctx context.Context, brokers []string) ([]TopicOutput, error) {
-	conn, err := kafka.ConnectToAnyBroker(ctx, brokers)
+func ListTopics(ctx context.Context, brokers []string, security *kafka.Security) ([]TopicOutput, error) {
+	conn, err := kafka.ConnectToAnyBroker(ctx, brokers, security)
 	if err != nil {
 		return nil, err
 	}
@@ -59,3 +60,18 @@ func ListTopics(ctx context.Context, brokers []string) ([]TopicOutput, error) {
 	}
 	return result, nil
 }
+
+// MatchTopics returns the names of all topics matching the regular expression, sorted by name
+func MatchTopics(ctx context.Context, brokers []string, pattern *regexp.Regexp, security *kafka.Security) ([]string, error) {
+	topics, err := ListTopics(ctx, brokers, security)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, topic := range topics {
+		if pattern.MatchString(topic.Name) {
+			names = append(names, topic.Name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,77 @@
+package mask
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// hashSize is the number of bytes of the HMAC kept in hashes (128 bits, 32 hex characters)
+const hashSize = 16
+
+// hash returns the hex-encoded HMAC-SHA256 of s, truncated to hashSize bytes
+func (m *Masker) hash(s string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte("hash\x00"))
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil)[:hashSize])
+}
+
+// tokenize replaces the digits and letters of s with ones derived from the HMAC-SHA256 of s: digits stay digits,
+// lowercase and non-ASCII letters become lowercase ASCII letters and uppercase letters stay uppercase
+// Other characters are kept, so the token has the shape of the value. For numbers, the first digit of the
+// integer part is not zero unless it is the only one, so that the token is still a valid JSON number
+func (m *Masker) tokenize(s string, number bool) string {
+	stream := &keyStream{secret: m.secret, seed: s}
+	var b strings.Builder
+	integerStart := number
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			d := stream.next() % 10
+			if integerStart && d == 0 && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9' {
+				d = 1 + stream.next()%9
+			}
+			b.WriteByte('0' + d)
+		case number:
+			b.WriteRune(r) // Sign, decimal point and exponent
+		case r >= 'A' && r <= 'Z':
+			b.WriteByte('A' + stream.next()%26)
+		case unicode.IsLetter(r):
+			b.WriteByte('a' + stream.next()%26)
+		default:
+			b.WriteRune(r)
+		}
+		if r != '-' {
+			integerStart = false
+		}
+	}
+	return b.String()
+}
+
+// keyStream is a deterministic stream of pseudo-random bytes derived from a seed and the secret
+type keyStream struct {
+	secret  []byte
+	seed    string
+	block   []byte
+	counter uint64
+}
+
+func (k *keyStream) next() byte {
+	if len(k.block) == 0 {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte("tokenize\x00"))
+		var counter [8]byte
+		binary.BigEndian.PutUint64(counter[:], k.counter)
+		mac.Write(counter[:])
+		mac.Write([]byte(k.seed))
+		k.block = mac.Sum(nil)
+		k.counter++
+	}
+	b := k.block[0]
+	k.block = k.block[1:]
+	return b
+}
@@ -0,0 +1,366 @@
+// Package mask anonymizes messages before they are recorded, with rules configured in YAML:
+//
+//	rules:
+//	  - field: .customer.email
+//	    action: hash
+//	  - field: .card.number
+//	    action: tokenize
+//	  - field: .ssn
+//	    action: redact
+//	  - key: '^user-(\d+)$'
+//	    action: hash
+//	  - header: x-user-email
+//	    action: redact
+//
+// Hashing and tokenizing are deterministic: the same input gives the same output in every message and
+// recording masked with the same rules and secret, so masked values can still be joined on
+package mask
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/jsonpath"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is how a rule masks a value
+type Action string
+
+const (
+	// ActionHash replaces a value with the hex-encoded HMAC-SHA256 of it (truncated to 128 bits)
+	ActionHash Action = "hash"
+	// ActionRedact replaces a value with a fixed replacement ("[REDACTED]" by default)
+	ActionRedact Action = "redact"
+	// ActionTokenize replaces the digits and letters of a value with pseudo-random ones derived from the whole
+	// value, keeping its length, punctuation and JSON type (e.g. a card number stays a 16-digit number)
+	ActionTokenize Action = "tokenize"
+)
+
+// DefaultRedaction is the replacement of redacted values
+const DefaultRedaction = "[REDACTED]"
+
+// Non-JSON value policies
+const (
+	// NonJSONRedact redacts values that are not valid JSON entirely, when there are field rules (the default:
+	// field rules cannot find the personal data in them)
+	NonJSONRedact = "redact"
+	// NonJSONKeep leaves values that are not valid JSON as they are, unmasked
+	NonJSONKeep = "keep"
+)
+
+// Config is the YAML configuration of a masker
+type Config struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+	// NonJSON is what to do with values that are not valid JSON when there are field rules: redact (default) or keep
+	NonJSON string `json:"non_json,omitempty" yaml:"non_json,omitempty"`
+}
+
+// Rule masks a JSON field, the parts of keys matching a pattern or a header. Exactly one of Field, Key and Header is set
+type Rule struct {
+	// Field is a JSON path into the message value, e.g. .customer.email
+	Field string `json:"field,omitempty" yaml:"field,omitempty"`
+	// Key is a regular expression matched against message keys: the matches are masked, or only their
+	// groups if it has any (e.g. '^user-(\d+)$' keeps the "user-" prefix)
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Header is the name of headers whose values are masked
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	Action Action `json:"action" yaml:"action"`
+	// With is the replacement of redacted values (DefaultRedaction if empty)
+	With string `json:"with,omitempty" yaml:"with,omitempty"`
+}
+
+// Masker is a compiled set of masking rules, safe for concurrent use
+type Masker struct {
+	fields  []fieldRule
+	keys    []keyRule
+	headers map[string]valueMasker
+	nonJSON string
+	secret  []byte
+	ruleSet transcoder.RuleSet
+
+	nonJSONValues atomic.Int64 // Values the field rules could not apply to
+}
+
+type fieldRule struct {
+	path jsonpath.Path
+	mask valueMasker
+}
+
+type keyRule struct {
+	pattern *regexp.Regexp
+	mask    valueMasker
+}
+
+// valueMasker masks a value
+type valueMasker struct {
+	action    Action
+	redaction string
+}
+
+// Parse compiles a masker from its YAML configuration, rejecting unknown fields
+// The secret keys the hashes and tokens; without one, anyone can recompute them for guessed values
+func Parse(data []byte, secret []byte) (*Masker, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var cfg Config
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid masking rules: %w", err)
+	}
+	return New(cfg, secret)
+}
+
+// New compiles a masker (see Parse)
+func New(cfg Config, secret []byte) (*Masker, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, fmt.Errorf("invalid masking rules: no rules")
+	}
+	m := &Masker{headers: make(map[string]valueMasker), nonJSON: cfg.NonJSON, secret: secret}
+	switch cfg.NonJSON {
+	case "":
+		m.nonJSON = NonJSONRedact
+	case NonJSONRedact, NonJSONKeep:
+	default:
+		return nil, fmt.Errorf("invalid masking rules: non_json must be %s or %s (got %q)", NonJSONKeep, NonJSONRedact, cfg.NonJSON)
+	}
+
+	for i, rule := range cfg.Rules {
+		if err := m.add(rule); err != nil {
+			return nil, fmt.Errorf("invalid masking rule %d: %w", i+1, err)
+		}
+	}
+	m.ruleSet = fingerprint(cfg, m.nonJSON, secret)
+	return m, nil
+}
+
+func (m *Masker) add(rule Rule) error {
+	targets := 0
+	for _, target := range []string{rule.Field, rule.Key, rule.Header} {
+		if target != "" {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("expected exactly one of field, key or header")
+	}
+	mask := valueMasker{action: rule.Action, redaction: rule.With}
+	switch rule.Action {
+	case ActionRedact:
+		if mask.redaction == "" {
+			mask.redaction = DefaultRedaction
+		}
+	case ActionHash, ActionTokenize:
+		if rule.With != "" {
+			return fmt.Errorf("with can only be used with the %s action", ActionRedact)
+		}
+	default:
+		return fmt.Errorf("action must be %s, %s or %s (got %q)", ActionHash, ActionRedact, ActionTokenize, rule.Action)
+	}
+
+	switch {
+	case rule.Field != "":
+		path, err := jsonpath.Parse(rule.Field)
+		if err != nil {
+			return err
+		}
+		m.fields = append(m.fields, fieldRule{path: path, mask: mask})
+	case rule.Key != "":
+		pattern, err := regexp.Compile(rule.Key)
+		if err != nil {
+			return fmt.Errorf("invalid key pattern: %w", err)
+		}
+		m.keys = append(m.keys, keyRule{pattern: pattern, mask: mask})
+	default:
+		if _, ok := m.headers[rule.Header]; ok {
+			return fmt.Errorf("header %q is already masked by another rule", rule.Header)
+		}
+		m.headers[rule.Header] = mask
+	}
+	return nil
+}
+
+// fingerprint identifies a rule set: the rules, in order, and the secret (without revealing it)
+// Recordings with the same fingerprint have masked values that can be joined
+func fingerprint(cfg Config, nonJSON string, secret []byte) transcoder.RuleSet {
+	cfg.NonJSON = nonJSON
+	rules, _ := json.Marshal(cfg) // Plain strings only, cannot fail
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("kafka-replay rule set"))
+	h := sha256.New()
+	h.Write(rules)
+	h.Write(mac.Sum(nil))
+	var ruleSet transcoder.RuleSet
+	copy(ruleSet[:], h.Sum(nil))
+	return ruleSet
+}
+
+// RuleSet returns the fingerprint of the rules and secret, written to the header of sanitized recordings
+func (m *Masker) RuleSet() transcoder.RuleSet {
+	return m.ruleSet
+}
+
+// NonJSON returns what is done with values that are not valid JSON: NonJSONRedact or NonJSONKeep
+func (m *Masker) NonJSON() string {
+	return m.nonJSON
+}
+
+// NonJSONValues returns the number of values the field rules could not apply to since they were not valid JSON,
+// redacted entirely or kept unmasked (see NonJSON)
+func (m *Masker) NonJSONValues() int64 {
+	return m.nonJSONValues.Load()
+}
+
+// Apply masks an entry in place
+// Field rules only apply to values that are valid JSON (see Config.NonJSON); a masked value is re-encoded
+// with its object keys sorted
+func (m *Masker) Apply(entry *transcoder.Entry) error {
+	if len(m.fields) > 0 && len(entry.Data) > 0 {
+		if err := m.applyFields(entry); err != nil {
+			return err
+		}
+	}
+	if len(m.keys) > 0 && len(entry.Key) > 0 {
+		key := string(entry.Key)
+		for _, rule := range m.keys {
+			key = m.maskMatches(key, rule)
+		}
+		entry.Key = []byte(key)
+	}
+	if len(m.headers) > 0 && len(entry.Headers) > 0 {
+		headers := make([]transcoder.Header, len(entry.Headers))
+		for i, h := range entry.Headers {
+			headers[i] = h
+			if mask, ok := m.headers[h.Key]; ok {
+				headers[i].Value = []byte(m.maskString(string(h.Value), mask))
+			}
+		}
+		entry.Headers = headers
+	}
+	return nil
+}
+
+func (m *Masker) applyFields(entry *transcoder.Entry) error {
+	document, err := jsonpath.Decode(entry.Data)
+	if err != nil {
+		m.nonJSONValues.Add(1)
+		if m.nonJSON == NonJSONRedact {
+			entry.Data = []byte(DefaultRedaction)
+		}
+		return nil
+	}
+	changed := false
+	for _, rule := range m.fields {
+		v, ok := rule.path.Get(document)
+		if !ok {
+			continue
+		}
+		masked, err := m.maskJSON(v, rule.mask)
+		if err != nil {
+			return err
+		}
+		if document, err = rule.path.Set(document, masked); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	data, err := jsonpath.Encode(document)
+	if err != nil {
+		return fmt.Errorf("failed to encode the masked value: %w", err)
+	}
+	entry.Data = data
+	return nil
+}
+
+// maskMatches masks the matches of a key rule (or their groups) in a key
+func (m *Masker) maskMatches(key string, rule keyRule) string {
+	matches := rule.pattern.FindAllStringSubmatchIndex(key, -1)
+	if matches == nil {
+		return key
+	}
+	var b bytes.Buffer
+	last := 0
+	for _, match := range matches {
+		spans := match[:2]
+		if len(match) > 2 {
+			spans = match[2:] // Only the groups
+		}
+		for i := 0; i < len(spans); i += 2 {
+			start, end := spans[i], spans[i+1]
+			if start < last {
+				continue // Unmatched group (-1) or nested in the previous one
+			}
+			b.WriteString(key[last:start])
+			b.WriteString(m.maskString(key[start:end], rule.mask))
+			last = end
+		}
+	}
+	b.WriteString(key[last:])
+	return b.String()
+}
+
+// maskString masks a key, header or JSON string
+func (m *Masker) maskString(s string, mask valueMasker) string {
+	switch mask.action {
+	case ActionHash:
+		return m.hash(s)
+	case ActionTokenize:
+		return m.tokenize(s, false)
+	default:
+		return mask.redaction
+	}
+}
+
+// maskJSON masks a JSON value decoded by jsonpath.Decode
+// Hashing any value gives a string (objects and arrays are hashed as JSON with sorted keys), redacting gives the
+// replacement string, and tokenizing keeps numbers as numbers and tokenizes every string and number in objects
+// and arrays (booleans and null are left as they are)
+func (m *Masker) maskJSON(v any, mask valueMasker) (any, error) {
+	switch mask.action {
+	case ActionRedact:
+		return mask.redaction, nil
+	case ActionHash:
+		if s, ok := v.(string); ok {
+			return m.hash(s), nil
+		}
+		data, err := jsonpath.Encode(v)
+		if err != nil {
+			return nil, err
+		}
+		return m.hash(string(data)), nil
+	}
+	switch v := v.(type) {
+	case string:
+		return m.tokenize(v, false), nil
+	case json.Number:
+		return json.Number(m.tokenize(v.String(), true)), nil
+	case map[string]any:
+		for key, value := range v {
+			masked, err := m.maskJSON(value, mask)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = masked
+		}
+		return v, nil
+	case []any:
+		for i, value := range v {
+			masked, err := m.maskJSON(value, mask)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = masked
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
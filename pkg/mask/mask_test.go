@@ -0,0 +1,204 @@
+package mask
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+const testRules = `
+rules:
+  - field: .customer.email
+    action: hash
+  - field: .card.number
+    action: tokenize
+  - field: .card.holder
+    action: tokenize
+  - field: .ssn
+    action: redact
+  - field: .address
+    action: redact
+    with: "***"
+  - key: '^user-(\d+)$'
+    action: hash
+  - header: x-user-email
+    action: redact
+`
+
+func testEntry() *transcoder.Entry {
+	return &transcoder.Entry{
+		Key:  []byte("user-42"),
+		Data: []byte(`{"customer":{"email":"ann@example.com"},"card":{"number":4111111111111111,"holder":"Ann O'Neil"},"ssn":"123-45-6789","address":{"city":"Paris"},"amount":10}`),
+		Headers: []transcoder.Header{
+			{Key: "x-user-email", Value: []byte("ann@example.com")},
+			{Key: "trace-id", Value: []byte("abc")},
+		},
+	}
+}
+
+func TestMasker_Apply(t *testing.T) {
+	masker, err := Parse([]byte(testRules), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	entry := testEntry()
+	if err := masker.Apply(entry); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	var value struct {
+		Customer struct {
+			Email string `json:"email"`
+		} `json:"customer"`
+		Card struct {
+			Number json.Number `json:"number"`
+			Holder string      `json:"holder"`
+		} `json:"card"`
+		SSN     string `json:"ssn"`
+		Address string `json:"address"`
+		Amount  int    `json:"amount"`
+	}
+	if err := json.Unmarshal(entry.Data, &value); err != nil {
+		t.Fatalf("masked value is not valid JSON: %v (%s)", err, entry.Data)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(value.Customer.Email) {
+		t.Errorf("email should be hashed, got %q", value.Customer.Email)
+	}
+	if !regexp.MustCompile(`^[1-9][0-9]{15}$`).MatchString(value.Card.Number.String()) || value.Card.Number == "4111111111111111" {
+		t.Errorf("card number should be tokenized to another 16-digit number, got %s", value.Card.Number)
+	}
+	if !regexp.MustCompile(`^[A-Z][a-z]{2} [A-Z]'[A-Z][a-z]{3}$`).MatchString(value.Card.Holder) {
+		t.Errorf("holder should be tokenized with the same shape, got %q", value.Card.Holder)
+	}
+	if value.SSN != DefaultRedaction || value.Address != "***" {
+		t.Errorf("ssn and address should be redacted, got %q and %q", value.SSN, value.Address)
+	}
+	if value.Amount != 10 {
+		t.Errorf("amount should be unchanged, got %d", value.Amount)
+	}
+	if !regexp.MustCompile(`^user-[0-9a-f]{32}$`).MatchString(string(entry.Key)) {
+		t.Errorf("key should keep its prefix and have its group hashed, got %q", entry.Key)
+	}
+	if string(entry.Headers[0].Value) != DefaultRedaction || string(entry.Headers[1].Value) != "abc" {
+		t.Errorf("only x-user-email should be redacted, got %v", entry.Headers)
+	}
+	if strings.Contains(string(entry.Data), "ann@example.com") {
+		t.Errorf("masked value still contains the email: %s", entry.Data)
+	}
+}
+
+func TestMasker_Deterministic(t *testing.T) {
+	first, err := Parse([]byte(testRules), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	second, err := Parse([]byte(testRules), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	otherSecret, err := Parse([]byte(testRules), []byte("other"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	a, b, c := testEntry(), testEntry(), testEntry()
+	for _, step := range []struct {
+		masker *Masker
+		entry  *transcoder.Entry
+	}{{first, a}, {second, b}, {otherSecret, c}} {
+		if err := step.masker.Apply(step.entry); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+	if string(a.Data) != string(b.Data) || string(a.Key) != string(b.Key) {
+		t.Errorf("masking with the same rules and secret should be deterministic:\n%s\n%s", a.Data, b.Data)
+	}
+	if string(a.Data) == string(c.Data) {
+		t.Error("masking with another secret should give other values")
+	}
+	if first.RuleSet() != second.RuleSet() || first.RuleSet() == otherSecret.RuleSet() {
+		t.Errorf("rule set fingerprints: %s, %s, %s", first.RuleSet(), second.RuleSet(), otherSecret.RuleSet())
+	}
+
+	// Hashes of the same value match across keys, fields and headers, so they can be joined on
+	joined, err := New(Config{Rules: []Rule{{Field: ".user", Action: ActionHash}, {Key: ".+", Action: ActionHash}}}, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	entry := &transcoder.Entry{Key: []byte("ann"), Data: []byte(`{"user":"ann"}`)}
+	if err := joined.Apply(entry); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if want := `{"user":"` + string(entry.Key) + `"}`; string(entry.Data) != want {
+		t.Errorf("hashes differ: key %s, value %s", entry.Key, entry.Data)
+	}
+}
+
+func TestMasker_NonJSON(t *testing.T) {
+	for _, tc := range []struct {
+		nonJSON string
+		want    string
+	}{
+		{"", DefaultRedaction}, // Masking must not fail open
+		{NonJSONRedact, DefaultRedaction},
+		{NonJSONKeep, "plain text"},
+	} {
+		masker, err := New(Config{Rules: []Rule{{Field: ".email", Action: ActionHash}}, NonJSON: tc.nonJSON}, nil)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		for _, data := range []string{`{"email": "a@b"}`, "plain text", `{"email": "trunc`} {
+			entry := &transcoder.Entry{Data: []byte(data)}
+			if err := masker.Apply(entry); err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if data == "plain text" && string(entry.Data) != tc.want {
+				t.Errorf("non_json %q: got %q, want %q", tc.nonJSON, entry.Data, tc.want)
+			}
+		}
+		if got := masker.NonJSONValues(); got != 2 {
+			t.Errorf("non_json %q: %d values not valid JSON, want 2", tc.nonJSON, got)
+		}
+	}
+
+	// Without field rules, values are not parsed nor counted
+	masker, err := New(Config{Rules: []Rule{{Header: "h", Action: ActionRedact}}}, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	entry := &transcoder.Entry{Data: []byte("plain text")}
+	if err := masker.Apply(entry); err != nil || string(entry.Data) != "plain text" || masker.NonJSONValues() != 0 {
+		t.Errorf("header rules only: got %q (%d values not valid JSON), %v", entry.Data, masker.NonJSONValues(), err)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want string
+	}{
+		{"rules: []\n", "no rules"},
+		{"rules:\n  - action: hash\n", "expected exactly one of field, key or header"},
+		{"rules:\n  - field: .a\n    key: b\n    action: hash\n", "expected exactly one of field, key or header"},
+		{"rules:\n  - field: .a\n    action: encrypt\n", `action must be hash, redact or tokenize (got "encrypt")`},
+		{"rules:\n  - field: .a\n    action: hash\n    with: x\n", "with can only be used with the redact action"},
+		{"rules:\n  - key: '('\n    action: hash\n", "invalid key pattern"},
+		{"rules:\n  - field: a\n    action: hash\n", "paths start with '.'"},
+		{"rules:\n  - header: h\n    action: hash\n  - header: h\n    action: redact\n", "already masked"},
+		{"rules:\n  - field: .a\n    action: hash\nnon_json: drop\n", "non_json must be keep or redact"},
+		{"rules:\n  - feild: .a\n    action: hash\n", "field feild not found"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.yaml), nil)
+		if err == nil {
+			t.Errorf("Parse(%q): expected an error", tt.yaml)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q): error %q does not contain %q", tt.yaml, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// PartitionStrategy decides where a message goes when its recorded partition does not exist in the target topic
+type PartitionStrategy int
+
+const (
+	// PartitionStrategyFail stops the replay (the target topic needs at least as many partitions as the source)
+	PartitionStrategyFail PartitionStrategy = iota
+	// PartitionStrategyModulo sends the message to its recorded partition modulo the partition count of the target topic
+	PartitionStrategyModulo
+)
+
+// String returns the name of the partition strategy
+func (s PartitionStrategy) String() string {
+	switch s {
+	case PartitionStrategyFail:
+		return "fail"
+	case PartitionStrategyModulo:
+		return "modulo"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// ParsePartitionStrategy parses a partition strategy name (fail or modulo)
+func ParsePartitionStrategy(name string) (PartitionStrategy, error) {
+	switch strings.ToLower(name) {
+	case "", "fail":
+		return PartitionStrategyFail, nil
+	case "modulo":
+		return PartitionStrategyModulo, nil
+	default:
+		return PartitionStrategyFail, fmt.Errorf("unsupported partition strategy %q (supported: fail, modulo)", name)
+	}
+}
+
+// PartitionRouting sends each message to the partition it was recorded from, preserving the per-partition order
+type PartitionRouting struct {
+	// Strategy applies to recorded partitions (after Map) that do not exist in the target topic
+	Strategy PartitionStrategy
+	// Map remaps recorded partitions (recorded partition -> target partition)
+	Map map[int]int
+	// PartitionCount returns the number of partitions of a target topic (from the cluster metadata)
+	// It is called once per target topic, before the first message is sent to it
+	PartitionCount func(ctx context.Context, topic string) (int, error)
+}
+
+// partitionRouter applies a PartitionRouting, caching the partition counts of the target topics
+type partitionRouter struct {
+	routing PartitionRouting
+	counts  map[string]int
+}
+
+func newPartitionRouter(routing PartitionRouting) *partitionRouter {
+	return &partitionRouter{routing: routing, counts: make(map[string]int)}
+}
+
+// partition returns the partition of topic an entry is replayed to
+func (r *partitionRouter) partition(ctx context.Context, topic string, entry *transcoder.Entry) (int, error) {
+	if entry.Partition < 0 {
+		return 0, fmt.Errorf("cannot preserve partitions: message at offset %d has no recorded partition (recorded before format version 3)", entry.Offset)
+	}
+	count, ok := r.counts[topic]
+	if !ok {
+		var err error
+		if count, err = r.routing.PartitionCount(ctx, topic); err != nil {
+			return 0, fmt.Errorf("failed to get the partition count of topic '%s': %w", topic, err)
+		}
+		if count <= 0 {
+			return 0, fmt.Errorf("topic '%s' has no partitions", topic)
+		}
+		r.counts[topic] = count
+	}
+
+	partition := entry.Partition
+	if target, ok := r.routing.Map[partition]; ok {
+		partition = target
+	}
+	if partition < count {
+		return partition, nil
+	}
+	if r.routing.Strategy == PartitionStrategyModulo {
+		return partition % count, nil
+	}
+	return 0, fmt.Errorf("topic '%s' has %d partitions, cannot replay a message to partition %d (use the modulo partition strategy or remap the partition)", topic, count, partition)
+}
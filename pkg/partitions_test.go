@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+func TestPartitionRouter(t *testing.T) {
+	tests := []struct {
+		name      string
+		routing   PartitionRouting
+		partition int
+		want      int
+		wantErr   string
+	}{
+		{name: "recorded partition", partition: 2, want: 2},
+		{name: "missing partition fails", partition: 5, wantErr: "topic 'orders' has 4 partitions, cannot replay a message to partition 5"},
+		{name: "modulo", routing: PartitionRouting{Strategy: PartitionStrategyModulo}, partition: 5, want: 1},
+		{name: "modulo of an existing partition", routing: PartitionRouting{Strategy: PartitionStrategyModulo}, partition: 3, want: 3},
+		{name: "explicit mapping", routing: PartitionRouting{Map: map[int]int{5: 0, 2: 3}}, partition: 5, want: 0},
+		{name: "explicit mapping of an existing partition", routing: PartitionRouting{Map: map[int]int{5: 0, 2: 3}}, partition: 2, want: 3},
+		{name: "partition missing from the mapping", routing: PartitionRouting{Map: map[int]int{5: 0}}, partition: 1, want: 1},
+		{name: "mapping to a missing partition fails", routing: PartitionRouting{Map: map[int]int{1: 9}}, partition: 1, wantErr: "cannot replay a message to partition 9"},
+		{name: "modulo after the mapping", routing: PartitionRouting{Strategy: PartitionStrategyModulo, Map: map[int]int{1: 9}}, partition: 1, want: 1},
+		{name: "no recorded partition", partition: -1, wantErr: "has no recorded partition"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			routing := tc.routing
+			routing.PartitionCount = func(ctx context.Context, topic string) (int, error) { return 4, nil }
+			r := newPartitionRouter(routing)
+			got, err := r.partition(context.Background(), "orders", &transcoder.Entry{Partition: tc.partition, Offset: 7})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected an error containing %q, got %d, %v", tc.wantErr, got, err)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("partition = %d, %v, want %d", got, err, tc.want)
+			}
+		})
+	}
+}
+
+// TestPartitionRouter_PartitionCount tests that the partition count of each target topic is looked up once
+func TestPartitionRouter_PartitionCount(t *testing.T) {
+	lookups := make(map[string]int)
+	r := newPartitionRouter(PartitionRouting{
+		Strategy: PartitionStrategyModulo,
+		PartitionCount: func(ctx context.Context, topic string) (int, error) {
+			lookups[topic]++
+			switch topic {
+			case "orders":
+				return 3, nil
+			case "empty":
+				return 0, nil
+			default:
+				return 0, errors.New("unknown topic")
+			}
+		},
+	})
+
+	for _, tc := range []struct {
+		topic     string
+		partition int
+		want      int
+	}{
+		{"orders", 4, 1},
+		{"orders", 2, 2},
+		{"orders", 7, 1},
+	} {
+		if got, err := r.partition(context.Background(), tc.topic, &transcoder.Entry{Partition: tc.partition}); err != nil || got != tc.want {
+			t.Errorf("%s/%d: partition = %d, %v, want %d", tc.topic, tc.partition, got, err, tc.want)
+		}
+	}
+	if lookups["orders"] != 1 {
+		t.Errorf("partition count of orders looked up %d times, want once", lookups["orders"])
+	}
+
+	if _, err := r.partition(context.Background(), "empty", &transcoder.Entry{}); err == nil || !strings.Contains(err.Error(), "topic 'empty' has no partitions") {
+		t.Errorf("expected a no partitions error, got %v", err)
+	}
+	if _, err := r.partition(context.Background(), "missing", &transcoder.Entry{}); err == nil || !strings.Contains(err.Error(), "failed to get the partition count of topic 'missing': unknown topic") {
+		t.Errorf("expected a partition count error, got %v", err)
+	}
+}
@@ -4,89 +4,402 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"sync"
+	"time"
 
-	kafka "github.com/lolocompany/kafka-replay/v2/pkg/kafka"
+	"github.com/lolocompany/kafka-replay/v2/pkg/filter"
+	kafkapkg "github.com/lolocompany/kafka-replay/v2/pkg/kafka"
+	"github.com/lolocompany/kafka-replay/v2/pkg/mask"
 	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+	"github.com/segmentio/kafka-go"
 )
 
 // RecordConfig holds configuration for the Record function
 type RecordConfig struct {
-	Consumer  *kafka.Consumer
+	Consumer *kafkapkg.Consumer
+	// Consumers are additional consumers (e.g. one per topic partition) recorded concurrently into the same output
+	Consumers []*kafkapkg.Consumer
 	Offset    *int64
 	Output    io.WriteCloser
 	Limit     int
 	FindBytes []byte // Optional byte sequence to search for in messages
+	// Filter is an optional filter expression messages must match (with FindBytes if both are set)
+	// Like FindBytes, it applies before Limit, so Limit counts matching messages
+	Filter *filter.Filter
+	// FromTime starts every consumer at its first message with a timestamp at or after this time (direct partition mode only)
+	FromTime *time.Time
+	// ToTime stops every consumer once its messages are past this time (direct partition mode only)
+	ToTime *time.Time
+	// UntilLatest stops every consumer at the latest offset (high-watermark) its partition had when the
+	// recording started, giving a point-in-time snapshot (direct partition mode only)
+	UntilLatest bool
+	// TimestampTypes are the timestamp types of the recorded topics (their message.timestamp.type config, read once
+	// when recording starts: Kafka does not report the type of each record batch to consumers)
+	// Topics missing from the map are recorded with an unknown timestamp type
+	TimestampTypes map[string]transcoder.TimestampType
+	// Compression is the codec used to compress the recorded entries (CompressionNone by default)
+	Compression transcoder.Compression
+	// Index is an optional writer for the index of the recording, written once recording has finished
+	Index io.Writer
+	// Masking masks the messages that pass the filters before they are written, and marks the recording as
+	// sanitized with its rule set. When appending, the recording must have been sanitized with the same rule set
+	Masking *mask.Masker
+	// Append continues an existing recording at this append point instead of starting a new one (Output
+	// must be positioned at its size). Consumers of partitions found in the recording start after the
+	// last recorded offset (direct partition mode only); Compression is ignored
+	Append *transcoder.AppendPoint
 }
 
 func Record(ctx context.Context, cfg RecordConfig) (int64, int64, error) {
-	if cfg.Consumer == nil {
+	consumers := cfg.Consumers
+	if cfg.Consumer != nil {
+		consumers = append([]*kafkapkg.Consumer{cfg.Consumer}, consumers...)
+	}
+	if len(consumers) == 0 {
 		return 0, 0, errors.New("consumer is required")
 	}
 	if cfg.Output == nil {
 		return 0, 0, errors.New("output is required")
 	}
+	if cfg.Append != nil {
+		if err := CheckAppendMasking(cfg.Append, cfg.Masking); err != nil {
+			return 0, 0, err
+		}
+	}
+	var sanitization *transcoder.Sanitization
+	if cfg.Masking != nil {
+		sanitization = &transcoder.Sanitization{RuleSet: cfg.Masking.RuleSet()}
+	}
 
 	// Set offset if specified
 	// Note: When using consumer groups, SetOffset will fail as offsets are managed automatically.
 	// In that case, we skip setting the offset and let the consumer group handle it.
 	if cfg.Offset != nil {
-		if err := cfg.Consumer.SetOffset(*cfg.Offset); err != nil {
-			// If SetOffset fails (e.g., when using consumer groups), we continue anyway.
-			// Consumer groups manage offsets automatically, so this is expected behavior.
-			// We only return the error if it's not related to consumer group offset management.
-			// For now, we'll just log and continue - consumer groups will use committed offsets.
+		for _, consumer := range consumers {
+			if err := consumer.SetOffset(*cfg.Offset); err != nil {
+				// If SetOffset fails (e.g., when using consumer groups), we continue anyway.
+				// Consumer groups manage offsets automatically, so this is expected behavior.
+				// We only return the error if it's not related to consumer group offset management.
+				// For now, we'll just log and continue - consumer groups will use committed offsets.
+			}
 		}
 	}
 
-	// Create message encoder
-	encoder, err := transcoder.NewEncodeWriter(cfg.Output)
+	// Resolve where each consumer starts and stops
+	bounds := make([]recordBounds, len(consumers))
+	for i, consumer := range consumers {
+		b, err := resolveBounds(consumer, cfg)
+		if err != nil {
+			return 0, 0, err
+		}
+		bounds[i] = b
+	}
+
+	// Create message encoder (safe to share between the consumer goroutines)
+	var encoder *transcoder.EncodeWriter
+	var err error
+	if cfg.Append != nil {
+		encoder, err = transcoder.NewAppendEncodeWriter(cfg.Output, cfg.Append)
+	} else {
+		encoder, err = transcoder.NewSanitizedEncodeWriter(cfg.Output, cfg.Compression, sanitization)
+	}
 	if err != nil {
 		return 0, 0, err
 	}
 	defer encoder.Close()
 
-	var messageCount int64
+	// Consumers stop as soon as one of them fails or the limit is reached
+	recordCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	for {
-		// Check if we've reached the message limit
-		if cfg.Limit > 0 && messageCount >= int64(cfg.Limit) {
-			break
+	r := &recorder{cfg: cfg, encoder: encoder, cancel: cancel}
+	var wg sync.WaitGroup
+	for i, consumer := range consumers {
+		wg.Add(1)
+		go func(consumer *kafkapkg.Consumer, bounds recordBounds) {
+			defer wg.Done()
+			if err := r.run(recordCtx, consumer, bounds); err != nil {
+				r.fail(err)
+			}
+		}(consumer, bounds[i])
+	}
+	wg.Wait()
+
+	// Write the last compressed frame, so that the byte count is complete
+	if err := encoder.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if cfg.Index != nil && r.err == nil {
+		if err := writeRecordingIndex(encoder, cfg.Index); err != nil {
+			r.err = fmt.Errorf("failed to write index: %w", err)
 		}
+	}
 
+	if r.err != nil {
+		return encoder.TotalBytes(), r.messageCount, r.err
+	}
+	if ctx.Err() != nil {
+		return encoder.TotalBytes(), r.messageCount, ctx.Err()
+	}
+	return encoder.TotalBytes(), r.messageCount, nil
+}
+
+// writeRecordingIndex writes the index built by the encoder while recording
+func writeRecordingIndex(encoder *transcoder.EncodeWriter, output io.Writer) error {
+	index, err := encoder.Index()
+	if err != nil {
+		return err
+	}
+	return transcoder.WriteIndex(output, index)
+}
+
+// partitionReader reads the messages of a recording from one consumer (a *kafka.Consumer)
+type partitionReader interface {
+	Partition() (string, int, bool)
+	SetAbsoluteOffset(offset int64) error
+	SetOffsetForTime(t time.Time) (int64, error)
+	OffsetForTime(t time.Time) (int64, error)
+	LastOffset() (int64, error)
+	Position() (int64, error)
+	ReadNextMessage(ctx context.Context) (kafka.Message, error)
+	EmptyFetch() bool
+}
+
+// recorder holds the state shared by the consumer goroutines of a recording
+type recorder struct {
+	cfg     RecordConfig
+	encoder *transcoder.EncodeWriter
+	cancel  context.CancelFunc
+
+	mu           sync.Mutex
+	messageCount int64
+	err          error
+}
+
+// run reads messages from one consumer and writes the matching ones until the context is done,
+// the limit is reached or the consumer is past its end bound
+func (r *recorder) run(ctx context.Context, consumer partitionReader, bounds recordBounds) error {
+	// While the end time has not been reached in the log, stop waiting for messages once it has
+	// passed on the wall clock (a quiet partition may never produce a message past it)
+	readCtx := ctx
+	if bounds.endTime != nil && bounds.endOffset < 0 {
+		var cancel context.CancelFunc
+		readCtx, cancel = context.WithDeadline(ctx, bounds.endTime.Add(endTimeGrace))
+		defer cancel()
+	}
+
+	position := bounds.startOffset
+	for {
 		// Check context cancellation
 		select {
 		case <-ctx.Done():
-			return encoder.TotalBytes(), messageCount, ctx.Err()
+			return nil
 		default:
 		}
 
+		// Stop once the end offset is reached
+		if bounds.endOffset >= 0 && position >= bounds.endOffset {
+			return nil
+		}
+
 		// Read next complete message
-		timestamp, key, messageData, err := cfg.Consumer.ReadNextMessage(ctx)
+		msg, err := consumer.ReadNextMessage(readCtx)
 		if err != nil {
 			if err == io.EOF {
-				// End of batch, continue to read next batch
+				// End of batch: the last offsets before the end offset may not be messages (transaction
+				// markers, compacted records), so stop once the position of the consumer reaches it or a
+				// batch has no messages, then continue to read the next batch
+				if bounds.endOffset >= 0 {
+					if consumer.EmptyFetch() {
+						return nil
+					}
+					if position, err = consumer.Position(); err != nil {
+						return err
+					}
+				}
 				continue
 			}
-			// Check if context was canceled
+			// Check if context was canceled (by the caller, the limit or another consumer)
 			if ctx.Err() != nil {
-				return encoder.TotalBytes(), messageCount, ctx.Err()
+				return nil
+			}
+			// The end time passed on the wall clock: read up to the current end of the partition
+			if readCtx.Err() != nil {
+				if bounds.endOffset, err = consumer.LastOffset(); err != nil {
+					return err
+				}
+				readCtx = ctx
+				continue
 			}
-			return encoder.TotalBytes(), messageCount, err
+			return err
+		}
+		position = msg.Offset + 1
+
+		// Stop once messages are past the end time
+		if bounds.endTime != nil && msg.Time.After(*bounds.endTime) {
+			return nil
 		}
 
 		// Filter by find bytes if specified
-		if cfg.FindBytes != nil && !bytes.Contains(messageData, cfg.FindBytes) {
+		if r.cfg.FindBytes != nil && !bytes.Contains(msg.Value, r.cfg.FindBytes) {
 			// Skip this message, continue to next one
 			continue
 		}
 
-		// Write the matching message (version 2 format with key)
-		if _, err := encoder.Write(timestamp, messageData, key); err != nil {
-			return encoder.TotalBytes(), messageCount, err
+		// Filter on the recorded entry (with key, headers and source topic, partition and offset)
+		entry := entryFromMessage(msg, r.timestampType(msg.Topic))
+		if !r.cfg.Filter.Match(entry) {
+			continue
+		}
+		if r.cfg.Masking != nil {
+			if err := r.cfg.Masking.Apply(entry); err != nil {
+				return fmt.Errorf("failed to mask message at offset %d: %w", msg.Offset, err)
+			}
+		}
+
+		done, err := r.write(entry)
+		if err != nil || done {
+			return err
 		}
-		messageCount++
+	}
+}
+
+// write writes a matching entry unless the limit has been reached
+// Returns true once the limit is reached
+func (r *recorder) write(entry *transcoder.Entry) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check if we've reached the message limit (another consumer may have reached it first)
+	if r.cfg.Limit > 0 && r.messageCount >= int64(r.cfg.Limit) {
+		return true, nil
+	}
+
+	// Write the matching entry
+	if _, err := r.encoder.WriteEntry(entry); err != nil {
+		return true, err
+	}
+	r.messageCount++
+
+	if r.cfg.Limit > 0 && r.messageCount >= int64(r.cfg.Limit) {
+		r.cancel()
+		return true, nil
+	}
+	return false, nil
+}
+
+// endTimeGrace is how long after the end time (on the wall clock) a consumer waits for a message past it
+// before treating the current end of its partition as the end bound
+const endTimeGrace = 5 * time.Second
+
+// recordBounds are the start and end of one consumer's recording
+type recordBounds struct {
+	startOffset int64      // Offset of the first message to read (-1 if unknown)
+	endOffset   int64      // Offset to stop at, exclusive (-1 for none)
+	endTime     *time.Time // Stop once messages are past this time (nil for none)
+}
+
+// resolveBounds positions a consumer at its start time and looks up its end offset
+// (the earlier of the end time and the current high-watermark, when requested)
+func resolveBounds(consumer partitionReader, cfg RecordConfig) (recordBounds, error) {
+	bounds := recordBounds{startOffset: -1, endOffset: -1, endTime: cfg.ToTime}
+
+	// Continue an appended recording after the last recorded offset of the partition
+	resumed := false
+	if topic, partition, ok := consumer.Partition(); ok && cfg.Append != nil {
+		if last, ok := cfg.Append.LastOffsets[topic][partition]; ok {
+			if err := consumer.SetAbsoluteOffset(last + 1); err != nil {
+				return bounds, fmt.Errorf("failed to continue partition %d of '%s' after offset %d: %w", partition, topic, last, err)
+			}
+			resumed = true
+		}
+	}
+
+	if cfg.FromTime == nil && cfg.ToTime == nil && !cfg.UntilLatest {
+		return bounds, nil
+	}
+
+	var err error
+	if cfg.FromTime != nil && !resumed {
+		if bounds.startOffset, err = consumer.SetOffsetForTime(*cfg.FromTime); err != nil {
+			return bounds, err
+		}
+	} else if bounds.startOffset, err = consumer.Position(); err != nil {
+		return bounds, err
 	}
 
-	return encoder.TotalBytes(), messageCount, nil
+	if cfg.ToTime != nil {
+		// The first message past the end time ends the recording
+		if bounds.endOffset, err = consumer.OffsetForTime(cfg.ToTime.Add(time.Millisecond)); err != nil {
+			return bounds, err
+		}
+	}
+
+	if cfg.UntilLatest {
+		latest, err := consumer.LastOffset()
+		if err != nil {
+			return bounds, err
+		}
+		if bounds.endOffset < 0 || latest < bounds.endOffset {
+			bounds.endOffset = latest
+		}
+	}
+	return bounds, nil
+}
+
+// timestampType returns the timestamp type of a recorded topic
+func (r *recorder) timestampType(topic string) transcoder.TimestampType {
+	if timestampType, ok := r.cfg.TimestampTypes[topic]; ok {
+		return timestampType
+	}
+	return transcoder.TimestampTypeUnknown
+}
+
+// fail records the first error and stops the other consumers
+func (r *recorder) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.cancel()
+}
+
+// CheckAppendMasking checks that messages appended at an append point are masked like the recording
+// (masking is nil when messages are not masked)
+func CheckAppendMasking(point *transcoder.AppendPoint, masking *mask.Masker) error {
+	recording := point.Sanitization
+	switch {
+	case recording == nil && masking != nil:
+		return errors.New("cannot append masked messages to a recording that is not sanitized")
+	case recording != nil && masking == nil:
+		return fmt.Errorf("the recording is sanitized (rule set %s): append with the same masking rules", recording.RuleSet)
+	case recording != nil && recording.RuleSet != masking.RuleSet():
+		return fmt.Errorf("the recording is sanitized with rule set %s, not %s: append with the same masking rules and secret", recording.RuleSet, masking.RuleSet())
+	default:
+		return nil
+	}
+}
+
+// entryFromMessage converts a consumed Kafka message to a recording entry
+func entryFromMessage(msg kafka.Message, timestampType transcoder.TimestampType) *transcoder.Entry {
+	var headers []transcoder.Header
+	if len(msg.Headers) > 0 {
+		headers = make([]transcoder.Header, len(msg.Headers))
+		for i, h := range msg.Headers {
+			headers[i] = transcoder.Header{Key: h.Key, Value: h.Value}
+		}
+	}
+	return &transcoder.Entry{
+		Timestamp:     msg.Time,
+		TimestampType: timestampType,
+		Key:           msg.Key,
+		Data:          msg.Value,
+		Headers:       headers,
+		Topic:         msg.Topic,
+		Partition:     msg.Partition,
+		Offset:        msg.Offset,
+	}
 }
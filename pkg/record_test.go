@@ -0,0 +1,330 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeStart is the timestamp of the message at offset 0 of a fakePartition, each offset one second later
+var fakeStart = time.Date(2026, 2, 2, 10, 0, 0, 0, time.UTC)
+
+// fakePartition is a partition read like a *kafka.Consumer in direct partition mode
+type fakePartition struct {
+	offsets   []int64 // Offsets of the messages, the others are records that are not messages (transaction markers)
+	first     int64   // First offset still in the partition (older records were deleted by retention)
+	end       int64   // High-watermark
+	batchSize int
+	// skipsRecords moves the position past the records after the last message of a batch, like kafka-go does
+	// when it knows the last offset of the batch
+	skipsRecords bool
+
+	next       int64
+	batch      []int64
+	inBatch    bool
+	read       int
+	emptyFetch bool
+	fetches    int
+}
+
+func (p *fakePartition) Partition() (string, int, bool) { return "orders", 0, true }
+func (p *fakePartition) LastOffset() (int64, error)     { return p.end, nil }
+func (p *fakePartition) Position() (int64, error)       { return p.next, nil }
+func (p *fakePartition) EmptyFetch() bool               { return p.emptyFetch }
+
+func (p *fakePartition) SetAbsoluteOffset(offset int64) error {
+	if offset < p.first || offset > p.end {
+		return fmt.Errorf("offset %d is out of range [%d, %d]", offset, p.first, p.end)
+	}
+	p.next = offset
+	return nil
+}
+
+func (p *fakePartition) OffsetForTime(t time.Time) (int64, error) {
+	for _, offset := range p.offsets {
+		if !fakeStart.Add(time.Duration(offset) * time.Second).Before(t) {
+			return offset, nil
+		}
+	}
+	return -1, nil
+}
+
+func (p *fakePartition) SetOffsetForTime(t time.Time) (int64, error) {
+	offset, _ := p.OffsetForTime(t)
+	if offset < 0 {
+		offset = p.end
+	}
+	p.next = offset
+	return offset, nil
+}
+
+func (p *fakePartition) ReadNextMessage(ctx context.Context) (kafka.Message, error) {
+	if !p.inBatch {
+		// A broker would block until new messages arrive, so fetching far too many batches means waiting forever
+		if p.fetches++; p.fetches > 100 {
+			return kafka.Message{}, fmt.Errorf("still reading after %d fetches", p.fetches)
+		}
+		p.inBatch, p.batch, p.read = true, nil, 0
+		for _, offset := range p.offsets {
+			if offset >= p.next && len(p.batch) < p.batchSize {
+				p.batch = append(p.batch, offset)
+			}
+		}
+	}
+	if len(p.batch) == 0 {
+		p.inBatch, p.emptyFetch = false, p.read == 0
+		if p.skipsRecords && p.read > 0 && p.next > p.offsets[len(p.offsets)-1] {
+			p.next = p.end
+		}
+		return kafka.Message{}, io.EOF
+	}
+	offset := p.batch[0]
+	p.batch, p.next, p.read = p.batch[1:], offset+1, p.read+1
+	return kafka.Message{Topic: "orders", Offset: offset, Time: fakeStart.Add(time.Duration(offset) * time.Second), Value: []byte("v")}, nil
+}
+
+// offsets returns the offsets from first to last, without the skipped ones
+func offsets(first, last int64, skipped ...int64) []int64 {
+	var result []int64
+	for offset := first; offset <= last; offset++ {
+		if !slices.Contains(skipped, offset) {
+			result = append(result, offset)
+		}
+	}
+	return result
+}
+
+// record runs a recorder on a partition and returns the recorded offsets
+func record(t *testing.T, partition *fakePartition, cfg RecordConfig) ([]int64, error) {
+	t.Helper()
+	bounds, err := resolveBounds(partition, cfg)
+	if err != nil {
+		t.Fatalf("resolveBounds failed: %v", err)
+	}
+	var out bytes.Buffer
+	encoder, err := transcoder.NewEncodeWriter(&out)
+	if err != nil {
+		t.Fatalf("NewEncodeWriter failed: %v", err)
+	}
+	r := &recorder{cfg: cfg, encoder: encoder, cancel: func() {}}
+	runErr := r.run(context.Background(), partition, bounds)
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	decoder, err := transcoder.NewDecodeReader(bytes.NewReader(out.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+	var recorded []int64
+	for {
+		entry, err := decoder.Read()
+		if err == io.EOF {
+			return recorded, runErr
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		recorded = append(recorded, entry.Offset)
+	}
+}
+
+func TestResolveBounds(t *testing.T) {
+	at := func(seconds float64) *time.Time {
+		t := fakeStart.Add(time.Duration(seconds * float64(time.Second)))
+		return &t
+	}
+	appended := &transcoder.AppendPoint{LastOffsets: map[string]map[int]int64{"orders": {0: 104}}}
+	otherPartition := &transcoder.AppendPoint{LastOffsets: map[string]map[int]int64{"orders": {1: 104}}}
+	deleted := &transcoder.AppendPoint{LastOffsets: map[string]map[int]int64{"orders": {0: 50}}}
+
+	tests := []struct {
+		name        string
+		partition   *fakePartition
+		cfg         RecordConfig
+		startOffset int64
+		endOffset   int64
+		position    int64 // Position of the consumer after resolving the bounds
+		wantErr     string
+	}{
+		{
+			name:        "no bounds",
+			partition:   &fakePartition{offsets: offsets(0, 9), end: 10},
+			startOffset: -1, endOffset: -1, position: 0,
+		},
+		{
+			name:        "until latest",
+			partition:   &fakePartition{offsets: offsets(0, 9), end: 10, next: 3},
+			cfg:         RecordConfig{UntilLatest: true},
+			startOffset: 3, endOffset: 10, position: 3,
+		},
+		{
+			name:        "until latest of an empty partition",
+			partition:   &fakePartition{end: 0},
+			cfg:         RecordConfig{UntilLatest: true},
+			startOffset: 0, endOffset: 0, position: 0,
+		},
+		{
+			name:        "from time of a message",
+			partition:   &fakePartition{offsets: offsets(0, 9), end: 10},
+			cfg:         RecordConfig{FromTime: at(3)},
+			startOffset: 3, endOffset: -1, position: 3,
+		},
+		{
+			name:        "from time between messages",
+			partition:   &fakePartition{offsets: offsets(0, 9, 4), end: 10},
+			cfg:         RecordConfig{FromTime: at(3.5)},
+			startOffset: 5, endOffset: -1, position: 5,
+		},
+		{
+			name:        "from time after the last message",
+			partition:   &fakePartition{offsets: offsets(0, 9), end: 10},
+			cfg:         RecordConfig{FromTime: at(60)},
+			startOffset: 10, endOffset: -1, position: 10,
+		},
+		{
+			name:        "to time of a message includes it",
+			partition:   &fakePartition{offsets: offsets(0, 9), end: 10, next: 2},
+			cfg:         RecordConfig{ToTime: at(5)},
+			startOffset: 2, endOffset: 6, position: 2,
+		},
+		{
+			name:        "to time after the last message",
+			partition:   &fakePartition{offsets: offsets(0, 9), end: 10},
+			cfg:         RecordConfig{ToTime: at(60)},
+			startOffset: 0, endOffset: -1, position: 0,
+		},
+		{
+			name:        "time range",
+			partition:   &fakePartition{offsets: offsets(0, 9), end: 10},
+			cfg:         RecordConfig{FromTime: at(2), ToTime: at(4)},
+			startOffset: 2, endOffset: 5, position: 2,
+		},
+		{
+			name:        "to time before the latest offset",
+			partition:   &fakePartition{offsets: offsets(0, 9), end: 10},
+			cfg:         RecordConfig{ToTime: at(4), UntilLatest: true},
+			startOffset: 0, endOffset: 5, position: 0,
+		},
+		{
+			name:        "latest offset before the to time",
+			partition:   &fakePartition{offsets: offsets(0, 9), end: 10},
+			cfg:         RecordConfig{ToTime: at(60), UntilLatest: true},
+			startOffset: 0, endOffset: 10, position: 0,
+		},
+		{
+			name:        "append resumes after the last recorded offset",
+			partition:   &fakePartition{offsets: offsets(100, 109), first: 100, end: 110, next: 100},
+			cfg:         RecordConfig{Append: appended},
+			startOffset: -1, endOffset: -1, position: 105,
+		},
+		{
+			name:        "append resumes before the from time",
+			partition:   &fakePartition{offsets: offsets(100, 109), first: 100, end: 110, next: 100},
+			cfg:         RecordConfig{Append: appended, FromTime: at(108), UntilLatest: true},
+			startOffset: 105, endOffset: 110, position: 105,
+		},
+		{
+			name:        "append of a partition missing from the recording",
+			partition:   &fakePartition{offsets: offsets(100, 109), first: 100, end: 110, next: 100},
+			cfg:         RecordConfig{Append: otherPartition, FromTime: at(108)},
+			startOffset: 108, endOffset: -1, position: 108,
+		},
+		{
+			name:      "append after messages deleted by retention",
+			partition: &fakePartition{offsets: offsets(100, 109), first: 100, end: 110, next: 100},
+			cfg:       RecordConfig{Append: deleted},
+			wantErr:   "failed to continue partition 0 of 'orders' after offset 50",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := resolveBounds(tc.partition, tc.cfg)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveBounds failed: %v", err)
+			}
+			if got.startOffset != tc.startOffset || got.endOffset != tc.endOffset || got.endTime != tc.cfg.ToTime {
+				t.Errorf("bounds = %d to %d (end time %v), want %d to %d (end time %v)",
+					got.startOffset, got.endOffset, got.endTime, tc.startOffset, tc.endOffset, tc.cfg.ToTime)
+			}
+			if tc.partition.next != tc.position {
+				t.Errorf("consumer is at offset %d, want %d", tc.partition.next, tc.position)
+			}
+		})
+	}
+}
+
+// TestRecorder_UntilLatest tests that --until-latest stops at the high-watermark the partition had when the
+// recording started, also when the last records below it are not messages (transaction markers)
+func TestRecorder_UntilLatest(t *testing.T) {
+	tests := []struct {
+		name      string
+		partition *fakePartition
+		want      string
+	}{
+		{
+			name:      "messages up to the high-watermark",
+			partition: &fakePartition{offsets: offsets(0, 5), end: 6, batchSize: 2},
+			want:      "[0 1 2 3 4 5]",
+		},
+		{
+			name:      "messages written after the start",
+			partition: &fakePartition{offsets: offsets(0, 9), end: 6, batchSize: 4},
+			want:      "[0 1 2 3 4 5]",
+		},
+		{
+			name:      "transaction markers, position past the batch",
+			partition: &fakePartition{offsets: offsets(0, 7, 2, 6, 7), end: 8, batchSize: 2, skipsRecords: true},
+			want:      "[0 1 3 4 5]",
+		},
+		{
+			name:      "transaction markers, empty fetch",
+			partition: &fakePartition{offsets: offsets(0, 7, 2, 6, 7), end: 8, batchSize: 2},
+			want:      "[0 1 3 4 5]",
+		},
+		{
+			name:      "empty partition",
+			partition: &fakePartition{end: 0, batchSize: 2},
+			want:      "[]",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorded, err := record(t, tc.partition, RecordConfig{UntilLatest: true})
+			if err != nil {
+				t.Fatalf("recording did not stop: %v", err)
+			}
+			if fmt.Sprint(recorded) != tc.want {
+				t.Errorf("recorded offsets %v, want %s", recorded, tc.want)
+			}
+		})
+	}
+}
+
+// TestRecorder_Append tests that an appended recording continues right after the last recorded offset of a
+// partition whose first offsets were deleted by retention
+func TestRecorder_Append(t *testing.T) {
+	partition := &fakePartition{offsets: offsets(100, 109), first: 100, end: 110, next: 100, batchSize: 3}
+	appended := &transcoder.AppendPoint{LastOffsets: map[string]map[int]int64{"orders": {0: 104}}}
+	recorded, err := record(t, partition, RecordConfig{Append: appended, UntilLatest: true})
+	if err != nil {
+		t.Fatalf("recording did not stop: %v", err)
+	}
+	if want := "[105 106 107 108 109]"; fmt.Sprint(recorded) != want {
+		t.Errorf("recorded offsets %v, want %s", recorded, want)
+	}
+}
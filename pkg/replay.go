@@ -9,8 +9,11 @@ import (
 	"os"
 	"time"
 
+	"github.com/lolocompany/kafka-replay/v2/pkg/filter"
 	kafkapkg "github.com/lolocompany/kafka-replay/v2/pkg/kafka"
+	"github.com/lolocompany/kafka-replay/v2/pkg/schemaregistry"
 	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transform"
 	"github.com/segmentio/kafka-go"
 )
 
@@ -33,6 +36,41 @@ type ReplayConfig struct {
 	LogWriter io.Writer
 	DryRun    bool   // If true, validate messages without actually sending to Kafka
 	FindBytes []byte // Optional byte sequence to search for in messages
+	// Filter is an optional filter expression messages must match (with FindBytes if both are set)
+	// The decoder must preserve timestamps for the timestamp field; messages still get the time they are sent
+	// unless PreserveTimestamps is set
+	Filter *filter.Filter
+	// Transform is an optional pipeline rewriting the messages that pass the filters before they are sent
+	// Like Filter, the decoder must preserve timestamps for the timestamp field of its where expressions
+	Transform *transform.Pipeline
+	// OriginalTopics sends each message to the topic it was recorded from instead of the producer's topic
+	OriginalTopics bool
+	TopicMap       map[string]string // Optional renames of recorded topics (recorded name -> target name), used with OriginalTopics
+	// StartEntry is the number (0-based) of the first entry to replay, also when looping
+	StartEntry int64
+	// StartTime starts replaying at the first entry with a timestamp at or after this time (overrides StartEntry)
+	StartTime *time.Time
+	// Index is the optional index of the recording, used to seek to StartEntry or StartTime without reading from the start
+	Index *transcoder.Index
+	// Timing replays messages with the gaps between their recorded timestamps instead of at Rate
+	// The decoder must preserve timestamps to read the gaps
+	Timing *ReplayTiming
+	// PreserveTimestamps keeps the recorded timestamps with Timing, Filter or Transform (otherwise messages get
+	// the time they are due or sent)
+	PreserveTimestamps bool
+	// PartitionRouting sends each message to the partition it was recorded from (cannot be used with Partition)
+	PartitionRouting *PartitionRouting
+	// Resume continues a replay at a position saved with Checkpoint (instead of StartEntry or StartTime)
+	Resume *transcoder.Position
+	// Checkpoint is called after each batch acknowledged by the brokers, with the position of the next
+	// message and the number of messages sent so far (not in dry-run mode). It cannot be used with Loop
+	Checkpoint func(position transcoder.Position, sent int64) error
+	// SchemaRemap rewrites the schema IDs of values framed by Confluent serializers to the IDs of their
+	// schemas in a target registry, registered under the subjects of the topics they are sent to (not in dry-run
+	// mode, which registers nothing)
+	SchemaRemap *schemaregistry.Remapper
+	// SchemaRemapKeys also remaps the schema IDs of keys (keys such as big-endian integers may look framed)
+	SchemaRemapKeys bool
 }
 
 func Replay(ctx context.Context, cfg ReplayConfig) (int64, error) {
@@ -45,6 +83,37 @@ func Replay(ctx context.Context, cfg ReplayConfig) (int64, error) {
 	if cfg.LogWriter == nil {
 		cfg.LogWriter = os.Stderr
 	}
+	if cfg.Partition != nil && cfg.PartitionRouting != nil {
+		return 0, errors.New("partition and partition routing cannot be used together")
+	}
+	if cfg.Loop && (cfg.Resume != nil || cfg.Checkpoint != nil) {
+		return 0, errors.New("checkpoints cannot be used when looping")
+	}
+	if cfg.Loop && !cfg.Decoder.Seekable() {
+		return 0, fmt.Errorf("cannot loop over a stream: %w", transcoder.ErrNotSeekable)
+	}
+	if cfg.Resume != nil {
+		if err := cfg.Decoder.SeekPosition(*cfg.Resume); err != nil {
+			return 0, fmt.Errorf("failed to resume at entry %d: %w", cfg.Resume.Entry, err)
+		}
+	} else if err := seekStart(cfg.Decoder, cfg.StartEntry, cfg.StartTime, cfg.Index); err != nil {
+		return 0, err
+	}
+
+	// kafka-go only honours the partition of a message with an explicit balancer
+	var router *partitionRouter
+	if cfg.PartitionRouting != nil {
+		router = newPartitionRouter(*cfg.PartitionRouting)
+	}
+	if cfg.Partition != nil || router != nil {
+		cfg.Producer.UseMessagePartitions()
+	}
+
+	var schedule *replaySchedule
+	if cfg.Timing != nil {
+		schedule = newReplaySchedule(*cfg.Timing)
+		cfg.Producer.SetBatchTimeout(TimingBatchTimeout)
+	}
 
 	// Rate limiting setup - track messages sent and time for steady rate
 	var messagesSent int64
@@ -56,6 +125,8 @@ func Replay(ctx context.Context, cfg ReplayConfig) (int64, error) {
 	var messageCount int64
 	batch := make([]kafka.Message, 0, BatchSize)
 	var batchBytes int64
+	// Position after the last message of the batch, where a resumed replay continues once the batch is sent
+	batchEnd := cfg.Decoder.Position()
 
 	flushBatch := func() error {
 		if len(batch) == 0 {
@@ -103,6 +174,11 @@ func Replay(ctx context.Context, cfg ReplayConfig) (int64, error) {
 			return fmt.Errorf("failed to write batch to Kafka: %w", err)
 		}
 		messagesSent += int64(len(batch))
+		if cfg.Checkpoint != nil {
+			if err := cfg.Checkpoint(batchEnd, messagesSent); err != nil {
+				return fmt.Errorf("failed to write checkpoint: %w", err)
+			}
+		}
 		batch = batch[:0]
 		batchBytes = 0
 		return nil
@@ -133,6 +209,12 @@ func Replay(ctx context.Context, cfg ReplayConfig) (int64, error) {
 					if err := cfg.Decoder.Reset(); err != nil {
 						return messageCount, err
 					}
+					if err := seekStart(cfg.Decoder, cfg.StartEntry, cfg.StartTime, cfg.Index); err != nil {
+						return messageCount, err
+					}
+					if schedule != nil {
+						schedule.reset()
+					}
 					continue
 				}
 				// No more looping, exit
@@ -152,20 +234,76 @@ func Replay(ctx context.Context, cfg ReplayConfig) (int64, error) {
 		if cfg.FindBytes != nil && !bytes.Contains(entry.Data, cfg.FindBytes) {
 			continue
 		}
+		if !cfg.Filter.Match(entry) {
+			continue
+		}
+		if err := cfg.Transform.Apply(entry); err != nil {
+			return messageCount, fmt.Errorf("failed to transform entry %d: %w", cfg.Decoder.EntryNumber()-1, err)
+		}
 
 		// Build Kafka message
 		kafkaMsg := kafka.Message{
-			Key:   entry.Key,
-			Value: entry.Data,
-			Time:  entry.Timestamp,
+			Key:     entry.Key,
+			Value:   entry.Data,
+			Headers: messageHeaders(entry.Headers),
+			Time:    entry.Timestamp,
+		}
+		// Route to the recorded topic (or its rename) if requested
+		if cfg.OriginalTopics {
+			if kafkaMsg.Topic, err = targetTopic(entry, cfg.TopicMap); err != nil {
+				return messageCount, err
+			}
 		}
 		// Set partition if specified in config (nil means auto-assignment)
 		if cfg.Partition != nil {
 			kafkaMsg.Partition = *cfg.Partition
 		}
+		topic := kafkaMsg.Topic
+		if topic == "" {
+			topic = cfg.Producer.Topic()
+		}
+		// Or keep the recorded partition
+		if router != nil {
+			if kafkaMsg.Partition, err = router.partition(ctx, topic, entry); err != nil {
+				return messageCount, err
+			}
+		}
+		// Point the schema IDs at the target registry
+		if cfg.SchemaRemap != nil && !cfg.DryRun {
+			n := cfg.Decoder.EntryNumber() - 1
+			if cfg.SchemaRemapKeys {
+				if kafkaMsg.Key, err = cfg.SchemaRemap.Remap(ctx, topic, true, kafkaMsg.Key); err != nil {
+					return messageCount, fmt.Errorf("failed to remap the key schema of entry %d: %w", n, err)
+				}
+			}
+			if kafkaMsg.Value, err = cfg.SchemaRemap.Remap(ctx, topic, false, kafkaMsg.Value); err != nil {
+				return messageCount, fmt.Errorf("failed to remap the value schema of entry %d: %w", n, err)
+			}
+		}
+
+		// With the original timing, send what is batched and wait until the message is due,
+		// so that batching does not squash the gaps between messages
+		if schedule != nil {
+			due := schedule.due(entry.Timestamp)
+			if time.Now().Before(due) {
+				if err := flushBatch(); err != nil {
+					return messageCount, err
+				}
+				select {
+				case <-ctx.Done():
+					return messageCount, ctx.Err()
+				case <-time.After(time.Until(due)):
+				}
+			}
+		}
+		// Timing, filters and transforms read the recorded timestamps, which are only sent if preserved
+		if (schedule != nil || cfg.Filter != nil || cfg.Transform != nil) && !cfg.PreserveTimestamps {
+			kafkaMsg.Time = time.Now()
+		}
 
 		// Add to batch
 		batch = append(batch, kafkaMsg)
+		batchEnd = cfg.Decoder.Position()
 		batchBytes += int64(len(entry.Data))
 		messageCount++
 
@@ -185,3 +323,26 @@ func Replay(ctx context.Context, cfg ReplayConfig) (int64, error) {
 
 	return messageCount, nil
 }
+
+// targetTopic returns the topic an entry is replayed to when replaying to the original topics
+func targetTopic(entry *transcoder.Entry, topicMap map[string]string) (string, error) {
+	if entry.Topic == "" {
+		return "", errors.New("message has no recorded topic (recorded before format version 3?); use --topic to choose the target topic")
+	}
+	if target, ok := topicMap[entry.Topic]; ok {
+		return target, nil
+	}
+	return entry.Topic, nil
+}
+
+// messageHeaders converts recorded headers to Kafka message headers
+func messageHeaders(headers []transcoder.Header) []kafka.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	result := make([]kafka.Header, len(headers))
+	for i, h := range headers {
+		result[i] = kafka.Header{Key: h.Key, Value: h.Value}
+	}
+	return result
+}
@@ -0,0 +1,216 @@
+package avro
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// avroLong encodes a zigzag variable-length long
+func avroLong(v int64) []byte {
+	return binary.AppendVarint(nil, v)
+}
+
+func avroString(s string) []byte {
+	return append(avroLong(int64(len(s))), s...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+const orderSchema = `{
+	"type": "record", "name": "Order", "namespace": "shop",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "quantity", "type": "int"},
+		{"name": "delta", "type": "long"},
+		{"name": "paid", "type": "boolean"},
+		{"name": "price", "type": "double"},
+		{"name": "note", "type": ["null", "string"]},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "counts", "type": {"type": "map", "values": "int"}},
+		{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 2}},
+		{"name": "amount", "type": {"type": "bytes", "logicalType": "decimal", "precision": 9, "scale": 2}},
+		{"name": "customer", "type": "Customer"},
+		{"name": "previous", "type": ["null", "Order"]}
+	]
+}`
+
+const customerSchema = `{"type": "record", "name": "shop.Customer", "fields": [{"name": "email", "type": "string"}]}`
+
+// orderData returns an Order and the JSON it decodes to
+func orderData() ([]byte, string) {
+	price := make([]byte, 8)
+	binary.LittleEndian.PutUint64(price, math.Float64bits(9.5))
+	data := concat(
+		avroString("o-1"),
+		avroLong(3),
+		avroLong(-42),
+		[]byte{1},
+		price,
+		avroLong(1), avroString("<gift>"),
+		avroLong(1),
+		// Two blocks, the second one with a negative count followed by its size in bytes
+		avroLong(1), avroString("a"), avroLong(-1), avroLong(2), avroString("b"), avroLong(0),
+		avroLong(1), avroString("x"), avroLong(7), avroLong(0),
+		[]byte{0xab, 0xcd},
+		avroLong(2), []byte{0xff, 0x85}, // -123 with scale 2
+		avroString("ann@example.com"),
+		avroLong(1), // Previous order, without a previous one
+		avroString("o-0"), avroLong(1), avroLong(0), []byte{0}, make([]byte, 8), avroLong(0), avroLong(0),
+		avroLong(0), avroLong(0), []byte{0, 0}, avroLong(1), []byte{0x01}, avroString("bob@example.com"), avroLong(0),
+	)
+	want := `{"id":"o-1","quantity":3,"delta":-42,"paid":true,"price":9.5,"note":"<gift>","status":"PAID",` +
+		`"tags":["a","b"],"counts":{"x":7},"hash":"q80=","amount":"-1.23","customer":{"email":"ann@example.com"},` +
+		`"previous":{"id":"o-0","quantity":1,"delta":0,"paid":false,"price":0,"note":null,"status":"NEW","tags":[],` +
+		`"counts":{},"hash":"AAA=","amount":"0.01","customer":{"email":"bob@example.com"},"previous":null}}`
+	return data, want
+}
+
+func TestSchema_Decode(t *testing.T) {
+	order, orderJSON := orderData()
+	nan := make([]byte, 4)
+	binary.LittleEndian.PutUint32(nan, math.Float32bits(float32(math.NaN())))
+
+	tests := []struct {
+		name       string
+		schema     string
+		references []string
+		data       []byte
+		want       string
+	}{
+		{name: "record", schema: orderSchema, references: []string{customerSchema}, data: order, want: orderJSON},
+		{name: "null", schema: `"null"`, data: nil, want: `null`},
+		{name: "int", schema: `"int"`, data: avroLong(-2147483648), want: `-2147483648`},
+		{name: "long", schema: `"long"`, data: avroLong(math.MaxInt64), want: `9223372036854775807`},
+		{name: "float NaN", schema: `"float"`, data: nan, want: `"NaN"`},
+		{name: "string", schema: `"string"`, data: avroString("é\"<"), want: `"é\"<"`},
+		{name: "bytes", schema: `"bytes"`, data: concat(avroLong(3), []byte{0, 1, 2}), want: `"AAEC"`},
+		{name: "union branch", schema: `["null", "long"]`, data: concat(avroLong(1), avroLong(5)), want: `5`},
+		{name: "enum", schema: `{"type": "enum", "name": "E", "symbols": ["A", "B"]}`, data: avroLong(1), want: `"B"`},
+		{
+			name:   "decimal fixed",
+			schema: `{"type": "fixed", "name": "D", "size": 3, "logicalType": "decimal", "precision": 6, "scale": 4}`,
+			data:   []byte{0x00, 0x30, 0x39}, // 12345
+			want:   `"1.2345"`,
+		},
+		{
+			name:   "map of arrays",
+			schema: `{"type": "map", "values": {"type": "array", "items": "int"}}`,
+			data:   concat(avroLong(1), avroString("k"), avroLong(2), avroLong(1), avroLong(2), avroLong(0), avroLong(0)),
+			want:   `{"k":[1,2]}`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := Parse(tc.schema, tc.references...)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			got, err := schema.Decode(tc.data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("Decode:\n got %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSchema_Decode_Errors(t *testing.T) {
+	order, _ := orderData()
+	tests := []struct {
+		name   string
+		schema string
+		data   []byte
+		want   string
+	}{
+		{"truncated", orderSchema, order[:len(order)-3], "truncated"},
+		{"trailing bytes", orderSchema, append(order, 0), "trailing"},
+		{"invalid union branch", `["null", "long"]`, avroLong(2), "invalid union branch 2"},
+		{"invalid enum symbol", `{"type": "enum", "name": "E", "symbols": ["A"]}`, avroLong(1), "invalid symbol index 1"},
+		{"negative length", `"string"`, avroLong(-1), "negative length"},
+		{"length past the data", `"bytes"`, avroLong(1 << 40), "truncated"},
+		{"block count past the data", `{"type": "array", "items": "null"}`, avroLong(1 << 40), "invalid block count"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := Parse(tc.schema, customerSchema)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if _, err := schema.Decode(tc.data); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		schema     string
+		references []string
+		wantName   string
+		wantErr    string
+	}{
+		{name: "named record", schema: orderSchema, references: []string{customerSchema}, wantName: "shop.Order"},
+		{
+			name:       "reference in the global namespace",
+			schema:     orderSchema,
+			references: []string{`{"type": "record", "name": "Customer", "fields": [{"name": "email", "type": "string"}]}`},
+			wantName:   "shop.Order",
+		},
+		{name: "primitive", schema: `"string"`, wantName: ""},
+		{name: "unknown type", schema: `{"type": "record", "name": "A", "fields": [{"name": "b", "type": "B"}]}`, wantErr: "unknown type: B"},
+		{name: "missing reference", schema: orderSchema, wantErr: "unknown type: Customer"},
+		{name: "record without a name", schema: `{"type": "record", "fields": []}`, wantErr: "name key required"},
+		{name: "fixed without a size", schema: `{"type": "fixed", "name": "F"}`, wantErr: "fixed must have a size"},
+		{name: "invalid JSON", schema: `{"type"`, wantErr: "invalid Avro schema"},
+		{name: "invalid reference", schema: `"string"`, references: []string{`{`}, wantErr: "invalid Avro schema reference 1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := Parse(tc.schema, tc.references...)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if schema.Name() != tc.wantName {
+				t.Errorf("Name() = %q, want %q", schema.Name(), tc.wantName)
+			}
+		})
+	}
+}
+
+// FuzzSchema_Decode tests that any data either fails to decode or decodes to valid JSON
+func FuzzSchema_Decode(f *testing.F) {
+	schema, err := Parse(orderSchema, customerSchema)
+	if err != nil {
+		f.Fatalf("Parse failed: %v", err)
+	}
+	order, _ := orderData()
+	f.Add(order)
+	f.Add(order[:10])
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		got, err := schema.Decode(data)
+		if err == nil && !json.Valid(got) {
+			t.Errorf("Decode(%x) returned invalid JSON %s", data, got)
+		}
+	})
+}
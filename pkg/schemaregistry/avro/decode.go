@@ -0,0 +1,241 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/hamba/avro/v2"
+)
+
+var errTruncated = errors.New("truncated data")
+
+// Decode decodes Avro binary data written with the schema to JSON
+func (s *Schema) Decode(data []byte) ([]byte, error) {
+	d := &decoder{reader: avro.NewReader(nil, 0).Reset(data), size: len(data)}
+	var out bytes.Buffer
+	err := d.value(&out, s.root)
+	if err == nil {
+		err = d.reader.Error
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = errTruncated
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid Avro data: %w", err)
+	}
+	if d.reader.Peek(); d.reader.Error == nil {
+		return nil, errors.New("invalid Avro data: trailing bytes")
+	}
+	return out.Bytes(), nil
+}
+
+// decoder writes the values read from Avro binary data as JSON. Reading errors are kept by the reader and
+// checked where they could otherwise make the decoder loop or allocate for nothing
+type decoder struct {
+	reader *avro.Reader
+	size   int // Size of the data, which bounds the lengths and counts read from it
+}
+
+func (d *decoder) value(out *bytes.Buffer, schema avro.Schema) error {
+	if d.reader.Error != nil {
+		return d.reader.Error
+	}
+	switch schema.Type() {
+	case avro.Null:
+		out.WriteString("null")
+	case avro.Boolean:
+		out.WriteString(strconv.FormatBool(d.reader.ReadBool()))
+	case avro.Int:
+		out.WriteString(strconv.FormatInt(int64(d.reader.ReadInt()), 10))
+	case avro.Long:
+		out.WriteString(strconv.FormatInt(d.reader.ReadLong(), 10))
+	case avro.Float:
+		writeFloat(out, float64(d.reader.ReadFloat()), 32)
+	case avro.Double:
+		writeFloat(out, d.reader.ReadDouble(), 64)
+	case avro.String:
+		b, err := d.bytes()
+		if err != nil {
+			return err
+		}
+		writeString(out, string(b))
+	case avro.Bytes:
+		b, err := d.bytes()
+		if err != nil {
+			return err
+		}
+		writeBytes(out, b, decimalScale(schema))
+	case avro.Fixed:
+		b := make([]byte, schema.(*avro.FixedSchema).Size())
+		d.reader.Read(b)
+		writeBytes(out, b, decimalScale(schema))
+	case avro.Enum:
+		enum := schema.(*avro.EnumSchema)
+		i := d.reader.ReadInt()
+		if symbols := enum.Symbols(); i >= 0 && int(i) < len(symbols) {
+			writeString(out, symbols[i])
+		} else if d.reader.Error == nil {
+			return fmt.Errorf("enum %s: invalid symbol index %d", enum.FullName(), i)
+		}
+	case avro.Union:
+		branches := schema.(*avro.UnionSchema).Types()
+		i := d.reader.ReadLong()
+		if i < 0 || i >= int64(len(branches)) {
+			if d.reader.Error != nil {
+				return d.reader.Error
+			}
+			return fmt.Errorf("invalid union branch %d", i)
+		}
+		return d.value(out, branches[i])
+	case avro.Record, avro.Error:
+		record := schema.(*avro.RecordSchema)
+		out.WriteByte('{')
+		for i, f := range record.Fields() {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			writeString(out, f.Name())
+			out.WriteByte(':')
+			if err := d.value(out, f.Type()); err != nil {
+				return fmt.Errorf("%s.%s: %w", record.FullName(), f.Name(), err)
+			}
+		}
+		out.WriteByte('}')
+	case avro.Ref:
+		return d.value(out, schema.(*avro.RefSchema).Schema())
+	case avro.Array, avro.Map:
+		return d.collection(out, schema)
+	default:
+		return fmt.Errorf("unsupported type %s", schema.Type())
+	}
+	return nil
+}
+
+// collection writes an array as a JSON array or a map as a JSON object
+func (d *decoder) collection(out *bytes.Buffer, schema avro.Schema) error {
+	var items avro.Schema
+	open, close := byte('['), byte(']')
+	if array, ok := schema.(*avro.ArraySchema); ok {
+		items = array.Items()
+	} else {
+		items = schema.(*avro.MapSchema).Values()
+		open, close = '{', '}'
+	}
+	out.WriteByte(open)
+	first := true
+	for {
+		count, _ := d.reader.ReadBlockHeader()
+		if d.reader.Error != nil {
+			return d.reader.Error
+		}
+		if count == 0 {
+			break
+		}
+		// Refuse counts that could not be read from the data rather than looping on them
+		if count > int64(d.size) || count < 0 {
+			return fmt.Errorf("invalid block count %d", count)
+		}
+		for ; count > 0; count-- {
+			if !first {
+				out.WriteByte(',')
+			}
+			first = false
+			if close == '}' {
+				key, err := d.bytes()
+				if err != nil {
+					return err
+				}
+				writeString(out, string(key))
+				out.WriteByte(':')
+			}
+			if err := d.value(out, items); err != nil {
+				return err
+			}
+		}
+	}
+	out.WriteByte(close)
+	return nil
+}
+
+// bytes reads the length and content of bytes or a string, refusing lengths larger than the data before
+// allocating them
+func (d *decoder) bytes() ([]byte, error) {
+	size := d.reader.ReadLong()
+	if d.reader.Error != nil {
+		return nil, d.reader.Error
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("negative length %d", size)
+	}
+	if size > int64(d.size) {
+		return nil, errTruncated
+	}
+	b := make([]byte, size)
+	d.reader.Read(b)
+	return b, d.reader.Error
+}
+
+// decimalScale returns the scale of bytes or fixed with the decimal logical type, -1 for other types
+func decimalScale(schema avro.Schema) int {
+	if logical, ok := schema.(avro.LogicalTypeSchema); ok {
+		if decimal, ok := logical.Logical().(*avro.DecimalLogicalSchema); ok {
+			return decimal.Scale()
+		}
+	}
+	return -1
+}
+
+// writeString writes a JSON string without escaping HTML characters
+func writeString(out *bytes.Buffer, s string) {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)           // Strings always encode
+	out.Truncate(out.Len() - 1) // Newline written by Encode
+}
+
+// writeFloat writes a float as a JSON number, or as a string for NaN and infinities
+func writeFloat(out *bytes.Buffer, f float64, bitSize int) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		writeString(out, strconv.FormatFloat(f, 'g', -1, bitSize))
+		return
+	}
+	out.WriteString(strconv.FormatFloat(f, 'g', -1, bitSize))
+}
+
+// writeBytes writes bytes as a base64 string, or decimals (scale >= 0) as a decimal number in a string
+func writeBytes(out *bytes.Buffer, b []byte, scale int) {
+	if scale < 0 {
+		writeString(out, base64.StdEncoding.EncodeToString(b))
+		return
+	}
+	// Two's-complement big-endian unscaled value
+	unscaled := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	writeString(out, formatDecimal(unscaled, scale))
+}
+
+// formatDecimal formats an unscaled value with scale digits after the decimal point
+func formatDecimal(unscaled *big.Int, scale int) string {
+	digits := new(big.Int).Abs(unscaled).String()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
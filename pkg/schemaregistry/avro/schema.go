@@ -0,0 +1,86 @@
+// Package avro decodes Avro binary data to JSON with its schema, for messages framed by the Confluent Schema Registry
+//
+// Schemas are parsed and binary data is read with github.com/hamba/avro; this package only maps the values to
+// JSON. Values are written in plain JSON rather than in the Avro JSON encoding: unions are written as the value of
+// their branch (without the {"type": value} wrapper), enums as their symbol, bytes and fixed as base64 strings
+// and decimals as decimal numbers in strings. Records keep the order of their fields
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hamba/avro/v2"
+)
+
+// Schema is a parsed Avro schema
+type Schema struct {
+	root avro.Schema
+}
+
+// Parse parses an Avro schema in JSON. References are the schemas of the named types the schema uses
+// without defining them (the references of a schema in the registry), parsed first in order
+func Parse(schema string, references ...string) (*Schema, error) {
+	cache := &avro.SchemaCache{} // Named types of the references, for this schema only
+	var global []avro.NamedSchema
+	for i, reference := range references {
+		parsed, err := avro.ParseWithCache(reference, "", cache)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Avro schema reference %d: %w", i+1, err)
+		}
+		if named, ok := parsed.(avro.NamedSchema); ok && named.Namespace() == "" {
+			global = append(global, named)
+		}
+	}
+	// Like the Java parser, also resolve names in the global namespace, e.g. a reference to a Customer
+	// without namespace from a record of the shop namespace
+	if len(global) > 0 {
+		var v any
+		if err := json.Unmarshal([]byte(schema), &v); err != nil {
+			return nil, fmt.Errorf("invalid Avro schema: %w", err)
+		}
+		namespaces := make(map[string]bool)
+		collectNamespaces(v, namespaces)
+		for namespace := range namespaces {
+			for _, named := range global {
+				cache.Add(namespace+"."+named.Name(), named)
+			}
+		}
+	}
+	root, err := avro.ParseWithCache(schema, "", cache)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	return &Schema{root: root}, nil
+}
+
+// Name returns the full name of the type of the schema: a record, enum or fixed (empty for other types)
+func (s *Schema) Name() string {
+	if named, ok := s.root.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	return ""
+}
+
+// collectNamespaces collects the namespaces of the named types defined in a schema
+func collectNamespaces(v any, namespaces map[string]bool) {
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			collectNamespaces(item, namespaces)
+		}
+	case map[string]any:
+		if namespace, ok := v["namespace"].(string); ok && namespace != "" {
+			namespaces[namespace] = true
+		}
+		if name, ok := v["name"].(string); ok {
+			if i := strings.LastIndex(name, "."); i > 0 {
+				namespaces[name[:i]] = true
+			}
+		}
+		for _, item := range v {
+			collectNamespaces(item, namespaces)
+		}
+	}
+}
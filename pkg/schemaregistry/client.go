@@ -0,0 +1,263 @@
+// Package schemaregistry reads Confluent Schema Registry schemas over HTTP and decodes the keys and values framed
+// by Confluent serializers (a magic byte and a 4-byte schema ID before the payload) with them, or remaps their
+// schema IDs to another registry
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaType is the type of a registered schema
+type SchemaType string
+
+const (
+	// SchemaTypeAvro is the type of Avro schemas, reported as an empty type by the registry
+	SchemaTypeAvro SchemaType = "AVRO"
+	// SchemaTypeProtobuf is the type of Protobuf schemas (.proto source)
+	SchemaTypeProtobuf SchemaType = "PROTOBUF"
+	// SchemaTypeJSON is the type of JSON schemas, whose payloads are JSON already
+	SchemaTypeJSON SchemaType = "JSON"
+)
+
+// RequestTimeout bounds every request to the registry
+const RequestTimeout = 30 * time.Second
+
+// Schema is a registered schema
+type Schema struct {
+	// ID is the global ID of the schema (set by lookups by ID and by subject and version)
+	ID         int         `json:"id,omitempty"`
+	Type       SchemaType  `json:"schemaType,omitempty"`
+	Schema     string      `json:"schema"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// SchemaType returns the type of the schema, SchemaTypeAvro if the registry did not report one
+func (s *Schema) SchemaType() SchemaType {
+	if s.Type == "" {
+		return SchemaTypeAvro
+	}
+	return s.Type
+}
+
+// Reference is a schema a schema depends on: a named Avro type or an imported .proto file
+type Reference struct {
+	// Name is the name of the reference in the schema: the full name of an Avro type or a .proto import path
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// ErrorCodeSchemaNotFound is the error code of the registry for unknown schema IDs
+const ErrorCodeSchemaNotFound = 40403
+
+// ErrSchemaNotFound is returned for schema IDs the registry does not know. Data that merely looks framed (e.g.
+// a big-endian integer key starting with a zero byte) usually has such an ID
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// Error is an error response of the registry
+type Error struct {
+	StatusCode int
+	Code       int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("schema registry returned HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("schema registry: %s (error code %d)", e.Message, e.Code)
+}
+
+// Is reports a schema not found response as ErrSchemaNotFound
+func (e *Error) Is(target error) bool {
+	return target == ErrSchemaNotFound && e.StatusCode == http.StatusNotFound && (e.Code == 0 || e.Code == ErrorCodeSchemaNotFound)
+}
+
+// Client is a Schema Registry client caching the schemas it reads, safe for concurrent use
+type Client struct {
+	baseURL  *url.URL
+	username string
+	password string
+	http     *http.Client
+
+	mu        sync.Mutex
+	byID      map[int]*Schema
+	missing   map[int]error // Responses to IDs the registry does not know
+	byVersion map[Reference]*Schema
+}
+
+// NewClient creates a client for the registry at baseURL (http or https), with basic authentication if a
+// username is set (credentials in the URL are used otherwise)
+func NewClient(baseURL, username, password string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid schema registry URL %q: expected http(s)://host[:port]", baseURL)
+	}
+	if username == "" && u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	u.User = nil
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &Client{
+		baseURL:   u,
+		username:  username,
+		password:  password,
+		http:      &http.Client{Timeout: RequestTimeout},
+		byID:      make(map[int]*Schema),
+		missing:   make(map[int]error),
+		byVersion: make(map[Reference]*Schema),
+	}, nil
+}
+
+// URL returns the URL of the registry, without credentials
+func (c *Client) URL() string {
+	return c.baseURL.String()
+}
+
+// SchemaByID returns the schema with a global ID. Unknown IDs return an error matching ErrSchemaNotFound and
+// are not requested again
+func (c *Client) SchemaByID(ctx context.Context, id int) (*Schema, error) {
+	c.mu.Lock()
+	schema, ok := c.byID[id]
+	missing := c.missing[id]
+	c.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+	if missing != nil {
+		return nil, missing
+	}
+
+	schema = &Schema{}
+	if err := c.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, schema); err != nil {
+		err = fmt.Errorf("failed to get schema %d: %w", id, err)
+		if errors.Is(err, ErrSchemaNotFound) {
+			c.mu.Lock()
+			c.missing[id] = err
+			c.mu.Unlock()
+		}
+		return nil, err
+	}
+	schema.ID = id
+	c.mu.Lock()
+	c.byID[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// SchemaByVersion returns a version of the schema of a subject (the name of the reference is not used)
+func (c *Client) SchemaByVersion(ctx context.Context, subject string, version int) (*Schema, error) {
+	key := Reference{Subject: subject, Version: version}
+	c.mu.Lock()
+	schema, ok := c.byVersion[key]
+	c.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema = &Schema{}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions/" + strconv.Itoa(version)
+	if err := c.do(ctx, http.MethodGet, path, nil, schema); err != nil {
+		return nil, fmt.Errorf("failed to get version %d of subject %s: %w", version, subject, err)
+	}
+	c.mu.Lock()
+	c.byVersion[key] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// registration is the body of the requests registering and looking up a schema under a subject
+type registration struct {
+	Type       SchemaType  `json:"schemaType,omitempty"`
+	Schema     string      `json:"schema"`
+	References []Reference `json:"references,omitempty"`
+}
+
+func newRegistration(schema *Schema) registration {
+	r := registration{Type: schema.Type, Schema: schema.Schema, References: schema.References}
+	if r.Type == SchemaTypeAvro {
+		r.Type = "" // Older registries reject the AVRO type, their default
+	}
+	return r
+}
+
+// Register registers a schema under a subject and returns its global ID. Registering a schema the subject has
+// already returns its existing ID
+func (c *Client) Register(ctx context.Context, subject string, schema *Schema) (int, error) {
+	var response struct {
+		ID int `json:"id"`
+	}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions"
+	if err := c.do(ctx, http.MethodPost, path, newRegistration(schema), &response); err != nil {
+		return 0, fmt.Errorf("failed to register schema under subject %s: %w", subject, err)
+	}
+	return response.ID, nil
+}
+
+// Version returns the version of a schema registered under a subject
+func (c *Client) Version(ctx context.Context, subject string, schema *Schema) (int, error) {
+	var response struct {
+		Version int `json:"version"`
+	}
+	path := "/subjects/" + url.PathEscape(subject)
+	if err := c.do(ctx, http.MethodPost, path, newRegistration(schema), &response); err != nil {
+		return 0, fmt.Errorf("failed to look up schema under subject %s: %w", subject, err)
+	}
+	return response.Version, nil
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := *c.baseURL
+	u.Path += path
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		registryErr := &Error{StatusCode: resp.StatusCode}
+		json.Unmarshal(data, registryErr) // Keep the status code only if the body is not an error response
+		return registryErr
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid schema registry response: %w", err)
+	}
+	return nil
+}
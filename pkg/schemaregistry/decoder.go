@@ -0,0 +1,154 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/schemaregistry/avro"
+	"github.com/lolocompany/kafka-replay/v2/pkg/schemaregistry/protobuf"
+)
+
+// MagicByte is the first byte of data framed by Confluent serializers
+const MagicByte = 0
+
+// FrameHeaderSize is the size of the framing before the payload: the magic byte and the 4-byte big-endian schema ID
+const FrameHeaderSize = 5
+
+// ErrNotFramed is returned when decoding data that is not framed by Confluent serializers
+var ErrNotFramed = errors.New("not in the Confluent wire format")
+
+// ErrInvalidPayload is returned when the payload of framed data does not decode with its schema, e.g. for data
+// that only looks framed
+var ErrInvalidPayload = errors.New("payload does not match its schema")
+
+// SchemaID returns the schema ID of data framed by Confluent serializers and the payload after the framing,
+// or false if the data is not framed
+func SchemaID(data []byte) (int, []byte, bool) {
+	if len(data) < FrameHeaderSize || data[0] != MagicByte {
+		return 0, nil, false
+	}
+	return int(binary.BigEndian.Uint32(data[1:FrameHeaderSize])), data[FrameHeaderSize:], true
+}
+
+// codec decodes the payloads written with a schema to JSON
+type codec interface {
+	Decode(payload []byte) ([]byte, error)
+}
+
+// jsonCodec passes JSON Schema payloads through, since they are JSON already
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(payload []byte) ([]byte, error) {
+	if !json.Valid(payload) {
+		return nil, errors.New("invalid JSON payload")
+	}
+	return payload, nil
+}
+
+// Decoder decodes data framed by Confluent serializers to JSON with the schemas of a registry, compiling each
+// schema once. It is safe for concurrent use
+type Decoder struct {
+	client *Client
+	mu     sync.Mutex
+	codecs map[int]codec
+}
+
+// NewDecoder creates a decoder reading schemas from a registry
+func NewDecoder(client *Client) *Decoder {
+	return &Decoder{client: client, codecs: make(map[int]codec)}
+}
+
+// Decode decodes framed data to JSON and returns its schema ID, or ErrNotFramed if the data is not framed.
+// Errors match ErrSchemaNotFound for unknown schema IDs and ErrInvalidPayload for payloads that do not decode.
+// Avro, Protobuf and JSON schemas are supported (see the avro and protobuf packages for their JSON)
+func (d *Decoder) Decode(ctx context.Context, data []byte) ([]byte, int, error) {
+	id, payload, ok := SchemaID(data)
+	if !ok {
+		return nil, 0, ErrNotFramed
+	}
+	c, err := d.codec(ctx, id)
+	if err != nil {
+		return nil, id, err
+	}
+	decoded, err := c.Decode(payload)
+	if err != nil {
+		return nil, id, fmt.Errorf("schema %d: %w: %w", id, ErrInvalidPayload, err)
+	}
+	return decoded, id, nil
+}
+
+// codec returns the compiled schema with an ID
+func (d *Decoder) codec(ctx context.Context, id int) (codec, error) {
+	d.mu.Lock()
+	c, ok := d.codecs[id]
+	d.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	schema, err := d.client.SchemaByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var references []namedSchema
+	if err := d.references(ctx, schema, make(map[Reference]bool), &references); err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+
+	switch schema.SchemaType() {
+	case SchemaTypeAvro:
+		sources := make([]string, len(references))
+		for i, reference := range references {
+			sources[i] = reference.schema.Schema
+		}
+		c, err = avro.Parse(schema.Schema, sources...)
+	case SchemaTypeProtobuf:
+		imports := make(map[string]string, len(references))
+		for _, reference := range references {
+			imports[reference.name] = reference.schema.Schema
+		}
+		c, err = protobuf.Parse(schema.Schema, imports)
+	case SchemaTypeJSON:
+		c = jsonCodec{}
+	default:
+		err = fmt.Errorf("unsupported schema type %s", schema.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+
+	d.mu.Lock()
+	d.codecs[id] = c
+	d.mu.Unlock()
+	return c, nil
+}
+
+// namedSchema is a referenced schema with its name in the schema referencing it
+type namedSchema struct {
+	name   string
+	schema *Schema
+}
+
+// references collects the schemas a schema references, directly or not, dependencies first
+func (d *Decoder) references(ctx context.Context, schema *Schema, seen map[Reference]bool, out *[]namedSchema) error {
+	for _, reference := range schema.References {
+		key := Reference{Subject: reference.Subject, Version: reference.Version}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		referenced, err := d.client.SchemaByVersion(ctx, reference.Subject, reference.Version)
+		if err != nil {
+			return fmt.Errorf("reference %s: %w", reference.Name, err)
+		}
+		if err := d.references(ctx, referenced, seen, out); err != nil {
+			return err
+		}
+		*out = append(*out, namedSchema{name: reference.Name, schema: referenced})
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package protobuf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxMessageIndexes bounds the number of message indexes read from the framing
+const maxMessageIndexes = 100
+
+var errTruncated = errors.New("truncated data")
+
+// jsonOptions writes the field names of the schema rather than their lowerCamelCase JSON names
+var jsonOptions = protojson.MarshalOptions{UseProtoNames: true}
+
+// Decode decodes a message as framed by Confluent serializers after the schema ID: the message indexes of its
+// type in the schema, then the binary message
+func (s *Schema) Decode(payload []byte) (decoded []byte, err error) {
+	indexes, n, err := readMessageIndexes(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Protobuf message indexes: %w", err)
+	}
+	m, err := s.message(indexes)
+	if err != nil {
+		return nil, err
+	}
+	message := dynamicpb.NewMessage(m)
+	// Dynamic messages panic on some invalid data, e.g. a map key with the wrong wire type
+	defer func() {
+		if r := recover(); r != nil {
+			decoded, err = nil, fmt.Errorf("invalid Protobuf message %s: %v", m.FullName(), r)
+		}
+	}()
+	if err := proto.Unmarshal(payload[n:], message); err != nil {
+		return nil, fmt.Errorf("invalid Protobuf message %s: %w", m.FullName(), err)
+	}
+	encoded, err := jsonOptions.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("Protobuf message %s: %w", m.FullName(), err)
+	}
+	// protojson varies its whitespace on purpose, compact it for a stable output
+	var out bytes.Buffer
+	if err := json.Compact(&out, encoded); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// MessageName returns the full name of the message type of a payload framed like for Decode
+func (s *Schema) MessageName(payload []byte) (string, error) {
+	indexes, _, err := readMessageIndexes(payload)
+	if err != nil {
+		return "", fmt.Errorf("invalid Protobuf message indexes: %w", err)
+	}
+	m, err := s.message(indexes)
+	if err != nil {
+		return "", err
+	}
+	return string(m.FullName()), nil
+}
+
+// readMessageIndexes reads the zigzag-encoded count and message indexes, returning the number of bytes read
+// A count of zero stands for the first message ([0])
+func readMessageIndexes(data []byte) ([]int, int, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, 0, errTruncated
+	}
+	if count < 0 || count > maxMessageIndexes {
+		return nil, 0, fmt.Errorf("invalid count %d", count)
+	}
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, m := binary.Varint(data[n:])
+		if m <= 0 {
+			return nil, 0, errTruncated
+		}
+		indexes[i] = int(index)
+		n += m
+	}
+	return indexes, n, nil
+}
@@ -0,0 +1,227 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const testSchema = `
+syntax = "proto3";
+package shop.v1;
+
+import "common.proto";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/wrappers.proto";
+
+option go_package = "example.com/shop/v1;shopv1"; // Ignored
+
+/* The first message, at index 0 */
+message Ping {}
+
+message Order {
+  enum Status {
+    STATUS_UNSPECIFIED = 0;
+    STATUS_PAID = 2 [deprecated = true];
+  }
+  message Line {
+    string sku = 1;
+    sint32 quantity = 2;
+  }
+  string id = 1;
+  Status status = 2;
+  repeated Line lines = 3;
+  map<string, int64> counts = 4;
+  repeated int32 packed = 5;
+  repeated fixed64 unpacked = 6;
+  common.Address address = 7;
+  google.protobuf.Timestamp created_at = 8;
+  google.protobuf.StringValue note = 9;
+  oneof payment {
+    bytes card = 10;
+    double cash = 11;
+  }
+  reserved 12, 13;
+  map<int32, Line> by_number = 14;
+}
+`
+
+const commonSchema = `
+syntax = "proto3";
+package common;
+message Address { string city = 1; Country country = 2; }
+enum Country { COUNTRY_UNSPECIFIED = 0; FR = 33; }
+`
+
+// Encoding helpers
+func tag(number int, wire protowire.Type) []byte {
+	return protowire.AppendTag(nil, protowire.Number(number), wire)
+}
+
+func varintField(number int, v uint64) []byte {
+	return protowire.AppendVarint(tag(number, protowire.VarintType), v)
+}
+
+func bytesField(number int, b []byte) []byte {
+	return protowire.AppendBytes(tag(number, protowire.BytesType), b)
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+// orderMessage returns an Order and the JSON it decodes to
+func orderMessage() ([]byte, string) {
+	fixed := make([]byte, 8)
+	binary.LittleEndian.PutUint64(fixed, math.MaxUint64)
+	cash := make([]byte, 8)
+	binary.LittleEndian.PutUint64(cash, math.Float64bits(12.5))
+	order := concat(
+		bytesField(1, []byte("o-1")),
+		varintField(2, 2),
+		bytesField(3, concat(bytesField(1, []byte("a")), varintField(2, 5))), // quantity -3
+		bytesField(3, concat(bytesField(1, []byte("b")))),
+		bytesField(4, concat(bytesField(1, []byte("x")), varintField(2, 7))),
+		bytesField(5, concat(binary.AppendUvarint(nil, 1), binary.AppendUvarint(nil, math.MaxUint64))), // 1, -1
+		tag(6, protowire.Fixed64Type), fixed,
+		bytesField(7, concat(bytesField(1, []byte("Paris")), varintField(2, 33))),
+		bytesField(8, concat(varintField(1, 1700000000), varintField(2, 500000000))),
+		bytesField(9, bytesField(1, []byte("<gift>"))),
+		varintField(99, 1), // Unknown field
+		tag(11, protowire.Fixed64Type), cash,
+		bytesField(14, concat(varintField(1, 3), bytesField(2, bytesField(1, []byte("c"))))),
+	)
+	want := `{"id":"o-1","status":"STATUS_PAID","lines":[{"sku":"a","quantity":-3},{"sku":"b"}],"counts":{"x":"7"},` +
+		`"packed":[1,-1],"unpacked":["18446744073709551615"],"address":{"city":"Paris","country":"FR"},` +
+		`"created_at":"2023-11-14T22:13:20.500Z","note":"<gift>","cash":12.5,"by_number":{"3":{"sku":"c"}}}`
+	// Message indexes [1] (Order): count 1, index 1, zigzag-encoded
+	return append([]byte{0x02, 0x02}, order...), want
+}
+
+func TestSchema_Decode(t *testing.T) {
+	schema, err := Parse(testSchema, map[string]string{"common.proto": commonSchema})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	order, orderJSON := orderMessage()
+
+	tests := []struct {
+		name    string
+		payload []byte
+		want    string
+		wantErr string
+	}{
+		{name: "nested types, imports and well-known types", payload: order, want: orderJSON},
+		// Message indexes [1, 0]
+		{name: "nested message", payload: append([]byte{0x04, 0x02, 0x00}, bytesField(1, []byte("z"))...), want: `{"sku":"z"}`},
+		// A single 0 for the first message
+		{name: "first message", payload: []byte{0x00}, want: `{}`},
+		{name: "unknown enum value", payload: append([]byte{0x02, 0x02}, varintField(2, 7)...), want: `{"status":7}`},
+		{name: "truncated message", payload: order[:len(order)-2], wantErr: "invalid Protobuf message shop.v1.Order"},
+		{name: "invalid message index", payload: []byte{0x02, 0x08}, wantErr: "no message at index"},
+		{name: "truncated message indexes", payload: []byte{0x04, 0x02}, wantErr: "truncated"},
+		{name: "invalid message index count", payload: []byte{0x01}, wantErr: "invalid count -1"},
+		{
+			name:    "map key with the wrong wire type",
+			payload: append([]byte{0x02, 0x02}, bytesField(4, concat(bytesField(1, []byte("0")), varintField(1, 0)))...),
+			wantErr: "invalid Protobuf message shop.v1.Order",
+		},
+		{name: "invalid UTF-8", payload: append([]byte{0x02, 0x02}, bytesField(1, []byte{0xff})...), wantErr: "invalid UTF-8"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := schema.Decode(tc.payload)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("Decode:\n got %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSchema_MessageName(t *testing.T) {
+	schema, err := Parse(testSchema, map[string]string{"common.proto": commonSchema})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	for _, tc := range []struct {
+		payload []byte
+		want    string
+	}{
+		{[]byte{0x00}, "shop.v1.Ping"},
+		{[]byte{0x02, 0x02}, "shop.v1.Order"},
+		{[]byte{0x04, 0x02, 0x00}, "shop.v1.Order.Line"},
+	} {
+		if got, err := schema.MessageName(tc.payload); err != nil || got != tc.want {
+			t.Errorf("MessageName(%x) = %q, %v; want %q", tc.payload, got, err, tc.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		imports map[string]string
+		wantErr string
+	}{
+		{name: "imports", schema: testSchema, imports: map[string]string{"common.proto": commonSchema}},
+		{name: "proto2 groups", schema: `syntax = "proto2"; message A { optional group G = 1 { optional int32 b = 2; } }`},
+		{
+			name:   "confluent options",
+			schema: `syntax = "proto3"; import "confluent/meta.proto"; message A { string a = 1 [(confluent.field_meta).doc = "d"]; }`,
+		},
+		{name: "unused missing import", schema: `syntax = "proto3"; import "unused.proto"; message A {}`},
+		{name: "type of a missing import", schema: testSchema, wantErr: "unknown type common.Address"},
+		{name: "unknown type", schema: `syntax = "proto3"; message A { B b = 1; }`, wantErr: "unknown type B"},
+		{name: "invalid field number", schema: `syntax = "proto3"; message A { string a = 0; }`, wantErr: "must be greater than zero"},
+		{name: "missing semicolon", schema: `syntax = "proto3"; message A { string a = 1 }`, wantErr: "expecting ';'"},
+		{name: "unexpected end of file", schema: `syntax = "proto3"; message A { string a = 1;`, wantErr: "unexpected $end"},
+		{name: "unterminated comment", schema: `syntax = "proto3"; message A { /* unterminated`, wantErr: "block comment never terminates"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.schema, tc.imports)
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("Parse failed: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+// FuzzSchema_Decode tests that any payload either fails to decode or decodes to valid JSON
+func FuzzSchema_Decode(f *testing.F) {
+	schema, err := Parse(testSchema, map[string]string{"common.proto": commonSchema})
+	if err != nil {
+		f.Fatalf("Parse failed: %v", err)
+	}
+	order, _ := orderMessage()
+	f.Add(order)
+	f.Add([]byte{0x00})
+	f.Add([]byte{0x04, 0x02, 0x00, 0x0a, 0x01, 'z'})
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		got, err := schema.Decode(payload)
+		if err == nil && !json.Valid(got) {
+			t.Errorf("Decode(%x) returned invalid JSON %s", payload, got)
+		}
+	})
+}
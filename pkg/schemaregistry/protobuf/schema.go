@@ -0,0 +1,92 @@
+// Package protobuf decodes Protobuf binary messages to JSON with their .proto schema, for messages framed by the
+// Confluent Schema Registry
+//
+// Schemas are compiled from .proto source, as stored by the registry, with github.com/bufbuild/protocompile, and
+// messages are decoded with google.golang.org/protobuf and written with its canonical JSON mapping (protojson),
+// like the Confluent console consumers: field names of the schema, enums by name, 64-bit integers as strings,
+// bytes as base64 strings, fields missing from the message left out and the well-known types written as their
+// JSON mapping
+package protobuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// schemaPath is the path the schema itself is compiled under; the registry does not name it
+const schemaPath = "schema.proto"
+
+// Schema is a compiled .proto schema with the files it imports
+type Schema struct {
+	file protoreflect.FileDescriptor
+}
+
+// Parse compiles a .proto schema. Imports are the sources of the .proto files it imports, directly or not, by
+// import path (the references of a schema in the registry). The well-known types of google/protobuf and the
+// confluent/meta.proto options are built in. Other imports missing from imports are compiled as empty files, so
+// they are only an error if a type of the file is used
+func Parse(schema string, imports map[string]string) (*Schema, error) {
+	sources := map[string]string{"confluent/meta.proto": confluentMeta}
+	for path, src := range imports {
+		sources[path] = src
+	}
+	sources[schemaPath] = schema
+
+	resolver := protocompile.WithStandardImports(&protocompile.SourceResolver{
+		Accessor: protocompile.SourceAccessorFromMap(sources),
+	})
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.ResolverFunc(func(path string) (protocompile.SearchResult, error) {
+			result, err := resolver.FindFileByPath(path)
+			if errors.Is(err, fs.ErrNotExist) && path != schemaPath {
+				return protocompile.SearchResult{Source: strings.NewReader(`syntax = "proto3";`)}, nil
+			}
+			return result, err
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Protobuf schema: %w", err)
+	}
+	return &Schema{file: files[0]}, nil
+}
+
+// message returns the message at Confluent message indexes: the index of a top-level message in the schema,
+// then of the nested messages down to it. No indexes is the first message
+func (s *Schema) message(indexes []int) (protoreflect.MessageDescriptor, error) {
+	if len(indexes) == 0 {
+		indexes = []int{0}
+	}
+	candidates := s.file.Messages()
+	var m protoreflect.MessageDescriptor
+	for _, i := range indexes {
+		if i < 0 || i >= candidates.Len() {
+			return nil, fmt.Errorf("no message at index %v in the schema", indexes)
+		}
+		m = candidates.Get(i)
+		candidates = m.Messages()
+	}
+	return m, nil
+}
+
+// confluentMeta is the source of confluent/meta.proto, whose options registries usually do not store
+const confluentMeta = `syntax = "proto3";
+package confluent;
+import "google/protobuf/descriptor.proto";
+message Meta {
+  string doc = 1;
+  map<string, string> params = 2;
+  repeated string tags = 3;
+}
+extend google.protobuf.FileOptions { Meta file_meta = 1088; }
+extend google.protobuf.MessageOptions { Meta message_meta = 1088; }
+extend google.protobuf.FieldOptions { Meta field_meta = 1088; }
+extend google.protobuf.EnumOptions { Meta enum_meta = 1088; }
+extend google.protobuf.EnumValueOptions { Meta enum_value_meta = 1088; }
+`
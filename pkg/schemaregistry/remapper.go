@@ -0,0 +1,222 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/schemaregistry/avro"
+	"github.com/lolocompany/kafka-replay/v2/pkg/schemaregistry/protobuf"
+)
+
+// SubjectStrategy names the subject schemas are registered under, like the subject name strategies of
+// Confluent serializers
+type SubjectStrategy string
+
+const (
+	// TopicNameStrategy registers schemas under <topic>-key and <topic>-value
+	TopicNameStrategy SubjectStrategy = "topic"
+	// RecordNameStrategy registers schemas under the full name of their Avro record or Protobuf message
+	RecordNameStrategy SubjectStrategy = "record"
+	// TopicRecordNameStrategy registers schemas under <topic>-<full name of the record or message>
+	TopicRecordNameStrategy SubjectStrategy = "topic-record"
+)
+
+// ParseSubjectStrategy parses a subject strategy name: topic, record or topic-record
+func ParseSubjectStrategy(name string) (SubjectStrategy, error) {
+	switch s := SubjectStrategy(name); s {
+	case TopicNameStrategy, RecordNameStrategy, TopicRecordNameStrategy:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid subject strategy %q: expected topic, record or topic-record", name)
+}
+
+// remapKey is a schema of the source registry registered under a subject of the target registry
+type remapKey struct {
+	id      int
+	subject string
+}
+
+// Remapper rewrites the schema IDs of framed data from a source registry to a target registry, registering each
+// schema (and the schemas it references) in the target registry the first time it is seen. It is safe for
+// concurrent use
+type Remapper struct {
+	source   *Decoder
+	target   *Client
+	strategy SubjectStrategy
+
+	skipUnknown bool
+	skipped     atomic.Int64
+
+	mu       sync.Mutex
+	ids      map[remapKey]int
+	versions map[Reference]int // Versions in the target registry of the referenced subject versions of the source
+}
+
+// NewRemapper creates a remapper from a source registry to a target registry
+func NewRemapper(source, target *Client, strategy SubjectStrategy) *Remapper {
+	return &Remapper{
+		source:   NewDecoder(source),
+		target:   target,
+		strategy: strategy,
+		ids:      make(map[remapKey]int),
+		versions: make(map[Reference]int),
+	}
+}
+
+// Source returns the client of the source registry
+func (r *Remapper) Source() *Client {
+	return r.source.client
+}
+
+// Target returns the client of the target registry
+func (r *Remapper) Target() *Client {
+	return r.target
+}
+
+// SkipUnknownSchemas returns values with a schema ID the source registry does not know unchanged instead of
+// failing, counting them (see Skipped). It must be called before the first Remap
+func (r *Remapper) SkipUnknownSchemas() {
+	r.skipUnknown = true
+}
+
+// Skipped returns the number of values returned unchanged by SkipUnknownSchemas
+func (r *Remapper) Skipped() int64 {
+	return r.skipped.Load()
+}
+
+// Remap returns a copy of framed data with the ID of its schema in the target registry, registered under the
+// subject of the strategy for a topic and either keys or values. Data that is not framed is returned unchanged.
+// Keys that only look framed, with a schema ID the source registry does not know, are returned unchanged too,
+// while such values return an error matching ErrSchemaNotFound (unless SkipUnknownSchemas was called)
+func (r *Remapper) Remap(ctx context.Context, topic string, key bool, data []byte) ([]byte, error) {
+	id, payload, ok := SchemaID(data)
+	if !ok {
+		return data, nil
+	}
+	if _, err := r.source.client.SchemaByID(ctx, id); err != nil {
+		if !errors.Is(err, ErrSchemaNotFound) {
+			return nil, fmt.Errorf("schema %d: %w", id, err)
+		}
+		if key {
+			return data, nil
+		}
+		if r.skipUnknown {
+			r.skipped.Add(1)
+			return data, nil
+		}
+		return nil, fmt.Errorf("value with unknown schema ID %d: %w", id, err)
+	}
+	subject, err := r.subject(ctx, topic, key, id, payload)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	targetID, err := r.targetID(ctx, id, subject)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+
+	remapped := make([]byte, len(data))
+	copy(remapped, data)
+	binary.BigEndian.PutUint32(remapped[1:FrameHeaderSize], uint32(targetID))
+	return remapped, nil
+}
+
+// subject returns the subject to register a schema under
+func (r *Remapper) subject(ctx context.Context, topic string, key bool, id int, payload []byte) (string, error) {
+	if r.strategy == TopicNameStrategy {
+		if key {
+			return topic + "-key", nil
+		}
+		return topic + "-value", nil
+	}
+
+	c, err := r.source.codec(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	var name string
+	switch c := c.(type) {
+	case *avro.Schema:
+		if name = c.Name(); name == "" {
+			return "", fmt.Errorf("the %s subject strategy requires a named Avro type", r.strategy)
+		}
+	case *protobuf.Schema:
+		if name, err = c.MessageName(payload); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("the %s subject strategy does not support JSON schemas", r.strategy)
+	}
+	if r.strategy == TopicRecordNameStrategy {
+		return topic + "-" + name, nil
+	}
+	return name, nil
+}
+
+// targetID returns the ID in the target registry of a schema of the source registry, registering it under a subject
+func (r *Remapper) targetID(ctx context.Context, id int, subject string) (int, error) {
+	key := remapKey{id: id, subject: subject}
+	r.mu.Lock()
+	targetID, ok := r.ids[key]
+	r.mu.Unlock()
+	if ok {
+		return targetID, nil
+	}
+
+	schema, err := r.source.client.SchemaByID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	references, err := r.references(ctx, schema.References)
+	if err != nil {
+		return 0, err
+	}
+	targetID, err = r.target.Register(ctx, subject, &Schema{Type: schema.Type, Schema: schema.Schema, References: references})
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	r.ids[key] = targetID
+	r.mu.Unlock()
+	return targetID, nil
+}
+
+// references registers referenced schemas in the target registry under their subjects, dependencies first, and
+// returns the references to their versions in the target registry
+func (r *Remapper) references(ctx context.Context, references []Reference) ([]Reference, error) {
+	var remapped []Reference
+	for _, reference := range references {
+		key := Reference{Subject: reference.Subject, Version: reference.Version}
+		r.mu.Lock()
+		version, ok := r.versions[key]
+		r.mu.Unlock()
+
+		if !ok {
+			referenced, err := r.source.client.SchemaByVersion(ctx, reference.Subject, reference.Version)
+			if err != nil {
+				return nil, fmt.Errorf("reference %s: %w", reference.Name, err)
+			}
+			nested, err := r.references(ctx, referenced.References)
+			if err != nil {
+				return nil, err
+			}
+			schema := &Schema{Type: referenced.Type, Schema: referenced.Schema, References: nested}
+			if _, err := r.target.Register(ctx, reference.Subject, schema); err != nil {
+				return nil, fmt.Errorf("reference %s: %w", reference.Name, err)
+			}
+			if version, err = r.target.Version(ctx, reference.Subject, schema); err != nil {
+				return nil, fmt.Errorf("reference %s: %w", reference.Name, err)
+			}
+			r.mu.Lock()
+			r.versions[key] = version
+			r.mu.Unlock()
+		}
+		remapped = append(remapped, Reference{Name: reference.Name, Subject: reference.Subject, Version: version})
+	}
+	return remapped, nil
+}
@@ -0,0 +1,185 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTarget registers schemas like a Schema Registry, giving identical schemas the same ID across subjects
+type fakeTarget struct {
+	*httptest.Server
+	mu       sync.Mutex
+	ids      map[string]int            // Registered schemas (as JSON registrations) by ID
+	subjects map[string][]registration // Versions of the subjects
+	posts    int
+}
+
+func newFakeTarget(t *testing.T) *fakeTarget {
+	t.Helper()
+	f := &fakeTarget{ids: make(map[string]int), subjects: make(map[string][]registration)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body registration
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.posts++
+		key, _ := json.Marshal(body)
+		subject, register := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/subjects/"), "/versions")
+		version := 0
+		for i, registered := range f.subjects[subject] {
+			if encoded, _ := json.Marshal(registered); bytes.Equal(encoded, key) {
+				version = i + 1
+			}
+		}
+		if register {
+			if version == 0 {
+				f.subjects[subject] = append(f.subjects[subject], body)
+			}
+			if _, ok := f.ids[string(key)]; !ok {
+				f.ids[string(key)] = 100 + len(f.ids)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"id": f.ids[string(key)]})
+			return
+		}
+		if version == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"error_code": 40403, "message": "Schema not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"subject": subject, "version": version, "id": f.ids[string(key)]})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func TestRemapper_Remap(t *testing.T) {
+	source := newFakeRegistry(t, map[string]any{
+		"/schemas/ids/1": map[string]any{
+			"schema":     `{"type": "record", "name": "Order", "namespace": "shop", "fields": [{"name": "customer", "type": "Customer"}]}`,
+			"references": []map[string]any{{"name": "Customer", "subject": "customer-value", "version": 3}},
+		},
+		"/subjects/customer-value/versions/3": map[string]any{
+			"id": 4, "schema": `{"type": "record", "name": "Customer", "fields": [{"name": "email", "type": "string"}]}`,
+		},
+		"/schemas/ids/2": map[string]any{
+			"schemaType": "PROTOBUF",
+			"schema":     `syntax = "proto3"; package shop; message Ping { int64 id = 1; } message Pong { int64 id = 1; }`,
+		},
+		"/schemas/ids/3": map[string]any{"schemaType": "JSON", "schema": `{"type": "object"}`},
+	})
+	target := newFakeTarget(t)
+	sourceClient, err := NewClient(source.URL, "", "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	targetClient, err := NewClient(target.URL, "", "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	ctx := context.Background()
+
+	remapper := NewRemapper(sourceClient, targetClient, TopicNameStrategy)
+	value := framed(1, 2, 'a')
+	got, err := remapper.Remap(ctx, "orders", false, value)
+	if err != nil {
+		t.Fatalf("Remap failed: %v", err)
+	}
+	// The reference is registered first (ID 100), then the schema (ID 101)
+	if want := framed(101, 2, 'a'); !bytes.Equal(got, want) {
+		t.Errorf("Remap = %v, want %v", got, want)
+	}
+	if !bytes.Equal(value, framed(1, 2, 'a')) {
+		t.Errorf("Remap modified its input: %v", value)
+	}
+	order := target.subjects["orders-value"]
+	if len(order) != 1 || len(order[0].References) != 1 || order[0].References[0] != (Reference{Name: "Customer", Subject: "customer-value", Version: 1}) {
+		t.Errorf("unexpected registration of orders-value: %+v", order)
+	}
+	posts := target.posts
+	if _, err := remapper.Remap(ctx, "orders", false, value); err != nil || target.posts != posts {
+		t.Errorf("expected the remapped ID to be cached (err %v, %d requests)", err, target.posts-posts)
+	}
+	got, err = remapper.Remap(ctx, "orders", true, framed(3, '1'))
+	if err != nil || !bytes.Equal(got, framed(102, '1')) || len(target.subjects["orders-key"]) != 1 {
+		t.Errorf("Remap(key) = %v, %v; want the JSON schema registered under orders-key", got, err)
+	}
+	if got, err := remapper.Remap(ctx, "orders", true, []byte("plain")); err != nil || string(got) != "plain" {
+		t.Errorf("Remap(unframed) = %q, %v; want it unchanged", got, err)
+	}
+
+	for _, tc := range []struct {
+		strategy SubjectStrategy
+		data     []byte
+		subject  string
+	}{
+		{RecordNameStrategy, framed(1), "shop.Order"},
+		{RecordNameStrategy, framed(2, 2, 2), "shop.Pong"},
+		{TopicRecordNameStrategy, framed(2, 0), "orders-shop.Ping"},
+	} {
+		if _, err := NewRemapper(sourceClient, targetClient, tc.strategy).Remap(ctx, "orders", false, tc.data); err != nil {
+			t.Fatalf("Remap(%s) failed: %v", tc.strategy, err)
+		}
+		if len(target.subjects[tc.subject]) != 1 {
+			t.Errorf("%s: expected a schema registered under %s, got subjects %v", tc.strategy, tc.subject, target.subjects)
+		}
+	}
+	if _, err := NewRemapper(sourceClient, targetClient, RecordNameStrategy).Remap(ctx, "orders", false, framed(3, '1')); err == nil {
+		t.Error("expected an error for the record strategy with a JSON schema")
+	}
+	// A big-endian integer key with an unknown "schema ID" is sent as recorded, with every strategy
+	longKey := []byte{0, 0, 0, 0, 9, 0, 0, 0x2a}
+	for _, strategy := range []SubjectStrategy{TopicNameStrategy, RecordNameStrategy} {
+		if got, err := NewRemapper(sourceClient, targetClient, strategy).Remap(ctx, "orders", true, longKey); err != nil || !bytes.Equal(got, longKey) {
+			t.Errorf("%s: Remap(unknown schema) = %v, %v; want it unchanged", strategy, got, err)
+		}
+	}
+	// A value with an unknown schema ID fails, unless unknown schemas are skipped
+	for _, strategy := range []SubjectStrategy{TopicNameStrategy, RecordNameStrategy} {
+		remapper := NewRemapper(sourceClient, targetClient, strategy)
+		if _, err := remapper.Remap(ctx, "orders", false, longKey); !errors.Is(err, ErrSchemaNotFound) || !strings.Contains(err.Error(), "unknown schema ID 9") {
+			t.Errorf("%s: Remap(value with an unknown schema): expected a schema not found error, got %v", strategy, err)
+		}
+		remapper.SkipUnknownSchemas()
+		for i := 0; i < 2; i++ {
+			if got, err := remapper.Remap(ctx, "orders", false, longKey); err != nil || !bytes.Equal(got, longKey) {
+				t.Errorf("%s: Remap(value with an unknown schema) with SkipUnknownSchemas = %v, %v; want it unchanged", strategy, got, err)
+			}
+		}
+		if remapper.Skipped() != 2 {
+			t.Errorf("%s: Skipped() = %d, want 2", strategy, remapper.Skipped())
+		}
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	failingClient, err := NewClient(failing.URL, "", "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if _, err := NewRemapper(failingClient, targetClient, TopicNameStrategy).Remap(ctx, "orders", false, framed(1)); err == nil || !strings.Contains(err.Error(), "schema 1") {
+		t.Errorf("expected the registry error, got %v", err)
+	}
+}
+
+func TestParseSubjectStrategy(t *testing.T) {
+	for _, name := range []string{"topic", "record", "topic-record"} {
+		if s, err := ParseSubjectStrategy(name); err != nil || string(s) != name {
+			t.Errorf("ParseSubjectStrategy(%q) = %q, %v", name, s, err)
+		}
+	}
+	if _, err := ParseSubjectStrategy("TopicNameStrategy"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
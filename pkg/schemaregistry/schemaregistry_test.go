@@ -0,0 +1,141 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry serves schemas like a Schema Registry, counting the requests per path
+type fakeRegistry struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses map[string]any
+	requests  map[string]int
+	auth      string // Expected "user:password", if set
+}
+
+func newFakeRegistry(t *testing.T, responses map[string]any) *fakeRegistry {
+	t.Helper()
+	f := &fakeRegistry{responses: responses, requests: make(map[string]int)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests[r.URL.Path]++
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		if f.auth != "" {
+			if user, password, ok := r.BasicAuth(); !ok || user+":"+password != f.auth {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]any{"error_code": 401, "message": "Unauthorized"})
+				return
+			}
+		}
+		response, ok := f.responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"error_code": 40403, "message": "Schema not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func framed(id byte, payload ...byte) []byte {
+	return append([]byte{MagicByte, 0, 0, 0, id}, payload...)
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]any{
+		"/schemas/ids/1": map[string]any{
+			"schema":     `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}, {"name": "customer", "type": "Customer"}]}`,
+			"references": []map[string]any{{"name": "Customer", "subject": "customer-value", "version": 1}},
+		},
+		"/subjects/customer-value/versions/1": map[string]any{
+			"id": 4, "schema": `{"type": "record", "name": "Customer", "fields": [{"name": "email", "type": "string"}]}`,
+		},
+		"/schemas/ids/2": map[string]any{
+			"schemaType": "PROTOBUF",
+			"schema":     `syntax = "proto3"; import "common.proto"; message Ping { common.Id id = 1; }`,
+			"references": []map[string]any{{"name": "common.proto", "subject": "common", "version": 2}},
+		},
+		"/subjects/common/versions/2": map[string]any{
+			"id": 5, "schemaType": "PROTOBUF", "schema": `syntax = "proto3"; package common; message Id { int64 value = 1; }`,
+		},
+		"/schemas/ids/3": map[string]any{"schemaType": "JSON", "schema": `{"type": "object"}`},
+	})
+	registry.auth = "user:secret"
+	client, err := NewClient(strings.Replace(registry.URL, "http://", "http://user:secret@", 1)+"/", "", "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if strings.Contains(client.URL(), "secret") {
+		t.Errorf("URL should not contain the credentials: %s", client.URL())
+	}
+	decoder := NewDecoder(client)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		data []byte
+		id   int
+		want string
+	}{
+		{framed(1, 6, 'o', '-', '1', 6, 'a', '@', 'b'), 1, `{"id":"o-1","customer":{"email":"a@b"}}`},
+		{framed(2, 0, 0x0a, 0x02, 0x08, 0x2a), 2, `{"id":{"value":"42"}}`},
+		{framed(3, []byte(`{"a": 1}`)...), 3, `{"a": 1}`},
+	} {
+		for i := 0; i < 2; i++ {
+			got, id, err := decoder.Decode(ctx, tc.data)
+			if err != nil {
+				t.Fatalf("Decode(schema %d) failed: %v", tc.id, err)
+			}
+			if id != tc.id || string(got) != tc.want {
+				t.Errorf("Decode = %s (schema %d), want %s (schema %d)", got, id, tc.want, tc.id)
+			}
+		}
+	}
+	for path, count := range registry.requests {
+		if count != 1 {
+			t.Errorf("%s requested %d times, expected once (cached)", path, count)
+		}
+	}
+
+	if _, _, err := decoder.Decode(ctx, []byte(`{"plain": "json"}`)); !errors.Is(err, ErrNotFramed) {
+		t.Errorf("expected ErrNotFramed for plain JSON, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		_, _, err = decoder.Decode(ctx, framed(9, 0))
+		var registryErr *Error
+		if !errors.As(err, &registryErr) || registryErr.Code != 40403 || registryErr.StatusCode != http.StatusNotFound || !errors.Is(err, ErrSchemaNotFound) {
+			t.Errorf("expected a schema not found error, got %v", err)
+		}
+	}
+	if count := registry.requests["/schemas/ids/9"]; count != 1 {
+		t.Errorf("unknown schema requested %d times, expected once (cached)", count)
+	}
+	if _, _, err := decoder.Decode(ctx, framed(1, 2)); !errors.Is(err, ErrInvalidPayload) || !strings.Contains(err.Error(), "schema 1: ") || !strings.Contains(err.Error(), "invalid Avro data") {
+		t.Errorf("expected an invalid data error, got %v", err)
+	}
+
+	unauthorized, err := NewClient(registry.URL, "user", "wrong")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if _, err := unauthorized.SchemaByID(ctx, 1); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+}
+
+func TestNewClient_InvalidURL(t *testing.T) {
+	for _, u := range []string{"localhost:8081", "ftp://registry", "http://"} {
+		if _, err := NewClient(u, "", ""); err == nil {
+			t.Errorf("NewClient(%q): expected an error", u)
+		}
+	}
+}
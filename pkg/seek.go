@@ -0,0 +1,19 @@
+package pkg
+
+import (
+	"time"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+// seekStart moves the decoder to the first entry to read: entry number startEntry (0-based), or the first
+// entry with a timestamp at or after startTime when it is set. The index is optional (nil scans the recording)
+func seekStart(decoder *transcoder.DecodeReader, startEntry int64, startTime *time.Time, index *transcoder.Index) error {
+	if startTime != nil {
+		return decoder.SeekTime(*startTime, index)
+	}
+	if startEntry > 0 {
+		return decoder.SeekEntry(startEntry, index)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package pkg
+
+import "time"
+
+// TimingBatchTimeout is the producer batch timeout when replaying with the original timing, short enough
+// not to delay messages that are due (the default timeout favours throughput over latency)
+const TimingBatchTimeout = time.Millisecond
+
+// ReplayTiming replays messages with the gaps between their recorded timestamps
+type ReplayTiming struct {
+	// Speed divides the gaps: 1 for the original timing, 2 for twice as fast, 0.5 for half as fast
+	Speed float64
+	// MaxWait caps the wait between two messages (after applying Speed), 0 for no cap
+	MaxWait time.Duration
+}
+
+// replaySchedule computes when each message is due, from the recorded timestamps
+// Messages are due relative to the first one, so that slow writes do not add up over the replay
+type replaySchedule struct {
+	timing ReplayTiming
+	start  time.Time     // When the first message was due
+	latest time.Time     // Latest recorded timestamp so far
+	offset time.Duration // When the latest message was due, relative to start
+}
+
+func newReplaySchedule(timing ReplayTiming) *replaySchedule {
+	if timing.Speed <= 0 {
+		timing.Speed = 1
+	}
+	return &replaySchedule{timing: timing}
+}
+
+// due returns when the message recorded at timestamp is due
+// A timestamp earlier than the latest one (e.g. from another partition) is due immediately
+func (s *replaySchedule) due(timestamp time.Time) time.Time {
+	if s.start.IsZero() {
+		s.start = time.Now()
+		s.latest = timestamp
+		return s.start
+	}
+	if timestamp.After(s.latest) {
+		gap := time.Duration(float64(timestamp.Sub(s.latest)) / s.timing.Speed)
+		if s.timing.MaxWait > 0 && gap > s.timing.MaxWait {
+			gap = s.timing.MaxWait
+		}
+		s.offset += gap
+		s.latest = timestamp
+	}
+	return s.start.Add(s.offset)
+}
+
+// reset starts the schedule over with the next message (when looping)
+func (s *replaySchedule) reset() {
+	*s = replaySchedule{timing: s.timing}
+}
@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReplaySchedule(t *testing.T) {
+	recorded := time.Date(2026, 2, 2, 10, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time {
+		return recorded.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	tests := []struct {
+		name       string
+		timing     ReplayTiming
+		timestamps []time.Time
+		want       []time.Duration // When each message is due, relative to the first one
+	}{
+		{
+			name:       "original timing",
+			timing:     ReplayTiming{Speed: 1},
+			timestamps: []time.Time{at(0), at(100), at(100), at(1100)},
+			want:       []time.Duration{0, 100 * time.Millisecond, 100 * time.Millisecond, 1100 * time.Millisecond},
+		},
+		{
+			name:       "twice as fast",
+			timing:     ReplayTiming{Speed: 2},
+			timestamps: []time.Time{at(0), at(100), at(1100)},
+			want:       []time.Duration{0, 50 * time.Millisecond, 550 * time.Millisecond},
+		},
+		{
+			name:       "half as fast",
+			timing:     ReplayTiming{Speed: 0.5},
+			timestamps: []time.Time{at(0), at(100), at(1100)},
+			want:       []time.Duration{0, 200 * time.Millisecond, 2200 * time.Millisecond},
+		},
+		{
+			name:       "speed defaults to 1",
+			timing:     ReplayTiming{},
+			timestamps: []time.Time{at(0), at(250)},
+			want:       []time.Duration{0, 250 * time.Millisecond},
+		},
+		{
+			name:       "max wait caps each gap",
+			timing:     ReplayTiming{Speed: 1, MaxWait: time.Second},
+			timestamps: []time.Time{at(0), at(60_000), at(60_500), at(120_500)},
+			want:       []time.Duration{0, time.Second, 1500 * time.Millisecond, 2500 * time.Millisecond},
+		},
+		{
+			name:       "max wait applies after the speed",
+			timing:     ReplayTiming{Speed: 10, MaxWait: time.Second},
+			timestamps: []time.Time{at(0), at(5_000), at(20_000)},
+			want:       []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond},
+		},
+		{
+			name:       "out of order timestamps are due immediately",
+			timing:     ReplayTiming{Speed: 1},
+			timestamps: []time.Time{at(0), at(300), at(200), at(250), at(400)},
+			want:       []time.Duration{0, 300 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond, 400 * time.Millisecond},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newReplaySchedule(tc.timing)
+			var got []time.Duration
+			for _, timestamp := range tc.timestamps {
+				got = append(got, s.due(timestamp).Sub(s.start))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("due at %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestReplaySchedule_Reset tests that a looped replay schedules the next message from a new start
+func TestReplaySchedule_Reset(t *testing.T) {
+	recorded := time.Date(2026, 2, 2, 10, 0, 0, 0, time.UTC)
+	s := newReplaySchedule(ReplayTiming{Speed: 2})
+	s.due(recorded)
+	s.due(recorded.Add(time.Minute))
+
+	s.reset()
+	first := s.due(recorded)
+	if s.offset != 0 || first != s.start || time.Since(first) > time.Second {
+		t.Errorf("after reset, the first message is due at %v (offset %v), want now", first, s.offset)
+	}
+	if got := s.due(recorded.Add(time.Second)).Sub(first); got != 500*time.Millisecond {
+		t.Errorf("after reset, a message one second later is due after %v, want 500ms", got)
+	}
+	if s.timing.Speed != 2 {
+		t.Errorf("reset changed the speed to %v", s.timing.Speed)
+	}
+}
@@ -0,0 +1,76 @@
+package transcoder
+
+import (
+	"fmt"
+	"io"
+)
+
+// AppendPoint describes where and how entries are appended to an existing file
+type AppendPoint struct {
+	// Compression is the compression codec of the file, used for the appended entries as well
+	Compression Compression
+	// Sanitization is the sanitization marked in the file header (nil if the messages were not masked): appended
+	// entries must be masked with the same rule set
+	Sanitization *Sanitization
+	// Size is the byte offset after the last complete entry (or compressed frame), where appended entries start
+	// Anything after it is a partially written entry or frame, which is discarded
+	Size int64
+	// Index is the index of the complete entries
+	Index *Index
+	// LastOffsets are the latest offsets recorded per topic and partition (entries without a source are skipped)
+	LastOffsets map[string]map[int]int64
+}
+
+// Entries returns the number of complete entries in the file
+func (p *AppendPoint) Entries() int64 {
+	return p.Index.Entries
+}
+
+// FindAppendPoint reads a file to find where entries can be appended to it
+// Only files in the current format version can be appended to, since appended entries are written in it
+func FindAppendPoint(reader io.ReadSeeker) (*AppendPoint, error) {
+	decoder, err := NewDecodeReader(reader, true)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.protocolVersion != ProtocolVersion {
+		return nil, fmt.Errorf("cannot append to a file in format version %d (only version %d files can be appended to)", decoder.protocolVersion, ProtocolVersion)
+	}
+
+	point := &AppendPoint{
+		Compression:  decoder.Compression(),
+		Sanitization: decoder.Sanitization(),
+		Size:         HeaderSize,
+		LastOffsets:  make(map[string]map[int]int64),
+	}
+	var builder indexBuilder
+	for {
+		position, newFrame := decoder.entryPosition()
+		entry, err := decoder.Read()
+		if err == io.EOF {
+			// Also returned for a partially written entry or frame
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read entry %d: %w", builder.index.Entries, err)
+		}
+		builder.add(position, newFrame, entry.Timestamp)
+
+		// The end of the entry is a complete file once the frame it is in has been read
+		if end, _ := decoder.entryPosition(); end >= 0 {
+			point.Size = end
+		}
+		if entry.HasSource() {
+			offsets := point.LastOffsets[entry.Topic]
+			if offsets == nil {
+				offsets = make(map[int]int64)
+				point.LastOffsets[entry.Topic] = offsets
+			}
+			if last, ok := offsets[entry.Partition]; !ok || entry.Offset > last {
+				offsets[entry.Partition] = entry.Offset
+			}
+		}
+	}
+	point.Index = builder.snapshot(point.Size)
+	return point, nil
+}
@@ -0,0 +1,112 @@
+package transcoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindAppendPoint_AppendAfterPartialEntry(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionZstd} {
+		t.Run(compression.String(), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			encoder, err := NewCompressedEncodeWriter(buf, compression)
+			if err != nil {
+				t.Fatalf("NewCompressedEncodeWriter failed: %v", err)
+			}
+			write := func(encoder *EncodeWriter, topic string, partition int, offset int64) {
+				t.Helper()
+				entry := &Entry{Timestamp: time.UnixMilli(offset), Topic: topic, Partition: partition, Offset: offset, Data: []byte("message")}
+				if _, err := encoder.WriteEntry(entry); err != nil {
+					t.Fatalf("WriteEntry failed: %v", err)
+				}
+			}
+			write(encoder, "orders", 0, 10)
+			write(encoder, "orders", 1, 20)
+			write(encoder, "orders", 0, 11)
+			write(encoder, "payments", 0, 5)
+			if err := encoder.Flush(); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+			complete := buf.Len()
+
+			// A crash while writing the next entry (or frame) leaves part of it at the end of the file
+			write(encoder, "orders", 1, 21)
+			if err := encoder.Flush(); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+			recording := buf.Bytes()[:complete+(buf.Len()-complete)/2]
+
+			point, err := FindAppendPoint(bytes.NewReader(recording))
+			if err != nil {
+				t.Fatalf("FindAppendPoint failed: %v", err)
+			}
+			if point.Compression != compression {
+				t.Errorf("Compression mismatch: expected %s, got %s", compression, point.Compression)
+			}
+			if point.Size != int64(complete) {
+				t.Errorf("Size mismatch: expected %d, got %d", complete, point.Size)
+			}
+			if point.Entries() != 4 {
+				t.Errorf("Entries mismatch: expected 4, got %d", point.Entries())
+			}
+			expectedOffsets := map[string]map[int]int64{"orders": {0: 11, 1: 20}, "payments": {0: 5}}
+			if !reflect.DeepEqual(point.LastOffsets, expectedOffsets) {
+				t.Errorf("LastOffsets mismatch: expected %v, got %v", expectedOffsets, point.LastOffsets)
+			}
+
+			// Append to the complete part of the file
+			appended := bytes.NewBuffer(append([]byte{}, recording[:point.Size]...))
+			appender, err := NewAppendEncodeWriter(appended, point)
+			if err != nil {
+				t.Fatalf("NewAppendEncodeWriter failed: %v", err)
+			}
+			write(appender, "orders", 1, 21)
+			write(appender, "orders", 0, 12)
+			index, err := appender.Index()
+			if err != nil {
+				t.Fatalf("Index failed: %v", err)
+			}
+			if index.RecordingSize != int64(appended.Len()) || index.Entries != 6 {
+				t.Errorf("Index mismatch: %d entries for %d bytes, file has %d bytes", index.Entries, index.RecordingSize, appended.Len())
+			}
+			built, err := BuildIndex(bytes.NewReader(appended.Bytes()))
+			if err != nil {
+				t.Fatalf("BuildIndex failed: %v", err)
+			}
+			if !reflect.DeepEqual(built, index) {
+				t.Errorf("Index of the appended file mismatch:\nencoder: %+v\nbuilt:   %+v", index, built)
+			}
+
+			decoder, err := NewDecodeReader(bytes.NewReader(appended.Bytes()), true)
+			if err != nil {
+				t.Fatalf("NewDecodeReader failed: %v", err)
+			}
+			var offsets []int64
+			for {
+				entry, err := decoder.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Read failed: %v", err)
+				}
+				offsets = append(offsets, entry.Offset)
+			}
+			if !reflect.DeepEqual(offsets, []int64{10, 20, 11, 5, 21, 12}) {
+				t.Errorf("Offsets of the appended file mismatch: %v", offsets)
+			}
+		})
+	}
+}
+
+func TestFindAppendPoint_OldVersion(t *testing.T) {
+	header := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
+	if _, err := FindAppendPoint(bytes.NewReader(header)); err == nil {
+		t.Error("Expected error when appending to a version 2 file")
+	}
+}
@@ -0,0 +1,243 @@
+package transcoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the codec used to compress the message entries of a file
+// It is stored in the first reserved byte of the file header (since version 3)
+type Compression int8
+
+const (
+	// CompressionNone stores message entries as they are
+	CompressionNone Compression = 0
+	// CompressionGzip compresses blocks of message entries with gzip
+	CompressionGzip Compression = 1
+	// CompressionZstd compresses blocks of message entries with zstd
+	CompressionZstd Compression = 2
+	// CompressionSnappy compresses blocks of message entries with snappy (block format)
+	CompressionSnappy Compression = 3
+)
+
+// String returns the name of the compression codec
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionSnappy:
+		return "snappy"
+	default:
+		return fmt.Sprintf("unknown(%d)", int8(c))
+	}
+}
+
+// ParseCompression parses a compression codec name (none, gzip, zstd or snappy)
+func ParseCompression(name string) (Compression, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return CompressionNone, nil
+	case "gzip", "gz":
+		return CompressionGzip, nil
+	case "zstd", "zst":
+		return CompressionZstd, nil
+	case "snappy":
+		return CompressionSnappy, nil
+	default:
+		return CompressionNone, fmt.Errorf("unsupported compression %q (supported: none, gzip, zstd, snappy)", name)
+	}
+}
+
+// valid reports whether c is a known compression codec
+func (c Compression) valid() bool {
+	return c >= CompressionNone && c <= CompressionSnappy
+}
+
+// blockCodec compresses or decompresses the blocks of message entries of a file
+// It must be closed to release the resources of the zstd encoder or decoder
+type blockCodec struct {
+	compression Compression
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+}
+
+// newCompressor creates a codec compressing blocks
+func newCompressor(compression Compression) (*blockCodec, error) {
+	c := &blockCodec{compression: compression}
+	if compression == CompressionZstd {
+		var err error
+		if c.zstdEncoder, err = zstd.NewWriter(nil); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// newDecompressor creates a codec decompressing blocks, of at most MaxFrameSize bytes
+func newDecompressor(compression Compression) (*blockCodec, error) {
+	c := &blockCodec{compression: compression}
+	if compression == CompressionZstd {
+		var err error
+		if c.zstdDecoder, err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxFrameSize)); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// close releases the zstd encoder or decoder
+func (c *blockCodec) close() error {
+	if c.zstdDecoder != nil {
+		c.zstdDecoder.Close()
+	}
+	if c.zstdEncoder != nil {
+		return c.zstdEncoder.Close()
+	}
+	return nil
+}
+
+// compress appends the compressed block to dst
+func (c *blockCodec) compress(dst, block []byte) ([]byte, error) {
+	switch c.compression {
+	case CompressionGzip:
+		buf := bytes.NewBuffer(dst)
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(block); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return c.zstdEncoder.EncodeAll(block, dst), nil
+	case CompressionSnappy:
+		return append(dst, s2.EncodeSnappy(nil, block)...), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", c.compression)
+	}
+}
+
+// decompress returns the decompressed block, which must be size bytes long
+// Decompression stops once the block is larger than size, so that corrupt frames cannot exhaust memory
+func (c *blockCodec) decompress(dst, compressed []byte, size int64) ([]byte, error) {
+	var block []byte
+	var err error
+	switch c.compression {
+	case CompressionGzip:
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(compressed)); err != nil {
+			return nil, err
+		}
+		buf := bytes.NewBuffer(dst[:0])
+		buf.Grow(int(size))
+		if _, err = io.Copy(buf, io.LimitReader(r, size+1)); err != nil {
+			return nil, err
+		}
+		block = buf.Bytes()
+	case CompressionZstd:
+		block, err = c.zstdDecoder.DecodeAll(compressed, dst[:0])
+	case CompressionSnappy:
+		var n int
+		if n, err = s2.DecodedLen(compressed); err == nil && int64(n) != size {
+			return nil, fmt.Errorf("decompressed block is %d bytes, expected %d", n, size)
+		}
+		if err == nil {
+			block, err = s2.Decode(dst[:cap(dst)], compressed)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", c.compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(block)) != size {
+		return nil, fmt.Errorf("decompressed block is %d bytes, expected %d", len(block), size)
+	}
+	return block, nil
+}
+
+// frameReader reads the message entries of a compressed file, one frame (compressed block) at a time
+type frameReader struct {
+	reader     io.Reader
+	codec      *blockCodec
+	header     []byte
+	compressed []byte
+	block      []byte
+	pos        int
+}
+
+func newFrameReader(reader io.Reader, codec *blockCodec) *frameReader {
+	return &frameReader{
+		reader: reader,
+		codec:  codec,
+		header: make([]byte, FrameHeaderSize),
+	}
+}
+
+// Read reads decompressed message entries
+// A truncated frame at the end of the file is reported as io.EOF
+func (f *frameReader) Read(p []byte) (int, error) {
+	for f.pos >= len(f.block) {
+		if err := f.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, f.block[f.pos:])
+	f.pos += n
+	return n, nil
+}
+
+// readFrame reads and decompresses the next frame
+func (f *frameReader) readFrame() error {
+	if _, err := io.ReadFull(f.reader, f.header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+		return err
+	}
+	compressedSize := int64(binary.BigEndian.Uint64(f.header[0:FrameSizeFieldSize]))
+	size := int64(binary.BigEndian.Uint64(f.header[FrameSizeFieldSize:FrameHeaderSize]))
+	if compressedSize < 0 || compressedSize > MaxFrameSize || size < 0 || size > MaxFrameSize {
+		return fmt.Errorf("invalid compressed frame: %d bytes (%d bytes uncompressed)", compressedSize, size)
+	}
+
+	if int64(cap(f.compressed)) < compressedSize {
+		f.compressed = make([]byte, compressedSize)
+	}
+	f.compressed = f.compressed[:compressedSize]
+	if _, err := io.ReadFull(f.reader, f.compressed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+		return fmt.Errorf("failed to read compressed frame: %w", err)
+	}
+
+	if int64(cap(f.block)) < size {
+		f.block = make([]byte, size)
+	}
+	block, err := f.codec.decompress(f.block, f.compressed, size)
+	if err != nil {
+		return fmt.Errorf("failed to decompress frame: %w", err)
+	}
+	f.block = block
+	f.pos = 0
+	return nil
+}
+
+// reset discards the current frame, after the underlying reader has been moved to the start of a frame
+func (f *frameReader) reset() {
+	f.block = f.block[:0]
+	f.pos = 0
+}
@@ -2,7 +2,12 @@ package transcoder
 
 const (
 	// ProtocolVersion is the current version of the binary protocol
-	ProtocolVersion = 2
+	ProtocolVersion = ProtocolVersion3
+	// ProtocolVersion3 is version 3 (with message keys and headers, source topic, partition and offset, millisecond
+	// timestamps and their type, and the compression codec and sanitization in the reserved header bytes)
+	ProtocolVersion3 = 3
+	// ProtocolVersion2 is the legacy version 2 (with message keys, without message headers)
+	ProtocolVersion2 = 2
 	// ProtocolVersion1 is the legacy version 1 (without message keys)
 	ProtocolVersion1 = 1
 	// HeaderVersionSize is the size of the version field in the header (int32 = 4 bytes)
@@ -11,10 +16,47 @@ const (
 	HeaderReservedSize = 16
 	// HeaderSize is the total size of the file header
 	HeaderSize = HeaderVersionSize + HeaderReservedSize // 20 bytes total
+	// HeaderCompressionSize is the size of the compression codec field, the first reserved byte of the header (int8 = 1 byte)
+	// Version 3 files use it, earlier versions leave it zero
+	HeaderCompressionSize = 1
+	// HeaderFlagsSize is the size of the flags field following the compression codec (1 byte, see HeaderFlagSanitized)
+	HeaderFlagsSize = 1
+	// HeaderRuleSetSize is the size of the fingerprint of the masking rule set following the flags (8 bytes)
+	HeaderRuleSetSize = 8
+	// HeaderFlagSanitized is set in the flags field when the messages were masked when they were recorded
+	HeaderFlagSanitized = 0x01
 	// TimestampSize is the size of the timestamp field (int64 Unix timestamp = 8 bytes)
+	// Version 3 stores milliseconds since epoch, earlier versions store seconds
 	TimestampSize = 8
+	// TimestampTypeSize is the size of the timestamp type field (int8 = 1 byte)
+	TimestampTypeSize = 1
+	// PartitionSize is the size of the source partition field (int32 = 4 bytes)
+	PartitionSize = 4
+	// OffsetSize is the size of the source offset field (int64 = 8 bytes)
+	OffsetSize = 8
+	// TopicSizeFieldSize is the size of the source topic size field (int64 = 8 bytes)
+	TopicSizeFieldSize = 8
 	// SizeFieldSize is the size of the message size field (int64 = 8 bytes)
 	SizeFieldSize = 8
 	// KeySizeFieldSize is the size of the key size field (int64 = 8 bytes)
 	KeySizeFieldSize = 8
+	// HeadersSizeFieldSize is the size of the message headers block size field (int64 = 8 bytes)
+	HeadersSizeFieldSize = 8
+	// HeaderCountSize is the size of the header count at the start of the headers block (int32 = 4 bytes)
+	HeaderCountSize = 4
+	// HeaderKeySizeFieldSize is the size of a header key size field (int32 = 4 bytes)
+	HeaderKeySizeFieldSize = 4
+	// HeaderValueSizeFieldSize is the size of a header value size field (int32 = 4 bytes)
+	HeaderValueSizeFieldSize = 4
+	// MaxFieldSize is the maximum size accepted for a topic, key, message or headers block (100 MB)
+	MaxFieldSize = 100 * 1024 * 1024
+	// FrameSizeFieldSize is the size of each of the two size fields of a compressed frame (int64 = 8 bytes)
+	FrameSizeFieldSize = 8
+	// FrameHeaderSize is the size of the header of a compressed frame (compressed size + uncompressed size)
+	FrameHeaderSize = 2 * FrameSizeFieldSize
+	// CompressionBlockSize is the uncompressed size after which a block of message entries is compressed into a frame (1 MB)
+	// Entries never span frames, so a frame holding one large entry can be bigger
+	CompressionBlockSize = 1024 * 1024
+	// MaxFrameSize is the maximum compressed or uncompressed size accepted for a frame (512 MB)
+	MaxFrameSize = 512 * 1024 * 1024
 )
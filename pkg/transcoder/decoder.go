@@ -2,6 +2,7 @@ package transcoder
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -10,40 +11,83 @@ import (
 )
 
 // DecodeReader decodes messages from a binary file format
-// Supports both version 1 (legacy, no keys) and version 2 (with keys)
+// Supports version 1 (legacy, no keys), version 2 (legacy, with keys) and version 3 (with keys, headers, source
+// topic, partition and offset, millisecond timestamps and their type, optionally compressed)
+// Compressed files are detected from the file header and decompressed transparently
+// Files are read from any reader, e.g. a pipe: seeking (to reset, or to move back or to a position) requires
+// an io.Seeker, while moving forward reads through the entries
 type DecodeReader struct {
-	reader             io.ReadSeeker
+	reader             io.Reader
+	source             *positionReader // Reads and seeks the file, keeping track of the position
+	entries            io.Reader       // Where entries are read from: the file, or the frame reader for compressed files
+	frames             *frameReader    // Reads the frames of compressed files (nil if uncompressed)
+	compression        Compression
+	sanitization       *Sanitization
 	timestampBuf       []byte
+	timestampTypeBuf   []byte
+	partitionBuf       []byte
+	offsetBuf          []byte
+	topicSizeBuf       []byte
 	keySizeBuf         []byte
 	sizeBuf            []byte
+	headersSizeBuf     []byte
 	preserveTimestamps bool
 	dataStartOffset    int64 // Offset after the header where message data starts
 	protocolVersion    int32
+	next               int64      // Number of the next entry (0-based)
+	pending            *Entry     // Entry read ahead by SeekTime, returned by the next Read
+	pendingPosition    Position   // Position of the pending entry
+	frameStart         IndexPoint // Start of the current compressed frame
 }
 
-type Entry struct {
-	Timestamp time.Time
-	Key       []byte
-	Data      []byte
+// Position is where an entry is in a file, for reading to continue from it later (see SeekPosition)
+type Position struct {
+	// Entry is the number of the entry (0-based)
+	Entry int64
+	// Offset is the byte offset reading can start at to reach the entry: the entry itself, or the start
+	// of its frame in compressed files
+	Offset int64
+	// OffsetEntry is the number of the entry at Offset
+	OffsetEntry int64
 }
 
+// ErrNotSeekable is returned when seeking is required in a file read from a stream, which can only be read forward
+var ErrNotSeekable = errors.New("cannot seek in a stream (only reading forward is possible)")
+
 // NewDecodeReader creates a new decoder for binary message files
 // It reads and validates the file header, then positions the reader at the start of message data
-// Supports both version 1 (legacy) and version 2 formats
-func NewDecodeReader(reader io.ReadSeeker, preserveTimestamps bool) (*DecodeReader, error) {
+// Supports version 1 (legacy) through version 3 formats
+// Readers that do not implement io.Seeker are read as streams (see Seekable)
+func NewDecodeReader(reader io.Reader, preserveTimestamps bool) (*DecodeReader, error) {
 	d := &DecodeReader{
 		reader:             reader,
 		timestampBuf:       make([]byte, TimestampSize),
+		timestampTypeBuf:   make([]byte, TimestampTypeSize),
+		partitionBuf:       make([]byte, PartitionSize),
+		offsetBuf:          make([]byte, OffsetSize),
+		topicSizeBuf:       make([]byte, TopicSizeFieldSize),
 		keySizeBuf:         make([]byte, KeySizeFieldSize),
 		sizeBuf:            make([]byte, SizeFieldSize),
+		headersSizeBuf:     make([]byte, HeadersSizeFieldSize),
 		preserveTimestamps: preserveTimestamps,
 	}
+	d.source = &positionReader{reader: reader}
+	d.entries = d.source
 
 	// Read and validate file header
 	if err := d.readFileHeader(); err != nil {
 		return nil, fmt.Errorf("failed to read file header: %w", err)
 	}
 
+	if d.compression != CompressionNone {
+		codec, err := newDecompressor(d.compression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up %s decompression: %w", d.compression, err)
+		}
+		d.frames = newFrameReader(d.source, codec)
+		d.entries = d.frames
+	}
+
 	// Store the offset after the header for reset operations
 	d.dataStartOffset = HeaderSize
 
@@ -51,25 +95,44 @@ func NewDecodeReader(reader io.ReadSeeker, preserveTimestamps bool) (*DecodeRead
 }
 
 // Read reads the next complete message from the binary file
-// Returns the message timestamp, key, value, and error
-// For version 1 files, key will be nil
+// Returns the message timestamp, key, headers, value, source, and error
+// For version 1 files, key will be nil. For version 1 and 2 files, headers will be nil, the source topic is empty,
+// partition and offset are -1, and timestamps have a resolution of one second and an unknown type
 func (d *DecodeReader) Read() (*Entry, error) {
-	// Read timestamp (8 bytes Unix timestamp)
-	if _, err := io.ReadFull(d.reader, d.timestampBuf); err != nil {
+	if entry := d.pending; entry != nil {
+		d.pending = nil
+		d.next++
+		if !d.preserveTimestamps {
+			entry.Timestamp = time.Now().UTC()
+		}
+		return entry, nil
+	}
+
+	entry, err := d.readEntry()
+	if err != nil {
+		return nil, err
+	}
+	d.next++
+	return entry, nil
+}
+
+// readEntry reads the next entry from the file
+func (d *DecodeReader) readEntry() (*Entry, error) {
+	if position, newFrame := d.entryPosition(); newFrame {
+		d.frameStart = IndexPoint{Entry: d.next, Position: position}
+	}
+
+	// Read timestamp (8 bytes Unix timestamp, in milliseconds since version 3)
+	if _, err := io.ReadFull(d.entries, d.timestampBuf); err != nil {
 		if err == io.EOF || err == io.ErrUnexpectedEOF {
 			return nil, io.EOF
 		}
 		return nil, fmt.Errorf("failed to read timestamp: %w", err)
 	}
 
-	var key []byte
-	var messageData []byte
-	var msgTime time.Time
-	var err error
-
 	if d.protocolVersion == ProtocolVersion1 {
 		// Use legacy decoder for version 1 format
-		msgTime, messageData, err = legacy.V1ReadMessage(d.reader, d.timestampBuf, d.sizeBuf, d.preserveTimestamps)
+		msgTime, messageData, err := legacy.V1ReadMessage(d.entries, d.timestampBuf, d.sizeBuf, d.preserveTimestamps)
 		if err != nil {
 			if err == io.EOF {
 				return nil, io.EOF
@@ -77,109 +140,367 @@ func (d *DecodeReader) Read() (*Entry, error) {
 			return nil, err
 		}
 		// Version 1 has no key
-		key = nil
 		return &Entry{
-			Timestamp: msgTime,
-			Key:       key,
-			Data:      messageData,
+			Timestamp:     msgTime,
+			TimestampType: TimestampTypeUnknown,
+			Key:           nil,
+			Data:          messageData,
+			Partition:     -1,
+			Offset:        -1,
 		}, nil
-	} else {
-		// Version 2 format: timestamp, key size, message size, key, message data
-		// Read key size (8 bytes)
-		if _, err := io.ReadFull(d.reader, d.keySizeBuf); err != nil {
+	}
+
+	// Version 2 and 3 format: timestamp, [timestamp type, partition, offset, topic size,] key size, message size,
+	// [headers size,] [topic,] key, [headers,] message data
+	timestampType := TimestampTypeUnknown
+	partition, offset := -1, int64(-1)
+	var topicSize int64
+	var err error
+	if d.protocolVersion >= ProtocolVersion3 {
+		if _, err := io.ReadFull(d.entries, d.timestampTypeBuf); err != nil {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
 				return nil, io.EOF
 			}
-			return nil, fmt.Errorf("failed to read key size: %w", err)
+			return nil, fmt.Errorf("failed to read timestamp type: %w", err)
 		}
+		timestampType = TimestampType(int8(d.timestampTypeBuf[0]))
 
-		keySize := int64(binary.BigEndian.Uint64(d.keySizeBuf))
-		if keySize < 0 || keySize > 100*1024*1024 { // Sanity check: max 100MB
-			return nil, fmt.Errorf("invalid key size: %d bytes", keySize)
+		if _, err := io.ReadFull(d.entries, d.partitionBuf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read partition: %w", err)
 		}
+		partition = int(int32(binary.BigEndian.Uint32(d.partitionBuf)))
 
-		// Read message size (8 bytes)
-		if _, err := io.ReadFull(d.reader, d.sizeBuf); err != nil {
+		if _, err := io.ReadFull(d.entries, d.offsetBuf); err != nil {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
 				return nil, io.EOF
 			}
-			return nil, fmt.Errorf("failed to read message size: %w", err)
+			return nil, fmt.Errorf("failed to read offset: %w", err)
 		}
+		offset = int64(binary.BigEndian.Uint64(d.offsetBuf))
 
-		messageSize := int64(binary.BigEndian.Uint64(d.sizeBuf))
-		if messageSize < 0 || messageSize > 100*1024*1024 { // Sanity check: max 100MB
-			return nil, fmt.Errorf("invalid message size: %d bytes", messageSize)
+		if topicSize, err = d.readSize(d.topicSizeBuf, "topic size"); err != nil {
+			return nil, err
 		}
+	}
 
-		// Read key data (if present)
-		if keySize > 0 {
-			key = make([]byte, keySize)
-			if _, err := io.ReadFull(d.reader, key); err != nil {
-				if err == io.EOF || err == io.ErrUnexpectedEOF {
-					return nil, io.EOF
-				}
-				return nil, fmt.Errorf("failed to read key data: %w", err)
-			}
-		} else {
-			key = nil
+	keySize, err := d.readSize(d.keySizeBuf, "key size")
+	if err != nil {
+		return nil, err
+	}
+	messageSize, err := d.readSize(d.sizeBuf, "message size")
+	if err != nil {
+		return nil, err
+	}
+	var headersSize int64
+	if d.protocolVersion >= ProtocolVersion3 {
+		if headersSize, err = d.readSize(d.headersSizeBuf, "headers size"); err != nil {
+			return nil, err
 		}
+	}
 
-		// Read message data
-		messageData = make([]byte, messageSize)
-		if _, err := io.ReadFull(d.reader, messageData); err != nil {
-			if err == io.EOF || err == io.ErrUnexpectedEOF {
-				return nil, io.EOF
-			}
-			return nil, fmt.Errorf("failed to read message data: %w", err)
+	// Read topic (if present)
+	var topic string
+	if topicSize > 0 {
+		topicData, err := d.readData(topicSize, "topic")
+		if err != nil {
+			return nil, err
+		}
+		topic = string(topicData)
+	}
+
+	// Read key data (if present)
+	var key []byte
+	if keySize > 0 {
+		if key, err = d.readData(keySize, "key data"); err != nil {
+			return nil, err
 		}
 	}
 
-	// Parse timestamp for version 2
+	// Read headers block (if present)
+	var headers []Header
+	if headersSize > 0 {
+		block, err := d.readData(headersSize, "headers")
+		if err != nil {
+			return nil, err
+		}
+		if headers, err = decodeHeaders(block); err != nil {
+			return nil, fmt.Errorf("failed to decode headers: %w", err)
+		}
+	}
+
+	// Read message data
+	messageData, err := d.readData(messageSize, "message data")
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse timestamp
+	var msgTime time.Time
 	if d.preserveTimestamps {
 		// Read Unix timestamp (int64, big-endian)
 		unixTimestamp := int64(binary.BigEndian.Uint64(d.timestampBuf))
-		msgTime = time.Unix(unixTimestamp, 0).UTC()
+		if d.protocolVersion >= ProtocolVersion3 {
+			msgTime = time.UnixMilli(unixTimestamp).UTC()
+		} else {
+			msgTime = time.Unix(unixTimestamp, 0).UTC()
+		}
 	} else {
 		msgTime = time.Now().UTC()
 	}
 
 	return &Entry{
-		Timestamp: msgTime,
-		Key:       key,
-		Data:      messageData,
+		Timestamp:     msgTime,
+		TimestampType: timestampType,
+		Key:           key,
+		Data:          messageData,
+		Headers:       headers,
+		Topic:         topic,
+		Partition:     partition,
+		Offset:        offset,
 	}, nil
 }
 
-// Close closes the underlying reader if it implements io.Closer
+// readSize reads a fixed-size (8 bytes) size field and validates it
+// A truncated entry is reported as io.EOF
+func (d *DecodeReader) readSize(buf []byte, name string) (int64, error) {
+	if _, err := io.ReadFull(d.entries, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, io.EOF
+		}
+		return 0, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	size := int64(binary.BigEndian.Uint64(buf))
+	if size < 0 || size > MaxFieldSize { // Sanity check: max 100MB
+		return 0, fmt.Errorf("invalid %s: %d bytes", name, size)
+	}
+	return size, nil
+}
+
+// readData reads size bytes of variable data
+// A truncated entry is reported as io.EOF
+func (d *DecodeReader) readData(size int64, name string) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(d.entries, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// Close releases the decompressor and closes the underlying reader if it implements io.Closer
 func (d *DecodeReader) Close() error {
+	if d.frames != nil {
+		d.frames.codec.close()
+	}
 	if closer, ok := d.reader.(io.Closer); ok {
 		return closer.Close()
 	}
 	return nil
 }
 
+// Seekable reports whether the file can be seeked, i.e. whether it is not read from a stream
+// Streams can only be read forward: Reset fails once entries have been read, and SeekEntry and SeekTime
+// only succeed while no entries have been read, by reading through the entries before the one to move to
+func (d *DecodeReader) Seekable() bool {
+	_, ok := d.reader.(io.Seeker)
+	return ok
+}
+
 // Reset seeks back to the start of message data (after the header)
 func (d *DecodeReader) Reset() error {
-	_, err := d.reader.Seek(d.dataStartOffset, io.SeekStart)
-	return err
+	return d.seekPoint(d.startPoint())
+}
+
+// SeekEntry moves to entry n (0-based), so that the next Read returns it
+// With an index (see Index), reading starts at the closest index point before the entry instead of at
+// the start of the file. Moving past the last entry is not an error: the next Read returns io.EOF
+func (d *DecodeReader) SeekEntry(n int64, index *Index) error {
+	if err := d.seekPoint(index.pointForEntry(n, d.startPoint())); err != nil {
+		return err
+	}
+	return d.skipTo(n)
+}
+
+// SeekPosition moves to an entry at a position returned by Position, so that the next Read returns it
+func (d *DecodeReader) SeekPosition(p Position) error {
+	if p.Offset < d.dataStartOffset || p.OffsetEntry < 0 || p.OffsetEntry > p.Entry {
+		return fmt.Errorf("invalid position: entry %d at offset %d (entry %d)", p.Entry, p.Offset, p.OffsetEntry)
+	}
+	if err := d.seekPoint(IndexPoint{Entry: p.OffsetEntry, Position: p.Offset}); err != nil {
+		return err
+	}
+	return d.skipTo(p.Entry)
+}
+
+// skipTo reads entries up to entry n (0-based)
+func (d *DecodeReader) skipTo(n int64) error {
+	for d.next < n {
+		if _, err := d.readEntry(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		d.next++
+	}
+	return nil
+}
+
+// SeekTime moves to the first entry with a timestamp at or after t, so that the next Read returns it
+// Entries are not necessarily in timestamp order (e.g. when several partitions were recorded into one file):
+// reading continues with all entries after that first one. With an index (see Index), reading starts at
+// the closest index point before the entry instead of at the start of the file
+func (d *DecodeReader) SeekTime(t time.Time, index *Index) error {
+	if err := d.seekPoint(index.pointForTime(t, d.startPoint())); err != nil {
+		return err
+	}
+
+	// Compare the recorded timestamps (Read replaces them if they are not preserved)
+	preserveTimestamps := d.preserveTimestamps
+	d.preserveTimestamps = true
+	defer func() { d.preserveTimestamps = preserveTimestamps }()
+	for {
+		position := d.position()
+		entry, err := d.readEntry()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if !entry.Timestamp.Before(t) {
+			d.pending = entry
+			d.pendingPosition = position
+			return nil
+		}
+		d.next++
+	}
+}
+
+// EntryNumber returns the number (0-based) of the entry the next Read returns
+func (d *DecodeReader) EntryNumber() int64 {
+	return d.next
+}
+
+// Position returns the position of the entry the next Read returns
+func (d *DecodeReader) Position() Position {
+	if d.pending != nil {
+		return d.pendingPosition
+	}
+	return d.position()
+}
+
+// position returns the position of the next entry in the file
+func (d *DecodeReader) position() Position {
+	offset, _ := d.entryPosition()
+	if offset < 0 {
+		// Inside the current frame
+		return Position{Entry: d.next, Offset: d.frameStart.Position, OffsetEntry: d.frameStart.Entry}
+	}
+	return Position{Entry: d.next, Offset: offset, OffsetEntry: d.next}
+}
+
+// startPoint returns the index point of the first entry
+func (d *DecodeReader) startPoint() IndexPoint {
+	return IndexPoint{Entry: 0, Position: d.dataStartOffset}
+}
+
+// seekPoint moves to an index point
+// Nothing is read or seeked if the next entry is already at the point, which is how streams move forward
+func (d *DecodeReader) seekPoint(p IndexPoint) error {
+	if position, _ := d.entryPosition(); position == p.Position && d.next == p.Entry && d.pending == nil {
+		return nil
+	}
+	if _, err := d.source.Seek(p.Position, io.SeekStart); err != nil {
+		return err
+	}
+	if d.frames != nil {
+		d.frames.reset()
+	}
+	d.next = p.Entry
+	d.pending = nil
+	return nil
+}
+
+// entryPosition returns the byte offset reading can start at for the next entry, or -1 if the next
+// entry is inside the current compressed frame, and whether the next entry starts a new frame
+func (d *DecodeReader) entryPosition() (int64, bool) {
+	if d.frames == nil {
+		return d.source.position, false
+	}
+	if d.frames.pos < len(d.frames.block) {
+		return -1, false
+	}
+	return d.source.position, true
+}
+
+// Compression returns the compression codec of the file (CompressionNone for files older than version 3)
+func (d *DecodeReader) Compression() Compression {
+	return d.compression
+}
+
+// Sanitization returns the sanitization marked in the file header, or nil if the messages were not masked
+// when they were recorded (always nil for files older than version 3)
+func (d *DecodeReader) Sanitization() *Sanitization {
+	return d.sanitization
 }
 
 // readFileHeader reads and validates the file header
 func (d *DecodeReader) readFileHeader() error {
 	headerBuf := make([]byte, HeaderSize)
-	if _, err := io.ReadFull(d.reader, headerBuf); err != nil {
+	if _, err := io.ReadFull(d.source, headerBuf); err != nil {
 		return err
 	}
 
 	// Read protocol version (int32, big-endian)
 	d.protocolVersion = int32(binary.BigEndian.Uint32(headerBuf[0:HeaderVersionSize]))
 
-	// Validate protocol version (support version 1 and 2)
-	if d.protocolVersion != ProtocolVersion1 && d.protocolVersion != ProtocolVersion {
-		return fmt.Errorf("unsupported protocol version: %d (supported versions: %d, %d)", d.protocolVersion, ProtocolVersion1, ProtocolVersion)
+	// Validate protocol version (support version 1 to the current version)
+	if d.protocolVersion < ProtocolVersion1 || d.protocolVersion > ProtocolVersion {
+		return fmt.Errorf("unsupported protocol version: %d (supported versions: %d to %d)", d.protocolVersion, ProtocolVersion1, ProtocolVersion)
 	}
 
-	// Reserved bytes are read but not used yet
+	// Read compression codec (first reserved byte, version 3 and later) and the sanitization flag and rule set
+	// The other reserved bytes are read but not used yet
+	if d.protocolVersion >= ProtocolVersion3 {
+		d.compression = Compression(int8(headerBuf[HeaderVersionSize]))
+		if !d.compression.valid() {
+			return fmt.Errorf("unsupported compression codec: %d", int8(headerBuf[HeaderVersionSize]))
+		}
+		d.sanitization = decodeSanitization(headerBuf)
+	}
 
 	return nil
 }
+
+// positionReader reads and seeks a file, keeping track of the position
+// The file is expected to be at its start when the decoder is created
+// Seeking fails with ErrNotSeekable if the reader is not an io.Seeker
+type positionReader struct {
+	reader   io.Reader
+	position int64
+}
+
+func (p *positionReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	p.position += int64(n)
+	return n, err
+}
+
+func (p *positionReader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := p.reader.(io.Seeker)
+	if !ok {
+		return 0, ErrNotSeekable
+	}
+	position, err := seeker.Seek(offset, whence)
+	if err == nil {
+		p.position = position
+	}
+	return position, err
+}
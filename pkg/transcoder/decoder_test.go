@@ -3,6 +3,7 @@ package transcoder
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 	"testing"
 	"time"
@@ -12,7 +13,7 @@ func TestNewDecodeReader(t *testing.T) {
 	// Create a valid file with header
 	buf := &bytes.Buffer{}
 	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
 	buf.Write(header)
 
 	reader := bytes.NewReader(buf.Bytes())
@@ -25,8 +26,8 @@ func TestNewDecodeReader(t *testing.T) {
 		t.Errorf("Expected dataStartOffset %d, got %d", HeaderSize, decoder.dataStartOffset)
 	}
 
-	if decoder.protocolVersion != ProtocolVersion {
-		t.Errorf("Expected protocol version %d, got %d", ProtocolVersion, decoder.protocolVersion)
+	if decoder.protocolVersion != ProtocolVersion2 {
+		t.Errorf("Expected protocol version %d, got %d", ProtocolVersion2, decoder.protocolVersion)
 	}
 }
 
@@ -48,7 +49,7 @@ func TestDecodeReader_Read(t *testing.T) {
 	// Create a file with header and one message
 	buf := &bytes.Buffer{}
 	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
 	buf.Write(header)
 
 	testTime := time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC)
@@ -104,7 +105,7 @@ func TestDecodeReader_ReadPreserveTimestamps(t *testing.T) {
 	// Create a file with header and one message
 	buf := &bytes.Buffer{}
 	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
 	buf.Write(header)
 
 	testTime := time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC)
@@ -154,7 +155,7 @@ func TestDecodeReader_ReadWithoutPreserveTimestamps(t *testing.T) {
 	// Create a file with header and one message
 	buf := &bytes.Buffer{}
 	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
 	buf.Write(header)
 
 	testTime := time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC)
@@ -206,7 +207,7 @@ func TestDecodeReader_MultipleReads(t *testing.T) {
 	// Create a file with header and multiple messages
 	buf := &bytes.Buffer{}
 	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
 	buf.Write(header)
 
 	messages := []struct {
@@ -272,7 +273,7 @@ func TestDecodeReader_Reset(t *testing.T) {
 	// Create a file with header and multiple messages
 	buf := &bytes.Buffer{}
 	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
 	buf.Write(header)
 
 	testTime := time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC)
@@ -333,7 +334,7 @@ func TestDecodeReader_EmptyMessage(t *testing.T) {
 	// Create a file with header and empty message
 	buf := &bytes.Buffer{}
 	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
 	buf.Write(header)
 
 	testTime := time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC)
@@ -379,7 +380,7 @@ func TestDecodeReader_InvalidSize(t *testing.T) {
 	// Create a file with invalid message size
 	buf := &bytes.Buffer{}
 	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
 	buf.Write(header)
 
 	testTime := time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC)
@@ -467,7 +468,7 @@ func TestDecodeReader_Version2WithKey(t *testing.T) {
 	// Create a version 2 file with a key
 	buf := &bytes.Buffer{}
 	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
 	buf.Write(header)
 
 	testTime := time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC)
@@ -514,3 +515,302 @@ func TestDecodeReader_Version2WithKey(t *testing.T) {
 		t.Errorf("Data mismatch: expected %q, got %q", testData, entry.Data)
 	}
 }
+
+// TestDecodeReader_Version3 tests reading version 3 files with message headers, source topic, partition and offset,
+// and millisecond timestamps with their type
+func TestDecodeReader_Version3(t *testing.T) {
+	buf := &bytes.Buffer{}
+	header := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion3))
+	buf.Write(header)
+
+	testTime := time.Date(2024, 2, 2, 10, 15, 30, 123000000, time.UTC)
+	testTopic := "orders"
+	testKey := []byte("message-key")
+	testData := []byte("Hello with headers!")
+
+	// Headers block: count, then key size + key + value size + value
+	headersBlock := binary.BigEndian.AppendUint32(nil, 1)
+	headersBlock = binary.BigEndian.AppendUint32(headersBlock, uint32(len("content-type")))
+	headersBlock = append(headersBlock, "content-type"...)
+	headersBlock = binary.BigEndian.AppendUint32(headersBlock, uint32(len("application/json")))
+	headersBlock = append(headersBlock, "application/json"...)
+
+	// Write message entry in version 3 format
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(testTime.UnixMilli())))
+	buf.WriteByte(byte(TimestampTypeLogAppendTime))
+	buf.Write(binary.BigEndian.AppendUint32(nil, 3))                         // Partition
+	buf.Write(binary.BigEndian.AppendUint64(nil, 1234))                      // Offset
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(len(testTopic))))    // Topic size
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(len(testKey))))      // Key size
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(len(testData))))     // Message size
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(len(headersBlock)))) // Headers size
+	buf.WriteString(testTopic)
+	buf.Write(testKey)
+	buf.Write(headersBlock)
+	buf.Write(testData)
+
+	decoder, err := NewDecodeReader(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+
+	entry, err := decoder.Read()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if !entry.Timestamp.Equal(testTime) {
+		t.Errorf("Timestamp mismatch: expected %v, got %v", testTime, entry.Timestamp)
+	}
+	if entry.TimestampType != TimestampTypeLogAppendTime {
+		t.Errorf("Timestamp type mismatch: expected %v, got %v", TimestampTypeLogAppendTime, entry.TimestampType)
+	}
+	if entry.Topic != testTopic || entry.Partition != 3 || entry.Offset != 1234 {
+		t.Errorf("Source mismatch: expected %s/3@1234, got %s/%d@%d", testTopic, entry.Topic, entry.Partition, entry.Offset)
+	}
+	if !entry.HasSource() {
+		t.Error("Expected entry to have a source")
+	}
+	if !bytes.Equal(entry.Key, testKey) {
+		t.Errorf("Key mismatch: expected %q, got %q", testKey, entry.Key)
+	}
+	if !bytes.Equal(entry.Data, testData) {
+		t.Errorf("Data mismatch: expected %q, got %q", testData, entry.Data)
+	}
+	if len(entry.Headers) != 1 {
+		t.Fatalf("Expected 1 header, got %d", len(entry.Headers))
+	}
+	if entry.Headers[0].Key != "content-type" || string(entry.Headers[0].Value) != "application/json" {
+		t.Errorf("Header mismatch: got %q=%q", entry.Headers[0].Key, entry.Headers[0].Value)
+	}
+
+	_, err = decoder.Read()
+	if err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+// TestDecodeReader_InvalidHeadersBlock tests that a corrupt headers block is reported as an error
+func TestDecodeReader_InvalidHeadersBlock(t *testing.T) {
+	buf := &bytes.Buffer{}
+	header := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion3))
+	buf.Write(header)
+
+	// Header count claims 5 headers but the block only has room for none
+	headersBlock := binary.BigEndian.AppendUint32(nil, 5)
+
+	buf.Write(binary.BigEndian.AppendUint64(nil, 0))                         // Timestamp
+	buf.WriteByte(byte(TimestampTypeCreateTime))                             // Timestamp type
+	buf.Write(binary.BigEndian.AppendUint32(nil, 0))                         // Partition
+	buf.Write(binary.BigEndian.AppendUint64(nil, 0))                         // Offset
+	buf.Write(binary.BigEndian.AppendUint64(nil, 0))                         // Topic size
+	buf.Write(binary.BigEndian.AppendUint64(nil, 0))                         // Key size
+	buf.Write(binary.BigEndian.AppendUint64(nil, 0))                         // Message size
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(len(headersBlock)))) // Headers size
+	buf.Write(headersBlock)
+
+	decoder, err := NewDecodeReader(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+
+	if _, err := decoder.Read(); err == nil || err == io.EOF {
+		t.Fatalf("Expected error for invalid headers block, got %v", err)
+	}
+}
+
+// TestDecodeReader_LegacyUnknownSource tests that entries of older versions report an unknown source, and
+// timestamps in whole seconds with an unknown type
+func TestDecodeReader_LegacyUnknownSource(t *testing.T) {
+	testTime := time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC)
+
+	buf := &bytes.Buffer{}
+	header := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
+	buf.Write(header)
+
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(testTime.Unix()))) // Timestamp
+	buf.Write(binary.BigEndian.AppendUint64(nil, 0))                       // Key size
+	buf.Write(binary.BigEndian.AppendUint64(nil, 4))                       // Message size
+	buf.WriteString("data")
+
+	decoder, err := NewDecodeReader(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+
+	entry, err := decoder.Read()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if entry.Topic != "" || entry.Partition != -1 || entry.Offset != -1 {
+		t.Errorf("Expected unknown source, got %q/%d@%d", entry.Topic, entry.Partition, entry.Offset)
+	}
+	if entry.HasSource() {
+		t.Error("Expected entry without source")
+	}
+	if !entry.Timestamp.Equal(testTime) {
+		t.Errorf("Timestamp mismatch: expected %v, got %v", testTime, entry.Timestamp)
+	}
+	if entry.TimestampType != TimestampTypeUnknown {
+		t.Errorf("Expected unknown timestamp type, got %d", entry.TimestampType)
+	}
+}
+
+// TestBlockCodec_SizeMismatch tests that blocks decompressing to more or fewer bytes than their frame declares are
+// rejected, without decompressing more than one byte past the declared size
+func TestBlockCodec_SizeMismatch(t *testing.T) {
+	block := bytes.Repeat([]byte("kafka-replay "), 1000)
+	for _, compression := range []Compression{CompressionGzip, CompressionZstd, CompressionSnappy} {
+		compressor, err := newCompressor(compression)
+		if err != nil {
+			t.Fatalf("%s: newCompressor failed: %v", compression, err)
+		}
+		compressed, err := compressor.compress(nil, block)
+		if err != nil {
+			t.Fatalf("%s: compress failed: %v", compression, err)
+		}
+		if err := compressor.close(); err != nil {
+			t.Errorf("%s: close failed: %v", compression, err)
+		}
+
+		decompressor, err := newDecompressor(compression)
+		if err != nil {
+			t.Fatalf("%s: newDecompressor failed: %v", compression, err)
+		}
+		if got, err := decompressor.decompress(nil, compressed, int64(len(block))); err != nil || !bytes.Equal(got, block) {
+			t.Errorf("%s: decompress failed: %v", compression, err)
+		}
+		for _, size := range []int64{int64(len(block)) - 1, int64(len(block)) + 1} {
+			if _, err := decompressor.decompress(nil, compressed, size); err == nil {
+				t.Errorf("%s: expected an error for a declared size of %d bytes", compression, size)
+			}
+		}
+		decompressor.close()
+	}
+}
+
+// TestDecodeReader_UnsupportedCompression tests that an unknown compression codec in the header is rejected
+func TestDecodeReader_UnsupportedCompression(t *testing.T) {
+	header := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion))
+	header[HeaderVersionSize] = 42
+
+	if _, err := NewDecodeReader(bytes.NewReader(header), true); err == nil {
+		t.Error("Expected error for unsupported compression codec")
+	}
+
+	// Version 2 files never use the reserved bytes, so they are not read as a codec
+	binary.BigEndian.PutUint32(header[0:HeaderVersionSize], uint32(ProtocolVersion2))
+	decoder, err := NewDecodeReader(bytes.NewReader(header), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed for version 2: %v", err)
+	}
+	if decoder.Compression() != CompressionNone {
+		t.Errorf("Expected no compression for version 2, got %s", decoder.Compression())
+	}
+}
+
+func TestDecodeReader_SeekPosition(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionZstd} {
+		recording, _ := writeIndexedRecording(t, compression, 4000)
+		decoder, err := NewDecodeReader(bytes.NewReader(recording), true)
+		if err != nil {
+			t.Fatalf("NewDecodeReader failed: %v", err)
+		}
+
+		// Save the position of some entries while reading
+		positions := make(map[int64]Position)
+		for i := int64(0); i < 4000; i++ {
+			if i == 0 || i == 1234 || i == 3999 {
+				positions[i] = decoder.Position()
+			}
+			if _, err := decoder.Read(); err != nil {
+				t.Fatalf("%s: Read %d failed: %v", compression, i, err)
+			}
+		}
+		end := decoder.Position()
+
+		for n, position := range positions {
+			if position.Entry != n {
+				t.Errorf("%s: position of entry %d has entry number %d", compression, n, position.Entry)
+			}
+			resumed, err := NewDecodeReader(bytes.NewReader(recording), true)
+			if err != nil {
+				t.Fatalf("NewDecodeReader failed: %v", err)
+			}
+			if err := resumed.SeekPosition(position); err != nil {
+				t.Fatalf("%s: SeekPosition(%+v) failed: %v", compression, position, err)
+			}
+			entry, err := resumed.Read()
+			if err != nil {
+				t.Fatalf("%s: Read after SeekPosition(%+v) failed: %v", compression, position, err)
+			}
+			if entry.Offset != n {
+				t.Errorf("%s: SeekPosition(%+v): read entry %d, expected %d", compression, position, entry.Offset, n)
+			}
+		}
+
+		// The position after the last entry resumes at the end of the file
+		if err := decoder.SeekPosition(end); err != nil {
+			t.Fatalf("%s: SeekPosition(%+v) failed: %v", compression, end, err)
+		}
+		if _, err := decoder.Read(); err != io.EOF {
+			t.Errorf("%s: expected EOF at the end position, got %v", compression, err)
+		}
+
+		if err := decoder.SeekPosition(Position{Entry: 1, Offset: 0, OffsetEntry: 0}); err == nil {
+			t.Errorf("%s: expected error for a position inside the file header", compression)
+		}
+	}
+}
+
+func TestDecodeReader_Stream(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionZstd} {
+		recording, _ := writeIndexedRecording(t, compression, 4000)
+
+		// A reader without Seek, like a pipe, can only be read forward
+		decoder, err := NewDecodeReader(struct{ io.Reader }{bytes.NewReader(recording)}, true)
+		if err != nil {
+			t.Fatalf("NewDecodeReader failed: %v", err)
+		}
+		if decoder.Seekable() {
+			t.Errorf("%s: a stream should not be seekable", compression)
+		}
+		if err := decoder.SeekEntry(1234, nil); err != nil {
+			t.Fatalf("%s: SeekEntry on a stream failed: %v", compression, err)
+		}
+		entry, err := decoder.Read()
+		if err != nil {
+			t.Fatalf("%s: Read failed: %v", compression, err)
+		}
+		if entry.Offset != 1234 {
+			t.Errorf("%s: SeekEntry(1234): read entry %d", compression, entry.Offset)
+		}
+		if err := decoder.Reset(); !errors.Is(err, ErrNotSeekable) {
+			t.Errorf("%s: Reset on a stream: expected ErrNotSeekable, got %v", compression, err)
+		}
+
+		decoder, err = NewDecodeReader(struct{ io.Reader }{bytes.NewReader(recording)}, true)
+		if err != nil {
+			t.Fatalf("NewDecodeReader failed: %v", err)
+		}
+		if err := decoder.SeekTime(indexTestTime(100), nil); err != nil {
+			t.Fatalf("%s: SeekTime on a stream failed: %v", compression, err)
+		}
+		if entry, err = decoder.Read(); err != nil || entry.Offset != 100 {
+			t.Errorf("%s: SeekTime: read entry %+v (%v), expected entry 100", compression, entry, err)
+		}
+
+		seekable, err := NewDecodeReader(bytes.NewReader(recording), true)
+		if err != nil {
+			t.Fatalf("NewDecodeReader failed: %v", err)
+		}
+		if !seekable.Seekable() {
+			t.Errorf("%s: a bytes.Reader should be seekable", compression)
+		}
+	}
+}
@@ -1,33 +1,65 @@
 package transcoder
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 )
 
 // EncodeWriter encodes messages to a binary file format
+// It is safe for concurrent use: entries written from several goroutines are never interleaved
+// With compression, entries are buffered and written as compressed frames; call Flush or Close
+// to write the last frame
 type EncodeWriter struct {
-	writer       io.Writer
-	timestampBuf []byte
-	keySizeBuf   []byte
-	sizeBuf      []byte
-	totalBytes   int64
+	mu               sync.Mutex
+	output           io.Writer // The file
+	writer           io.Writer // Where entries are written: the file, or the block buffer with compression
+	compression      Compression
+	sanitization     *Sanitization
+	codec            *blockCodec
+	block            bytes.Buffer
+	frame            []byte
+	timestampBuf     []byte
+	timestampTypeBuf []byte
+	partitionBuf     []byte
+	offsetBuf        []byte
+	topicSizeBuf     []byte
+	keySizeBuf       []byte
+	sizeBuf          []byte
+	headersSizeBuf   []byte
+	headersBuf       []byte
+	totalBytes       int64
+	index            indexBuilder
 }
 
-// NewEncodeWriter creates a new encoder for binary message files
+// NewEncodeWriter creates a new encoder for uncompressed binary message files
 // It writes the file header and positions the writer ready for message data
-// New files are written in version 2 format (with message keys)
+// New files are written in version 3 format (with message keys, headers, source topic, partition and offset,
+// and millisecond timestamps with their timestamp type)
 func NewEncodeWriter(writer io.Writer) (*EncodeWriter, error) {
-	e := &EncodeWriter{
-		writer:       writer,
-		timestampBuf: make([]byte, TimestampSize),
-		keySizeBuf:   make([]byte, KeySizeFieldSize),
-		sizeBuf:      make([]byte, SizeFieldSize),
+	return NewCompressedEncodeWriter(writer, CompressionNone)
+}
+
+// NewCompressedEncodeWriter creates a new encoder for binary message files whose entries are compressed
+// in blocks with the given codec (CompressionNone writes uncompressed entries, see NewEncodeWriter)
+func NewCompressedEncodeWriter(writer io.Writer, compression Compression) (*EncodeWriter, error) {
+	return NewSanitizedEncodeWriter(writer, compression, nil)
+}
+
+// NewSanitizedEncodeWriter creates a new encoder like NewCompressedEncodeWriter, marking the file as sanitized
+// in its header: the caller masks the messages with the rule set of the sanitization before writing them
+// A nil sanitization writes an unmarked file
+func NewSanitizedEncodeWriter(writer io.Writer, compression Compression, sanitization *Sanitization) (*EncodeWriter, error) {
+	e, err := newEncodeWriter(writer, compression)
+	if err != nil {
+		return nil, err
 	}
+	e.sanitization = sanitization
 
-	// Write file header with version 2
+	// Write file header with the current version
 	if err := e.writeFileHeader(); err != nil {
 		return nil, fmt.Errorf("failed to write file header: %w", err)
 	}
@@ -37,78 +69,269 @@ func NewEncodeWriter(writer io.Writer) (*EncodeWriter, error) {
 	return e, nil
 }
 
-// Write writes a message to the output in version 2 binary format:
-// timestamp (8 bytes) + key size (8 bytes) + message size (8 bytes) + key (variable) + message data (variable)
-// If key is nil or empty, key size is written as 0
+// NewAppendEncodeWriter creates an encoder that continues an existing file at the append point found by
+// FindAppendPoint, with the compression of the file. The writer must be positioned at point.Size
+// No file header is written; the index (see Index) covers the existing entries as well
+func NewAppendEncodeWriter(writer io.Writer, point *AppendPoint) (*EncodeWriter, error) {
+	e, err := newEncodeWriter(writer, point.Compression)
+	if err != nil {
+		return nil, err
+	}
+	e.totalBytes = point.Size
+	if point.Index != nil {
+		e.index = indexBuilder{index: *point.Index.copy()}
+	}
+	return e, nil
+}
+
+// newEncodeWriter creates an encoder without writing the file header
+func newEncodeWriter(writer io.Writer, compression Compression) (*EncodeWriter, error) {
+	if !compression.valid() {
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+	e := &EncodeWriter{
+		output:           writer,
+		writer:           writer,
+		compression:      compression,
+		timestampBuf:     make([]byte, TimestampSize),
+		timestampTypeBuf: make([]byte, TimestampTypeSize),
+		partitionBuf:     make([]byte, PartitionSize),
+		offsetBuf:        make([]byte, OffsetSize),
+		topicSizeBuf:     make([]byte, TopicSizeFieldSize),
+		keySizeBuf:       make([]byte, KeySizeFieldSize),
+		sizeBuf:          make([]byte, SizeFieldSize),
+		headersSizeBuf:   make([]byte, HeadersSizeFieldSize),
+	}
+	if compression != CompressionNone {
+		codec, err := newCompressor(compression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up %s compression: %w", compression, err)
+		}
+		e.codec = codec
+		e.writer = &e.block
+	}
+	return e, nil
+}
+
+// Write writes a message without headers, timestamp type and source information, see WriteEntry
 func (e *EncodeWriter) Write(timestamp time.Time, messageData []byte, key []byte) (int64, error) {
-	messageSize := int64(len(messageData))
-	keySize := int64(len(key))
-	if key == nil {
-		keySize = 0
+	return e.WriteEntry(&Entry{
+		Timestamp:     timestamp,
+		TimestampType: TimestampTypeUnknown,
+		Key:           key,
+		Data:          messageData,
+		Partition:     -1,
+		Offset:        -1,
+	})
+}
+
+// WriteEntry writes a message to the output in version 3 binary format:
+// timestamp (8 bytes) + timestamp type (1 byte) + partition (4 bytes) + offset (8 bytes) + topic size (8 bytes) +
+// key size (8 bytes) + message size (8 bytes) + headers size (8 bytes) +
+// topic (variable) + key (variable) + headers (variable) + message data (variable)
+// If the key is nil or empty, key size is written as 0. If there are no headers, headers size is written as 0
+// Unknown partitions and offsets are written as -1, an unknown topic as an empty topic
+// With compression, the entry is added to the current block, which is written once it is full
+// The returned size is the uncompressed size of the entry
+func (e *EncodeWriter) WriteEntry(entry *Entry) (int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Where the entry can be read from: uncompressed entries from where they start, compressed entries only from
+	// the start of their frame. It is indexed once the entry is written
+	position, newFrame := e.totalBytes, false
+	if e.compression != CompressionNone {
+		if newFrame = e.block.Len() == 0; !newFrame {
+			position = -1
+		}
 	}
 
-	// Write timestamp (fixed size: 8 bytes Unix timestamp, big-endian)
-	unixTimestamp := timestamp.Unix()
+	topicSize := int64(len(entry.Topic))
+	messageSize := int64(len(entry.Data))
+	keySize := int64(len(entry.Key))
+	headersSize := headersBlockSize(entry.Headers)
+
+	// Write timestamp (fixed size: 8 bytes Unix timestamp in milliseconds, big-endian)
+	unixTimestamp := entry.Timestamp.UnixMilli()
 	binary.BigEndian.PutUint64(e.timestampBuf, uint64(unixTimestamp))
 	if _, err := e.writer.Write(e.timestampBuf); err != nil {
 		return 0, err
 	}
+	bytesWritten := int64(TimestampSize)
+
+	// Write timestamp type (fixed size: 1 byte)
+	e.timestampTypeBuf[0] = byte(entry.TimestampType)
+	if _, err := e.writer.Write(e.timestampTypeBuf); err != nil {
+		return bytesWritten, err
+	}
+	bytesWritten += TimestampTypeSize
+
+	// Write source partition (fixed size: 4 bytes, big-endian)
+	binary.BigEndian.PutUint32(e.partitionBuf, uint32(int32(entry.Partition)))
+	if _, err := e.writer.Write(e.partitionBuf); err != nil {
+		return bytesWritten, err
+	}
+	bytesWritten += PartitionSize
+
+	// Write source offset (fixed size: 8 bytes, big-endian)
+	binary.BigEndian.PutUint64(e.offsetBuf, uint64(entry.Offset))
+	if _, err := e.writer.Write(e.offsetBuf); err != nil {
+		return bytesWritten, err
+	}
+	bytesWritten += OffsetSize
+
+	// Write topic size (fixed size: 8 bytes, big-endian)
+	binary.BigEndian.PutUint64(e.topicSizeBuf, uint64(topicSize))
+	if _, err := e.writer.Write(e.topicSizeBuf); err != nil {
+		return bytesWritten, err
+	}
+	bytesWritten += TopicSizeFieldSize
 
 	// Write key size (fixed size: 8 bytes, big-endian)
 	binary.BigEndian.PutUint64(e.keySizeBuf, uint64(keySize))
 	if _, err := e.writer.Write(e.keySizeBuf); err != nil {
-		return TimestampSize, err
+		return bytesWritten, err
 	}
+	bytesWritten += KeySizeFieldSize
 
 	// Write message size (fixed size: 8 bytes, big-endian)
 	binary.BigEndian.PutUint64(e.sizeBuf, uint64(messageSize))
 	if _, err := e.writer.Write(e.sizeBuf); err != nil {
-		return TimestampSize + KeySizeFieldSize, err
+		return bytesWritten, err
+	}
+	bytesWritten += SizeFieldSize
+
+	// Write headers size (fixed size: 8 bytes, big-endian)
+	binary.BigEndian.PutUint64(e.headersSizeBuf, uint64(headersSize))
+	if _, err := e.writer.Write(e.headersSizeBuf); err != nil {
+		return bytesWritten, err
+	}
+	bytesWritten += HeadersSizeFieldSize
+
+	// Write topic (if present)
+	if topicSize > 0 {
+		if _, err := io.WriteString(e.writer, entry.Topic); err != nil {
+			return bytesWritten, err
+		}
+		bytesWritten += topicSize
 	}
 
 	// Write key data (if present)
 	if keySize > 0 {
-		if _, err := e.writer.Write(key); err != nil {
-			return TimestampSize + KeySizeFieldSize + SizeFieldSize, err
+		if _, err := e.writer.Write(entry.Key); err != nil {
+			return bytesWritten, err
 		}
+		bytesWritten += keySize
+	}
+
+	// Write headers block (if present)
+	if headersSize > 0 {
+		e.headersBuf = encodeHeaders(e.headersBuf[:0], entry.Headers)
+		if _, err := e.writer.Write(e.headersBuf); err != nil {
+			return bytesWritten, err
+		}
+		bytesWritten += headersSize
 	}
 
 	// Write message data
-	if _, err := e.writer.Write(messageData); err != nil {
-		return TimestampSize + KeySizeFieldSize + SizeFieldSize + keySize, err
+	if _, err := e.writer.Write(entry.Data); err != nil {
+		return bytesWritten, err
 	}
+	bytesWritten += messageSize
+	e.index.add(position, newFrame, entry.Timestamp)
 
-	bytesWritten := TimestampSize + KeySizeFieldSize + SizeFieldSize + keySize + messageSize
-	e.totalBytes += bytesWritten
+	if e.compression == CompressionNone {
+		e.totalBytes += bytesWritten
+	} else if e.block.Len() >= CompressionBlockSize {
+		if err := e.flushBlock(); err != nil {
+			return bytesWritten, err
+		}
+	}
 
 	return bytesWritten, nil
 }
 
+// Flush compresses the buffered entries and writes them as a frame (does nothing without compression)
+func (e *EncodeWriter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.flushBlock()
+}
+
+// flushBlock writes the buffered entries as a frame:
+// compressed size (8 bytes) + uncompressed size (8 bytes) + compressed block (variable)
+func (e *EncodeWriter) flushBlock() error {
+	if e.compression == CompressionNone || e.block.Len() == 0 {
+		return nil
+	}
+
+	frame, err := e.codec.compress(append(e.frame[:0], make([]byte, FrameHeaderSize)...), e.block.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to compress block: %w", err)
+	}
+	binary.BigEndian.PutUint64(frame[0:FrameSizeFieldSize], uint64(len(frame)-FrameHeaderSize))
+	binary.BigEndian.PutUint64(frame[FrameSizeFieldSize:FrameHeaderSize], uint64(e.block.Len()))
+	e.frame = frame
+
+	if _, err := e.output.Write(frame); err != nil {
+		return err
+	}
+	e.totalBytes += int64(len(frame))
+	e.block.Reset()
+	return nil
+}
+
+// Index writes the buffered entries (with compression) and returns the index of the entries written so far,
+// see WriteIndex
+func (e *EncodeWriter) Index() (*Index, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.flushBlock(); err != nil {
+		return nil, err
+	}
+	return e.index.snapshot(e.totalBytes), nil
+}
+
 // TotalBytes returns the total number of bytes written so far (including header)
+// With compression, entries buffered for the current frame are not counted until it is written
 func (e *EncodeWriter) TotalBytes() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	return e.totalBytes
 }
 
-// Close closes the underlying writer if it implements io.Closer
+// Close writes the buffered entries (with compression), releases the compressor and closes the underlying writer
+// if it implements io.Closer
 func (e *EncodeWriter) Close() error {
-	if closer, ok := e.writer.(io.Closer); ok {
-		return closer.Close()
+	flushErr := e.Flush()
+	if e.codec != nil {
+		if err := e.codec.close(); err != nil && flushErr == nil {
+			flushErr = err
+		}
 	}
-	return nil
+	if closer, ok := e.output.(io.Closer); ok {
+		if err := closer.Close(); err != nil && flushErr == nil {
+			return err
+		}
+	}
+	return flushErr
 }
 
 // writeFileHeader writes the file header containing protocol version and reserved space
-// Always writes version 2 (current version)
+// Always writes the current version
 func (e *EncodeWriter) writeFileHeader() error {
 	headerBuf := make([]byte, HeaderSize)
 
-	// Write protocol version 2 (int32, big-endian)
+	// Write protocol version (int32, big-endian)
 	binary.BigEndian.PutUint32(headerBuf[0:HeaderVersionSize], uint32(ProtocolVersion))
 
-	// Reserved bytes are already zero-initialized
+	// Write compression codec (first reserved byte) and the sanitization flag and rule set, the other reserved
+	// bytes are already zero-initialized
+	headerBuf[HeaderVersionSize] = byte(e.compression)
+	encodeSanitization(headerBuf, e.sanitization)
 
 	// Write header
-	if _, err := e.writer.Write(headerBuf); err != nil {
+	if _, err := e.output.Write(headerBuf); err != nil {
 		return err
 	}
 
@@ -3,6 +3,8 @@ package transcoder
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -39,6 +41,95 @@ func TestNewEncodeWriter(t *testing.T) {
 	}
 }
 
+func TestNewCompressedEncodeWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder, err := NewCompressedEncodeWriter(buf, CompressionZstd)
+	if err != nil {
+		t.Fatalf("NewCompressedEncodeWriter failed: %v", err)
+	}
+
+	// The codec is stored in the first reserved byte
+	header := buf.Bytes()[:HeaderSize]
+	if Compression(header[HeaderVersionSize]) != CompressionZstd {
+		t.Errorf("Compression mismatch: expected %d, got %d", CompressionZstd, header[HeaderVersionSize])
+	}
+
+	// Entries are buffered until the block is flushed
+	if _, err := encoder.Write(time.Now(), []byte("test message"), nil); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if buf.Len() != HeaderSize {
+		t.Errorf("Expected only the header before flushing, got %d bytes", buf.Len())
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if buf.Len() <= HeaderSize+FrameHeaderSize {
+		t.Errorf("Expected a frame after flushing, got %d bytes", buf.Len())
+	}
+	if encoder.TotalBytes() != int64(buf.Len()) {
+		t.Errorf("Total bytes mismatch: expected %d, got %d", buf.Len(), encoder.TotalBytes())
+	}
+
+	if _, err := NewCompressedEncodeWriter(&bytes.Buffer{}, Compression(42)); err == nil {
+		t.Error("Expected error for unsupported compression")
+	}
+}
+
+func TestNewSanitizedEncodeWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sanitization := &Sanitization{RuleSet: RuleSet{1, 2, 3, 4, 5, 6, 7, 8}}
+	encoder, err := NewSanitizedEncodeWriter(buf, CompressionGzip, sanitization)
+	if err != nil {
+		t.Fatalf("NewSanitizedEncodeWriter failed: %v", err)
+	}
+	if _, err := encoder.Write(time.Now(), []byte("masked"), nil); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The flag and the rule set follow the codec, the rest of the header stays reserved
+	header := buf.Bytes()[:HeaderSize]
+	if header[HeaderVersionSize+HeaderCompressionSize] != HeaderFlagSanitized {
+		t.Errorf("Flags mismatch: expected %d, got %d", HeaderFlagSanitized, header[HeaderVersionSize+HeaderCompressionSize])
+	}
+	for _, b := range header[HeaderVersionSize+HeaderCompressionSize+HeaderFlagsSize+HeaderRuleSetSize:] {
+		if b != 0 {
+			t.Errorf("Reserved bytes should be zero: %v", header)
+			break
+		}
+	}
+
+	decoder, err := NewDecodeReader(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+	if got := decoder.Sanitization(); got == nil || got.RuleSet != sanitization.RuleSet {
+		t.Errorf("Sanitization mismatch: expected %v, got %v", sanitization, got)
+	}
+	if decoder.Compression() != CompressionGzip {
+		t.Errorf("Compression mismatch: expected %s, got %s", CompressionGzip, decoder.Compression())
+	}
+	if entry, err := decoder.Read(); err != nil || string(entry.Data) != "masked" {
+		t.Errorf("Read = %v, %v", entry, err)
+	}
+
+	// Files without the flag are not sanitized
+	plain := &bytes.Buffer{}
+	if _, err := NewEncodeWriter(plain); err != nil {
+		t.Fatalf("NewEncodeWriter failed: %v", err)
+	}
+	decoder, err = NewDecodeReader(bytes.NewReader(plain.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+	if decoder.Sanitization() != nil {
+		t.Errorf("Expected no sanitization, got %v", decoder.Sanitization())
+	}
+}
+
 func TestEncodeWriter_Write(t *testing.T) {
 	buf := &bytes.Buffer{}
 	encoder, err := NewEncodeWriter(buf)
@@ -54,7 +145,7 @@ func TestEncodeWriter_Write(t *testing.T) {
 		t.Fatalf("Write failed: %v", err)
 	}
 
-	expectedBytes := int64(TimestampSize + KeySizeFieldSize + SizeFieldSize + len(testData))
+	expectedBytes := int64(TimestampSize + TimestampTypeSize + PartitionSize + OffsetSize + TopicSizeFieldSize + KeySizeFieldSize + SizeFieldSize + HeadersSizeFieldSize + len(testData))
 	if bytesWritten != expectedBytes {
 		t.Errorf("Expected %d bytes written, got %d", expectedBytes, bytesWritten)
 	}
@@ -69,14 +160,39 @@ func TestEncodeWriter_Write(t *testing.T) {
 	allData := buf.Bytes()
 	offset := HeaderSize
 
-	// Check timestamp
+	// Check timestamp (Unix milliseconds)
 	timestampBytes := allData[offset : offset+TimestampSize]
 	unixTimestamp := int64(binary.BigEndian.Uint64(timestampBytes))
-	if unixTimestamp != testTime.Unix() {
-		t.Errorf("Timestamp mismatch: expected %d, got %d", testTime.Unix(), unixTimestamp)
+	if unixTimestamp != testTime.UnixMilli() {
+		t.Errorf("Timestamp mismatch: expected %d, got %d", testTime.UnixMilli(), unixTimestamp)
 	}
 	offset += TimestampSize
 
+	// Check timestamp type (should be -1 when unknown)
+	if timestampType := int8(allData[offset]); timestampType != int8(TimestampTypeUnknown) {
+		t.Errorf("Timestamp type mismatch: expected %d, got %d", TimestampTypeUnknown, timestampType)
+	}
+	offset += TimestampTypeSize
+
+	// Check partition and offset (should be -1 when the source is unknown)
+	partition := int32(binary.BigEndian.Uint32(allData[offset : offset+PartitionSize]))
+	if partition != -1 {
+		t.Errorf("Partition mismatch: expected -1, got %d", partition)
+	}
+	offset += PartitionSize
+	sourceOffset := int64(binary.BigEndian.Uint64(allData[offset : offset+OffsetSize]))
+	if sourceOffset != -1 {
+		t.Errorf("Offset mismatch: expected -1, got %d", sourceOffset)
+	}
+	offset += OffsetSize
+
+	// Check topic size (should be 0 when the topic is unknown)
+	topicSize := int64(binary.BigEndian.Uint64(allData[offset : offset+TopicSizeFieldSize]))
+	if topicSize != 0 {
+		t.Errorf("Topic size mismatch: expected 0, got %d", topicSize)
+	}
+	offset += TopicSizeFieldSize
+
 	// Check key size (should be 0 for nil key)
 	keySizeBytes := allData[offset : offset+KeySizeFieldSize]
 	keySize := int64(binary.BigEndian.Uint64(keySizeBytes))
@@ -93,6 +209,14 @@ func TestEncodeWriter_Write(t *testing.T) {
 	}
 	offset += SizeFieldSize
 
+	// Check headers size (should be 0 when there are no headers)
+	headersSizeBytes := allData[offset : offset+HeadersSizeFieldSize]
+	headersSize := int64(binary.BigEndian.Uint64(headersSizeBytes))
+	if headersSize != 0 {
+		t.Errorf("Headers size mismatch: expected 0, got %d", headersSize)
+	}
+	offset += HeadersSizeFieldSize
+
 	// Check data
 	dataBytes := allData[offset : offset+len(testData)]
 	if !bytes.Equal(dataBytes, testData) {
@@ -137,10 +261,10 @@ func TestEncodeWriter_MultipleWrites(t *testing.T) {
 		// Read timestamp
 		timestampBytes := allData[offset : offset+TimestampSize]
 		unixTimestamp := int64(binary.BigEndian.Uint64(timestampBytes))
-		if unixTimestamp != msg.timestamp.Unix() {
-			t.Errorf("Message %d timestamp mismatch: expected %d, got %d", i, msg.timestamp.Unix(), unixTimestamp)
+		if unixTimestamp != msg.timestamp.UnixMilli() {
+			t.Errorf("Message %d timestamp mismatch: expected %d, got %d", i, msg.timestamp.UnixMilli(), unixTimestamp)
 		}
-		offset += TimestampSize
+		offset += TimestampSize + TimestampTypeSize + PartitionSize + OffsetSize + TopicSizeFieldSize
 
 		// Read key size (should be 0 for nil key)
 		keySizeBytes := allData[offset : offset+KeySizeFieldSize]
@@ -156,7 +280,7 @@ func TestEncodeWriter_MultipleWrites(t *testing.T) {
 		if size != int64(len(msg.data)) {
 			t.Errorf("Message %d size mismatch: expected %d, got %d", i, len(msg.data), size)
 		}
-		offset += SizeFieldSize
+		offset += SizeFieldSize + HeadersSizeFieldSize
 
 		// Read data
 		dataBytes := allData[offset : offset+len(msg.data)]
@@ -182,14 +306,14 @@ func TestEncodeWriter_EmptyMessage(t *testing.T) {
 		t.Fatalf("Write failed: %v", err)
 	}
 
-	expectedBytes := int64(TimestampSize + KeySizeFieldSize + SizeFieldSize)
+	expectedBytes := int64(TimestampSize + TimestampTypeSize + PartitionSize + OffsetSize + TopicSizeFieldSize + KeySizeFieldSize + SizeFieldSize + HeadersSizeFieldSize)
 	if bytesWritten != expectedBytes {
 		t.Errorf("Expected %d bytes written, got %d", expectedBytes, bytesWritten)
 	}
 
 	// Verify size field is 0
 	allData := buf.Bytes()
-	offset := HeaderSize + TimestampSize + KeySizeFieldSize
+	offset := HeaderSize + TimestampSize + TimestampTypeSize + PartitionSize + OffsetSize + TopicSizeFieldSize + KeySizeFieldSize
 	sizeBytes := allData[offset : offset+SizeFieldSize]
 	size := int64(binary.BigEndian.Uint64(sizeBytes))
 	if size != 0 {
@@ -215,8 +339,156 @@ func TestEncodeWriter_LargeMessage(t *testing.T) {
 		t.Fatalf("Write failed: %v", err)
 	}
 
-	expectedBytes := TimestampSize + KeySizeFieldSize + SizeFieldSize + int64(len(largeData))
+	expectedBytes := TimestampSize + TimestampTypeSize + PartitionSize + OffsetSize + TopicSizeFieldSize + KeySizeFieldSize + SizeFieldSize + HeadersSizeFieldSize + int64(len(largeData))
+	if bytesWritten != expectedBytes {
+		t.Errorf("Expected %d bytes written, got %d", expectedBytes, bytesWritten)
+	}
+}
+
+func TestEncodeWriter_WriteEntryWithHeaders(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder, err := NewEncodeWriter(buf)
+	if err != nil {
+		t.Fatalf("NewEncodeWriter failed: %v", err)
+	}
+
+	entry := &Entry{
+		Timestamp: time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC),
+		Key:       []byte("user-123"),
+		Data:      []byte("Hello, World!"),
+		Headers: []Header{
+			{Key: "trace-id", Value: []byte("abc")},
+			{Key: "empty", Value: nil},
+		},
+	}
+
+	bytesWritten, err := encoder.WriteEntry(entry)
+	if err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+
+	// count (4) + "trace-id" (4+8) + "abc" (4+3) + "empty" (4+5) + nil value (4+0)
+	expectedHeadersSize := int64(HeaderCountSize + 4 + 8 + 4 + 3 + 4 + 5 + 4)
+	expectedBytes := TimestampSize + TimestampTypeSize + PartitionSize + OffsetSize + TopicSizeFieldSize + KeySizeFieldSize + SizeFieldSize + HeadersSizeFieldSize +
+		int64(len(entry.Key)) + expectedHeadersSize + int64(len(entry.Data))
 	if bytesWritten != expectedBytes {
 		t.Errorf("Expected %d bytes written, got %d", expectedBytes, bytesWritten)
 	}
+
+	allData := buf.Bytes()
+	offset := HeaderSize + TimestampSize + TimestampTypeSize + PartitionSize + OffsetSize + TopicSizeFieldSize + KeySizeFieldSize + SizeFieldSize
+	headersSize := int64(binary.BigEndian.Uint64(allData[offset : offset+HeadersSizeFieldSize]))
+	if headersSize != expectedHeadersSize {
+		t.Errorf("Headers size mismatch: expected %d, got %d", expectedHeadersSize, headersSize)
+	}
+	offset += HeadersSizeFieldSize + len(entry.Key)
+
+	// Check header count
+	count := binary.BigEndian.Uint32(allData[offset : offset+HeaderCountSize])
+	if count != 2 {
+		t.Errorf("Header count mismatch: expected 2, got %d", count)
+	}
+	offset += HeaderCountSize
+
+	// Check first header key
+	keySize := int(binary.BigEndian.Uint32(allData[offset : offset+HeaderKeySizeFieldSize]))
+	offset += HeaderKeySizeFieldSize
+	if got := string(allData[offset : offset+keySize]); got != "trace-id" {
+		t.Errorf("Header key mismatch: expected %q, got %q", "trace-id", got)
+	}
+
+	// Message data is written last
+	if !bytes.HasSuffix(allData, entry.Data) {
+		t.Errorf("Expected message data at the end of the entry")
+	}
+}
+
+func TestEncodeWriter_WriteEntryMillisecondTimestamp(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder, err := NewEncodeWriter(buf)
+	if err != nil {
+		t.Fatalf("NewEncodeWriter failed: %v", err)
+	}
+
+	entry := &Entry{
+		Timestamp:     time.Date(2024, 2, 2, 10, 15, 30, 123456789, time.UTC),
+		TimestampType: TimestampTypeLogAppendTime,
+		Data:          []byte("data"),
+		Partition:     -1,
+		Offset:        -1,
+	}
+	if _, err := encoder.WriteEntry(entry); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+
+	allData := buf.Bytes()
+	offset := HeaderSize
+	unixTimestamp := int64(binary.BigEndian.Uint64(allData[offset : offset+TimestampSize]))
+	if expected := int64(1706868930123); unixTimestamp != expected {
+		t.Errorf("Timestamp mismatch: expected %d, got %d", expected, unixTimestamp)
+	}
+	offset += TimestampSize
+	if timestampType := TimestampType(int8(allData[offset])); timestampType != TimestampTypeLogAppendTime {
+		t.Errorf("Timestamp type mismatch: expected %v, got %v", TimestampTypeLogAppendTime, timestampType)
+	}
+}
+
+func TestEncodeWriter_ConcurrentWrites(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder, err := NewEncodeWriter(buf)
+	if err != nil {
+		t.Fatalf("NewEncodeWriter failed: %v", err)
+	}
+
+	const writers = 8
+	const perWriter = 100
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(partition int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				entry := &Entry{
+					Timestamp: time.Unix(0, 0),
+					Key:       []byte(fmt.Sprintf("key-%d-%d", partition, i)),
+					Data:      bytes.Repeat([]byte{byte(partition)}, 64+i),
+					Topic:     "orders",
+					Partition: partition,
+					Offset:    int64(i),
+				}
+				if _, err := encoder.WriteEntry(entry); err != nil {
+					t.Errorf("WriteEntry failed: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if encoder.TotalBytes() != int64(buf.Len()) {
+		t.Errorf("Total bytes mismatch: expected %d, got %d", buf.Len(), encoder.TotalBytes())
+	}
+
+	// Every entry must decode intact and offsets must be in order per partition
+	decoder, err := NewDecodeReader(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+	nextOffset := make(map[int]int64)
+	for n := 0; n < writers*perWriter; n++ {
+		entry, err := decoder.Read()
+		if err != nil {
+			t.Fatalf("Read %d failed: %v", n, err)
+		}
+		if entry.Offset != nextOffset[entry.Partition] {
+			t.Fatalf("Partition %d: expected offset %d, got %d", entry.Partition, nextOffset[entry.Partition], entry.Offset)
+		}
+		if expected := fmt.Sprintf("key-%d-%d", entry.Partition, entry.Offset); string(entry.Key) != expected {
+			t.Fatalf("Key mismatch: expected %q, got %q", expected, entry.Key)
+		}
+		if !bytes.Equal(entry.Data, bytes.Repeat([]byte{byte(entry.Partition)}, 64+int(entry.Offset))) {
+			t.Fatalf("Data mismatch for partition %d offset %d", entry.Partition, entry.Offset)
+		}
+		nextOffset[entry.Partition]++
+	}
 }
@@ -0,0 +1,148 @@
+package transcoder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// TimestampType tells whether a message timestamp was set by the producer or by the broker
+type TimestampType int8
+
+const (
+	// TimestampTypeUnknown is used when the timestamp type was not recorded (files older than version 3)
+	TimestampTypeUnknown TimestampType = -1
+	// TimestampTypeCreateTime is a timestamp set by the producer when the message was created
+	TimestampTypeCreateTime TimestampType = 0
+	// TimestampTypeLogAppendTime is a timestamp set by the broker when the message was appended to the log
+	TimestampTypeLogAppendTime TimestampType = 1
+)
+
+// String returns the Kafka name of the timestamp type, or an empty string if it is unknown
+func (t TimestampType) String() string {
+	switch t {
+	case TimestampTypeCreateTime:
+		return "CreateTime"
+	case TimestampTypeLogAppendTime:
+		return "LogAppendTime"
+	default:
+		return ""
+	}
+}
+
+// ParseTimestampType parses a Kafka timestamp type name ("CreateTime" or "LogAppendTime")
+func ParseTimestampType(name string) (TimestampType, error) {
+	switch name {
+	case "CreateTime":
+		return TimestampTypeCreateTime, nil
+	case "LogAppendTime":
+		return TimestampTypeLogAppendTime, nil
+	default:
+		return TimestampTypeUnknown, fmt.Errorf("unknown timestamp type: %q", name)
+	}
+}
+
+// Entry is a single recorded message
+type Entry struct {
+	Timestamp     time.Time
+	TimestampType TimestampType // CreateTime or LogAppendTime (unknown for files older than version 3)
+	Key           []byte
+	Data          []byte
+	Headers       []Header // Kafka message headers (nil for version 1 and 2 files)
+	Topic         string   // Source topic ("" when unknown, e.g. for files older than version 3)
+	Partition     int      // Source partition (-1 when unknown)
+	Offset        int64    // Source offset (-1 when unknown)
+}
+
+// HasSource reports whether the entry carries its source partition and offset
+func (e *Entry) HasSource() bool {
+	return e.Partition >= 0 && e.Offset >= 0
+}
+
+// Header is a Kafka message header (key/value pair)
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// headersBlockSize returns the encoded size of the headers block, or 0 if there are no headers
+func headersBlockSize(headers []Header) int64 {
+	if len(headers) == 0 {
+		return 0
+	}
+	size := int64(HeaderCountSize)
+	for _, h := range headers {
+		size += HeaderKeySizeFieldSize + int64(len(h.Key)) + HeaderValueSizeFieldSize + int64(len(h.Value))
+	}
+	return size
+}
+
+// encodeHeaders appends the headers block to buf:
+// header count (4 bytes) + for each header: key size (4 bytes) + key + value size (4 bytes) + value
+func encodeHeaders(buf []byte, headers []Header) []byte {
+	if len(headers) == 0 {
+		return buf
+	}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(headers)))
+	for _, h := range headers {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(h.Key)))
+		buf = append(buf, h.Key...)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(h.Value)))
+		buf = append(buf, h.Value...)
+	}
+	return buf
+}
+
+// decodeHeaders parses a headers block written by encodeHeaders
+func decodeHeaders(block []byte) ([]Header, error) {
+	if len(block) == 0 {
+		return nil, nil
+	}
+	if len(block) < HeaderCountSize {
+		return nil, fmt.Errorf("headers block too short: %d bytes", len(block))
+	}
+	count := int(binary.BigEndian.Uint32(block[:HeaderCountSize]))
+	block = block[HeaderCountSize:]
+
+	// Each header needs at least its two size fields, which bounds the count
+	if count < 0 || count > len(block)/(HeaderKeySizeFieldSize+HeaderValueSizeFieldSize) {
+		return nil, fmt.Errorf("invalid header count: %d", count)
+	}
+
+	headers := make([]Header, 0, count)
+	for i := 0; i < count; i++ {
+		key, rest, err := readHeaderField(block, HeaderKeySizeFieldSize)
+		if err != nil {
+			return nil, fmt.Errorf("header %d key: %w", i, err)
+		}
+		value, rest, err := readHeaderField(rest, HeaderValueSizeFieldSize)
+		if err != nil {
+			return nil, fmt.Errorf("header %d value: %w", i, err)
+		}
+		block = rest
+
+		var v []byte
+		if len(value) > 0 {
+			v = make([]byte, len(value))
+			copy(v, value)
+		}
+		headers = append(headers, Header{Key: string(key), Value: v})
+	}
+	if len(block) != 0 {
+		return nil, fmt.Errorf("unexpected %d trailing bytes in headers block", len(block))
+	}
+	return headers, nil
+}
+
+// readHeaderField reads a size-prefixed field and returns it along with the remaining bytes
+func readHeaderField(block []byte, sizeFieldSize int) ([]byte, []byte, error) {
+	if len(block) < sizeFieldSize {
+		return nil, nil, fmt.Errorf("truncated size field")
+	}
+	size := int(binary.BigEndian.Uint32(block[:sizeFieldSize]))
+	block = block[sizeFieldSize:]
+	if size < 0 || size > len(block) {
+		return nil, nil, fmt.Errorf("invalid size: %d bytes", size)
+	}
+	return block[:size], block[size:], nil
+}
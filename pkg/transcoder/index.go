@@ -0,0 +1,195 @@
+package transcoder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	// IndexFileSuffix is appended to the path of a recording to get the path of its index (sidecar file)
+	IndexFileSuffix = ".idx"
+	// IndexMagic identifies index files
+	IndexMagic = "KRIX"
+	// IndexVersion is the current version of the index file format
+	IndexVersion = 1
+	// IndexInterval is the number of entries between two index points of an uncompressed recording
+	// Compressed recordings get an index point at the start of every frame
+	IndexInterval = 1000
+	// IndexHeaderSize is the size of the index file header:
+	// magic (4 bytes) + version (4 bytes) + recording size (8 bytes) + entry count (8 bytes) + point count (8 bytes)
+	IndexHeaderSize = 32
+	// IndexPointSize is the size of an index point: entry number (8 bytes) + position (8 bytes) + max timestamp (8 bytes)
+	IndexPointSize = 24
+)
+
+// IndexPoint is a position in a recording where reading can start
+type IndexPoint struct {
+	// Entry is the number of the entry at Position (0-based)
+	Entry int64
+	// Position is the byte offset of the entry in the recording (of its frame for compressed recordings)
+	Position int64
+	// MaxTimestamp is the latest timestamp (Unix milliseconds) of the entries from this point up to the next one
+	MaxTimestamp int64
+}
+
+// Index maps entry numbers and timestamps to byte offsets in a recording, so that readers can start
+// at an entry or a time without reading the recording from the start
+type Index struct {
+	// RecordingSize is the size in bytes of the recording the index was built for
+	// An index whose recording size differs from the size of the recording is stale
+	RecordingSize int64
+	// Entries is the number of entries in the recording
+	Entries int64
+	// Points are the index points in ascending order of entry number and position
+	Points []IndexPoint
+}
+
+// IndexPath returns the path of the index (sidecar file) of the recording at path
+func IndexPath(path string) string {
+	return path + IndexFileSuffix
+}
+
+// pointForEntry returns the last index point at or before entry n
+func (idx *Index) pointForEntry(n int64, start IndexPoint) IndexPoint {
+	if idx == nil {
+		return start
+	}
+	i := sort.Search(len(idx.Points), func(i int) bool { return idx.Points[i].Entry > n })
+	if i == 0 {
+		return start
+	}
+	return idx.Points[i-1]
+}
+
+// pointForTime returns the first index point followed by an entry with a timestamp at or after t
+// (the last index point if there is none)
+func (idx *Index) pointForTime(t time.Time, start IndexPoint) IndexPoint {
+	if idx == nil || len(idx.Points) == 0 {
+		return start
+	}
+	millis := t.UnixMilli()
+	for _, p := range idx.Points {
+		if p.MaxTimestamp >= millis {
+			return p
+		}
+	}
+	return idx.Points[len(idx.Points)-1]
+}
+
+// indexBuilder builds the index of a recording while its entries are written or read
+type indexBuilder struct {
+	index Index
+}
+
+// add records an entry. position is the byte offset where reading can start at this entry,
+// or -1 if it cannot (the entry is inside a compressed frame)
+// newPoint forces an index point (at the start of every compressed frame)
+func (b *indexBuilder) add(position int64, newPoint bool, timestamp time.Time) {
+	points := b.index.Points
+	if position >= 0 && (newPoint || len(points) == 0 || b.index.Entries-points[len(points)-1].Entry >= IndexInterval) {
+		b.index.Points = append(b.index.Points, IndexPoint{Entry: b.index.Entries, Position: position, MaxTimestamp: timestamp.UnixMilli()})
+	}
+	if last := len(b.index.Points) - 1; last >= 0 && timestamp.UnixMilli() > b.index.Points[last].MaxTimestamp {
+		b.index.Points[last].MaxTimestamp = timestamp.UnixMilli()
+	}
+	b.index.Entries++
+}
+
+// snapshot returns a copy of the index built so far for a recording of the given size
+func (b *indexBuilder) snapshot(recordingSize int64) *Index {
+	idx := b.index.copy()
+	idx.RecordingSize = recordingSize
+	return idx
+}
+
+// copy returns a copy of the index that does not share its points
+func (idx *Index) copy() *Index {
+	points := make([]IndexPoint, len(idx.Points))
+	copy(points, idx.Points)
+	return &Index{RecordingSize: idx.RecordingSize, Entries: idx.Entries, Points: points}
+}
+
+// BuildIndex reads a recording from the start and returns its index
+// Recordings in all format versions are supported
+func BuildIndex(reader io.ReadSeeker) (*Index, error) {
+	decoder, err := NewDecodeReader(reader, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var builder indexBuilder
+	for {
+		position, newFrame := decoder.entryPosition()
+		entry, err := decoder.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read entry %d: %w", builder.index.Entries, err)
+		}
+		builder.add(position, newFrame, entry.Timestamp)
+	}
+
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	return builder.snapshot(size), nil
+}
+
+// WriteIndex writes an index in the index file format
+func WriteIndex(w io.Writer, idx *Index) error {
+	buf := make([]byte, IndexHeaderSize, IndexHeaderSize+len(idx.Points)*IndexPointSize)
+	copy(buf[0:4], IndexMagic)
+	binary.BigEndian.PutUint32(buf[4:8], uint32(IndexVersion))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(idx.RecordingSize))
+	binary.BigEndian.PutUint64(buf[16:24], uint64(idx.Entries))
+	binary.BigEndian.PutUint64(buf[24:32], uint64(len(idx.Points)))
+	for _, p := range idx.Points {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(p.Entry))
+		buf = binary.BigEndian.AppendUint64(buf, uint64(p.Position))
+		buf = binary.BigEndian.AppendUint64(buf, uint64(p.MaxTimestamp))
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadIndex reads an index written by WriteIndex
+func ReadIndex(r io.Reader) (*Index, error) {
+	header := make([]byte, IndexHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read index header: %w", err)
+	}
+	if string(header[0:4]) != IndexMagic {
+		return nil, fmt.Errorf("not an index file")
+	}
+	if version := int32(binary.BigEndian.Uint32(header[4:8])); version != IndexVersion {
+		return nil, fmt.Errorf("unsupported index version: %d (supported version: %d)", version, IndexVersion)
+	}
+
+	idx := &Index{
+		RecordingSize: int64(binary.BigEndian.Uint64(header[8:16])),
+		Entries:       int64(binary.BigEndian.Uint64(header[16:24])),
+	}
+	count := int64(binary.BigEndian.Uint64(header[24:32]))
+	if count < 0 || count > idx.Entries {
+		return nil, fmt.Errorf("invalid index point count: %d", count)
+	}
+
+	pointBuf := make([]byte, IndexPointSize)
+	idx.Points = make([]IndexPoint, 0, count)
+	for i := int64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, pointBuf); err != nil {
+			return nil, fmt.Errorf("failed to read index point %d: %w", i, err)
+		}
+		idx.Points = append(idx.Points, IndexPoint{
+			Entry:        int64(binary.BigEndian.Uint64(pointBuf[0:8])),
+			Position:     int64(binary.BigEndian.Uint64(pointBuf[8:16])),
+			MaxTimestamp: int64(binary.BigEndian.Uint64(pointBuf[16:24])),
+		})
+	}
+	return idx, nil
+}
@@ -0,0 +1,220 @@
+package transcoder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeIndexedRecording writes count entries with the given compression and returns the recording and the
+// index built by the encoder. Entry i has offset i; timestamps go back one hour every 1500 entries to
+// simulate several partitions recorded into one file
+func writeIndexedRecording(t *testing.T, compression Compression, count int) ([]byte, *Index) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	encoder, err := NewCompressedEncodeWriter(buf, compression)
+	if err != nil {
+		t.Fatalf("NewCompressedEncodeWriter failed: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		entry := &Entry{
+			Timestamp: indexTestTime(i),
+			Data:      bytes.Repeat([]byte(fmt.Sprintf("message-%d;", i)), 50),
+			Partition: 0,
+			Offset:    int64(i),
+		}
+		if _, err := encoder.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry %d failed: %v", i, err)
+		}
+	}
+	index, err := encoder.Index()
+	if err != nil {
+		t.Fatalf("Index failed: %v", err)
+	}
+	return buf.Bytes(), index
+}
+
+func indexTestTime(i int) time.Time {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return base.Add(time.Duration(i%1500)*time.Second - time.Duration(i/1500)*time.Hour)
+}
+
+func TestEncodeWriter_IndexMatchesBuildIndex(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionZstd} {
+		t.Run(compression.String(), func(t *testing.T) {
+			recording, index := writeIndexedRecording(t, compression, 4000)
+			if index.Entries != 4000 {
+				t.Errorf("Entries mismatch: expected 4000, got %d", index.Entries)
+			}
+			if index.RecordingSize != int64(len(recording)) {
+				t.Errorf("Recording size mismatch: expected %d, got %d", len(recording), index.RecordingSize)
+			}
+			if len(index.Points) < 2 {
+				t.Fatalf("Expected several index points, got %d", len(index.Points))
+			}
+
+			built, err := BuildIndex(bytes.NewReader(recording))
+			if err != nil {
+				t.Fatalf("BuildIndex failed: %v", err)
+			}
+			if !reflect.DeepEqual(built, index) {
+				t.Errorf("BuildIndex mismatch:\nencoder: %+v\nbuilt:   %+v", index, built)
+			}
+		})
+	}
+}
+
+// limitedWriter fails once more than n bytes have been written
+type limitedWriter struct {
+	buf bytes.Buffer
+	n   int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > w.n {
+		return 0, io.ErrShortWrite
+	}
+	return w.buf.Write(p)
+}
+
+// TestEncodeWriter_IndexFailedWrite tests that an entry that could not be written is not indexed
+func TestEncodeWriter_IndexFailedWrite(t *testing.T) {
+	entrySize := TimestampSize + TimestampTypeSize + PartitionSize + OffsetSize + TopicSizeFieldSize + KeySizeFieldSize +
+		SizeFieldSize + HeadersSizeFieldSize + len("data")
+	output := &limitedWriter{n: HeaderSize + 2*entrySize + 10} // The third entry fails part way
+	encoder, err := NewEncodeWriter(output)
+	if err != nil {
+		t.Fatalf("NewEncodeWriter failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err := encoder.WriteEntry(&Entry{Timestamp: indexTestTime(i), Data: []byte("data"), Partition: 0, Offset: int64(i)})
+		if (err != nil) != (i == 2) {
+			t.Fatalf("WriteEntry %d: unexpected error %v", i, err)
+		}
+	}
+	index, err := encoder.Index()
+	if err != nil {
+		t.Fatalf("Index failed: %v", err)
+	}
+	if index.Entries != 2 || len(index.Points) != 1 {
+		t.Errorf("Index of the written entries mismatch: %d entries, %d points", index.Entries, len(index.Points))
+	}
+}
+
+func TestDecodeReader_SeekEntry(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionSnappy} {
+		recording, index := writeIndexedRecording(t, compression, 4000)
+		for _, idx := range []*Index{nil, index} {
+			decoder, err := NewDecodeReader(bytes.NewReader(recording), true)
+			if err != nil {
+				t.Fatalf("NewDecodeReader failed: %v", err)
+			}
+			for _, n := range []int64{3500, 0, 1001, 2999} {
+				if err := decoder.SeekEntry(n, idx); err != nil {
+					t.Fatalf("%s: SeekEntry(%d) failed: %v", compression, n, err)
+				}
+				if decoder.EntryNumber() != n {
+					t.Errorf("%s: EntryNumber after SeekEntry(%d) is %d", compression, n, decoder.EntryNumber())
+				}
+				entry, err := decoder.Read()
+				if err != nil {
+					t.Fatalf("%s: Read after SeekEntry(%d) failed: %v", compression, n, err)
+				}
+				if entry.Offset != n {
+					t.Errorf("%s: SeekEntry(%d) with index %v: read entry %d", compression, n, idx != nil, entry.Offset)
+				}
+			}
+
+			// Past the last entry
+			if err := decoder.SeekEntry(5000, idx); err != nil {
+				t.Fatalf("%s: SeekEntry past the end failed: %v", compression, err)
+			}
+			if _, err := decoder.Read(); err != io.EOF {
+				t.Errorf("%s: expected EOF after SeekEntry past the end, got %v", compression, err)
+			}
+		}
+	}
+}
+
+func TestDecodeReader_SeekTime(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionGzip} {
+		recording, index := writeIndexedRecording(t, compression, 4000)
+		for _, idx := range []*Index{nil, index} {
+			decoder, err := NewDecodeReader(bytes.NewReader(recording), false)
+			if err != nil {
+				t.Fatalf("NewDecodeReader failed: %v", err)
+			}
+
+			// Entries 0..1499 are 12:00:00..12:24:59, 1500..2999 an hour earlier, 3000..3999 two hours earlier
+			// The first entry at or after 11:10:00 is entry 1500+600
+			target := time.Date(2024, 1, 1, 11, 10, 0, 0, time.UTC)
+			if err := decoder.SeekTime(target, idx); err != nil {
+				t.Fatalf("%s: SeekTime failed: %v", compression, err)
+			}
+			if decoder.EntryNumber() != 0 && decoder.EntryNumber() != 2100 {
+				t.Errorf("%s: EntryNumber after SeekTime is %d", compression, decoder.EntryNumber())
+			}
+			entry, err := decoder.Read()
+			if err != nil {
+				t.Fatalf("%s: Read after SeekTime failed: %v", compression, err)
+			}
+			if entry.Offset != 0 {
+				t.Errorf("%s: SeekTime with index %v: read entry %d, expected 0 (12:00 is after 11:10)", compression, idx != nil, entry.Offset)
+			}
+
+			// 12:10:00 first appears at entry 600
+			target = time.Date(2024, 1, 1, 12, 10, 0, 0, time.UTC)
+			if err := decoder.SeekTime(target, idx); err != nil {
+				t.Fatalf("%s: SeekTime failed: %v", compression, err)
+			}
+			if entry, err = decoder.Read(); err != nil {
+				t.Fatalf("%s: Read after SeekTime failed: %v", compression, err)
+			}
+			if entry.Offset != 600 {
+				t.Errorf("%s: SeekTime with index %v: read entry %d, expected 600", compression, idx != nil, entry.Offset)
+			}
+			// Timestamps are not preserved, the recorded one is only used to seek
+			if entry.Timestamp.Year() == 2024 {
+				t.Errorf("%s: expected the current time as timestamp, got %v", compression, entry.Timestamp)
+			}
+			if entry, err = decoder.Read(); err != nil || entry.Offset != 601 {
+				t.Errorf("%s: expected entry 601 after entry 600, got %v (%v)", compression, entry, err)
+			}
+
+			// Past the last timestamp
+			if err := decoder.SeekTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), idx); err != nil {
+				t.Fatalf("%s: SeekTime past the end failed: %v", compression, err)
+			}
+			if _, err := decoder.Read(); err != io.EOF {
+				t.Errorf("%s: expected EOF after SeekTime past the end, got %v", compression, err)
+			}
+		}
+	}
+}
+
+func TestWriteIndexReadIndex(t *testing.T) {
+	_, index := writeIndexedRecording(t, CompressionNone, 2500)
+
+	buf := &bytes.Buffer{}
+	if err := WriteIndex(buf, index); err != nil {
+		t.Fatalf("WriteIndex failed: %v", err)
+	}
+	if buf.Len() != IndexHeaderSize+len(index.Points)*IndexPointSize {
+		t.Errorf("Index size mismatch: expected %d, got %d", IndexHeaderSize+len(index.Points)*IndexPointSize, buf.Len())
+	}
+
+	read, err := ReadIndex(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadIndex failed: %v", err)
+	}
+	if !reflect.DeepEqual(read, index) {
+		t.Errorf("Index mismatch:\nwritten: %+v\nread:    %+v", index, read)
+	}
+
+	if _, err := ReadIndex(bytes.NewReader([]byte("not an index file at all, really"))); err == nil {
+		t.Error("Expected error for invalid index file")
+	}
+}
@@ -2,6 +2,7 @@ package transcoder
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"testing"
 	"time"
@@ -332,3 +333,240 @@ func TestRoundTripLargeMessage(t *testing.T) {
 
 	decoder.Close()
 }
+
+// TestRoundTripKeysAndHeaders tests round-trip of entries with keys and headers
+func TestRoundTripKeysAndHeaders(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	encoder, err := NewEncodeWriter(buf)
+	if err != nil {
+		t.Fatalf("NewEncodeWriter failed: %v", err)
+	}
+
+	entries := []*Entry{
+		{
+			Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Key:       []byte("order-1"),
+			Data:      []byte(`{"id":1}`),
+			Headers: []Header{
+				{Key: "trace-id", Value: []byte("4bf92f3577b34da6")},
+				{Key: "schema-id", Value: []byte{0x00, 0x00, 0x00, 0x2a}},
+			},
+		},
+		{
+			Timestamp: time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC),
+			Data:      []byte("no key, no headers"),
+		},
+		{
+			Timestamp: time.Date(2024, 1, 1, 0, 0, 2, 0, time.UTC),
+			Data:      []byte("duplicate header keys"),
+			Headers: []Header{
+				{Key: "retry", Value: []byte("1")},
+				{Key: "retry", Value: []byte("2")},
+			},
+		},
+	}
+
+	for _, entry := range entries {
+		if _, err := encoder.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry failed: %v", err)
+		}
+	}
+	encoder.Close()
+
+	decoder, err := NewDecodeReader(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+
+	for i, expected := range entries {
+		entry, err := decoder.Read()
+		if err != nil {
+			t.Fatalf("Read %d failed: %v", i, err)
+		}
+
+		if !entry.Timestamp.Equal(expected.Timestamp) {
+			t.Errorf("Entry %d timestamp mismatch: expected %v, got %v", i, expected.Timestamp, entry.Timestamp)
+		}
+		if !bytes.Equal(entry.Key, expected.Key) {
+			t.Errorf("Entry %d key mismatch: expected %q, got %q", i, expected.Key, entry.Key)
+		}
+		if !bytes.Equal(entry.Data, expected.Data) {
+			t.Errorf("Entry %d data mismatch: expected %q, got %q", i, expected.Data, entry.Data)
+		}
+		if len(entry.Headers) != len(expected.Headers) {
+			t.Fatalf("Entry %d: expected %d headers, got %d", i, len(expected.Headers), len(entry.Headers))
+		}
+		for j, h := range expected.Headers {
+			if entry.Headers[j].Key != h.Key || !bytes.Equal(entry.Headers[j].Value, h.Value) {
+				t.Errorf("Entry %d header %d mismatch: expected %q=%q, got %q=%q", i, j, h.Key, h.Value, entry.Headers[j].Key, entry.Headers[j].Value)
+			}
+		}
+	}
+
+	if _, err := decoder.Read(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+
+	decoder.Close()
+}
+
+// TestRoundTripSource tests round-trip of the source topic, partition and offset
+func TestRoundTripSource(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	encoder, err := NewEncodeWriter(buf)
+	if err != nil {
+		t.Fatalf("NewEncodeWriter failed: %v", err)
+	}
+
+	entries := []*Entry{
+		{Timestamp: time.Unix(0, 0), Data: []byte("a"), Topic: "orders", Partition: 0, Offset: 0},
+		{Timestamp: time.Unix(0, 0), Data: []byte("b"), Topic: "payments.eu", Partition: 11, Offset: 9876543210},
+		{Timestamp: time.Unix(0, 0), Data: []byte("c"), Partition: -1, Offset: -1},
+	}
+
+	for _, entry := range entries {
+		if _, err := encoder.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry failed: %v", err)
+		}
+	}
+	encoder.Close()
+
+	decoder, err := NewDecodeReader(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+
+	for i, expected := range entries {
+		entry, err := decoder.Read()
+		if err != nil {
+			t.Fatalf("Read %d failed: %v", i, err)
+		}
+		if entry.Topic != expected.Topic || entry.Partition != expected.Partition || entry.Offset != expected.Offset {
+			t.Errorf("Entry %d source mismatch: expected %q/%d@%d, got %q/%d@%d", i,
+				expected.Topic, expected.Partition, expected.Offset, entry.Topic, entry.Partition, entry.Offset)
+		}
+		if !bytes.Equal(entry.Data, expected.Data) {
+			t.Errorf("Entry %d data mismatch: expected %q, got %q", i, expected.Data, entry.Data)
+		}
+	}
+
+	decoder.Close()
+}
+
+func TestRoundTripMillisecondTimestamps(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	encoder, err := NewEncodeWriter(buf)
+	if err != nil {
+		t.Fatalf("NewEncodeWriter failed: %v", err)
+	}
+
+	// Several messages within the same second must keep their order and spacing
+	base := time.Date(2024, 2, 2, 10, 15, 30, 0, time.UTC)
+	entries := []*Entry{
+		{Timestamp: base.Add(1 * time.Millisecond), TimestampType: TimestampTypeCreateTime, Data: []byte("a"), Partition: -1, Offset: -1},
+		{Timestamp: base.Add(250 * time.Millisecond), TimestampType: TimestampTypeCreateTime, Data: []byte("b"), Partition: -1, Offset: -1},
+		{Timestamp: base.Add(999 * time.Millisecond), TimestampType: TimestampTypeLogAppendTime, Data: []byte("c"), Partition: -1, Offset: -1},
+		{Timestamp: base, TimestampType: TimestampTypeUnknown, Data: []byte("d"), Partition: -1, Offset: -1},
+	}
+
+	for _, entry := range entries {
+		if _, err := encoder.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry failed: %v", err)
+		}
+	}
+	encoder.Close()
+
+	decoder, err := NewDecodeReader(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("NewDecodeReader failed: %v", err)
+	}
+
+	for i, expected := range entries {
+		entry, err := decoder.Read()
+		if err != nil {
+			t.Fatalf("Read %d failed: %v", i, err)
+		}
+		if !entry.Timestamp.Equal(expected.Timestamp) {
+			t.Errorf("Entry %d timestamp mismatch: expected %v, got %v", i, expected.Timestamp, entry.Timestamp)
+		}
+		if entry.TimestampType != expected.TimestampType {
+			t.Errorf("Entry %d timestamp type mismatch: expected %d, got %d", i, expected.TimestampType, entry.TimestampType)
+		}
+	}
+
+	decoder.Close()
+}
+
+// TestRoundTripCompressed tests that compressed files spanning several frames decode to the written entries,
+// also after a reset
+func TestRoundTripCompressed(t *testing.T) {
+	for _, compression := range []Compression{CompressionGzip, CompressionZstd, CompressionSnappy} {
+		t.Run(compression.String(), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			encoder, err := NewCompressedEncodeWriter(buf, compression)
+			if err != nil {
+				t.Fatalf("NewCompressedEncodeWriter failed: %v", err)
+			}
+
+			// Enough JSON-like data for several frames
+			const count = 5000
+			baseTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i := 0; i < count; i++ {
+				entry := &Entry{
+					Timestamp:     baseTime.Add(time.Duration(i) * time.Millisecond),
+					TimestampType: TimestampTypeCreateTime,
+					Key:           []byte(fmt.Sprintf("key-%d", i)),
+					Data:          bytes.Repeat([]byte(fmt.Sprintf(`{"id":%d,"type":"OrderCreated"}`, i)), 10),
+					Headers:       []Header{{Key: "seq", Value: []byte(fmt.Sprintf("%d", i))}},
+					Topic:         "orders",
+					Partition:     i % 3,
+					Offset:        int64(i),
+				}
+				if _, err := encoder.WriteEntry(entry); err != nil {
+					t.Fatalf("WriteEntry %d failed: %v", i, err)
+				}
+			}
+			if err := encoder.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			if encoder.TotalBytes() != int64(buf.Len()) {
+				t.Errorf("Total bytes mismatch: expected %d, got %d", buf.Len(), encoder.TotalBytes())
+			}
+
+			decoder, err := NewDecodeReader(bytes.NewReader(buf.Bytes()), true)
+			if err != nil {
+				t.Fatalf("NewDecodeReader failed: %v", err)
+			}
+			if decoder.Compression() != compression {
+				t.Errorf("Compression mismatch: expected %s, got %s", compression, decoder.Compression())
+			}
+
+			for pass := 0; pass < 2; pass++ {
+				for i := 0; i < count; i++ {
+					entry, err := decoder.Read()
+					if err != nil {
+						t.Fatalf("Pass %d: Read %d failed: %v", pass, i, err)
+					}
+					if entry.Offset != int64(i) || string(entry.Key) != fmt.Sprintf("key-%d", i) {
+						t.Fatalf("Pass %d: entry %d mismatch: offset %d, key %q", pass, i, entry.Offset, entry.Key)
+					}
+					if !entry.Timestamp.Equal(baseTime.Add(time.Duration(i) * time.Millisecond)) {
+						t.Fatalf("Pass %d: entry %d timestamp mismatch: %v", pass, i, entry.Timestamp)
+					}
+					if len(entry.Headers) != 1 || string(entry.Headers[0].Value) != fmt.Sprintf("%d", i) {
+						t.Fatalf("Pass %d: entry %d headers mismatch: %+v", pass, i, entry.Headers)
+					}
+				}
+				if _, err := decoder.Read(); err != io.EOF {
+					t.Fatalf("Pass %d: expected EOF, got %v", pass, err)
+				}
+				if err := decoder.Reset(); err != nil {
+					t.Fatalf("Reset failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package transcoder
+
+import (
+	"encoding/hex"
+)
+
+// RuleSet is the fingerprint of the masking rules applied to the messages of a sanitized recording
+type RuleSet [HeaderRuleSetSize]byte
+
+// String returns the fingerprint in hexadecimal
+func (r RuleSet) String() string {
+	return hex.EncodeToString(r[:])
+}
+
+// Sanitization tells that the messages of a recording were masked when they were recorded
+// It is stored in the file header (since version 3): a flag and the fingerprint of the rule set
+type Sanitization struct {
+	RuleSet RuleSet
+}
+
+// encodeSanitization writes the sanitization fields of the file header (zero when s is nil)
+func encodeSanitization(header []byte, s *Sanitization) {
+	if s == nil {
+		return
+	}
+	offset := HeaderVersionSize + HeaderCompressionSize
+	header[offset] |= HeaderFlagSanitized
+	copy(header[offset+HeaderFlagsSize:offset+HeaderFlagsSize+HeaderRuleSetSize], s.RuleSet[:])
+}
+
+// decodeSanitization reads the sanitization fields of the file header, or returns nil if they are not set
+func decodeSanitization(header []byte) *Sanitization {
+	offset := HeaderVersionSize + HeaderCompressionSize
+	if header[offset]&HeaderFlagSanitized == 0 {
+		return nil
+	}
+	s := &Sanitization{}
+	copy(s.RuleSet[:], header[offset+HeaderFlagsSize:offset+HeaderFlagsSize+HeaderRuleSetSize])
+	return s
+}
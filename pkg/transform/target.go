@@ -0,0 +1,220 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/jsonpath"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+type targetKind int
+
+const (
+	targetKey targetKind = iota
+	targetValue
+	targetHeader
+	targetPath
+)
+
+// target is the part of a message a step transforms
+// Keys, values and headers are strings; JSON paths hold JSON values (strings are copied to them as strings)
+type target struct {
+	kind   targetKind
+	header string
+	path   jsonpath.Path
+}
+
+// parseTarget parses key, value, header["name"] or a JSON path such as .a.b[0]
+func parseTarget(s string) (target, error) {
+	switch {
+	case s == "key":
+		return target{kind: targetKey}, nil
+	case s == "value":
+		return target{kind: targetValue}, nil
+	case strings.HasPrefix(s, "header[") && strings.HasSuffix(s, "]"):
+		name, err := strconv.Unquote(s[len("header[") : len(s)-1])
+		if err != nil {
+			return target{}, fmt.Errorf("invalid target %s: header names are double-quoted, e.g. header[\"trace-id\"]", s)
+		}
+		return target{kind: targetHeader, header: name}, nil
+	case strings.HasPrefix(s, "."):
+		path, err := jsonpath.Parse(s)
+		if err != nil {
+			return target{}, err
+		}
+		return target{kind: targetPath, path: path}, nil
+	default:
+		return target{}, fmt.Errorf("invalid target %q: use key, value, header[\"name\"] or a JSON path such as .a.b", s)
+	}
+}
+
+func (t target) String() string {
+	switch t.kind {
+	case targetKey:
+		return "key"
+	case targetValue:
+		return "value"
+	case targetHeader:
+		return fmt.Sprintf("header[%s]", strconv.Quote(t.header))
+	default:
+		return t.path.String()
+	}
+}
+
+// get returns the value of the target and whether it exists
+func (t target) get(m *message) (any, bool) {
+	entry := m.entry
+	switch t.kind {
+	case targetKey:
+		if len(entry.Key) == 0 {
+			return nil, false
+		}
+		return string(entry.Key), true
+	case targetValue:
+		if m.flush() != nil {
+			return nil, false
+		}
+		return string(entry.Data), true
+	case targetHeader:
+		for _, h := range entry.Headers {
+			if h.Key == t.header {
+				return string(h.Value), true
+			}
+		}
+		return nil, false
+	default:
+		document, ok := m.json()
+		if !ok {
+			return nil, false
+		}
+		return t.path.Get(document)
+	}
+}
+
+// set sets the target; a header replaces the first header with the name (or is added) and the others are removed
+// Setting a JSON path leaves values that are not valid JSON unchanged
+func (t target) set(m *message, v any) error {
+	entry := m.entry
+	switch t.kind {
+	case targetKey:
+		text, err := toText(v)
+		if err != nil {
+			return err
+		}
+		entry.Key = []byte(text)
+	case targetValue:
+		text, err := toText(v)
+		if err != nil {
+			return err
+		}
+		m.setData([]byte(text))
+	case targetHeader:
+		text, err := toText(v)
+		if err != nil {
+			return err
+		}
+		entry.Headers = withHeader(entry.Headers, transcoder.Header{Key: t.header, Value: []byte(text)})
+	default:
+		document, ok := m.json()
+		if !ok {
+			return nil
+		}
+		// Copy objects and arrays, so that later steps changing them do not change the step values or their source
+		document, err := t.path.Set(document, clone(v))
+		if err != nil {
+			return err
+		}
+		m.setJSON(document)
+	}
+	return nil
+}
+
+// delete deletes the target; deleting the key leaves the message without a key
+func (t target) delete(m *message) {
+	entry := m.entry
+	switch t.kind {
+	case targetKey:
+		entry.Key = nil
+	case targetValue:
+		m.setData(nil)
+	case targetHeader:
+		entry.Headers = withoutHeader(entry.Headers, t.header)
+	default:
+		document, ok := m.json()
+		if !ok {
+			return
+		}
+		if document, ok = t.path.Delete(document); ok {
+			m.setJSON(document)
+		}
+	}
+}
+
+// withHeader returns a copy of the headers with the header in place of the first one with its key, or added
+func withHeader(headers []transcoder.Header, header transcoder.Header) []transcoder.Header {
+	result := make([]transcoder.Header, 0, len(headers)+1)
+	found := false
+	for _, h := range headers {
+		switch {
+		case h.Key != header.Key:
+			result = append(result, h)
+		case !found:
+			result = append(result, header)
+			found = true
+		}
+	}
+	if !found {
+		result = append(result, header)
+	}
+	return result
+}
+
+// withoutHeader returns a copy of the headers without those with the key
+func withoutHeader(headers []transcoder.Header, key string) []transcoder.Header {
+	result := make([]transcoder.Header, 0, len(headers))
+	for _, h := range headers {
+		if h.Key != key {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// toText converts a value to a key, value or header: strings as they are, other values encoded as JSON
+func toText(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		data, err := jsonpath.Encode(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+// clone returns a deep copy of objects and arrays
+func clone(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for key, value := range v {
+			c[key] = clone(value)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, value := range v {
+			c[i] = clone(value)
+		}
+		return c
+	default:
+		return v
+	}
+}
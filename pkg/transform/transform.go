@@ -0,0 +1,288 @@
+// Package transform rewrites recorded messages before they are replayed, with steps configured in YAML:
+//
+//	transforms:
+//	  - set: .tenant_id
+//	    value: staging
+//	  - delete: .internal.debug
+//	  - rename: .userEmail
+//	    to: .email
+//	  - replace: .email
+//	    pattern: '@prod\.example\.com$'
+//	    with: '@staging.example.com'
+//	  - set: key
+//	    from: .tenant_id
+//	  - set: header["x-replayed-by"]
+//	    value: kafka-replay
+//	    where: 'topic == "orders"'
+//
+// Each step transforms a target: the message key, its value, a header (header["name"]) or a JSON path into the
+// value (.a.b[0]). Steps run in order, each on the result of the previous ones
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/filter"
+	"github.com/lolocompany/kafka-replay/v2/pkg/jsonpath"
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the YAML configuration of a pipeline
+type Config struct {
+	Transforms []Step `json:"transforms" yaml:"transforms"`
+}
+
+// Step is one transformation. Exactly one of Set, Delete, Rename and Replace is set to its target
+type Step struct {
+	// Set sets the target to Value, or to the value of the From target (the step is skipped if From does not exist)
+	Set   string `json:"set,omitempty" yaml:"set,omitempty"`
+	Value any    `json:"value,omitempty" yaml:"value,omitempty"`
+	From  string `json:"from,omitempty" yaml:"from,omitempty"`
+	// Delete deletes the target (all headers with the name for a header)
+	Delete string `json:"delete,omitempty" yaml:"delete,omitempty"`
+	// Rename moves the target to the To target
+	Rename string `json:"rename,omitempty" yaml:"rename,omitempty"`
+	To     string `json:"to,omitempty" yaml:"to,omitempty"`
+	// Replace replaces the matches of the regular expression Pattern in the target (if it is a string) With a
+	// replacement, which can refer to submatches ($1, ${name})
+	Replace string `json:"replace,omitempty" yaml:"replace,omitempty"`
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	With    string `json:"with,omitempty" yaml:"with,omitempty"`
+	// Where is an optional filter expression (see package filter): the step only transforms matching messages
+	Where string `json:"where,omitempty" yaml:"where,omitempty"`
+}
+
+// Pipeline is a compiled sequence of transformation steps, safe for concurrent use
+type Pipeline struct {
+	steps []step
+}
+
+// step is a compiled Step
+type step struct {
+	description string
+	where       *filter.Filter
+	apply       func(m *message) error
+}
+
+// Parse compiles a pipeline from its YAML configuration, rejecting unknown fields
+func Parse(data []byte) (*Pipeline, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var cfg Config
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid transforms: %w", err)
+	}
+	return New(cfg.Transforms)
+}
+
+// New compiles a pipeline
+func New(steps []Step) (*Pipeline, error) {
+	p := &Pipeline{}
+	for i, s := range steps {
+		compiled, err := compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform %d: %w", i+1, err)
+		}
+		p.steps = append(p.steps, compiled)
+	}
+	return p, nil
+}
+
+// Len returns the number of steps of the pipeline
+func (p *Pipeline) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.steps)
+}
+
+// Apply transforms an entry in place (a nil pipeline leaves it unchanged)
+// JSON paths only apply to values that are valid JSON; a value rewritten through JSON paths is re-encoded
+// with its object keys sorted
+func (p *Pipeline) Apply(entry *transcoder.Entry) error {
+	if p == nil {
+		return nil
+	}
+	m := &message{entry: entry}
+	for i, s := range p.steps {
+		if s.where != nil {
+			if err := m.flush(); err != nil {
+				return err
+			}
+			if !s.where.Match(entry) {
+				continue
+			}
+		}
+		if err := s.apply(m); err != nil {
+			return fmt.Errorf("transform %d (%s): %w", i+1, s.description, err)
+		}
+	}
+	return m.flush()
+}
+
+func compile(s Step) (step, error) {
+	actions := 0
+	for _, target := range []string{s.Set, s.Delete, s.Rename, s.Replace} {
+		if target != "" {
+			actions++
+		}
+	}
+	if actions != 1 {
+		return step{}, fmt.Errorf("expected exactly one of set, delete, rename or replace")
+	}
+	if s.Set == "" && (s.Value != nil || s.From != "") {
+		return step{}, fmt.Errorf("value and from can only be used with set")
+	}
+	if s.Rename == "" && s.To != "" {
+		return step{}, fmt.Errorf("to can only be used with rename")
+	}
+	if s.Replace == "" && (s.Pattern != "" || s.With != "") {
+		return step{}, fmt.Errorf("pattern and with can only be used with replace")
+	}
+
+	var compiled step
+	if s.Where != "" {
+		where, err := filter.Parse(s.Where)
+		if err != nil {
+			return step{}, err
+		}
+		compiled.where = where
+	}
+
+	switch {
+	case s.Set != "":
+		t, err := parseTarget(s.Set)
+		if err != nil {
+			return step{}, err
+		}
+		if s.From != "" {
+			if s.Value != nil {
+				return step{}, fmt.Errorf("value and from cannot be used together")
+			}
+			from, err := parseTarget(s.From)
+			if err != nil {
+				return step{}, err
+			}
+			compiled.description = fmt.Sprintf("set %s from %s", t, from)
+			compiled.apply = func(m *message) error {
+				v, ok := from.get(m)
+				if !ok {
+					return nil
+				}
+				return t.set(m, v)
+			}
+			break
+		}
+		// Check that the value can be encoded as JSON (YAML maps with non-string keys cannot)
+		if _, err := jsonpath.Encode(s.Value); err != nil {
+			return step{}, fmt.Errorf("invalid value for %s: %w", t, err)
+		}
+		compiled.description = fmt.Sprintf("set %s", t)
+		compiled.apply = func(m *message) error {
+			return t.set(m, s.Value)
+		}
+	case s.Delete != "":
+		t, err := parseTarget(s.Delete)
+		if err != nil {
+			return step{}, err
+		}
+		compiled.description = fmt.Sprintf("delete %s", t)
+		compiled.apply = func(m *message) error {
+			t.delete(m)
+			return nil
+		}
+	case s.Rename != "":
+		from, err := parseTarget(s.Rename)
+		if err != nil {
+			return step{}, err
+		}
+		if s.To == "" {
+			return step{}, fmt.Errorf("rename requires to")
+		}
+		to, err := parseTarget(s.To)
+		if err != nil {
+			return step{}, err
+		}
+		compiled.description = fmt.Sprintf("rename %s to %s", from, to)
+		compiled.apply = func(m *message) error {
+			v, ok := from.get(m)
+			if !ok {
+				return nil
+			}
+			from.delete(m)
+			return to.set(m, v)
+		}
+	default:
+		t, err := parseTarget(s.Replace)
+		if err != nil {
+			return step{}, err
+		}
+		if s.Pattern == "" {
+			return step{}, fmt.Errorf("replace requires pattern")
+		}
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return step{}, fmt.Errorf("invalid pattern: %w", err)
+		}
+		compiled.description = fmt.Sprintf("replace in %s", t)
+		compiled.apply = func(m *message) error {
+			v, ok := t.get(m)
+			if !ok {
+				return nil
+			}
+			text, ok := v.(string)
+			if !ok {
+				return nil
+			}
+			return t.set(m, re.ReplaceAllString(text, s.With))
+		}
+	}
+	return compiled, nil
+}
+
+// message is the entry being transformed, with its value decoded as JSON on first use
+// JSON paths change the decoded document, which is encoded back into the entry by flush
+type message struct {
+	entry    *transcoder.Entry
+	document any
+	decoded  bool
+	valid    bool
+	dirty    bool
+}
+
+func (m *message) json() (any, bool) {
+	if !m.decoded {
+		m.decoded = true
+		document, err := jsonpath.Decode(m.entry.Data)
+		m.document, m.valid = document, err == nil
+	}
+	return m.document, m.valid
+}
+
+func (m *message) setJSON(document any) {
+	m.document = document
+	m.dirty = true
+}
+
+// flush encodes the document into the entry value if JSON paths changed it
+func (m *message) flush() error {
+	if !m.dirty {
+		return nil
+	}
+	data, err := jsonpath.Encode(m.document)
+	if err != nil {
+		return fmt.Errorf("failed to encode the transformed value: %w", err)
+	}
+	m.entry.Data = data
+	m.dirty = false
+	return nil
+}
+
+// setData replaces the entry value, discarding its decoded document
+func (m *message) setData(data []byte) {
+	m.entry.Data = data
+	m.document, m.decoded, m.valid, m.dirty = nil, false, false, false
+}
@@ -0,0 +1,137 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lolocompany/kafka-replay/v2/pkg/transcoder"
+)
+
+func TestPipeline_Apply(t *testing.T) {
+	pipeline, err := Parse([]byte(`
+transforms:
+  - set: .tenant
+    value: staging
+  - set: .meta
+    value: {version: 2, tags: [replayed]}
+  - delete: .internal
+  - rename: .userEmail
+    to: .email
+  - replace: .email
+    pattern: '@prod\.example\.com$'
+    with: '@staging.example.com'
+  - set: key
+    from: .tenant
+  - set: header["x-replayed-by"]
+    value: kafka-replay
+  - delete: header["trace-id"]
+  - replace: header["source"]
+    pattern: '^(\w+)-prod$'
+    with: '${1}-staging'
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	entry := &transcoder.Entry{
+		Key:  []byte("prod"),
+		Data: []byte(`{"tenant":"prod","internal":{"debug":true},"userEmail":"ann@prod.example.com","amount":12345678901234567890}`),
+		Headers: []transcoder.Header{
+			{Key: "trace-id", Value: []byte("abc")},
+			{Key: "source", Value: []byte("web-prod")},
+			{Key: "x-replayed-by", Value: []byte("someone")},
+		},
+	}
+	if err := pipeline.Apply(entry); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	wantData := `{"amount":12345678901234567890,"email":"ann@staging.example.com","meta":{"tags":["replayed"],"version":2},"tenant":"staging"}`
+	if string(entry.Data) != wantData {
+		t.Errorf("Data = %s, want %s", entry.Data, wantData)
+	}
+	if string(entry.Key) != "staging" {
+		t.Errorf("Key = %q, want %q", entry.Key, "staging")
+	}
+	var headers []string
+	for _, h := range entry.Headers {
+		headers = append(headers, h.Key+"="+string(h.Value))
+	}
+	if got, want := strings.Join(headers, ","), "source=web-staging,x-replayed-by=kafka-replay"; got != want {
+		t.Errorf("Headers = %s, want %s", got, want)
+	}
+}
+
+func TestPipeline_ApplyWhere(t *testing.T) {
+	pipeline, err := New([]Step{
+		{Set: ".version", Value: 2, Where: `.type == "OrderCreated"`},
+		{Replace: "value", Pattern: "prod", With: "staging"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{`{"type":"OrderCreated","env":"prod"}`, `{"env":"staging","type":"OrderCreated","version":2}`},
+		{`{"type":"OrderPaid","env":"prod"}`, `{"type":"OrderPaid","env":"staging"}`},
+		{`not json, prod`, `not json, staging`},
+	} {
+		entry := &transcoder.Entry{Data: []byte(tc.data)}
+		if err := pipeline.Apply(entry); err != nil {
+			t.Fatalf("Apply(%s) failed: %v", tc.data, err)
+		}
+		if string(entry.Data) != tc.want {
+			t.Errorf("Apply(%s) = %s, want %s", tc.data, entry.Data, tc.want)
+		}
+	}
+}
+
+func TestPipeline_SetValueNotShared(t *testing.T) {
+	pipeline, err := New([]Step{
+		{Set: ".meta", Value: map[string]any{"tags": []any{"a"}}},
+		{Set: ".meta.tags[1]", Value: "b"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		entry := &transcoder.Entry{Data: []byte(`{}`)}
+		if err := pipeline.Apply(entry); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if want := `{"meta":{"tags":["a","b"]}}`; string(entry.Data) != want {
+			t.Errorf("message %d: Data = %s, want %s", i, entry.Data, want)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want string
+	}{
+		{"transforms:\n  - set: .a\n    delete: .b\n", "expected exactly one of set, delete, rename or replace"},
+		{"transforms:\n  - {}\n", "expected exactly one of set, delete, rename or replace"},
+		{"transforms:\n  - rename: .a\n", "rename requires to"},
+		{"transforms:\n  - delete: .a\n    to: .b\n", "to can only be used with rename"},
+		{"transforms:\n  - replace: key\n", "replace requires pattern"},
+		{"transforms:\n  - replace: key\n    pattern: '('\n", "invalid pattern"},
+		{"transforms:\n  - set: name\n", `invalid target "name"`},
+		{"transforms:\n  - set: header[x]\n", "header names are double-quoted"},
+		{"transforms:\n  - set: .a\n    where: 'nope'\n", "invalid filter"},
+		{"transforms:\n  - set: .a\n    valeu: 1\n", "field valeu not found"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.yaml))
+		if err == nil {
+			t.Errorf("Parse(%q): expected an error", tt.yaml)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q): error %q does not contain %q", tt.yaml, err, tt.want)
+		}
+	}
+}